@@ -0,0 +1,386 @@
+package quack
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/duckdb/duckdb-go/v2"
+)
+
+// connTx runs fn in a transaction on conn, which unlike a sql.Tx leaves the
+// raw connection at hand for the appender.
+func connTx(ctx context.Context, conn *sql.Conn, fn func() error) (err error) {
+	if _, err := conn.ExecContext(ctx, "BEGIN TRANSACTION;"); err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			conn.ExecContext(context.WithoutCancel(ctx), "ROLLBACK;")
+		}
+	}()
+	if err := fn(); err != nil {
+		return err
+	}
+	_, err = conn.ExecContext(ctx, "COMMIT;")
+	return err
+}
+
+// appendTo runs fn with an appender for table on conn and closes it, which
+// flushes the rows appended.
+func appendTo(conn *sql.Conn, table string, fn func(*duckdb.Appender) error) error {
+	return conn.Raw(func(dc any) error {
+		a, err := duckdb.NewAppenderFromConn(dc.(driver.Conn), "", table)
+		if err != nil {
+			return err
+		}
+		return errors.Join(fn(a), a.Close())
+	})
+}
+
+// InsertRows appends rows to table through the DuckDB appender, mapping
+// struct fields to columns by their db or json tag or their name, as
+// ValidateSchema does. A table that does not exist yet is created from the
+// fields of T, with pointer fields nullable. Columns without a field are
+// set to NULL. The rows are appended in a single transaction and are not
+// journaled.
+func InsertRows[T any](ctx context.Context, c *Client, table string, rows []T) (err error) {
+	fields, err := structFields(reflect.TypeFor[T]())
+	if err != nil {
+		return err
+	}
+	if len(fields) == 0 {
+		return fmt.Errorf("%s has no fields to insert", reflect.TypeFor[T]())
+	}
+	if err := c.lock("InsertRows"); err != nil {
+		return err
+	}
+	defer c.unlock("InsertRows", &err)
+	if err := c.precheck(rowsSize(rows, fields)); err != nil {
+		return err
+	}
+	conn, err := c.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = connTx(ctx, conn, func() error {
+		if err := tableExists(ctx, conn, table); errors.Is(err, os.ErrNotExist) {
+			if err := createFromFields(ctx, conn, table, fields); err != nil {
+				return err
+			}
+		} else if err != nil {
+			return err
+		}
+		columns, err := describe(ctx, conn, table)
+		if err != nil {
+			return err
+		}
+		// index maps each column to its field, -1 when it has none.
+		index := make([]int, len(columns))
+		byName := make(map[string]int, len(fields))
+		for i, f := range fields {
+			byName[strings.ToLower(f.name)] = i
+		}
+		for i, col := range columns {
+			j, ok := byName[strings.ToLower(col.Name)]
+			if !ok {
+				j = -1
+			}
+			index[i] = j
+			delete(byName, strings.ToLower(col.Name))
+		}
+		schemaErr := &SchemaError{Table: table}
+		for _, f := range fields {
+			if _, ok := byName[strings.ToLower(f.name)]; ok {
+				schemaErr.Missing = append(schemaErr.Missing, f.name)
+			}
+		}
+		if !schemaErr.empty() {
+			return schemaErr
+		}
+		return appendTo(conn, table, func(a *duckdb.Appender) error {
+			values := make([]driver.Value, len(columns))
+			for n, row := range rows {
+				v := reflect.ValueOf(row)
+				for i, j := range index {
+					values[i] = nil
+					if j >= 0 {
+						values[i] = fieldValue(v.Field(fields[j].index))
+					}
+				}
+				if err := a.AppendRow(values...); err != nil {
+					return fmt.Errorf("row %d: %w", n, err)
+				}
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return err
+	}
+	c.counters.insert(InsertResult{Table: table, Rows: int64(len(rows))})
+	return nil
+}
+
+func createFromFields(ctx context.Context, db querier, table string, fields []structField) error {
+	defs := make([]string, len(fields))
+	for i, f := range fields {
+		typ, _ := duckdbType(f.typ)
+		defs[i] = Column{Name: f.name, Type: typ, Nullable: f.nullable}.definition()
+	}
+	if err := createSchema(ctx, db, table); err != nil {
+		return err
+	}
+	if _, err := db.ExecContext(ctx, fmt.Sprintf("CREATE TABLE %s (%s);", table, strings.Join(defs, ", "))); err != nil {
+		return err
+	}
+	return recordSchema(ctx, db, table, "create")
+}
+
+// fieldValue converts a struct field to the value the appender takes for
+// its column type: nil for a nil pointer, and the underlying basic type for
+// named types.
+// rowsSize estimates the bytes rows take, counting strings and byte slices
+// by their length and other values as 8 bytes.
+func rowsSize[T any](rows []T, fields []structField) int64 {
+	var size int64
+	for _, row := range rows {
+		v := reflect.ValueOf(row)
+		for _, f := range fields {
+			switch value := fieldValue(v.Field(f.index)).(type) {
+			case nil:
+			case string:
+				size += int64(len(value))
+			case []byte:
+				size += int64(len(value))
+			default:
+				size += 8
+			}
+		}
+	}
+	return size
+}
+
+func fieldValue(v reflect.Value) driver.Value {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	switch v.Type() {
+	case timeType:
+		return v.Interface()
+	case bytesType:
+		return v.Bytes()
+	}
+	switch v.Kind() {
+	case reflect.Bool:
+		return v.Bool()
+	case reflect.Int8:
+		return int8(v.Int())
+	case reflect.Int16:
+		return int16(v.Int())
+	case reflect.Int32:
+		return int32(v.Int())
+	case reflect.Int, reflect.Int64:
+		return v.Int()
+	case reflect.Uint8:
+		return uint8(v.Uint())
+	case reflect.Uint16:
+		return uint16(v.Uint())
+	case reflect.Uint32:
+		return uint32(v.Uint())
+	case reflect.Uint, reflect.Uint64:
+		return v.Uint()
+	case reflect.Float32:
+		return float32(v.Float())
+	case reflect.Float64:
+		return v.Float()
+	case reflect.String:
+		return v.String()
+	}
+	return v.Interface()
+}
+
+// ErrAppenderClosed is returned by an Appender used after Close.
+var ErrAppenderClosed = errors.New("appender is closed")
+
+// Appender appends rows to a table through the DuckDB appender, for loads
+// too large or too frequent for Insert. Rows are buffered on a connection
+// of their own and become visible when Flush or Close commits them; other
+// operations proceed meanwhile. An Appender is safe for concurrent use.
+//
+// An error appending a row discards the rows appended since the last Flush
+// and closes the Appender. Client.Close waits for open appenders like it
+// does for result sets, and discards the rows of those still open when it
+// gives up on them.
+type Appender struct {
+	c       *Client
+	table   string
+	columns int
+
+	mux  sync.Mutex
+	conn driver.Conn
+	app  *duckdb.Appender
+	rows int64
+	// err is set once the appender is closed, to what closed it.
+	err  error
+	stop func() bool
+}
+
+// NewAppender opens an Appender for table, which must exist.
+func (c *Client) NewAppender(ctx context.Context, table string) (_ *Appender, err error) {
+	if err := c.lock("NewAppender"); err != nil {
+		return nil, err
+	}
+	defer c.unlock("NewAppender", &err)
+	columns, err := describe(ctx, c.db, table)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := c.connecter.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	a := &Appender{c: c, table: table, columns: len(columns), conn: conn}
+	if err := a.exec(ctx, "BEGIN TRANSACTION;"); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if a.app, err = duckdb.NewAppenderFromConn(conn, "", table); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	c.appenders.Add(1)
+	a.stop = context.AfterFunc(c.ops, func() {
+		a.mux.Lock()
+		defer a.mux.Unlock()
+		a.abort(ErrClosed)
+	})
+	return a, nil
+}
+
+func (a *Appender) exec(ctx context.Context, stmt string) error {
+	_, err := a.conn.(driver.ExecerContext).ExecContext(ctx, stmt, nil)
+	return err
+}
+
+// abort closes the appender discarding the rows not yet flushed, and makes
+// later calls fail with err. Call it with a.mux held.
+func (a *Appender) abort(err error) {
+	if a.err != nil {
+		return
+	}
+	a.err = err
+	a.app.Close()
+	a.exec(context.Background(), "ROLLBACK;")
+	a.release()
+}
+
+func (a *Appender) release() {
+	a.conn.Close()
+	a.stop()
+	a.c.appenders.Add(-1)
+}
+
+// AppendRow appends a row with a value for every column of the table, in
+// table order.
+func (a *Appender) AppendRow(values ...any) (err error) {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	if a.err != nil {
+		return a.err
+	}
+	if len(values) != a.columns {
+		return fmt.Errorf("append to %s: got %d values for %d columns", a.table, len(values), a.columns)
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			a.abort(fmt.Errorf("append to %s: panic: %v", a.table, r))
+			panic(r)
+		}
+	}()
+	row := make([]driver.Value, len(values))
+	for i, v := range values {
+		row[i] = v
+	}
+	if err := a.app.AppendRow(row...); err != nil {
+		err = fmt.Errorf("append to %s: %w", a.table, err)
+		a.abort(err)
+		return err
+	}
+	a.rows++
+	return nil
+}
+
+// Flush commits the rows appended so far.
+func (a *Appender) Flush() error {
+	return a.locked("Appender.Flush", func() error {
+		if err := a.app.Flush(); err != nil {
+			return err
+		}
+		if err := a.exec(context.Background(), "COMMIT;"); err != nil {
+			return err
+		}
+		a.commit()
+		return a.exec(context.Background(), "BEGIN TRANSACTION;")
+	})
+}
+
+// Close commits the rows appended so far and closes the Appender. Closing
+// it again does nothing; closing one that failed returns what failed it.
+func (a *Appender) Close() error {
+	err := a.locked("Appender.Close", func() error {
+		if err := a.app.Close(); err != nil {
+			return err
+		}
+		if err := a.exec(context.Background(), "COMMIT;"); err != nil {
+			return err
+		}
+		a.commit()
+		a.err = ErrAppenderClosed
+		a.release()
+		return nil
+	})
+	if errors.Is(err, ErrAppenderClosed) {
+		return nil
+	}
+	return err
+}
+
+func (a *Appender) commit() {
+	a.c.counters.insert(InsertResult{Table: a.table, Rows: a.rows})
+	a.rows = 0
+}
+
+// locked runs fn under the client lock, which Flush and Close take like any
+// write, even while Close waits for them. An error from fn aborts the
+// appender.
+func (a *Appender) locked(op string, fn func() error) (err error) {
+	a.c.acquire()
+	defer a.c.release()
+	defer func() { a.c.counters.operation(op, err) }()
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	if a.err != nil {
+		return a.err
+	}
+	if a.c.closed {
+		a.abort(ErrClosed)
+		return ErrClosed
+	}
+	defer func() {
+		if err != nil {
+			a.abort(err)
+		}
+	}()
+	return fn()
+}
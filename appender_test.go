@@ -0,0 +1,173 @@
+package quack
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type level string
+
+type reading struct {
+	ID      int64     `db:"id"`
+	Sensor  string    `json:"sensor"`
+	Level   level     `db:"level"`
+	At      time.Time `db:"at"`
+	Raw     []byte    `db:"raw"`
+	Value   *float64  `db:"value"`
+	Small   int8
+	skipped int
+}
+
+func Test_InsertRows(t *testing.T) {
+	client, err := New(t.TempDir(), 1)
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+	at := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	v := 1.5
+	rows := []reading{
+		{ID: 1, Sensor: "a", Level: "high", At: at, Raw: []byte{0, 1}, Value: &v, Small: -1},
+		{ID: 2, Sensor: "b", At: at.Add(time.Hour)},
+	}
+	require.NoError(t, InsertRows(t.Context(), client, "readings", rows))
+	columns, err := describe(t.Context(), client.db, "readings")
+	require.NoError(t, err)
+	require.Equal(t, []Column{
+		{Name: "id", Type: "BIGINT"},
+		{Name: "sensor", Type: "VARCHAR"},
+		{Name: "level", Type: "VARCHAR"},
+		{Name: "at", Type: "TIMESTAMP"},
+		{Name: "raw", Type: "BLOB"},
+		{Name: "value", Type: "DOUBLE", Nullable: true},
+		{Name: "Small", Type: "TINYINT"},
+	}, columns)
+	require.NoError(t, ValidateSchema[reading](t.Context(), client, "readings"))
+	var (
+		sensors, levels string
+		first           time.Time
+		raw             []byte
+		sum             float64
+		small           int8
+	)
+	require.NoError(t, client.db.QueryRow("SELECT string_agg(sensor, ',' ORDER BY id), string_agg(level, ',' ORDER BY id), min(\"at\"), max(raw), sum(\"value\"), min(Small) FROM readings;").
+		Scan(&sensors, &levels, &first, &raw, &sum, &small))
+	require.Equal(t, "a,b", sensors)
+	require.Equal(t, "high,", levels)
+	require.True(t, at.Equal(first))
+	require.Equal(t, []byte{0, 1}, raw)
+	require.Equal(t, v, sum)
+	require.Equal(t, int8(-1), small)
+
+	// Appending to an existing table fills columns without a field with NULL.
+	_, err = client.db.Exec("ALTER TABLE readings ADD COLUMN note VARCHAR;")
+	require.NoError(t, err)
+	require.NoError(t, InsertRows(t.Context(), client, "readings", rows[:1]))
+	var n, notes int
+	require.NoError(t, client.db.QueryRow("SELECT count(*), count(note) FROM readings;").Scan(&n, &notes))
+	require.Equal(t, 3, n)
+	require.Zero(t, notes)
+
+	type other struct {
+		ID    int64 `db:"id"`
+		Extra string
+	}
+	var schemaErr *SchemaError
+	require.ErrorAs(t, InsertRows(t.Context(), client, "readings", []other{{ID: 3}}), &schemaErr)
+	require.Equal(t, []string{"Extra"}, schemaErr.Missing)
+	type bad struct {
+		C chan int
+	}
+	require.ErrorContains(t, InsertRows(t.Context(), client, "bad", []bad{{}}), "unsupported type chan int")
+	type null struct {
+		ID *int64 `db:"id"`
+	}
+	require.Error(t, InsertRows(t.Context(), client, "readings", []null{{ID: nil}}))
+	require.NoError(t, client.db.QueryRow("SELECT count(*) FROM readings;").Scan(&n))
+	require.Equal(t, 3, n)
+}
+
+func Test_Appender(t *testing.T) {
+	client, err := New(t.TempDir(), 1)
+	require.NoError(t, err)
+	require.NoError(t, client.CreateTable(t.Context(), "events", []Column{{Name: "id", Type: "BIGINT"}, {Name: "name", Type: "VARCHAR", Nullable: true}}))
+	count := func() int {
+		var n int
+		require.NoError(t, client.db.QueryRow("SELECT count(*) FROM events;").Scan(&n))
+		return n
+	}
+	_, err = client.NewAppender(t.Context(), "missing")
+	require.Error(t, err)
+
+	a, err := client.NewAppender(t.Context(), "events")
+	require.NoError(t, err)
+	require.NoError(t, a.AppendRow(int64(1), "a"))
+	require.ErrorContains(t, a.AppendRow(int64(2)), "got 1 values for 2 columns")
+	require.NoError(t, a.AppendRow(int64(2), nil))
+	require.Zero(t, count())
+	require.NoError(t, a.Flush())
+	require.Equal(t, 2, count())
+	require.NoError(t, a.AppendRow(int64(3), "c"))
+	require.NoError(t, a.Close())
+	require.NoError(t, a.Close())
+	require.Equal(t, 3, count())
+	require.ErrorIs(t, a.AppendRow(int64(4), "d"), ErrAppenderClosed)
+	stats, err := client.Stats(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, int64(3), stats.Tables["events"].RowsInserted)
+	require.Zero(t, stats.InFlight)
+
+	// A failing row discards the rows since the last Flush.
+	a, err = client.NewAppender(t.Context(), "events")
+	require.NoError(t, err)
+	require.NoError(t, a.AppendRow(int64(4), "d"))
+	require.NoError(t, a.Flush())
+	require.NoError(t, a.AppendRow(int64(5), "e"))
+	err = a.AppendRow("x", "f")
+	require.Error(t, err)
+	require.ErrorIs(t, a.Flush(), err)
+	require.ErrorIs(t, a.Close(), err)
+	require.Equal(t, 4, count())
+
+	// An appender left open, here by a panicking batch, does not hold up a
+	// Close without grace, which discards its rows.
+	a, err = client.NewAppender(t.Context(), "events")
+	require.NoError(t, err)
+	require.NoError(t, a.AppendRow(int64(6), "g"))
+	func() {
+		defer func() { recover() }()
+		panic("batch failed")
+	}()
+	require.NoError(t, client.Close(context.Background()))
+	require.ErrorIs(t, a.AppendRow(int64(7), "h"), ErrClosed)
+	require.ErrorIs(t, a.Close(), ErrClosed)
+}
+
+func Test_AppenderCloseGrace(t *testing.T) {
+	dir := t.TempDir()
+	client, err := New(dir, 1)
+	require.NoError(t, err)
+	require.NoError(t, client.CreateTable(t.Context(), "events", []Column{{Name: "id", Type: "BIGINT"}}))
+	a, err := client.NewAppender(t.Context(), "events")
+	require.NoError(t, err)
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		for i := range 10 {
+			a.AppendRow(int64(i))
+		}
+		a.Close()
+	}()
+	ctx, cancel := context.WithTimeout(t.Context(), 10*time.Second)
+	defer cancel()
+	require.NoError(t, client.Close(ctx))
+	infos, err := snapshotInfos(filepath.Join(dir, "snapshot"))
+	require.NoError(t, err)
+	r, err := OpenSnapshot(t.Context(), infos[0].Path)
+	require.NoError(t, err)
+	defer r.Close()
+	var n int
+	require.NoError(t, r.db.QueryRow("SELECT count(*) FROM events;").Scan(&n))
+	require.Equal(t, 10, n)
+}
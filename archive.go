@@ -0,0 +1,285 @@
+package quack
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"context"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+type snapshotConfig struct {
+	workers       int
+	deterministic bool
+	// maxBytes caps the total size of the snapshots kept by rotation.
+	maxBytes int64
+	codec    snapshotCodec
+	level    int
+}
+
+// snapshotCodec is how the entries of a snapshot are compressed.
+type snapshotCodec int
+
+const (
+	// defaultCodec is deflate at its default level.
+	defaultCodec snapshotCodec = iota
+	storeCodec
+	deflateCodec
+	zstdCodec
+)
+
+// zipZstd is the zip method number of Zstandard.
+const zipZstd uint16 = 93
+
+// compressor returns the zip method of the codec of cfg and a writer
+// compressing into w with it.
+func (cfg snapshotConfig) compressor(w io.Writer) (uint16, io.WriteCloser, error) {
+	switch cfg.codec {
+	case storeCodec:
+		return zip.Store, nopWriteCloser{w}, nil
+	case deflateCodec:
+		fw, err := flate.NewWriter(w, cfg.level)
+		return zip.Deflate, fw, err
+	case zstdCodec:
+		level := zstd.SpeedDefault
+		if cfg.level != 0 {
+			level = zstd.EncoderLevelFromZstd(cfg.level)
+		}
+		// Entries are already compressed concurrently.
+		zw, err := zstd.NewWriter(w, zstd.WithEncoderLevel(level), zstd.WithEncoderConcurrency(1))
+		return zipZstd, zw, err
+	}
+	fw, err := flate.NewWriter(w, flate.DefaultCompression)
+	return zip.Deflate, fw, err
+}
+
+type nopWriteCloser struct{ io.Writer }
+
+func (nopWriteCloser) Close() error { return nil }
+
+// entryTime is the modification time of every entry in deterministic
+// snapshots, the earliest an MS-DOS timestamp can hold.
+var entryTime = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// spoolLimit is how much of a compressed entry is kept in memory before it
+// spills to a temporary file.
+var spoolLimit = 8 << 20
+
+// spool buffers a compressed entry, in memory while it is small and in a
+// temporary file under staging once it outgrows spoolLimit.
+type spool struct {
+	staging string
+	buf     bytes.Buffer
+	f       *os.File
+	size    int64
+}
+
+func (s *spool) Write(p []byte) (int, error) {
+	if s.f == nil && s.buf.Len()+len(p) > spoolLimit {
+		f, err := os.CreateTemp(s.staging, dumpPrefix)
+		if err != nil {
+			return 0, err
+		}
+		s.f = f
+		if _, err := s.buf.WriteTo(f); err != nil {
+			return 0, err
+		}
+	}
+	var (
+		n   int
+		err error
+	)
+	if s.f != nil {
+		n, err = s.f.Write(p)
+	} else {
+		n, err = s.buf.Write(p)
+	}
+	s.size += int64(n)
+	return n, err
+}
+
+func (s *spool) reader() (io.Reader, error) {
+	if s.f == nil {
+		return &s.buf, nil
+	}
+	if _, err := s.f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return s.f, nil
+}
+
+func (s *spool) close() {
+	if s.f != nil {
+		s.f.Close()
+		os.Remove(s.f.Name())
+	}
+}
+
+type compressed struct {
+	header *zip.FileHeader
+	data   *spool
+}
+
+// compressFile compresses root/name into a spool with the codec of cfg and
+// returns the raw entry header describing it.
+func compressFile(staging, root, name string, cfg snapshotConfig) (*compressed, error) {
+	f, err := os.Open(filepath.Join(root, filepath.FromSlash(name)))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	h, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return nil, err
+	}
+	h.Name = name
+	if cfg.deterministic {
+		// CreateRaw writes the MS-DOS time fields as they are, so set
+		// those rather than Modified alone.
+		h.SetModTime(entryTime)
+		h.SetMode(0644)
+	}
+	c := &compressed{header: h, data: &spool{staging: staging}}
+	method, fw, err := cfg.compressor(c.data)
+	if err != nil {
+		return nil, err
+	}
+	h.Method = method
+	crc := crc32.NewIEEE()
+	n, err := io.Copy(io.MultiWriter(fw, crc), f)
+	if err == nil {
+		err = fw.Close()
+	}
+	if err != nil {
+		c.data.close()
+		return nil, err
+	}
+	h.CRC32 = crc.Sum32()
+	h.UncompressedSize64 = uint64(n)
+	h.CompressedSize64 = uint64(c.data.size)
+	return c, nil
+}
+
+// addDir adds the files under root to zw like zip.Writer.AddFS, compressing
+// up to cfg.workers files concurrently (GOMAXPROCS when not set). Entries are
+// written in lexical order, and at most that many of them are held at a time.
+func addDir(zw *zip.Writer, root, staging string, cfg snapshotConfig) error {
+	workers := cfg.workers
+	if workers < 1 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	var names []string
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		names = append(names, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	type result struct {
+		entry *compressed
+		err   error
+	}
+	results := make([]chan result, len(names))
+	for i := range results {
+		results[i] = make(chan result, 1)
+	}
+	slots := make(chan struct{}, workers)
+	stop := make(chan struct{})
+	launched := 0
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i, name := range names {
+			select {
+			case slots <- struct{}{}:
+			case <-stop:
+				return
+			}
+			launched = i + 1
+			go func() {
+				entry, err := compressFile(staging, root, name, cfg)
+				results[i] <- result{entry, err}
+			}()
+		}
+	}()
+	write := func(c *compressed) error {
+		defer c.data.close()
+		w, err := zw.CreateRaw(c.header)
+		if err != nil {
+			return err
+		}
+		r, err := c.data.reader()
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(w, r)
+		return err
+	}
+	for i := range names {
+		r := <-results[i]
+		if r.err == nil {
+			r.err = write(r.entry)
+		}
+		if r.err != nil {
+			// Release the entries still being compressed.
+			close(stop)
+			<-done
+			for _, ch := range results[i+1 : launched] {
+				if r := <-ch; r.entry != nil {
+					r.entry.data.close()
+				}
+			}
+			return r.err
+		}
+		<-slots
+	}
+	<-done
+	return nil
+}
+
+// stabilizeExport rewrites a directory written by EXPORT DATABASE so that it
+// only depends on the database contents: table data is sorted by all
+// columns and load.sql refers to data files by name instead of by the
+// temporary export path. importDir resolves bare names against the archive.
+func stabilizeExport(ctx context.Context, db querier, dir string) error {
+	p := filepath.Join(dir, "load.sql")
+	load, err := os.ReadFile(p)
+	if err != nil {
+		return err
+	}
+	stmts, ok := parseLoad(string(load))
+	if !ok {
+		return fmt.Errorf("cannot make snapshot deterministic: unrecognised load.sql")
+	}
+	var b strings.Builder
+	for _, stmt := range stmts {
+		file := quoteLiteral(filepath.Join(dir, stmt.file))
+		if _, err := db.ExecContext(ctx, fmt.Sprintf("COPY (SELECT * FROM %s ORDER BY ALL) TO %s%s", stmt.table, file, stmt.rest)); err != nil {
+			return err
+		}
+		fmt.Fprintf(&b, "COPY %s FROM %s%s\n", stmt.table, quoteLiteral(stmt.file), stmt.rest)
+	}
+	return os.WriteFile(p, []byte(b.String()), 0644)
+}
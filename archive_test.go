@@ -0,0 +1,228 @@
+package quack
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeFiles(t testing.TB, dir string, files map[string][]byte) {
+	t.Helper()
+	for name, data := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0755))
+		require.NoError(t, os.WriteFile(p, data, 0644))
+	}
+}
+
+func noise(n int) []byte {
+	r := rand.New(rand.NewPCG(1, 2))
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte('a' + r.IntN(4))
+	}
+	return b
+}
+
+func Test_addDir(t *testing.T) {
+	defer func(limit int) { spoolLimit = limit }(spoolLimit)
+	spoolLimit = 1 << 10
+	root, staging := t.TempDir(), t.TempDir()
+	files := map[string][]byte{
+		"schema.sql":     []byte("CREATE TABLE a (x INTEGER);"),
+		"load.sql":       []byte("COPY a FROM 'a.json';"),
+		"a.json":         noise(64 << 10),
+		"nested/b.json":  noise(100),
+		"empty.json":     nil,
+		"large/tail.csv": noise(16 << 10),
+	}
+	writeFiles(t, root, files)
+	for _, workers := range []int{1, 3, 0} {
+		t.Run(strconv.Itoa(workers), func(t *testing.T) {
+			var buf bytes.Buffer
+			zw := zip.NewWriter(&buf)
+			require.NoError(t, addDir(zw, root, staging, snapshotConfig{workers: workers}))
+			require.NoError(t, zw.Close())
+			zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+			require.NoError(t, err)
+			var names []string
+			for _, f := range zr.File {
+				names = append(names, f.Name)
+				rc, err := f.Open()
+				require.NoError(t, err)
+				got, err := io.ReadAll(rc)
+				require.NoError(t, err)
+				require.NoError(t, rc.Close())
+				require.Equal(t, len(files[f.Name]), len(got), f.Name)
+				require.True(t, bytes.Equal(files[f.Name], got), f.Name)
+			}
+			require.Equal(t, []string{"a.json", "empty.json", "large/tail.csv", "load.sql", "nested/b.json", "schema.sql"}, names)
+			spooled, err := os.ReadDir(staging)
+			require.NoError(t, err)
+			require.Empty(t, spooled)
+		})
+	}
+	t.Run("deterministic", func(t *testing.T) {
+		var buf bytes.Buffer
+		zw := zip.NewWriter(&buf)
+		require.NoError(t, addDir(zw, root, staging, snapshotConfig{deterministic: true}))
+		require.NoError(t, zw.Close())
+		zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		require.NoError(t, err)
+		for _, f := range zr.File {
+			require.True(t, f.Modified.Equal(entryTime), f.Name)
+			require.Equal(t, os.FileMode(0644), f.Mode(), f.Name)
+		}
+	})
+	t.Run("missing root", func(t *testing.T) {
+		zw := zip.NewWriter(io.Discard)
+		require.Error(t, addDir(zw, filepath.Join(root, "missing"), staging, snapshotConfig{workers: 2}))
+	})
+}
+
+func BenchmarkAddDir(b *testing.B) {
+	root := b.TempDir()
+	files := make(map[string][]byte)
+	for i := range 8 {
+		files[fmt.Sprintf("table_%d.json", i)] = noise(4 << 20)
+	}
+	writeFiles(b, root, files)
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(strconv.Itoa(workers), func(b *testing.B) {
+			b.SetBytes(8 * 4 << 20)
+			for b.Loop() {
+				zw := zip.NewWriter(io.Discard)
+				if err := addDir(zw, root, b.TempDir(), snapshotConfig{workers: workers}); err != nil {
+					b.Fatal(err)
+				}
+				if err := zw.Close(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func Test_DeterministicSnapshots(t *testing.T) {
+	digests := func(t *testing.T, opts ...any) []string {
+		dir := t.TempDir()
+		var b, head strings.Builder
+		for i := range 5000 {
+			fmt.Fprintf(&b, "{\"id\":%d,\"name\":\"n%d\",\"tags\":[%d]}\n", i, i%7, i%3)
+			if i < 2500 {
+				fmt.Fprintf(&head, "{\"id\":%d,\"name\":\"n%d\",\"tags\":[%d]}\n", i, i%7, i%3)
+			}
+		}
+		client, err := New(dir, 5, opts...)
+		require.NoError(t, err)
+		require.NoError(t, client.Insert(t.Context(), "events", strings.NewReader(b.String())))
+		require.NoError(t, client.Insert(t.Context(), "other", strings.NewReader(`{"x":1}`)))
+		require.NoError(t, client.Close(t.Context()))
+		// Same contents, different physical row order.
+		client, err = New(dir, 5, opts...)
+		require.NoError(t, err)
+		_, err = client.DeleteWhere(t.Context(), "events", "id < 2500")
+		require.NoError(t, err)
+		require.NoError(t, client.Insert(t.Context(), "events", strings.NewReader(head.String())))
+		require.NoError(t, client.Close(t.Context()))
+
+		names, err := listDir(filepath.Join(dir, "snapshot"))
+		require.NoError(t, err)
+		require.Len(t, names, 2)
+		var sums []string
+		for _, name := range names {
+			data, err := os.ReadFile(filepath.Join(dir, "snapshot", name))
+			require.NoError(t, err)
+			sums = append(sums, fmt.Sprintf("%x", sha256.Sum256(data)))
+		}
+
+		client, err = New(dir, 5, opts...)
+		require.NoError(t, err)
+		defer client.Close(t.Context())
+		require.NoError(t, client.RollbackSnapshot(t.Context(), 2))
+		var n int
+		require.NoError(t, client.db.QueryRow("SELECT count(*) FROM events;").Scan(&n))
+		require.Equal(t, 5000, n)
+		return sums
+	}
+	sums := digests(t, WithDeterministicSnapshots())
+	require.Equal(t, sums[0], sums[1])
+	sums = digests(t)
+	require.NotEqual(t, sums[0], sums[1])
+}
+
+func Test_SnapshotCompression(t *testing.T) {
+	var b strings.Builder
+	for i := range 5000 {
+		fmt.Fprintf(&b, "{\"id\":%d,\"name\":\"n%d\"}\n", i, i%7)
+	}
+	for name, tc := range map[string]struct {
+		opt    ClientOption
+		method uint16
+	}{
+		"default":  {nil, zip.Deflate},
+		"store":    {WithStoredSnapshots(), zip.Store},
+		"deflate1": {WithDeflateSnapshots(1), zip.Deflate},
+		"deflate9": {WithDeflateSnapshots(9), zip.Deflate},
+		"zstd":     {WithZstdSnapshots(0), zipZstd},
+		"zstd19":   {WithZstdSnapshots(19), zipZstd},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			var opts []any
+			if tc.opt != nil {
+				opts = append(opts, tc.opt)
+			}
+			client, err := New(dir, 5, opts...)
+			require.NoError(t, err)
+			require.NoError(t, client.Insert(t.Context(), "events", strings.NewReader(b.String())))
+			require.NoError(t, client.Close(t.Context()))
+
+			client, err = New(dir, 5, opts...)
+			require.NoError(t, err)
+			infos, err := client.ListSnapshots()
+			require.NoError(t, err)
+			require.Len(t, infos, 1)
+			zr, err := openArchive(infos[0].Path)
+			require.NoError(t, err)
+			for _, zf := range zr.File {
+				require.Equal(t, tc.method, zf.Method, zf.Name)
+			}
+			require.NoError(t, zr.Close())
+			contents, err := client.InspectSnapshot(t.Context(), infos[0].ID)
+			require.NoError(t, err)
+			require.NoError(t, contents.VerifyError)
+			_, err = client.DeleteWhere(t.Context(), "events", "true")
+			require.NoError(t, err)
+			require.NoError(t, client.Close(t.Context()))
+
+			// Restored by a client writing another codec.
+			client, err = New(dir, 5, WithStoredSnapshots())
+			require.NoError(t, err)
+			defer client.Close(t.Context())
+			require.NoError(t, client.RestoreSnapshot(t.Context(), infos[0].ID))
+			var n int
+			require.NoError(t, client.db.QueryRow("SELECT count(*) FROM events;").Scan(&n))
+			require.Equal(t, 5000, n)
+		})
+	}
+
+	for _, level := range []int{-2, 0, 10} {
+		_, err := New(t.TempDir(), 5, WithDeflateSnapshots(level))
+		require.ErrorContains(t, err, fmt.Sprintf("snapshot deflate level %d out of range", level))
+	}
+	for _, level := range []int{-5, 23} {
+		_, err := New(t.TempDir(), 5, WithZstdSnapshots(level))
+		require.ErrorContains(t, err, fmt.Sprintf("snapshot zstd level %d out of range", level))
+	}
+}
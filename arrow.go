@@ -0,0 +1,171 @@
+//go:build duckdb_arrow
+
+package quack
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"os"
+	"sync/atomic"
+
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/ipc"
+	"github.com/duckdb/duckdb-go/v2"
+)
+
+// arrowView is the name the record batches of InsertArrow are scanned by.
+const arrowView = "quack_arrow"
+
+// InsertArrow inserts the record batches of r into table, matching columns
+// by name, in a single transaction. A table that does not exist yet is
+// created from the Arrow schema, with fields that are not nullable NOT
+// NULL. Dictionary-encoded columns are stored as their values. Arrow inputs
+// are not journaled and need the duckdb_arrow build tag.
+func (c *Client) InsertArrow(ctx context.Context, table string, r array.RecordReader) (err error) {
+	if err := c.lock("InsertArrow"); err != nil {
+		return err
+	}
+	defer c.unlock("InsertArrow", &err)
+	conn, err := c.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	var release func()
+	if err := conn.Raw(func(dc any) error {
+		a, err := duckdb.NewArrowFromConn(dc.(driver.Conn))
+		if err != nil {
+			return err
+		}
+		release, err = a.RegisterView(r, arrowView)
+		return err
+	}); err != nil {
+		return err
+	}
+	defer release()
+	var rows int64
+	err = connTx(ctx, conn, func() error {
+		if err := tableExists(ctx, conn, table); !os.IsNotExist(err) {
+			if err != nil {
+				return err
+			}
+			res, err := conn.ExecContext(ctx, fmt.Sprintf("INSERT INTO %s BY NAME SELECT * FROM %s;", table, arrowView))
+			if err != nil {
+				return err
+			}
+			rows, err = res.RowsAffected()
+			return err
+		}
+		if err := createSchema(ctx, conn, table); err != nil {
+			return err
+		}
+		if _, err := conn.ExecContext(ctx, fmt.Sprintf("CREATE TABLE %s AS SELECT * FROM %s;", table, arrowView)); err != nil {
+			return err
+		}
+		for _, f := range r.Schema().Fields() {
+			if f.Nullable {
+				continue
+			}
+			if _, err := conn.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s SET NOT NULL;", table, quoteIdent(f.Name))); err != nil {
+				return err
+			}
+		}
+		if err := conn.QueryRowContext(ctx, fmt.Sprintf("SELECT count(*) FROM %s;", table)).Scan(&rows); err != nil {
+			return err
+		}
+		return recordSchema(ctx, conn, table, "create")
+	})
+	if err != nil {
+		return err
+	}
+	c.counters.insert(InsertResult{Table: table, Rows: rows})
+	return nil
+}
+
+// InsertArrowIPC is InsertArrow reading an Arrow IPC stream from r.
+func (c *Client) InsertArrowIPC(ctx context.Context, table string, r io.Reader) error {
+	rr, err := ipc.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("arrow input: %w", err)
+	}
+	defer rr.Release()
+	return c.InsertArrow(ctx, table, rr)
+}
+
+// QueryArrow runs stmt with args and returns its result as Arrow record
+// batches, converted from DuckDB's vectors without scanning Go values. The
+// client lock is only held to start the query; Release the reader to end
+// it.
+func (c *Client) QueryArrow(ctx context.Context, stmt string, args ...any) (_ array.RecordReader, err error) {
+	cfg, args := splitQueryArgs(args)
+	if err := c.rlock("QueryArrow"); err != nil {
+		return nil, err
+	}
+	defer c.runlock("QueryArrow", &err)
+	ctx = c.queryContext(ctx, cfg)
+	conn, err := c.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var rr array.RecordReader
+	// The connection is kept out of the pool until the reader is
+	// released, so the driver connection outlives Raw.
+	if err := conn.Raw(func(dc any) error {
+		a, err := duckdb.NewArrowFromConn(dc.(driver.Conn))
+		if err != nil {
+			return err
+		}
+		rr, err = a.QueryContext(ctx, c.limitRows(stmt, cfg), args...)
+		return err
+	}); err != nil {
+		conn.Close()
+		return nil, resultError(err)
+	}
+	r := &arrowRows{RecordReader: rr, conn: conn}
+	r.refs.Store(1)
+	return r, nil
+}
+
+// arrowRows closes the connection of QueryArrow with its last release.
+type arrowRows struct {
+	array.RecordReader
+	conn *sql.Conn
+	refs atomic.Int64
+}
+
+func (r *arrowRows) Retain() {
+	r.refs.Add(1)
+	r.RecordReader.Retain()
+}
+
+func (r *arrowRows) Release() {
+	r.RecordReader.Release()
+	if r.refs.Add(-1) == 0 {
+		r.conn.Close()
+	}
+}
+
+// QueryArrowIPC is QueryArrow writing the result to w as an Arrow IPC
+// stream.
+func (c *Client) QueryArrowIPC(ctx context.Context, w io.Writer, stmt string, args ...any) error {
+	rr, err := c.QueryArrow(ctx, stmt, args...)
+	if err != nil {
+		return err
+	}
+	defer rr.Release()
+	iw := ipc.NewWriter(w, ipc.WithSchema(rr.Schema()))
+	for rr.Next() {
+		if err := iw.Write(rr.RecordBatch()); err != nil {
+			iw.Close()
+			return err
+		}
+	}
+	if err := rr.Err(); err != nil {
+		iw.Close()
+		return err
+	}
+	return iw.Close()
+}
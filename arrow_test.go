@@ -0,0 +1,105 @@
+//go:build duckdb_arrow
+
+package quack
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/ipc"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_InsertArrow(t *testing.T) {
+	kind := &arrow.DictionaryType{IndexType: arrow.PrimitiveTypes.Int32, ValueType: arrow.BinaryTypes.String}
+	schema := arrow.NewSchema([]arrow.Field{
+		{Name: "id", Type: arrow.PrimitiveTypes.Int64},
+		{Name: "kind", Type: kind, Nullable: true},
+		{Name: "score", Type: arrow.PrimitiveTypes.Float64, Nullable: true},
+	}, nil)
+	b := array.NewRecordBuilder(memory.DefaultAllocator, schema)
+	defer b.Release()
+	b.Field(0).(*array.Int64Builder).AppendValues([]int64{1, 2, 3}, nil)
+	kinds := b.Field(1).(*array.BinaryDictionaryBuilder)
+	require.NoError(t, kinds.AppendString("a"))
+	kinds.AppendNull()
+	require.NoError(t, kinds.AppendString("b"))
+	b.Field(2).(*array.Float64Builder).AppendValues([]float64{0.5, 0, 2}, []bool{true, false, true})
+	rec := b.NewRecord()
+	defer rec.Release()
+
+	client, err := New(t.TempDir(), 1)
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+	rr, err := array.NewRecordReader(schema, []arrow.Record{rec, rec})
+	require.NoError(t, err)
+	defer rr.Release()
+	require.NoError(t, client.InsertArrow(t.Context(), "events", rr))
+	columns, err := describe(t.Context(), client.db, "events")
+	require.NoError(t, err)
+	require.Equal(t, []Column{
+		{Name: "id", Type: "BIGINT"},
+		{Name: "kind", Type: "VARCHAR", Nullable: true},
+		{Name: "score", Type: "DOUBLE", Nullable: true},
+	}, columns)
+
+	var buf bytes.Buffer
+	w := ipc.NewWriter(&buf, ipc.WithSchema(schema))
+	require.NoError(t, w.Write(rec))
+	require.NoError(t, w.Close())
+	require.NoError(t, client.InsertArrowIPC(t.Context(), "events", &buf))
+	var got string
+	require.NoError(t, client.db.QueryRow("SELECT string_agg(concat_ws(':', id, coalesce(kind, 'null'), coalesce(score::VARCHAR, 'null')), ',' ORDER BY rowid) FROM events;").Scan(&got))
+	require.Equal(t, "1:a:0.5,2:null:null,3:b:2.0,1:a:0.5,2:null:null,3:b:2.0,1:a:0.5,2:null:null,3:b:2.0", got)
+	stats, err := client.Stats(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, int64(9), stats.Tables["events"].RowsInserted)
+
+	require.ErrorContains(t, client.InsertArrowIPC(t.Context(), "events", bytes.NewReader([]byte("not arrow"))), "arrow input")
+}
+
+func Test_QueryArrow(t *testing.T) {
+	client, err := New(t.TempDir(), 1)
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+	stmt := `SELECT range AS id, 1.25::DECIMAL(10,2) AS d, [range, range + 1] AS l, {'k': 'v' || range} AS s
+		FROM range(?) ORDER BY id;`
+	var buf bytes.Buffer
+	require.NoError(t, client.QueryArrowIPC(t.Context(), &buf, stmt, 3))
+	rr, err := ipc.NewReader(&buf)
+	require.NoError(t, err)
+	defer rr.Release()
+	fields := rr.Schema().Fields()
+	require.Len(t, fields, 4)
+	require.Equal(t, arrow.PrimitiveTypes.Int64, fields[0].Type)
+	require.Equal(t, &arrow.Decimal128Type{Precision: 10, Scale: 2}, fields[1].Type)
+	require.Equal(t, arrow.LIST, fields[2].Type.ID())
+	require.Equal(t, arrow.PrimitiveTypes.Int64, fields[2].Type.(*arrow.ListType).Elem())
+	require.Equal(t, arrow.STRUCT, fields[3].Type.ID())
+	var rows int64
+	for rr.Next() {
+		rec := rr.RecordBatch()
+		rows += rec.NumRows()
+		require.Equal(t, "[0 1 2]", rec.Column(0).String())
+		require.Equal(t, "[[0 1] [1 2] [2 3]]", rec.Column(2).String())
+		require.Equal(t, `{["v0" "v1" "v2"]}`, rec.Column(3).String())
+		require.Equal(t, "1.25", rec.Column(1).(*array.Decimal128).ValueStr(0))
+	}
+	require.NoError(t, rr.Err())
+	require.Equal(t, int64(3), rows)
+
+	// Results stream while the client is used.
+	r, err := client.QueryArrow(t.Context(), "SELECT range FROM range(10000000);")
+	require.NoError(t, err)
+	require.True(t, r.Next())
+	require.NoError(t, client.Insert(t.Context(), "events", bytes.NewReader([]byte(`{"id":1}`))))
+	r.Release()
+	require.Equal(t, 0, client.db.Stats().InUse)
+
+	_, err = client.QueryArrow(t.Context(), "SELECT * FROM missing;")
+	require.Error(t, err)
+	require.Equal(t, 0, client.db.Stats().InUse)
+}
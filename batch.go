@@ -0,0 +1,44 @@
+package quack
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+)
+
+// InsertBatch inserts each reader of inputs into the table it is keyed by,
+// as Insert does, in a single transaction: either every table gets its rows
+// or, when any input fails, none does and tables the batch would have
+// created do not exist.
+func (c *Client) InsertBatch(ctx context.Context, inputs map[string]io.Reader, opts ...InsertOption) (err error) {
+	cfg := c.ingestConfig(opts)
+	if err := cfg.validate(); err != nil {
+		return err
+	}
+	if err := c.lock("InsertBatch"); err != nil {
+		return err
+	}
+	defer c.unlock("InsertBatch", &err)
+	tx, err := begin(ctx, c.db)
+	if err != nil {
+		return err
+	}
+	defer tx.rollback()
+	results := make([]InsertResult, 0, len(inputs))
+	for _, table := range slices.Sorted(maps.Keys(inputs)) {
+		res, err := insert(ctx, tx, c.stagingDir, table, inputs[table], cfg)
+		if err != nil {
+			return fmt.Errorf("insert %s: %w", table, err)
+		}
+		results = append(results, res)
+	}
+	if err := tx.commit(); err != nil {
+		return err
+	}
+	for _, res := range results {
+		c.counters.insert(res)
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package quack
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_InsertBatch(t *testing.T) {
+	client, err := New(t.TempDir(), 1, WithJournal())
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+	count := func(table string) int64 {
+		var n int64
+		require.NoError(t, client.db.QueryRow("SELECT count(*) FROM "+table+";").Scan(&n))
+		return n
+	}
+	require.NoError(t, client.InsertBatch(t.Context(), map[string]io.Reader{
+		"orders":   strings.NewReader(`{"id":1}` + "\n" + `{"id":2}`),
+		"payments": strings.NewReader(`{"order_id":1,"amount":9.5}`),
+	}))
+	require.Equal(t, int64(2), count("orders"))
+	require.Equal(t, int64(1), count("payments"))
+	stats, err := client.Stats(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, int64(2), stats.Tables["orders"].RowsInserted)
+	entries, err := os.ReadDir(client.journalDir())
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+
+	err = client.InsertBatch(t.Context(), map[string]io.Reader{
+		"line_items": strings.NewReader(`{"order_id":3,"sku":"a"}`),
+		"orders":     strings.NewReader(`{"id":3}`),
+		"payments":   strings.NewReader(`{"order_id":3,"amount":"x"}`),
+	})
+	require.ErrorContains(t, err, "insert payments")
+	require.Equal(t, int64(2), count("orders"))
+	require.Equal(t, int64(1), count("payments"))
+	require.ErrorIs(t, tableExists(t.Context(), client.db, "line_items"), os.ErrNotExist)
+	entries, err = os.ReadDir(client.journalDir())
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+}
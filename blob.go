@@ -0,0 +1,263 @@
+package quack
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+)
+
+const (
+	blobPrefix = "quack_blobs_"
+	// blobChunkSize bounds the size of a single row, so neither writing nor
+	// reading a blob holds more than one chunk in memory.
+	blobChunkSize      = 1 << 20
+	defaultMaxBlobSize = 256 << 20
+)
+
+var (
+	ErrBlobTooLarge = errors.New("blob exceeds size limit")
+
+	bucketPattern = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+)
+
+// BlobInfo describes a stored blob.
+type BlobInfo struct {
+	Key      string
+	Size     int64
+	Metadata map[string]string
+	Created  time.Time
+}
+
+func blobTable(bucket string) (string, error) {
+	if !bucketPattern.MatchString(bucket) {
+		return "", fmt.Errorf("invalid bucket name %q", bucket)
+	}
+	return blobPrefix + bucket, nil
+}
+
+// PutBlob stores the content of r under key in bucket, replacing any blob
+// already stored there. Content is split into chunks of at most 1 MiB, and r
+// is read while the write lock is held.
+func (c *Client) PutBlob(ctx context.Context, bucket, key string, r io.Reader, metadata map[string]string) (err error) {
+	table, err := blobTable(bucket)
+	if err != nil {
+		return err
+	}
+	meta, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+	if err := c.lock("PutBlob"); err != nil {
+		return err
+	}
+	defer c.unlock("PutBlob", &err)
+	if _, err := c.db.ExecContext(ctx, fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	id VARCHAR NOT NULL,
+	key VARCHAR NOT NULL,
+	chunk INTEGER NOT NULL,
+	data BLOB NOT NULL,
+	size BIGINT,
+	metadata JSON,
+	created TIMESTAMP,
+	PRIMARY KEY (key, chunk)
+);`, table)); err != nil {
+		return err
+	}
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE key = $1;", table), key); err != nil {
+		return err
+	}
+	id := ulid.MustNewDefault(time.Now()).String()
+	insert := fmt.Sprintf("INSERT INTO %s (id, key, chunk, data) VALUES ($1, $2, $3, $4);", table)
+	buf := make([]byte, blobChunkSize)
+	var size int64
+	for chunk := 0; ; chunk++ {
+		n, err := io.ReadFull(r, buf)
+		if n == 0 && chunk > 0 {
+			break
+		}
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return err
+		}
+		size += int64(n)
+		if c.maxBlobSize > 0 && size > c.maxBlobSize {
+			return fmt.Errorf("%s/%s: %w (max: %d bytes)", bucket, key, ErrBlobTooLarge, c.maxBlobSize)
+		}
+		if err := c.precheck(size); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, insert, id, key, chunk, buf[:n]); err != nil {
+			return err
+		}
+		if n < len(buf) {
+			break
+		}
+	}
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf(
+		"UPDATE %s SET size = $1, metadata = $2, created = $3 WHERE key = $4 AND chunk = 0;", table,
+	), size, string(meta), time.Now().UTC(), key); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	c.counters.touch(table)
+	return nil
+}
+
+// GetBlob opens the blob stored under key in bucket. It fails with
+// os.ErrNotExist if there is none. Chunks are fetched as the reader is
+// consumed; reading fails if the blob is replaced or deleted meanwhile.
+func (c *Client) GetBlob(ctx context.Context, bucket, key string) (_ io.ReadCloser, err error) {
+	table, err := blobTable(bucket)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.rlock("GetBlob"); err != nil {
+		return nil, err
+	}
+	defer c.runlock("GetBlob", &err)
+	if err := tableExists(ctx, c.db, table); err != nil {
+		return nil, fmt.Errorf("blob %s/%s: %w", bucket, key, err)
+	}
+	var (
+		id   string
+		size int64
+	)
+	err = c.db.QueryRowContext(ctx, fmt.Sprintf("SELECT id, size FROM %s WHERE key = $1 AND chunk = 0;", table), key).Scan(&id, &size)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("blob %s/%s: %w", bucket, key, os.ErrNotExist)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &blobReader{ctx: ctx, c: c, table: table, bucket: bucket, key: key, id: id, remaining: size}, nil
+}
+
+type blobReader struct {
+	ctx                    context.Context
+	c                      *Client
+	table, bucket, key, id string
+	chunk                  int
+	remaining              int64
+	buf                    []byte
+	closed                 bool
+}
+
+func (b *blobReader) Read(p []byte) (int, error) {
+	if b.closed {
+		return 0, os.ErrClosed
+	}
+	for len(b.buf) == 0 {
+		if b.remaining == 0 {
+			return 0, io.EOF
+		}
+		if err := b.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, b.buf)
+	b.buf = b.buf[n:]
+	return n, nil
+}
+
+func (b *blobReader) next() (err error) {
+	if err := b.c.rlock("ReadBlob"); err != nil {
+		return err
+	}
+	defer b.c.runlock("ReadBlob", &err)
+	err = b.c.db.QueryRowContext(b.ctx, fmt.Sprintf(
+		"SELECT data FROM %s WHERE key = $1 AND id = $2 AND chunk = $3;", b.table,
+	), b.key, b.id, b.chunk).Scan(&b.buf)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("blob %s/%s changed while reading", b.bucket, b.key)
+	}
+	if err != nil {
+		return err
+	}
+	b.chunk++
+	b.remaining -= int64(len(b.buf))
+	return nil
+}
+
+func (b *blobReader) Close() error {
+	b.closed = true
+	b.buf = nil
+	return nil
+}
+
+// DeleteBlob removes the blob stored under key in bucket, if any.
+func (c *Client) DeleteBlob(ctx context.Context, bucket, key string) (err error) {
+	table, err := blobTable(bucket)
+	if err != nil {
+		return err
+	}
+	if err := c.lock("DeleteBlob"); err != nil {
+		return err
+	}
+	defer c.unlock("DeleteBlob", &err)
+	if err := tableExists(ctx, c.db, table); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	if _, err := c.db.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE key = $1;", table), key); err != nil {
+		return err
+	}
+	c.counters.touch(table)
+	return nil
+}
+
+// ListBlobs lists the blobs in bucket whose key starts with prefix, ordered
+// by key.
+func (c *Client) ListBlobs(ctx context.Context, bucket, prefix string) (_ []BlobInfo, err error) {
+	table, err := blobTable(bucket)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.rlock("ListBlobs"); err != nil {
+		return nil, err
+	}
+	defer c.runlock("ListBlobs", &err)
+	if err := tableExists(ctx, c.db, table); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	rows, err := c.db.QueryContext(ctx, fmt.Sprintf(
+		"SELECT key, size, coalesce(metadata::VARCHAR, 'null'), created FROM %s WHERE chunk = 0 AND starts_with(key, $1) ORDER BY key;", table,
+	), prefix)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var blobs []BlobInfo
+	for rows.Next() {
+		var (
+			info BlobInfo
+			meta string
+		)
+		if err := rows.Scan(&info.Key, &info.Size, &meta, &info.Created); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(meta), &info.Metadata); err != nil {
+			return nil, err
+		}
+		blobs = append(blobs, info)
+	}
+	return blobs, rows.Err()
+}
@@ -0,0 +1,76 @@
+package quack
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Blob(t *testing.T) {
+	dir := t.TempDir()
+	client, err := New(dir, 3, WithMaxBlobSize(4<<20))
+	require.NoError(t, err)
+
+	large := make([]byte, blobChunkSize*2+123)
+	_, err = rand.Read(large)
+	require.NoError(t, err)
+	small := []byte{0, 1, '\\', 'x', '4', '1', 0xff, '"', 0x80}
+	require.NoError(t, client.PutBlob(t.Context(), "art", "img/large", bytes.NewReader(large), map[string]string{"type": "raw"}))
+	require.NoError(t, client.PutBlob(t.Context(), "art", "img/small", bytes.NewReader(small), nil))
+	require.NoError(t, client.PutBlob(t.Context(), "art", "empty", bytes.NewReader(nil), nil))
+	require.ErrorIs(t, client.PutBlob(t.Context(), "art", "huge", bytes.NewReader(make([]byte, 5<<20)), nil), ErrBlobTooLarge)
+	require.Error(t, client.PutBlob(t.Context(), "a-b", "x", bytes.NewReader(nil), nil))
+
+	expect := func(key string, want []byte) {
+		t.Helper()
+		r, err := client.GetBlob(t.Context(), "art", key)
+		require.NoError(t, err)
+		got, err := io.ReadAll(r)
+		require.NoError(t, err)
+		require.NoError(t, r.Close())
+		require.Equal(t, want, got)
+	}
+	expect("img/large", large)
+	expect("img/small", small)
+	expect("empty", []byte{})
+	_, err = client.GetBlob(t.Context(), "art", "huge")
+	require.ErrorIs(t, err, os.ErrNotExist)
+	_, err = client.GetBlob(t.Context(), "none", "x")
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	blobs, err := client.ListBlobs(t.Context(), "art", "img/")
+	require.NoError(t, err)
+	require.Len(t, blobs, 2)
+	require.Equal(t, "img/large", blobs[0].Key)
+	require.Equal(t, int64(len(large)), blobs[0].Size)
+	require.Equal(t, map[string]string{"type": "raw"}, blobs[0].Metadata)
+	require.Equal(t, int64(len(small)), blobs[1].Size)
+
+	t.Run("replaced while reading", func(t *testing.T) {
+		r, err := client.GetBlob(t.Context(), "art", "img/large")
+		require.NoError(t, err)
+		_, err = r.Read(make([]byte, 10))
+		require.NoError(t, err)
+		require.NoError(t, client.PutBlob(t.Context(), "art", "img/large", bytes.NewReader(large), nil))
+		_, err = io.ReadAll(r)
+		require.Error(t, err)
+	})
+
+	require.NoError(t, client.Close(t.Context()))
+	client, err = New(dir, 3)
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+	require.NoError(t, client.DeleteBlob(t.Context(), "art", "img/small"))
+	require.NoError(t, client.RollbackSnapshot(t.Context(), 1))
+	expect("img/large", large)
+	expect("img/small", small)
+	expect("empty", []byte{})
+	require.NoError(t, client.DeleteBlob(t.Context(), "art", "img/small"))
+	blobs, err = client.ListBlobs(t.Context(), "art", "")
+	require.NoError(t, err)
+	require.Len(t, blobs, 2)
+}
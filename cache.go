@@ -0,0 +1,210 @@
+package quack
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// cacheDatabase holds the external caches excluded from snapshots. EXPORT
+// DATABASE only covers the main database, so they never reach an archive
+// and rollback leaves them alone.
+const cacheDatabase = "quack_cache"
+
+type external struct {
+	name, path string
+	format     Format
+	refresh    time.Duration
+	exclude    bool
+	stop, done chan struct{}
+
+	// Guarded by Client.cacheMux.
+	refreshed, modTime time.Time
+	err                error
+}
+
+func (e *external) table() string {
+	if e.exclude {
+		return cacheDatabase + "." + e.name
+	}
+	return e.name
+}
+
+// CacheInfo reports the state of a cache created by CacheExternal.
+type CacheInfo struct {
+	Name, Path string
+	Format     Format
+	// Table is the table to query, qualified with quack_cache for caches
+	// excluded from snapshots.
+	Table   string
+	Refresh time.Duration
+	// Refreshed is when the cache was last loaded and SourceModTime the
+	// modification time of the source at that point, zero when the source
+	// is not a local file.
+	Refreshed, SourceModTime time.Time
+	// Err is the error of the last refresh attempt, if it failed.
+	Err error
+}
+
+type CacheOption func(*external)
+
+// ExcludeFromSnapshots keeps the cache out of snapshots. The cache table is
+// then created in the quack_cache database, e.g. quack_cache.prices.
+func ExcludeFromSnapshots() CacheOption {
+	return func(e *external) { e.exclude = true }
+}
+
+func (c *Client) cacheFile() string {
+	return filepath.Join(c.dir, "cache.ddb")
+}
+
+func removeDatabaseFile(file string) error {
+	for _, f := range []string{file, file + ".wal"} {
+		if err := os.Remove(f); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// CacheExternal materializes the external file at path into the table name
+// and, when refresh is positive, reloads it on that interval whenever the
+// source file has changed. Each load replaces the table in a single
+// statement, so queries see either the old or the new contents.
+func (c *Client) CacheExternal(ctx context.Context, name, path string, format Format, refresh time.Duration, opts ...CacheOption) error {
+	e := &external{name: name, path: path, format: format, refresh: refresh, stop: make(chan struct{}), done: make(chan struct{})}
+	for _, opt := range opts {
+		opt(e)
+	}
+	c.cacheMux.Lock()
+	if _, ok := c.caches[name]; ok {
+		c.cacheMux.Unlock()
+		return fmt.Errorf("external cache %s already exists", name)
+	}
+	c.caches[name] = e
+	c.cacheMux.Unlock()
+	if err := c.refreshExternal(ctx, e, true); err != nil {
+		c.cacheMux.Lock()
+		delete(c.caches, name)
+		c.cacheMux.Unlock()
+		return err
+	}
+	if refresh > 0 {
+		go c.refreshLoop(e)
+	} else {
+		close(e.done)
+	}
+	return nil
+}
+
+// RefreshExternal reloads the cache name now, whether or not its source
+// changed.
+func (c *Client) RefreshExternal(ctx context.Context, name string) error {
+	c.cacheMux.Lock()
+	e, ok := c.caches[name]
+	c.cacheMux.Unlock()
+	if !ok {
+		return fmt.Errorf("external cache %s: %w", name, os.ErrNotExist)
+	}
+	return c.refreshExternal(ctx, e, true)
+}
+
+// ExternalCaches reports the state of every cache, ordered by name.
+func (c *Client) ExternalCaches() []CacheInfo {
+	c.cacheMux.Lock()
+	defer c.cacheMux.Unlock()
+	infos := make([]CacheInfo, 0, len(c.caches))
+	for _, e := range c.caches {
+		infos = append(infos, CacheInfo{
+			Name:          e.name,
+			Path:          e.path,
+			Format:        e.format,
+			Table:         e.table(),
+			Refresh:       e.refresh,
+			Refreshed:     e.refreshed,
+			SourceModTime: e.modTime,
+			Err:           e.err,
+		})
+	}
+	slices.SortFunc(infos, func(a, b CacheInfo) int { return strings.Compare(a.Name, b.Name) })
+	return infos
+}
+
+func (c *Client) refreshLoop(e *external) {
+	defer close(e.done)
+	t := time.NewTicker(e.refresh)
+	defer t.Stop()
+	for {
+		select {
+		case <-e.stop:
+			return
+		case <-t.C:
+			if err := c.refreshExternal(context.Background(), e, false); err != nil {
+				c.logger.Warn("refreshing external cache failed", "name", e.name, "err", err)
+			}
+		}
+	}
+}
+
+func (c *Client) refreshExternal(ctx context.Context, e *external, force bool) error {
+	var modTime time.Time
+	if info, err := os.Stat(e.path); err == nil {
+		modTime = info.ModTime()
+	}
+	c.cacheMux.Lock()
+	unchanged := !modTime.IsZero() && modTime.Equal(e.modTime) && e.err == nil
+	c.cacheMux.Unlock()
+	if unchanged && !force {
+		return nil
+	}
+	err := c.materialize(ctx, e)
+	c.cacheMux.Lock()
+	defer c.cacheMux.Unlock()
+	e.err = err
+	if err == nil {
+		e.refreshed = time.Now()
+		e.modTime = modTime
+	}
+	return err
+}
+
+func (c *Client) materialize(ctx context.Context, e *external) (err error) {
+	if err := c.lock("RefreshExternal"); err != nil {
+		return err
+	}
+	defer c.unlock("RefreshExternal", &err)
+	if e.exclude && !c.cacheAttached {
+		if _, err := c.db.ExecContext(ctx, fmt.Sprintf("ATTACH %s AS %s;", quoteLiteral(c.cacheFile()), cacheDatabase)); err != nil {
+			return err
+		}
+		c.cacheAttached = true
+	}
+	if _, err := c.db.ExecContext(ctx, fmt.Sprintf("CREATE OR REPLACE TABLE %s AS SELECT * FROM %s;", e.table(), e.format.reader(e.path))); err != nil {
+		return err
+	}
+	c.counters.touch(e.table())
+	return nil
+}
+
+// stopCaches stops the refresh loops. It must be called without holding
+// the write lock, which a refresh in progress is waiting for.
+func (c *Client) stopCaches() {
+	c.cacheMux.Lock()
+	caches := make([]*external, 0, len(c.caches))
+	for _, e := range c.caches {
+		caches = append(caches, e)
+	}
+	c.cacheMux.Unlock()
+	for _, e := range caches {
+		select {
+		case <-e.stop:
+		default:
+			close(e.stop)
+		}
+		<-e.done
+	}
+}
@@ -0,0 +1,66 @@
+package quack
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_CacheExternal(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "it's")
+	src := filepath.Join(t.TempDir(), "prices.csv")
+	write := func(body string, mtime time.Time) {
+		t.Helper()
+		require.NoError(t, os.WriteFile(src, []byte(body), 0644))
+		require.NoError(t, os.Chtimes(src, mtime, mtime))
+	}
+	start := time.Now().Add(-time.Hour).Truncate(time.Second)
+	write("id,price\n1,10\n", start)
+
+	client, err := New(dir, 3)
+	require.NoError(t, err)
+	count := func(table string) int {
+		var n int
+		require.NoError(t, client.db.QueryRow("SELECT count(*) FROM "+table+";").Scan(&n))
+		return n
+	}
+	require.NoError(t, client.CacheExternal(t.Context(), "prices", src, FormatCSV, 20*time.Millisecond))
+	require.NoError(t, client.CacheExternal(t.Context(), "scratch", src, FormatCSV, 0, ExcludeFromSnapshots()))
+	require.Error(t, client.CacheExternal(t.Context(), "prices", src, FormatCSV, 0))
+	require.Error(t, client.CacheExternal(t.Context(), "missing", filepath.Join(dir, "none.csv"), FormatCSV, 0))
+	require.Equal(t, 1, count("prices"))
+	require.Equal(t, 1, count("quack_cache.scratch"))
+
+	infos := client.ExternalCaches()
+	require.Len(t, infos, 2)
+	require.Equal(t, "prices", infos[0].Table)
+	require.Equal(t, "quack_cache.scratch", infos[1].Table)
+	require.True(t, infos[0].SourceModTime.Equal(start))
+	require.NoError(t, infos[0].Err)
+
+	write("id,price\n1,10\n2,20\n", start.Add(time.Minute))
+	require.Eventually(t, func() bool { return count("prices") == 2 }, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, 1, count("quack_cache.scratch"))
+	require.NoError(t, client.RefreshExternal(t.Context(), "scratch"))
+	require.Equal(t, 2, count("quack_cache.scratch"))
+	require.Error(t, client.RefreshExternal(t.Context(), "nope"))
+	require.True(t, client.ExternalCaches()[0].SourceModTime.Equal(start.Add(time.Minute)))
+
+	require.NoError(t, client.Compact(t.Context()))
+	require.Equal(t, 2, count("quack_cache.scratch"))
+	require.NoError(t, client.Close(t.Context()))
+
+	client, err = New(dir, 3)
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+	require.Equal(t, 2, count("prices"))
+	require.NoError(t, client.CacheExternal(t.Context(), "scratch", src, FormatCSV, 0, ExcludeFromSnapshots()))
+	require.NoError(t, client.RollbackSnapshot(t.Context(), 1))
+	require.Equal(t, 2, count("quack_cache.scratch"))
+	tables, err := showTables(t.Context(), client.db)
+	require.NoError(t, err)
+	require.NotContains(t, tables, "scratch")
+}
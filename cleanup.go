@@ -0,0 +1,77 @@
+package quack
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+const (
+	dumpPrefix   = "quack-dump-"
+	loadPrefix   = "quack-load-"
+	insertPrefix = "quack-insert-"
+
+	// orphanAge is how old a temporary artifact has to be before New
+	// considers it abandoned by a crashed process.
+	orphanAge = 24 * time.Hour
+)
+
+var (
+	stagingPattern = regexp.MustCompile(`^quack-(dump|load|insert)-\d+$`)
+	// legacyPattern matches temporary names used by older versions. They are
+	// only swept from a dedicated staging directory, never from os.TempDir.
+	legacyPattern      = regexp.MustCompile(`^(dump|load|insert)\d+$`)
+	snapshotTmpPattern = regexp.MustCompile(`^[0-9A-HJKMNP-TV-Z]{26}\.tmp$`)
+)
+
+func sweepDir(dir string, before time.Time, match func(string) bool) ([]string, error) {
+	infos, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var removed []string
+	for _, info := range infos {
+		if !match(info.Name()) {
+			continue
+		}
+		fi, err := info.Info()
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return removed, err
+		}
+		if !fi.ModTime().Before(before) {
+			continue
+		}
+		p := filepath.Join(dir, info.Name())
+		if err := os.RemoveAll(p); err != nil {
+			return removed, err
+		}
+		removed = append(removed, p)
+	}
+	return removed, nil
+}
+
+func (c *Client) sweepOrphans(now time.Time) error {
+	before := now.Add(-orphanAge)
+	staging := c.stagingDir
+	dedicated := staging != ""
+	if !dedicated {
+		staging = os.TempDir()
+	}
+	removed, err := sweepDir(staging, before, func(name string) bool {
+		return stagingPattern.MatchString(name) || (dedicated && legacyPattern.MatchString(name))
+	})
+	if err != nil {
+		return err
+	}
+	snapshots, err := sweepDir(filepath.Join(c.dir, "snapshot"), before, snapshotTmpPattern.MatchString)
+	if err != nil {
+		return err
+	}
+	for _, p := range append(removed, snapshots...) {
+		c.logger.Info("removed orphaned temporary file", "path", p)
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package quack
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_sweepOrphans(t *testing.T) {
+	dir := t.TempDir()
+	staging := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "snapshot"), 0755))
+	old := time.Now().Add(-2 * orphanAge)
+	touch := func(p string, mtime time.Time) {
+		require.NoError(t, os.WriteFile(p, nil, 0644))
+		require.NoError(t, os.Chtimes(p, mtime, mtime))
+	}
+	require.NoError(t, os.Mkdir(filepath.Join(staging, "quack-dump-123"), 0755))
+	require.NoError(t, os.Chtimes(filepath.Join(staging, "quack-dump-123"), old, old))
+	touch(filepath.Join(staging, "quack-insert-456"), old)
+	touch(filepath.Join(staging, "insert789"), old)
+	touch(filepath.Join(staging, "quack-load-999"), time.Now())
+	touch(filepath.Join(staging, "notes.txt"), old)
+	touch(filepath.Join(dir, "snapshot", "01HZZZZZZZZZZZZZZZZZZZZZZZ.tmp"), old)
+	touch(filepath.Join(dir, "snapshot", "01HZZZZZZZZZZZZZZZZZZZZZZZ"), old)
+
+	var buf bytes.Buffer
+	client, err := New(dir, 3, WithStagingDir(staging), WithLogger(slog.New(slog.NewTextHandler(&buf, nil))))
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+
+	names, err := listDir(staging)
+	require.NoError(t, err)
+	require.Equal(t, []string{"notes.txt", "quack-load-999"}, names)
+	names, err = listDir(filepath.Join(dir, "snapshot"))
+	require.NoError(t, err)
+	require.Equal(t, []string{"01HZZZZZZZZZZZZZZZZZZZZZZZ"}, names)
+	require.Contains(t, buf.String(), "quack-dump-123")
+	require.Contains(t, buf.String(), "01HZZZZZZZZZZZZZZZZZZZZZZZ.tmp")
+}
@@ -0,0 +1,193 @@
+package quack
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrClosed is returned by operations started once Close has begun.
+var ErrClosed = errors.New("client is closed")
+
+// drainInterval is how often Close checks for in-flight operations.
+const drainInterval = 5 * time.Millisecond
+
+// lock takes the client lock for the operation op unless Close has begun.
+// Release it with unlock.
+func (c *Client) lock(op string) error {
+	if c.closing.Load() {
+		c.counters.operation(op, ErrClosed)
+		return ErrClosed
+	}
+	c.acquire()
+	if c.closing.Load() {
+		c.release()
+		c.counters.operation(op, ErrClosed)
+		return ErrClosed
+	}
+	return nil
+}
+
+// unlock releases the lock taken by lock and counts op with its outcome.
+func (c *Client) unlock(op string, err *error) {
+	c.release()
+	c.counters.operation(op, *err)
+}
+
+// rlock takes the client lock shared with other reads for the operation op
+// unless Close has begun. Operations that only read the database take it,
+// so they run concurrently with each other but not with writes. Release it
+// with runlock.
+func (c *Client) rlock(op string) error {
+	if c.closing.Load() {
+		c.counters.operation(op, ErrClosed)
+		return ErrClosed
+	}
+	c.mux.RLock()
+	if c.closing.Load() {
+		c.mux.RUnlock()
+		c.counters.operation(op, ErrClosed)
+		return ErrClosed
+	}
+	return nil
+}
+
+// runlock releases the lock taken by rlock and counts op with its outcome.
+func (c *Client) runlock(op string, err *error) {
+	c.mux.RUnlock()
+	c.counters.operation(op, *err)
+}
+
+// lockQuery takes the lock a query run with cfg needs: the shared one,
+// unless a setting must be changed for it. It returns the matching unlock.
+func (c *Client) lockQuery(op string, cfg queryConfig) (func(string, *error), error) {
+	if cfg.memoryLimit != "" {
+		return c.unlock, c.lock(op)
+	}
+	return c.runlock, c.rlock(op)
+}
+
+func (c *Client) acquire() {
+	c.mux.Lock()
+	c.lockedAt = time.Now()
+}
+
+func (c *Client) release() {
+	c.counters.held(time.Since(c.lockedAt))
+	c.mux.Unlock()
+}
+
+// opContext returns the context for work that outlives the call starting
+// it, such as the rows handed to the caller: ctx, also canceled when Close
+// gives up waiting for in-flight operations. Call it with the lock held.
+func (c *Client) opContext(ctx context.Context) context.Context {
+	if ctx.Done() == nil {
+		// Deriving from the client context directly leaves nothing
+		// registered once the rows are closed.
+		return opValues{Context: c.ops, values: ctx}
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	stop := context.AfterFunc(c.ops, cancel)
+	context.AfterFunc(ctx, func() { stop() })
+	return ctx
+}
+
+// opValues is the client context carrying the values of another one.
+type opValues struct {
+	context.Context
+	values context.Context
+}
+
+func (o opValues) Value(key any) any {
+	if v := o.Context.Value(key); v != nil {
+		return v
+	}
+	return o.values.Value(key)
+}
+
+// inFlight counts the statements running, result sets and appenders open
+// on the client's databases. Call it with the lock held.
+func (c *Client) inFlight() int {
+	n := c.db.Stats().InUse + int(c.appenders.Load())
+	for _, db := range c.databases {
+		n += db.Stats().InUse
+	}
+	return n
+}
+
+// drain waits for the operations in flight to finish until ctx is done,
+// then cancels the rest and waits for them to let go of their connections.
+// It returns how many were canceled, counting an operation holding the lock
+// as one.
+func (c *Client) drain(ctx context.Context) int {
+	busy := func() int {
+		if !c.mux.TryLock() {
+			return 1
+		}
+		defer c.mux.Unlock()
+		return c.inFlight()
+	}
+	tick := time.NewTicker(drainInterval)
+	defer tick.Stop()
+	n := busy()
+wait:
+	for n > 0 && ctx.Done() != nil {
+		select {
+		case <-ctx.Done():
+			break wait
+		case <-tick.C:
+			n = busy()
+		}
+	}
+	if n == 0 {
+		return 0
+	}
+	c.cancelOps()
+	for busy() > 0 {
+		<-tick.C
+	}
+	return n
+}
+
+// reopen admits operations again after a failed Close.
+func (c *Client) reopen() {
+	if c.ops.Err() != nil {
+		c.ops, c.cancelOps = context.WithCancel(context.Background())
+	}
+	c.closing.Store(false)
+}
+
+// dedupOnClose deduplicates the tables set by WithDedupOnClose, each in a
+// transaction of its own, and stops once ctx is done.
+func (c *Client) dedupOnClose(ctx context.Context) error {
+	if !c.dedupClose {
+		return nil
+	}
+	tables := c.dedupCloseTables
+	if len(tables) == 0 {
+		infos, err := listTables(ctx, c.db, tablesConfig{excludeInternal: true}, nil)
+		if err != nil {
+			return fmt.Errorf("deduplicate on close: %w", err)
+		}
+		for _, t := range infos {
+			if !t.View && t.Schema == "main" {
+				tables = append(tables, t.Name)
+			}
+		}
+	}
+	var errs []error
+	for _, table := range tables {
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, fmt.Errorf("deduplicate on close: %w", err))
+			break
+		}
+		removed, err := dedupTx(ctx, c.db, table, newDedupConfig(c.dedupOpts[table]).orderBy)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("deduplicate %s on close: %w", table, err))
+			continue
+		}
+		c.counters.remove(table, removed)
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,133 @@
+package quack
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_CloseDrain(t *testing.T) {
+	t.Run("waits for readers", func(t *testing.T) {
+		client, err := New(t.TempDir(), 3)
+		require.NoError(t, err)
+		rows, err := client.Query(t.Context(), "SELECT range FROM range(1000);")
+		require.NoError(t, err)
+		stats, err := client.Stats(t.Context())
+		require.NoError(t, err)
+		require.Equal(t, 1, stats.InFlight)
+		done := make(chan int)
+		go func() {
+			defer rows.Close()
+			time.Sleep(50 * time.Millisecond)
+			n := 0
+			for rows.Next() {
+				n++
+			}
+			done <- n
+		}()
+		ctx, cancel := context.WithTimeout(t.Context(), 10*time.Second)
+		defer cancel()
+		require.NoError(t, client.Close(ctx))
+		require.Equal(t, 1000, <-done)
+		require.NoError(t, rows.Err())
+	})
+	t.Run("cancels stragglers", func(t *testing.T) {
+		client, err := New(t.TempDir(), 3)
+		require.NoError(t, err)
+		rows, err := client.Query(t.Context(), "SELECT range FROM range(1000000);")
+		require.NoError(t, err)
+		r, err := client.QueryReader(t.Context(), "SELECT range AS i FROM range(100000000);")
+		require.NoError(t, err)
+		defer r.Close()
+		done := make(chan error)
+		go func() {
+			defer rows.Close()
+			for rows.Next() {
+				time.Sleep(time.Millisecond)
+			}
+			done <- rows.Err()
+		}()
+		ctx, cancel := context.WithTimeout(t.Context(), 50*time.Millisecond)
+		defer cancel()
+		require.NoError(t, client.Close(ctx))
+		require.ErrorIs(t, <-done, context.Canceled)
+		_, err = io.ReadAll(r)
+		require.ErrorIs(t, err, context.Canceled)
+		_, err = client.Query(t.Context(), "SELECT 1;")
+		require.ErrorIs(t, err, ErrClosed)
+		require.ErrorIs(t, client.Close(t.Context()), ErrClosed)
+	})
+	t.Run("no grace", func(t *testing.T) {
+		client, err := New(t.TempDir(), 3)
+		require.NoError(t, err)
+		rows, err := client.Query(context.Background(), "SELECT range FROM range(1000);")
+		require.NoError(t, err)
+		defer rows.Close()
+		require.NoError(t, client.Close(context.Background()))
+		for rows.Next() {
+		}
+		require.ErrorIs(t, rows.Err(), context.Canceled)
+	})
+}
+
+func Test_DedupOnClose(t *testing.T) {
+	data := `{"id":2}` + "\n" + `{"id":1}` + "\n" + `{"id":2}`
+	closeWith := func(t *testing.T, ctx context.Context, opts ...any) (string, error) {
+		dir := t.TempDir()
+		client, err := New(dir, 3, opts...)
+		require.NoError(t, err)
+		for _, table := range []string{"events", "other"} {
+			require.NoError(t, client.Insert(t.Context(), table, strings.NewReader(data)))
+		}
+		return dir, client.Close(ctx)
+	}
+	ids := func(t *testing.T, dir, table string) string {
+		infos, err := snapshotInfos(filepath.Join(dir, "snapshot"))
+		require.NoError(t, err)
+		r, err := OpenSnapshot(t.Context(), infos[0].Path)
+		require.NoError(t, err)
+		defer r.Close()
+		var s string
+		require.NoError(t, r.db.QueryRow(fmt.Sprintf("SELECT string_agg(id::VARCHAR, ',' ORDER BY rowid) FROM %s;", table)).Scan(&s))
+		return s
+	}
+	t.Run("all tables", func(t *testing.T) {
+		dir, err := closeWith(t, t.Context(), WithDedupOnClose(), WithTableDedup("other", OrderBy("id")))
+		require.NoError(t, err)
+		require.Len(t, strings.Split(ids(t, dir, "events"), ","), 2)
+		require.Equal(t, "1,2", ids(t, dir, "other"))
+	})
+	t.Run("named tables", func(t *testing.T) {
+		dir, err := closeWith(t, t.Context(), WithDedupOnClose("other"))
+		require.NoError(t, err)
+		require.Equal(t, "2,1,2", ids(t, dir, "events"))
+		require.Len(t, strings.Split(ids(t, dir, "other"), ","), 2)
+	})
+	t.Run("failure aborts", func(t *testing.T) {
+		client, err := New(t.TempDir(), 3, WithDedupOnClose(), WithTableDedup("events", OrderBy("missing")))
+		require.NoError(t, err)
+		require.NoError(t, client.Insert(t.Context(), "events", strings.NewReader(data)))
+		require.ErrorContains(t, client.Close(t.Context()), "deduplicate events on close")
+		_, err = client.Query(t.Context(), "SELECT 1;")
+		require.NoError(t, err)
+	})
+	t.Run("failure continues", func(t *testing.T) {
+		dir, err := closeWith(t, t.Context(), WithDedupOnClose(), WithTableDedup("events", OrderBy("missing")), ContinueCloseOnDedupError())
+		require.NoError(t, err)
+		require.Equal(t, "2,1,2", ids(t, dir, "events"))
+		require.Len(t, strings.Split(ids(t, dir, "other"), ","), 2)
+	})
+	t.Run("deadline", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(t.Context())
+		cancel()
+		dir, err := closeWith(t, ctx, WithDedupOnClose(), ContinueCloseOnDedupError())
+		require.NoError(t, err)
+		require.Equal(t, "2,1,2", ids(t, dir, "events"))
+	})
+}
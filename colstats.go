@@ -0,0 +1,126 @@
+package quack
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// ColumnStats summarizes the values of one column. Min, Max and TopValues
+// hold values in their SQL text form; they are empty when every value is
+// NULL.
+type ColumnStats struct {
+	Column string `json:"column"`
+	Type   string `json:"type"`
+	// Rows is the number of rows scanned, which is the sample size when
+	// sampling.
+	Rows         int64   `json:"rows"`
+	Nulls        int64   `json:"nulls"`
+	NullFraction float64 `json:"null_fraction"`
+	Min          string  `json:"min,omitempty"`
+	Max          string  `json:"max,omitempty"`
+	// Distinct is an approximate count of distinct non-NULL values.
+	Distinct int64 `json:"distinct"`
+	// TopValues are the approximately most frequent values, most frequent
+	// first.
+	TopValues []string `json:"top_values,omitempty"`
+}
+
+type columnStatsConfig struct {
+	sample int64
+	topK   int
+}
+
+type ColumnStatsOption func(*columnStatsConfig)
+
+// SampleRows computes the statistics over a random sample of n rows rather
+// than the whole table.
+func SampleRows(n int64) ColumnStatsOption {
+	return func(c *columnStatsConfig) { c.sample = n }
+}
+
+// TopK sets how many frequent values to report per column. Defaults to 5.
+func TopK(k int) ColumnStatsOption {
+	return func(c *columnStatsConfig) { c.topK = k }
+}
+
+// ColumnStats computes statistics for the given columns of table, or all of
+// them when none are given, in a single scan.
+func (c *Client) ColumnStats(ctx context.Context, table string, columns []string, opts ...ColumnStatsOption) (_ []ColumnStats, err error) {
+	cfg := columnStatsConfig{topK: 5}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if err := c.lock("ColumnStats"); err != nil {
+		return nil, err
+	}
+	defer c.unlock("ColumnStats", &err)
+	return columnStats(ctx, c.db, table, columns, cfg)
+}
+
+func columnStats(ctx context.Context, db querier, table string, columns []string, cfg columnStatsConfig) ([]ColumnStats, error) {
+	all, err := describe(ctx, db, table)
+	if err != nil {
+		return nil, err
+	}
+	types := make(map[string]string, len(all))
+	for _, col := range all {
+		types[col.Name] = col.Type
+	}
+	if len(columns) == 0 {
+		for _, col := range all {
+			columns = append(columns, col.Name)
+		}
+	}
+	exprs := []string{"count(*)"}
+	for _, name := range columns {
+		if _, ok := types[name]; !ok {
+			return nil, fmt.Errorf("table %s has no column %s", table, name)
+		}
+		col := quoteIdent(name)
+		exprs = append(exprs,
+			fmt.Sprintf("count(%s)", col),
+			fmt.Sprintf("min(%s)::VARCHAR", col),
+			fmt.Sprintf("max(%s)::VARCHAR", col),
+			fmt.Sprintf("approx_count_distinct(%s)", col),
+			fmt.Sprintf("coalesce(approx_top_k(%s, %d)::VARCHAR[], [])", col, max(cfg.topK, 1)),
+		)
+	}
+	stmt := fmt.Sprintf("SELECT %s FROM %s", strings.Join(exprs, ", "), table)
+	if cfg.sample > 0 {
+		stmt += fmt.Sprintf(" USING SAMPLE %d ROWS", cfg.sample)
+	}
+	var (
+		rows  int64
+		stats = make([]ColumnStats, len(columns))
+		dest  = []any{&rows}
+		mins  = make([]sql.NullString, len(columns))
+		maxs  = make([]sql.NullString, len(columns))
+		tops  = make([][]any, len(columns))
+	)
+	for i := range columns {
+		dest = append(dest, &stats[i].Rows, &mins[i], &maxs[i], &stats[i].Distinct, &tops[i])
+	}
+	if err := db.QueryRowContext(ctx, stmt+";").Scan(dest...); err != nil {
+		return nil, err
+	}
+	for i, name := range columns {
+		s := &stats[i]
+		s.Column, s.Type = name, types[name]
+		s.Nulls, s.Rows = rows-s.Rows, rows
+		if rows > 0 {
+			s.NullFraction = float64(s.Nulls) / float64(rows)
+		}
+		s.Min, s.Max = mins[i].String, maxs[i].String
+		if cfg.topK <= 0 {
+			continue
+		}
+		for _, v := range tops[i] {
+			if v != nil {
+				s.TopValues = append(s.TopValues, v.(string))
+			}
+		}
+	}
+	return stats, nil
+}
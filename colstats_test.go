@@ -0,0 +1,53 @@
+package quack
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ColumnStats(t *testing.T) {
+	client, err := New(t.TempDir(), 3)
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+	var b strings.Builder
+	for i := range 100 {
+		fmt.Fprintf(&b, "{\"id\":%d,\"kind\":%q,\"note\":null}\n", i, []string{"a", "a", "a", "b"}[i%4])
+	}
+	for range 20 {
+		b.WriteString("{\"id\":null,\"kind\":\"c\",\"note\":null}\n")
+	}
+	require.NoError(t, client.Insert(t.Context(), "events", strings.NewReader(b.String())))
+
+	stats, err := client.ColumnStats(t.Context(), "events", nil, TopK(2))
+	require.NoError(t, err)
+	require.Len(t, stats, 3)
+	id, kind, note := stats[0], stats[1], stats[2]
+	require.Equal(t, "id", id.Column)
+	require.Equal(t, "BIGINT", id.Type)
+	require.Equal(t, int64(120), id.Rows)
+	require.Equal(t, int64(20), id.Nulls)
+	require.InDelta(t, 20.0/120, id.NullFraction, 1e-9)
+	require.Equal(t, "0", id.Min)
+	require.Equal(t, "99", id.Max)
+	require.InDelta(t, 100, id.Distinct, 5)
+	require.Equal(t, []string{"a", "b"}, kind.TopValues)
+	require.Equal(t, int64(3), kind.Distinct)
+	require.Equal(t, 1.0, note.NullFraction)
+	require.Empty(t, note.Min)
+	require.Empty(t, note.TopValues)
+
+	stats, err = client.ColumnStats(t.Context(), "events", []string{"kind"}, SampleRows(10))
+	require.NoError(t, err)
+	require.Len(t, stats, 1)
+	require.Equal(t, int64(10), stats[0].Rows)
+	_, err = client.ColumnStats(t.Context(), "events", []string{"nope"})
+	require.Error(t, err)
+
+	out, err := json.Marshal(stats[0])
+	require.NoError(t, err)
+	require.Contains(t, string(out), `"column":"kind"`)
+}
@@ -0,0 +1,157 @@
+package quack
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// commentsTable mirrors comments set through SetComment. EXPORT DATABASE
+// drops COMMENT ON metadata, so the mirror is what carries comments through
+// snapshots.
+const commentsTable = "quack_comments"
+
+type Comments struct {
+	Table   string
+	Columns map[string]string
+}
+
+func commentStmt(table, column, comment string) string {
+	value := "NULL"
+	if comment != "" {
+		value = quoteLiteral(comment)
+	}
+	if column == "" {
+		return fmt.Sprintf("COMMENT ON TABLE %s IS %s;", table, value)
+	}
+	return fmt.Sprintf("COMMENT ON COLUMN %s.%s IS %s;", table, quoteIdent(column), value)
+}
+
+// applyComments re-applies mirrored comments after a table was recreated by
+// a restore or rewrite. An empty table applies comments of all tables.
+func applyComments(ctx context.Context, db querier, table string) error {
+	if err := tableExists(ctx, db, commentsTable); os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	rows, err := db.QueryContext(ctx, "SELECT table_name, column_name, comment FROM "+commentsTable+" WHERE ? = '' OR table_name = ?;", table, table)
+	if err != nil {
+		return err
+	}
+	var stmts []string
+	for rows.Next() {
+		var t, col, comment string
+		if err := rows.Scan(&t, &col, &comment); err != nil {
+			rows.Close()
+			return err
+		}
+		stmts = append(stmts, commentStmt(t, col, comment))
+	}
+	if err := rows.Close(); err != nil {
+		return err
+	}
+	for _, stmt := range stmts {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetComment attaches comment to target, which is either a table name or
+// "table.column". An empty comment removes it.
+func (c *Client) SetComment(ctx context.Context, target, comment string) (err error) {
+	if err := c.lock("SetComment"); err != nil {
+		return err
+	}
+	defer c.unlock("SetComment", &err)
+	table, column := target, ""
+	if i := strings.LastIndexByte(target, '.'); i >= 0 {
+		if err := tableExists(ctx, c.db, target[:i]); err == nil {
+			table, column = target[:i], target[i+1:]
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+	}
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	stmts := []string{
+		commentStmt(table, column, comment),
+		"CREATE TABLE IF NOT EXISTS " + commentsTable + " (table_name VARCHAR, column_name VARCHAR, comment VARCHAR, PRIMARY KEY (table_name, column_name));",
+	}
+	for _, stmt := range stmts {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	if comment == "" {
+		_, err = tx.ExecContext(ctx, "DELETE FROM "+commentsTable+" WHERE table_name = ? AND column_name = ?;", table, column)
+	} else {
+		_, err = tx.ExecContext(ctx, "INSERT OR REPLACE INTO "+commentsTable+" VALUES (?, ?, ?);", table, column, comment)
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (c *Client) GetComments(ctx context.Context, table string) (_ Comments, err error) {
+	if err := c.rlock("GetComments"); err != nil {
+		return Comments{}, err
+	}
+	defer c.runlock("GetComments", &err)
+	comments := Comments{Columns: make(map[string]string)}
+	var comment sql.NullString
+	schema, name := splitTable(table)
+	row := c.db.QueryRowContext(ctx, "SELECT comment FROM duckdb_tables() WHERE database_name = current_database() AND schema_name = coalesce(nullif(?, ''), current_schema()) AND table_name = ?;", schema, name)
+	if err := row.Scan(&comment); err == sql.ErrNoRows {
+		return comments, os.ErrNotExist
+	} else if err != nil {
+		return comments, err
+	}
+	comments.Table = comment.String
+	rows, err := c.db.QueryContext(ctx, "SELECT column_name, comment FROM duckdb_columns() WHERE database_name = current_database() AND schema_name = coalesce(nullif(?, ''), current_schema()) AND table_name = ? AND comment IS NOT NULL;", schema, name)
+	if err != nil {
+		return comments, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name, &comment); err != nil {
+			return comments, err
+		}
+		comments.Columns[name] = comment.String
+	}
+	return comments, rows.Err()
+}
+
+// withComments fills the Comment of each of table's columns from the catalog.
+func withComments(ctx context.Context, db querier, table string, columns []Column) ([]Column, error) {
+	schema, name := splitTable(table)
+	rows, err := db.QueryContext(ctx, "SELECT column_name, comment FROM duckdb_columns() WHERE database_name = current_database() AND schema_name = coalesce(nullif(?, ''), current_schema()) AND table_name = ? AND comment IS NOT NULL;", schema, name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	comments := make(map[string]string)
+	for rows.Next() {
+		var column, comment string
+		if err := rows.Scan(&column, &comment); err != nil {
+			return nil, err
+		}
+		comments[column] = comment
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for i := range columns {
+		columns[i].Comment = comments[columns[i].Name]
+	}
+	return columns, nil
+}
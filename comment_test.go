@@ -0,0 +1,62 @@
+package quack
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Comments(t *testing.T) {
+	dir := t.TempDir()
+	client, err := New(dir, 2)
+	require.NoError(t, err)
+	require.NoError(t, client.Insert(t.Context(), "events", strings.NewReader(`{"id":1,"name":"a"}`)))
+	require.NoError(t, client.SetComment(t.Context(), "events", "source: billing API"))
+	require.NoError(t, client.SetComment(t.Context(), "events.id", "primary id"))
+	require.NoError(t, client.SetComment(t.Context(), "events.name", "temporary"))
+	require.NoError(t, client.SetComment(t.Context(), "events.name", ""))
+	expected := Comments{Table: "source: billing API", Columns: map[string]string{"id": "primary id"}}
+	comments, err := client.GetComments(t.Context(), "events")
+	require.NoError(t, err)
+	require.Equal(t, expected, comments)
+	require.NoError(t, client.Deduplicate(t.Context(), "events"))
+	comments, err = client.GetComments(t.Context(), "events")
+	require.NoError(t, err)
+	require.Equal(t, expected, comments)
+	require.NoError(t, client.Close(t.Context()))
+
+	client, err = New(dir, 2)
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+	require.NoError(t, client.RollbackSnapshot(t.Context(), 1))
+	comments, err = client.GetComments(t.Context(), "events")
+	require.NoError(t, err)
+	require.Equal(t, expected, comments)
+	columns, err := client.Describe(t.Context(), "events")
+	require.NoError(t, err)
+	comment := make(map[string]string)
+	for _, col := range columns {
+		comment[col.Name] = col.Comment
+	}
+	require.Equal(t, map[string]string{"id": "primary id", "name": ""}, comment)
+}
+
+func Test_CommentsQualified(t *testing.T) {
+	client, err := New(t.TempDir(), 1)
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+	require.NoError(t, client.Insert(t.Context(), "events", strings.NewReader(`{"id":1}`)))
+	require.NoError(t, client.Insert(t.Context(), "raw.events", strings.NewReader(`{"id":1}`)))
+	require.NoError(t, client.SetComment(t.Context(), "raw.events", "raw feed"))
+	require.NoError(t, client.SetComment(t.Context(), "raw.events.id", "raw id"))
+	comments, err := client.GetComments(t.Context(), "raw.events")
+	require.NoError(t, err)
+	require.Equal(t, Comments{Table: "raw feed", Columns: map[string]string{"id": "raw id"}}, comments)
+	comments, err = client.GetComments(t.Context(), "events")
+	require.NoError(t, err)
+	require.Equal(t, Comments{Columns: map[string]string{}}, comments)
+	_, err = client.GetComments(t.Context(), "other.events")
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
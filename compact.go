@@ -0,0 +1,89 @@
+package quack
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// ErrResultsOpen is returned by Compact while result sets or appenders are
+// still open, since the database file cannot be replaced underneath them.
+var ErrResultsOpen = errors.New("result sets are still open")
+
+// compact rewrites the database into a fresh file so space freed by deletes
+// is returned to the filesystem. The caller must hold the write lock.
+func (c *Client) compact(ctx context.Context) error {
+	if n := c.inFlight(); n > 0 {
+		return fmt.Errorf("compact: %w (%d in flight)", ErrResultsOpen, n)
+	}
+	if _, err := c.db.ExecContext(ctx, "FORCE CHECKPOINT;"); err != nil {
+		return err
+	}
+	tmp := c.dbPath() + ".compact"
+	if err := os.Remove(tmp); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	defer os.Remove(tmp)
+	stmts := []string{
+		fmt.Sprintf("ATTACH %s AS quack_compact;", quoteLiteral(tmp)),
+		"COPY FROM DATABASE " + mainDatabase + " TO quack_compact;",
+		"DETACH quack_compact;",
+	}
+	for _, stmt := range stmts {
+		if _, err := c.db.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	if err := c.closeDatabases(); err != nil {
+		return err
+	}
+	c.plans.reset()
+	if err := c.db.Close(); err != nil {
+		return err
+	}
+	if err := c.connecter.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, c.dbPath()); err != nil {
+		return err
+	}
+	if err := os.Remove(c.dbPath() + ".wal"); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return c.open(ctx)
+}
+
+func (c *Client) maybeCompact(ctx context.Context) error {
+	if c.autoCompact <= 0 {
+		return nil
+	}
+	if _, err := c.db.ExecContext(ctx, "CHECKPOINT;"); err != nil {
+		return err
+	}
+	s, err := c.stats(ctx)
+	if err != nil {
+		return err
+	}
+	if s.WasteRatio() <= c.autoCompact {
+		return nil
+	}
+	if n := c.inFlight(); n > 0 {
+		// The next delete tries again once the result sets are closed.
+		c.logger.Info("deferring compaction", "in_flight", n)
+		return nil
+	}
+	c.logger.Info("compacting database", "file_size", s.FileSize, "used_size", s.UsedSize)
+	return c.compact(ctx)
+}
+
+// Compact checkpoints the database and rewrites it into a new file,
+// reclaiming space left behind by deleted rows. It fails with
+// ErrResultsOpen while rows returned by Query are still open.
+func (c *Client) Compact(ctx context.Context) (err error) {
+	if err := c.lock("Compact"); err != nil {
+		return err
+	}
+	defer c.unlock("Compact", &err)
+	return c.compact(ctx)
+}
@@ -0,0 +1,62 @@
+package quack
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_AutoCompact(t *testing.T) {
+	client, err := New(t.TempDir(), 1, WithAutoCompact(0.5))
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+	rows, err := client.Query(t.Context(), "CREATE TABLE t AS SELECT range AS id, md5(range::VARCHAR) || md5((range * 7)::VARCHAR) AS s FROM range(500000);")
+	require.NoError(t, err)
+	require.NoError(t, rows.Close())
+	rows, err = client.Query(t.Context(), "CHECKPOINT;")
+	require.NoError(t, err)
+	require.NoError(t, rows.Close())
+	before, err := client.Stats(t.Context())
+	require.NoError(t, err)
+
+	n, err := client.DeleteWhere(t.Context(), "t", "id >= ?", 1000)
+	require.NoError(t, err)
+	require.Equal(t, int64(499000), n)
+	after, err := client.Stats(t.Context())
+	require.NoError(t, err)
+	require.Less(t, after.FileSize, before.FileSize/2)
+
+	var count int
+	row, err := client.Query(t.Context(), "SELECT count(*) FROM t;")
+	require.NoError(t, err)
+	require.True(t, row.Next())
+	require.NoError(t, row.Scan(&count))
+	require.NoError(t, row.Close())
+	require.Equal(t, 1000, count)
+}
+
+func Test_CompactOpenRows(t *testing.T) {
+	client, err := New(filepath.Join(t.TempDir(), "it's"), 1, WithAutoCompact(0.1))
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+	rows, err := client.Query(t.Context(), "CREATE TABLE t AS SELECT range AS id, md5(range::VARCHAR) AS s FROM range(200000);")
+	require.NoError(t, err)
+	require.NoError(t, rows.Close())
+
+	open, err := client.Query(t.Context(), "SELECT id FROM t ORDER BY id;")
+	require.NoError(t, err)
+	require.True(t, open.Next())
+	n, err := client.DeleteWhere(t.Context(), "t", "id >= ?", 1000)
+	require.NoError(t, err)
+	require.Equal(t, int64(199000), n)
+	require.ErrorIs(t, client.Compact(t.Context()), ErrResultsOpen)
+	count := 1
+	for open.Next() {
+		count++
+	}
+	require.NoError(t, open.Err())
+	require.NoError(t, open.Close())
+	require.Equal(t, 200000, count)
+	require.NoError(t, client.Compact(t.Context()))
+}
@@ -0,0 +1,53 @@
+package quack
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// decompress returns r decompressed when it starts with the magic bytes of
+// gzip or zstd, and r itself otherwise. Sniffing only peeks at the input.
+func decompress(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	magic, _ := br.Peek(len(zstdMagic))
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("gzip input: %w", err)
+		}
+		return &decompressor{ReadCloser: zr, format: "gzip"}, nil
+	case bytes.HasPrefix(magic, zstdMagic):
+		d, err := zstd.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("zstd input: %w", err)
+		}
+		return &decompressor{ReadCloser: d.IOReadCloser(), format: "zstd"}, nil
+	}
+	return io.NopCloser(br), nil
+}
+
+// decompressor names the compression format in read errors, which are
+// otherwise as terse as "unexpected EOF" for a truncated stream.
+type decompressor struct {
+	io.ReadCloser
+	format string
+}
+
+func (d *decompressor) Read(p []byte) (int, error) {
+	n, err := d.ReadCloser.Read(p)
+	if err != nil && err != io.EOF {
+		err = fmt.Errorf("%s input: %w", d.format, err)
+	}
+	return n, err
+}
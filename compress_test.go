@@ -0,0 +1,51 @@
+package quack
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_InsertCompressed(t *testing.T) {
+	staging := t.TempDir()
+	client, err := New(t.TempDir(), 1, WithStagingDir(staging))
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+	input := strings.Repeat(`{"id":1}`+"\n", 100)
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	_, err = zw.Write([]byte(input))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	var zst bytes.Buffer
+	enc, err := zstd.NewWriter(&zst)
+	require.NoError(t, err)
+	_, err = enc.Write([]byte(input))
+	require.NoError(t, err)
+	require.NoError(t, enc.Close())
+
+	for _, r := range []*bytes.Reader{bytes.NewReader(gz.Bytes()), bytes.NewReader(zst.Bytes()), bytes.NewReader([]byte(input))} {
+		res, err := client.Ingest(t.Context(), "events", r)
+		require.NoError(t, err)
+		require.Equal(t, int64(100), res.Rows)
+		require.Equal(t, int64(len(input)), res.Bytes)
+	}
+	res, err := client.InsertStream(t.Context(), "events", bytes.NewReader(gz.Bytes()))
+	require.NoError(t, err)
+	require.Equal(t, int64(100), res.Rows)
+	require.NoError(t, client.InsertCSV(t.Context(), "short", strings.NewReader("1"), CSVOptions{}))
+
+	truncated := gz.Bytes()[:gz.Len()-10]
+	require.ErrorContains(t, client.Insert(t.Context(), "fresh", bytes.NewReader(truncated)), "gzip input: unexpected EOF")
+	_, err = client.InsertStream(t.Context(), "fresh", bytes.NewReader(truncated))
+	require.ErrorContains(t, err, "gzip input")
+	require.Error(t, tableExists(t.Context(), client.db, "fresh"))
+	entries, err := os.ReadDir(staging)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
@@ -0,0 +1,104 @@
+package quack
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+type copyTableConfig struct {
+	replace bool
+}
+
+type CopyTableOption func(*copyTableConfig)
+
+// ReplaceTable makes CopyTableTo replace a destination table that already
+// exists instead of appending to it.
+func ReplaceTable() CopyTableOption {
+	return func(c *copyTableConfig) { c.replace = true }
+}
+
+// CopyTableTo copies table into the database of dst through a Parquet file
+// in the staging directory. A destination table that does not exist is
+// created with the columns of the source, including their types and NOT
+// NULL constraints, though generated columns arrive as stored values. An
+// existing table is appended to by column name unless ReplaceTable is set.
+// The clients are locked one after the other, never both at once.
+func (c *Client) CopyTableTo(ctx context.Context, dst *Client, table string, opts ...CopyTableOption) error {
+	if dst == c {
+		return errors.New("cannot copy a table into its own client")
+	}
+	var cfg copyTableConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	file, columns, err := c.exportTable(ctx, table)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file)
+	_, err = dst.ingest([]InsertOption{withFormat(FormatParquet)}, func(icfg insertConfig) (InsertResult, error) {
+		tx, err := begin(ctx, dst.db)
+		if err != nil {
+			return InsertResult{}, err
+		}
+		defer tx.rollback()
+		if cfg.replace {
+			if _, err := tx.ExecContext(ctx, fmt.Sprintf("DROP TABLE IF EXISTS %s;", table)); err != nil {
+				return InsertResult{}, err
+			}
+		}
+		created := false
+		if err := tableExists(ctx, tx, table); os.IsNotExist(err) {
+			if err := createSchema(ctx, tx, table); err != nil {
+				return InsertResult{}, err
+			}
+			defs := make([]string, len(columns))
+			for i, col := range columns {
+				defs[i] = col.definition()
+			}
+			if _, err := tx.ExecContext(ctx, fmt.Sprintf("CREATE TABLE %s (%s);", table, strings.Join(defs, ", "))); err != nil {
+				return InsertResult{}, err
+			}
+			if err := recordSchema(ctx, tx, table, "create"); err != nil {
+				return InsertResult{}, err
+			}
+			created = true
+		} else if err != nil {
+			return InsertResult{}, err
+		}
+		res, err := insertFile(ctx, tx, dst.stagingDir, table, file, icfg)
+		if err != nil {
+			return res, err
+		}
+		res.Created = created
+		return res, tx.commit()
+	})
+	return err
+}
+
+// exportTable writes table to a Parquet file in the staging directory and
+// returns it with the columns of the table.
+func (c *Client) exportTable(ctx context.Context, table string) (_ string, _ []Column, err error) {
+	if err := c.lock("CopyTableTo"); err != nil {
+		return "", nil, err
+	}
+	defer c.unlock("CopyTableTo", &err)
+	columns, err := describe(ctx, c.db, table)
+	if err != nil {
+		return "", nil, err
+	}
+	f, err := os.CreateTemp(c.stagingDir, dumpPrefix)
+	if err != nil {
+		return "", nil, err
+	}
+	f.Close()
+	stmt := fmt.Sprintf("COPY %s TO %s (FORMAT parquet);", table, quoteLiteral(f.Name()))
+	if _, err := c.db.ExecContext(ctx, stmt); err != nil {
+		os.Remove(f.Name())
+		return "", nil, err
+	}
+	return f.Name(), columns, nil
+}
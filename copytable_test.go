@@ -0,0 +1,48 @@
+package quack
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_CopyTableTo(t *testing.T) {
+	src, err := New(t.TempDir(), 1)
+	require.NoError(t, err)
+	defer src.Close(t.Context())
+	dst, err := New(t.TempDir(), 1, WithJournal())
+	require.NoError(t, err)
+	defer dst.Close(t.Context())
+	columns := []Column{
+		{Name: "id", Type: "INTEGER"},
+		{Name: "at", Type: "TIMESTAMP", Nullable: true},
+		{Name: "price", Type: "DECIMAL(10,2)", Nullable: true},
+		{Name: "doubled", Type: "INTEGER", Nullable: true, GeneratedAs: "id * 2"},
+	}
+	require.NoError(t, src.CreateTable(t.Context(), "items", columns))
+	require.NoError(t, src.Insert(t.Context(), "items", strings.NewReader(`{"id":1,"at":"2024-01-01 10:00:00","price":1.25}`+"\n"+`{"id":2}`)))
+
+	require.NoError(t, src.CopyTableTo(t.Context(), dst, "items"))
+	got, err := describe(t.Context(), dst.db, "items")
+	require.NoError(t, err)
+	columns[3].GeneratedAs = ""
+	require.Equal(t, columns, got)
+	rows := func() string {
+		var s string
+		require.NoError(t, dst.db.QueryRow("SELECT string_agg(concat_ws(':', id, \"at\", price, doubled), ',' ORDER BY rowid) FROM items;").Scan(&s))
+		return s
+	}
+	require.Equal(t, "1:2024-01-01 10:00:00:1.25:2,2:4", rows())
+
+	require.NoError(t, src.CopyTableTo(t.Context(), dst, "items"))
+	require.Equal(t, "1:2024-01-01 10:00:00:1.25:2,2:4,1:2024-01-01 10:00:00:1.25:2,2:4", rows())
+	require.NoError(t, src.CopyTableTo(t.Context(), dst, "items", ReplaceTable()))
+	require.Equal(t, "1:2024-01-01 10:00:00:1.25:2,2:4", rows())
+	stats, err := dst.Stats(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, int64(6), stats.Tables["items"].RowsInserted)
+
+	require.Error(t, src.CopyTableTo(t.Context(), dst, "missing"))
+	require.Error(t, src.CopyTableTo(t.Context(), src, "items"))
+}
@@ -0,0 +1,236 @@
+package quack
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"math/big"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/duckdb/duckdb-go/v2"
+)
+
+// CSVOptions describes CSV input. The zero value reads comma separated
+// input without a header, detecting quoting and column types as
+// read_csv_auto does.
+type CSVOptions struct {
+	// Delimiter separates the fields, ',' when zero.
+	Delimiter rune
+	// Quote encloses fields holding delimiters or newlines, and Escape
+	// escapes a quote within them. Both are detected when zero.
+	Quote  rune
+	Escape rune
+	// Header is set when the first line names the columns.
+	Header bool
+	// SkipRows is the number of lines before the header or first row to
+	// skip, such as a title line.
+	SkipRows int
+	// NullString is the field value read as NULL, e.g. `\N`. Empty fields
+	// are NULL when it is empty.
+	NullString string
+	// Encoding is "utf-8", the default, "utf-16" or "latin-1".
+	Encoding string
+	// Columns overrides the detected types of the named columns, e.g.
+	// {"zip": "VARCHAR"} to keep leading zeros.
+	Columns map[string]string
+}
+
+var csvEncodings = []string{"utf-8", "utf-16", "latin-1"}
+
+// validate rejects options read_csv cannot take.
+func (o CSVOptions) validate() error {
+	for _, c := range []struct {
+		name string
+		r    rune
+	}{{"delimiter", o.Delimiter}, {"quote", o.Quote}, {"escape", o.Escape}} {
+		switch {
+		case c.r == 0:
+		case c.r >= utf8.RuneSelf:
+			return fmt.Errorf("csv %s %q is not a single byte", c.name, c.r)
+		case c.r == '\n' || c.r == '\r':
+			return fmt.Errorf("csv %s cannot be a newline", c.name)
+		}
+	}
+	if o.Delimiter != 0 && (o.Delimiter == o.Quote || o.Delimiter == o.Escape) {
+		return fmt.Errorf("csv delimiter %q is also the quote or escape", o.Delimiter)
+	}
+	if o.SkipRows < 0 {
+		return fmt.Errorf("csv skip rows %d is negative", o.SkipRows)
+	}
+	if o.Encoding != "" && !slices.Contains(csvEncodings, strings.ToLower(o.Encoding)) {
+		return fmt.Errorf("csv encoding %q is not one of %s", o.Encoding, strings.Join(csvEncodings, ", "))
+	}
+	for name, typ := range o.Columns {
+		if name == "" || typ == "" {
+			return fmt.Errorf("csv column %q needs a name and a type", name)
+		}
+	}
+	return nil
+}
+
+// params lists the options with the names read_csv and COPY share, each
+// joined to its value by assign.
+func (o CSVOptions) params(assign string) string {
+	params := []string{"header" + assign + strconv.FormatBool(o.Header)}
+	for _, c := range []struct {
+		name string
+		r    rune
+	}{{"delim", o.Delimiter}, {"quote", o.Quote}, {"escape", o.Escape}} {
+		if c.r != 0 {
+			params = append(params, c.name+assign+quoteLiteral(string(c.r)))
+		}
+	}
+	if o.SkipRows > 0 {
+		params = append(params, "skip"+assign+strconv.Itoa(o.SkipRows))
+	}
+	if o.NullString != "" {
+		params = append(params, "nullstr"+assign+quoteLiteral(o.NullString))
+	}
+	if o.Encoding != "" {
+		params = append(params, "encoding"+assign+quoteLiteral(strings.ToLower(o.Encoding)))
+	}
+	return strings.Join(params, ", ")
+}
+
+// readParams is params for read_csv, which also takes the column types.
+func (o CSVOptions) readParams() string {
+	if len(o.Columns) == 0 {
+		return o.params("=")
+	}
+	types := make([]string, 0, len(o.Columns))
+	for _, name := range slices.Sorted(maps.Keys(o.Columns)) {
+		types = append(types, quoteLiteral(name)+": "+quoteLiteral(o.Columns[name]))
+	}
+	return o.params("=") + ", types={" + strings.Join(types, ", ") + "}"
+}
+
+// WithCSV reads the input as CSV described by opts.
+func WithCSV(opts CSVOptions) InsertOption {
+	return func(c *insertConfig) {
+		c.format = FormatCSV
+		c.csv = &opts
+	}
+}
+
+// InsertCSV is Insert for CSV input. A table that does not exist yet is
+// created with the column types DuckDB detects.
+func (c *Client) InsertCSV(ctx context.Context, table string, r io.Reader, csv CSVOptions, opts ...InsertOption) error {
+	return c.Insert(ctx, table, r, append(opts, WithCSV(csv))...)
+}
+
+// CSVError is returned for CSV input DuckDB cannot read.
+type CSVError struct {
+	// Line is the line of the input DuckDB reported.
+	Line   int64
+	Reason string
+	Err    error
+}
+
+func (e *CSVError) Error() string {
+	return fmt.Sprintf("csv line %d: %s", e.Line, e.Reason)
+}
+
+func (e *CSVError) Unwrap() error { return e.Err }
+
+var csvErrorLine = regexp.MustCompile(`CSV Error on Line: (\d+)\n(?:Original Line: .*\n)?(.*)`)
+
+// csvError turns a DuckDB error about CSV input into a CSVError.
+func csvError(err error) error {
+	m := csvErrorLine.FindStringSubmatch(err.Error())
+	if m == nil {
+		return err
+	}
+	line, _ := strconv.ParseInt(m[1], 10, 64)
+	return &CSVError{Line: line, Reason: strings.TrimSpace(m[2]), Err: err}
+}
+
+// CSVWriteOptions describes the CSV QueryCSV writes. The zero value writes
+// comma separated rows without a header, with NULL as an empty field.
+type CSVWriteOptions struct {
+	// Header writes the column names as the first line.
+	Header bool
+	// Delimiter separates the fields, ',' when zero.
+	Delimiter rune
+	// NullString is written for NULL values.
+	NullString string
+}
+
+// QueryCSV runs stmt with args and writes its result to w as CSV, quoting
+// fields holding delimiters, quotes or newlines. Rows are written as they
+// are read; the client lock is only held to start the query.
+func (c *Client) QueryCSV(ctx context.Context, w io.Writer, stmt string, opts CSVWriteOptions, args ...any) error {
+	rows, _, err := c.streamQuery(ctx, "QueryCSV", stmt, args)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	cw := csv.NewWriter(w)
+	if opts.Delimiter != 0 {
+		cw.Comma = opts.Delimiter
+	}
+	if opts.Header {
+		if err := cw.Write(columns); err != nil {
+			return err
+		}
+	}
+	vals := make([]any, len(columns))
+	ptrs := make([]any, len(columns))
+	for i := range vals {
+		ptrs[i] = &vals[i]
+	}
+	record := make([]string, len(columns))
+	for rows.Next() {
+		if err := rows.Scan(ptrs...); err != nil {
+			return err
+		}
+		for i, v := range vals {
+			if record[i], err = csvField(v, opts.NullString); err != nil {
+				return fmt.Errorf("column %s: %w", columns[i], err)
+			}
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return err
+	}
+	return rows.Close()
+}
+
+// csvField formats a value scanned from DuckDB as a CSV field. Nested
+// values are written as JSON.
+func csvField(v any, null string) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return null, nil
+	case string:
+		return v, nil
+	case []byte:
+		return string(v), nil
+	case time.Time:
+		return v.Format("2006-01-02 15:04:05.999999999"), nil
+	case *big.Int, duckdb.Decimal, duckdb.UUID:
+		return fmt.Sprint(v), nil
+	case []any, map[string]any, duckdb.Map, duckdb.Union:
+		b, err := json.Marshal(jsonValue(v))
+		return string(b), err
+	}
+	return fmt.Sprint(v), nil
+}
@@ -0,0 +1,122 @@
+package quack
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_InsertCSV(t *testing.T) {
+	dir := t.TempDir()
+	client, err := New(dir, 3, WithJournal())
+	require.NoError(t, err)
+	require.NoError(t, client.Close(t.Context()))
+	client, err = New(dir, 3, WithJournal())
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+	opts := CSVOptions{Delimiter: ';', Header: true, NullString: `\N`}
+	require.NoError(t, client.InsertCSV(t.Context(), "people", strings.NewReader("id;name\n1;a\n2;\\N\n"), opts))
+	require.NoError(t, client.InsertCSV(t.Context(), "people", strings.NewReader("id;name\n3;c\n"), opts))
+	res, err := client.Ingest(t.Context(), "people", strings.NewReader("4,d\n"), WithCSV(CSVOptions{}))
+	require.NoError(t, err)
+	require.Equal(t, int64(1), res.Rows)
+	columns, err := describe(t.Context(), client.db, "people")
+	require.NoError(t, err)
+	require.Equal(t, []Column{{Name: "id", Type: "BIGINT", Nullable: true}, {Name: "name", Type: "VARCHAR", Nullable: true}}, columns)
+	names := func(c *Client) string {
+		rows, err := c.Query(t.Context(), "SELECT string_agg(coalesce(name, 'NULL'), ',' ORDER BY id) FROM people;")
+		require.NoError(t, err)
+		defer rows.Close()
+		require.True(t, rows.Next())
+		var s string
+		require.NoError(t, rows.Scan(&s))
+		return s
+	}
+	require.Equal(t, "a,NULL,c,d", names(client))
+
+	err = client.InsertCSV(t.Context(), "people", strings.NewReader("id;name\n5;e\nx;f\n"), opts)
+	var csvErr *CSVError
+	require.ErrorAs(t, err, &csvErr)
+	require.Equal(t, int64(3), csvErr.Line)
+	require.Contains(t, err.Error(), "csv line 3: ")
+	require.Contains(t, csvErr.Reason, `column "id"`)
+	require.Equal(t, "a,NULL,c,d", names(client))
+
+	// Journal replay reads the input with the options it was inserted with.
+	require.NoError(t, client.RollbackSnapshot(t.Context(), 1))
+	n, err := client.RecoverJournal(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, 3, n)
+	require.Equal(t, "a,NULL,c,d", names(client))
+}
+
+func Test_CSVOptions(t *testing.T) {
+	client, err := New(t.TempDir(), 1)
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+	opts := CSVOptions{Delimiter: '|', Quote: '\'', Escape: '\'', Header: true, SkipRows: 1, NullString: `\N`, Columns: map[string]string{"zip": "VARCHAR"}}
+	data := "exported 2024-01-01\nid|name|zip\n1|'a|b'|01234\n2|'c''d'|\\N\n"
+	require.NoError(t, client.InsertCSV(t.Context(), "people", strings.NewReader(data), opts))
+	require.NoError(t, client.InsertCSV(t.Context(), "people", strings.NewReader(data), opts))
+	columns, err := describe(t.Context(), client.db, "people")
+	require.NoError(t, err)
+	require.Equal(t, []Column{{Name: "id", Type: "BIGINT", Nullable: true}, {Name: "name", Type: "VARCHAR", Nullable: true}, {Name: "zip", Type: "VARCHAR", Nullable: true}}, columns)
+	var got string
+	require.NoError(t, client.db.QueryRow("SELECT string_agg(concat_ws(':', id, name, coalesce(zip, 'null')), ',' ORDER BY rowid) FROM people;").Scan(&got))
+	require.Equal(t, "1:a|b:01234,2:c'd:null,1:a|b:01234,2:c'd:null", got)
+
+	require.NoError(t, client.InsertCSV(t.Context(), "latin", strings.NewReader("name\ncaf\xe9\n"), CSVOptions{Header: true, Encoding: "latin-1"}))
+	require.NoError(t, client.db.QueryRow("SELECT name FROM latin;").Scan(&got))
+	require.Equal(t, "café", got)
+
+	for _, tc := range []struct {
+		opts CSVOptions
+		err  string
+	}{
+		{CSVOptions{Delimiter: '§'}, "not a single byte"},
+		{CSVOptions{Quote: '\n'}, "cannot be a newline"},
+		{CSVOptions{Delimiter: '"', Quote: '"'}, "also the quote"},
+		{CSVOptions{SkipRows: -1}, "negative"},
+		{CSVOptions{Encoding: "ebcdic"}, "not one of"},
+		{CSVOptions{Columns: map[string]string{"zip": ""}}, "needs a name and a type"},
+	} {
+		require.ErrorContains(t, client.InsertCSV(t.Context(), "invalid", strings.NewReader("1\n"), tc.opts), tc.err)
+	}
+	err = client.Insert(t.Context(), "invalid", strings.NewReader("1\n"), WithCSV(CSVOptions{Columns: map[string]string{"a": "INTEGER"}}), WithColumns([]Column{{Name: "a", Type: "INTEGER"}}))
+	require.ErrorContains(t, err, "cannot be combined")
+	require.Error(t, tableExists(t.Context(), client.db, "invalid"))
+}
+
+func Test_QueryCSV(t *testing.T) {
+	client, err := New(t.TempDir(), 1)
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+	var buf strings.Builder
+	stmt := `SELECT * FROM (VALUES (1, 'a,b', 'say "hi"', 'two' || chr(10) || 'lines', TIMESTAMP '2024-01-02 03:04:05', 1.50::DECIMAL(5,2), [1, 2]),
+		(2, NULL, '', 'x', NULL, NULL, NULL)) t(id, c, q, n, ts, d, l) WHERE id <= ? ORDER BY id;`
+	require.NoError(t, client.QueryCSV(t.Context(), &buf, stmt, CSVWriteOptions{Header: true, NullString: `\N`}, 2))
+	require.Equal(t, "id,c,q,n,ts,d,l\n1,\"a,b\",\"say \"\"hi\"\"\",\"two\nlines\",2024-01-02 03:04:05,1.5,\"[1,2]\"\n2,\\N,,x,\\N,\\N,\\N\n", buf.String())
+
+	buf.Reset()
+	require.NoError(t, client.QueryCSV(t.Context(), &buf, "SELECT 1 AS a, 'x;y' AS b;", CSVWriteOptions{Delimiter: ';'}))
+	require.Equal(t, "1;\"x;y\"\n", buf.String())
+
+	t.Run("cancel", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(t.Context())
+		w := &cancelWriter{cancel: cancel}
+		err := client.QueryCSV(ctx, w, "SELECT range AS i FROM range(100000000);", CSVWriteOptions{})
+		require.ErrorIs(t, err, context.Canceled)
+		require.NoError(t, client.QueryCSV(t.Context(), io.Discard, "SELECT 1;", CSVWriteOptions{}))
+	})
+}
+
+// cancelWriter cancels a context on its first write.
+type cancelWriter struct{ cancel context.CancelFunc }
+
+func (w *cancelWriter) Write(p []byte) (int, error) {
+	w.cancel()
+	return len(p), nil
+}
@@ -0,0 +1,209 @@
+package quack
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/duckdb/duckdb-go/v2"
+)
+
+var reservedDatabases = []string{mainDatabase, cacheDatabase, "main", "memory", "system", "temp"}
+
+// useConnector opens connections to the shared DuckDB instance that default
+// to an attached database, so unqualified names resolve inside it.
+type useConnector struct {
+	*duckdb.Connector
+	catalog string
+}
+
+// Close leaves the shared connector open: closing the pool of a database
+// must not close it under the client.
+func (useConnector) Close() error { return nil }
+
+func (u useConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := u.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.(driver.ExecerContext).ExecContext(ctx, "USE "+quoteIdent(u.catalog)+";", nil); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+func (c *Client) databaseDir(name string) string {
+	return filepath.Join(c.dir, "databases", name)
+}
+
+func (c *Client) databaseSnapshotDir(name string) string {
+	return filepath.Join(c.databaseDir(name), "snapshot")
+}
+
+func (c *Client) attachDatabase(ctx context.Context, conn *sql.Conn, name string) error {
+	file := filepath.Join(c.databaseDir(name), "database.ddb")
+	if _, err := conn.ExecContext(ctx, fmt.Sprintf("ATTACH %s AS %s;", quoteLiteral(file), quoteIdent(name))); err != nil {
+		return err
+	}
+	c.databases[name] = sql.OpenDB(useConnector{Connector: c.connecter, catalog: name})
+	return nil
+}
+
+// openDatabases attaches every database created by CreateDatabase.
+func (c *Client) openDatabases(ctx context.Context, conn *sql.Conn) error {
+	c.databases = make(map[string]*sql.DB)
+	names, err := listDir(filepath.Join(c.dir, "databases"))
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if err := c.attachDatabase(ctx, conn, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Client) closeDatabases() error {
+	for name, db := range c.databases {
+		if err := db.Close(); err != nil {
+			return err
+		}
+		delete(c.databases, name)
+	}
+	return nil
+}
+
+// CreateDatabase creates a separate database file under the client
+// directory and attaches it as name. It is reattached by New, snapshotted
+// into its own snapshot directory on Close and accessed through On.
+func (c *Client) CreateDatabase(ctx context.Context, name string) (err error) {
+	if sanitizeTable(name) != name || slices.Contains(reservedDatabases, name) {
+		return fmt.Errorf("invalid database name %q", name)
+	}
+	if err := c.lock("CreateDatabase"); err != nil {
+		return err
+	}
+	defer c.unlock("CreateDatabase", &err)
+	if _, ok := c.databases[name]; ok {
+		return fmt.Errorf("database %s already exists", name)
+	}
+	if err := os.MkdirAll(c.databaseSnapshotDir(name), 0755); err != nil {
+		return err
+	}
+	conn, err := c.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return c.attachDatabase(ctx, conn, name)
+}
+
+func (c *Client) Databases() []string {
+	c.acquire()
+	defer c.release()
+	names := make([]string, 0, len(c.databases))
+	for name := range c.databases {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Database is a handle on a database created by CreateDatabase.
+type Database struct {
+	c    *Client
+	name string
+}
+
+// On returns a handle on the database name. Calls on it fail if the
+// database does not exist.
+func (c *Client) On(name string) *Database {
+	return &Database{c: c, name: name}
+}
+
+// db returns the pool of the database. The caller must hold the lock.
+func (d *Database) db() (*sql.DB, error) {
+	db, ok := d.c.databases[d.name]
+	if !ok {
+		return nil, fmt.Errorf("database %s: %w", d.name, os.ErrNotExist)
+	}
+	return db, nil
+}
+
+// Insert is Client.Insert into the database. The disk budget applies, but
+// inserts are not journaled, and they are not counted in Stats, whose
+// tables are those of the client database.
+func (d *Database) Insert(ctx context.Context, table string, r io.Reader, opts ...InsertOption) (err error) {
+	cfg := d.c.ingestConfig(opts)
+	cfg.journal = nil
+	if err := cfg.validate(); err != nil {
+		return err
+	}
+	if err := d.c.lock("Database.Insert"); err != nil {
+		return err
+	}
+	defer d.c.unlock("Database.Insert", &err)
+	db, err := d.db()
+	if err != nil {
+		return err
+	}
+	_, err = insert(ctx, db, d.c.stagingDir, table, r, cfg)
+	return err
+}
+
+func (d *Database) Query(ctx context.Context, stmt string, args ...any) (_ *sql.Rows, err error) {
+	if err := d.c.rlock("Database.Query"); err != nil {
+		return nil, err
+	}
+	defer d.c.runlock("Database.Query", &err)
+	db, err := d.db()
+	if err != nil {
+		return nil, err
+	}
+	return db.QueryContext(d.c.opContext(ctx), stmt, args...)
+}
+
+func (d *Database) Deduplicate(ctx context.Context, table string, opts ...DedupOption) (err error) {
+	cfg := newDedupConfig(opts)
+	if err := d.c.lock("Database.Deduplicate"); err != nil {
+		return err
+	}
+	defer d.c.unlock("Database.Deduplicate", &err)
+	db, err := d.db()
+	if err != nil {
+		return err
+	}
+	_, err = dedupTx(ctx, db, table, cfg.orderBy)
+	return err
+}
+
+// RollbackSnapshot restores only this database from its n-th newest
+// snapshot.
+func (d *Database) RollbackSnapshot(ctx context.Context, n int, opts ...RestoreOption) (err error) {
+	if n > d.c.n {
+		return fmt.Errorf("cannot rollback to last %d snapshot (max: %d)", n, d.c.n)
+	}
+	cfg := newRestoreConfig(opts)
+	p := cfg.notifier()
+	defer p.wait()
+	if err := d.c.lock("Database.RollbackSnapshot"); err != nil {
+		return err
+	}
+	defer d.c.unlock("Database.RollbackSnapshot", &err)
+	db, err := d.db()
+	if err != nil {
+		return err
+	}
+	return withSetting(ctx, db, "threads", cfg.threads(), func() error {
+		return rollback(ctx, db, d.c.stagingDir, d.c.databaseSnapshotDir(d.name), n, p)
+	})
+}
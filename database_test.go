@@ -0,0 +1,113 @@
+package quack
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Databases(t *testing.T) {
+	dir := t.TempDir()
+	count := func(t *testing.T, query func() (int, error)) int {
+		t.Helper()
+		n, err := query()
+		require.NoError(t, err)
+		return n
+	}
+	tenantCount := func(client *Client) func() (int, error) {
+		return func() (int, error) {
+			rows, err := client.On("tenant_a").Query(t.Context(), "SELECT count(*) FROM events;")
+			if err != nil {
+				return 0, err
+			}
+			defer rows.Close()
+			var n int
+			rows.Next()
+			return n, rows.Scan(&n)
+		}
+	}
+	client, err := New(dir, 3)
+	require.NoError(t, err)
+	require.Error(t, client.CreateDatabase(t.Context(), "main"))
+	require.Error(t, client.CreateDatabase(t.Context(), "bad name"))
+	require.NoError(t, client.CreateDatabase(t.Context(), "tenant_a"))
+	require.Error(t, client.CreateDatabase(t.Context(), "tenant_a"))
+	require.NoError(t, client.On("tenant_a").Insert(t.Context(), "events", strings.NewReader(`{"id":1}`)))
+	require.Error(t, client.On("tenant_b").Insert(t.Context(), "events", strings.NewReader(`{"id":1}`)))
+	require.NoError(t, client.Insert(t.Context(), "main_only", strings.NewReader(`{"id":1}`)))
+	_, err = client.Query(t.Context(), "SELECT * FROM events;")
+	require.Error(t, err)
+	require.NoError(t, client.Close(t.Context()))
+
+	client, err = New(dir, 3)
+	require.NoError(t, err)
+	require.Equal(t, []string{"tenant_a"}, client.Databases())
+	require.Equal(t, 1, count(t, tenantCount(client)))
+	require.NoError(t, client.On("tenant_a").Insert(t.Context(), "events", strings.NewReader(`{"id":2}`)))
+	require.NoError(t, client.Close(t.Context()))
+	expectSnapshots(t, dir, 2)
+	files, err := listDir(client.databaseSnapshotDir("tenant_a"))
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+
+	client, err = New(dir, 3)
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+	require.Equal(t, 2, count(t, tenantCount(client)))
+	require.NoError(t, client.On("tenant_a").RollbackSnapshot(t.Context(), 2))
+	require.Equal(t, 1, count(t, tenantCount(client)))
+	rows, err := client.Query(t.Context(), "SELECT * FROM main_only;")
+	require.NoError(t, err)
+	require.NoError(t, rows.Close())
+}
+
+func Test_DatabasePoolClose(t *testing.T) {
+	client, err := New(t.TempDir(), 1, WithDiskBudget(1<<30))
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+	require.NoError(t, client.CreateDatabase(t.Context(), "tenant_a"))
+	require.NoError(t, client.On("tenant_a").Insert(t.Context(), "events", strings.NewReader(`{"id":1}`)))
+
+	// Closing the pool of a database leaves the client's connector open.
+	require.NoError(t, client.databases["tenant_a"].Close())
+	client.db.SetMaxIdleConns(0)
+	conn, err := client.db.Conn(t.Context())
+	require.NoError(t, err)
+	var n int
+	require.NoError(t, conn.QueryRowContext(t.Context(), "SELECT count(*) FROM tenant_a.events;").Scan(&n))
+	require.Equal(t, 1, n)
+	require.NoError(t, conn.Close())
+	delete(client.databases, "tenant_a")
+
+	budget, err := New(t.TempDir(), 1, WithDiskBudget(1))
+	require.NoError(t, err)
+	defer budget.Close(t.Context())
+	require.NoError(t, budget.CreateDatabase(t.Context(), "tenant_a"))
+	require.ErrorIs(t, budget.On("tenant_a").Insert(t.Context(), "events", strings.NewReader(`{"id":1}`)), ErrQuotaExceeded)
+}
+
+func Test_DatabaseQuotedPath(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "it's")
+	client, err := New(dir, 1)
+	require.NoError(t, err)
+	require.NoError(t, client.CreateDatabase(t.Context(), "tenant_a"))
+	require.NoError(t, client.On("tenant_a").Insert(t.Context(), "events", strings.NewReader(`{"id":1}`)))
+	require.NoError(t, client.Close(t.Context()))
+
+	client, err = New(dir, 1)
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+	// Queries share the client lock with other reads.
+	require.NoError(t, client.rlock("test"))
+	rows, err := client.On("tenant_a").Query(t.Context(), "SELECT count(*) FROM events;")
+	var noErr error
+	client.runlock("test", &noErr)
+	require.NoError(t, err)
+	defer rows.Close()
+	var n int
+	require.True(t, rows.Next())
+	require.NoError(t, rows.Scan(&n))
+	require.Equal(t, 1, n)
+}
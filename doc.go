@@ -0,0 +1,138 @@
+package quack
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Doc is a document stored with PutDoc.
+type Doc struct {
+	Key     string
+	Doc     json.RawMessage
+	Updated time.Time
+}
+
+// PutDoc stores doc, encoded as JSON, under key in collection, replacing
+// the document already stored under key. The collection is a table with a
+// primary key on key, created on first use.
+func (c *Client) PutDoc(ctx context.Context, collection, key string, doc any) (err error) {
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	if err := c.lock("PutDoc"); err != nil {
+		return err
+	}
+	defer c.unlock("PutDoc", &err)
+	if err := c.precheck(int64(len(b))); err != nil {
+		return err
+	}
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := tableExists(ctx, tx, collection); errors.Is(err, os.ErrNotExist) {
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf("CREATE TABLE %s (key VARCHAR PRIMARY KEY, doc JSON NOT NULL, updated TIMESTAMP NOT NULL);", quoteTable(collection))); err != nil {
+			return err
+		}
+		if err := recordSchema(ctx, tx, collection, "create"); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf(
+		"INSERT INTO %s (key, doc, updated) VALUES (?, ?, ?) ON CONFLICT (key) DO UPDATE SET doc = excluded.doc, updated = excluded.updated;", quoteTable(collection),
+	), key, string(b), time.Now().UTC()); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	c.counters.touch(collection)
+	return nil
+}
+
+// GetDoc decodes the document stored under key in collection into out. It
+// fails with os.ErrNotExist if there is none.
+func (c *Client) GetDoc(ctx context.Context, collection, key string, out any) (err error) {
+	if err := c.rlock("GetDoc"); err != nil {
+		return err
+	}
+	defer c.runlock("GetDoc", &err)
+	if err := tableExists(ctx, c.db, collection); err != nil {
+		return fmt.Errorf("document %s/%s: %w", collection, key, err)
+	}
+	var doc string
+	err = c.db.QueryRowContext(ctx, fmt.Sprintf("SELECT doc::VARCHAR FROM %s WHERE key = ?;", quoteTable(collection)), key).Scan(&doc)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("document %s/%s: %w", collection, key, os.ErrNotExist)
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(doc), out)
+}
+
+// DeleteDoc removes the document stored under key in collection, if any.
+func (c *Client) DeleteDoc(ctx context.Context, collection, key string) (err error) {
+	if err := c.lock("DeleteDoc"); err != nil {
+		return err
+	}
+	defer c.unlock("DeleteDoc", &err)
+	if err := tableExists(ctx, c.db, collection); errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if _, err := c.db.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE key = ?;", quoteTable(collection)), key); err != nil {
+		return err
+	}
+	c.counters.touch(collection)
+	return nil
+}
+
+// QueryDocs lists the documents in collection matching predicate, ordered by
+// key. The predicate is a SQL boolean expression in which doc is the JSON
+// document, such as "doc->>'$.status' = ?", and args bind its parameters.
+// The predicate is spliced into the query as trusted SQL, so pass values
+// through args rather than formatting them into it. An empty predicate
+// matches every document.
+func (c *Client) QueryDocs(ctx context.Context, collection, predicate string, args ...any) (_ []Doc, err error) {
+	if predicate == "" {
+		predicate = "true"
+	}
+	if err := c.rlock("QueryDocs"); err != nil {
+		return nil, err
+	}
+	defer c.runlock("QueryDocs", &err)
+	if err := tableExists(ctx, c.db, collection); errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	rows, err := c.db.QueryContext(ctx, fmt.Sprintf("SELECT key, doc::VARCHAR, updated FROM %s WHERE (%s) ORDER BY key;", quoteTable(collection), predicate), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var docs []Doc
+	for rows.Next() {
+		var (
+			d   Doc
+			doc string
+		)
+		if err := rows.Scan(&d.Key, &doc, &d.Updated); err != nil {
+			return nil, err
+		}
+		d.Doc = json.RawMessage(doc)
+		docs = append(docs, d)
+	}
+	return docs, rows.Err()
+}
@@ -0,0 +1,77 @@
+package quack
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Docs(t *testing.T) {
+	type user struct {
+		Name   string `json:"name"`
+		Status string `json:"status"`
+	}
+	dir := t.TempDir()
+	client, err := New(dir, 3)
+	require.NoError(t, err)
+
+	require.NoError(t, client.PutDoc(t.Context(), "users", "u1", user{"ann", "active"}))
+	require.NoError(t, client.PutDoc(t.Context(), "users", "u2", user{"bob", "active"}))
+	require.NoError(t, client.PutDoc(t.Context(), "users", "u3", user{"cat", "gone"}))
+	require.NoError(t, client.PutDoc(t.Context(), "users", "u2", user{"bob", "gone"}))
+
+	var u user
+	require.NoError(t, client.GetDoc(t.Context(), "users", "u2", &u))
+	require.Equal(t, user{"bob", "gone"}, u)
+	require.ErrorIs(t, client.GetDoc(t.Context(), "users", "u9", &u), os.ErrNotExist)
+	require.ErrorIs(t, client.GetDoc(t.Context(), "nobody", "u1", &u), os.ErrNotExist)
+
+	keys := func(predicate string, args ...any) []string {
+		t.Helper()
+		docs, err := client.QueryDocs(t.Context(), "users", predicate, args...)
+		require.NoError(t, err)
+		var keys []string
+		for _, d := range docs {
+			keys = append(keys, d.Key)
+		}
+		return keys
+	}
+	require.Equal(t, []string{"u1", "u2", "u3"}, keys(""))
+	require.Equal(t, []string{"u2", "u3"}, keys("doc->>'$.status' = ?", "gone"))
+
+	// u2 and u3 now hold the same document but must stay separate.
+	require.NoError(t, client.PutDoc(t.Context(), "users", "u3", user{"bob", "gone"}))
+	require.NoError(t, client.Deduplicate(t.Context(), "users"))
+	require.Equal(t, []string{"u1", "u2", "u3"}, keys(""))
+	require.NoError(t, client.PutDoc(t.Context(), "users", "u1", user{"ann", "gone"}))
+	require.Equal(t, []string{"u1", "u2", "u3"}, keys("doc->>'$.status' = ?", "gone"))
+
+	require.NoError(t, client.DeleteDoc(t.Context(), "users", "u1"))
+	require.NoError(t, client.DeleteDoc(t.Context(), "users", "u1"))
+	require.Equal(t, []string{"u2", "u3"}, keys(""))
+	require.NoError(t, client.Close(t.Context()))
+
+	client, err = New(dir, 3)
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+	require.NoError(t, client.RollbackSnapshot(t.Context(), 1))
+	require.NoError(t, client.PutDoc(t.Context(), "users", "u2", user{"bob", "back"}))
+	require.Equal(t, []string{"u2", "u3"}, keys(""))
+	require.Equal(t, []string{"u2"}, keys("doc->>'$.status' = ?", "back"))
+}
+
+func Test_DocsQuotedCollection(t *testing.T) {
+	client, err := New(t.TempDir(), 1)
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+	require.NoError(t, client.PutDoc(t.Context(), "user docs", "u1", map[string]string{"name": "ann"}))
+	var doc map[string]string
+	require.NoError(t, client.GetDoc(t.Context(), "user docs", "u1", &doc))
+	require.Equal(t, map[string]string{"name": "ann"}, doc)
+	docs, err := client.QueryDocs(t.Context(), "user docs", "doc->>'$.name' = ?", "ann")
+	require.NoError(t, err)
+	require.Len(t, docs, 1)
+	require.NoError(t, client.DeleteDoc(t.Context(), "user docs", "u1"))
+	require.ErrorIs(t, client.GetDoc(t.Context(), "user docs", "u1", &doc), os.ErrNotExist)
+}
@@ -0,0 +1,257 @@
+package quack
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+type dumpConfig struct {
+	schema, data bool
+	tables       []string
+}
+
+type DumpOption func(*dumpConfig)
+
+// SchemaOnly leaves the INSERT statements out of DumpSQL.
+func SchemaOnly() DumpOption {
+	return func(c *dumpConfig) { c.data = false }
+}
+
+// DataOnly leaves the CREATE statements out of DumpSQL.
+func DataOnly() DumpOption {
+	return func(c *dumpConfig) { c.schema = false }
+}
+
+// DumpTables restricts DumpSQL to the named tables and views, given as name
+// or schema.name. Types and schemas are still dumped in full.
+func DumpTables(names ...string) DumpOption {
+	return func(c *dumpConfig) { c.tables = append(c.tables, names...) }
+}
+
+type dumpTable struct {
+	schema, name, sql string
+}
+
+func (t dumpTable) ref() string {
+	if t.schema == "main" {
+		return quoteIdent(t.name)
+	}
+	return quoteIdent(t.schema) + "." + quoteIdent(t.name)
+}
+
+func (c dumpConfig) includes(t dumpTable) bool {
+	return len(c.tables) == 0 || slices.Contains(c.tables, t.name) || slices.Contains(c.tables, t.schema+"."+t.name)
+}
+
+// DumpSQL writes the database as a SQL script: the CREATE statements for its
+// schemas, types, tables and views, then one INSERT per row. Tables are
+// ordered by schema and name, views in creation order so they can refer to
+// each other, and rows by primary key, or by all columns for tables without
+// one, so identical databases produce identical dumps.
+// Values are written as their text form cast back to the column type, which
+// DuckDB reads back exactly; BLOBs are written as hex.
+func (c *Client) DumpSQL(ctx context.Context, w io.Writer, opts ...DumpOption) (err error) {
+	cfg := dumpConfig{schema: true, data: true}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if err := c.lock("DumpSQL"); err != nil {
+		return err
+	}
+	defer c.unlock("DumpSQL", &err)
+	bw := bufio.NewWriter(w)
+	if err := dumpSQL(ctx, c.db, bw, cfg); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+func dumpSQL(ctx context.Context, db querier, w io.Writer, cfg dumpConfig) error {
+	tables, err := dumpTables(ctx, db, "SELECT schema_name, table_name, sql FROM duckdb_tables() WHERE database_name = current_database() ORDER BY 1, 2;")
+	if err != nil {
+		return err
+	}
+	if cfg.schema {
+		schemas, err := queryStrings(ctx, db, "SELECT schema_name FROM duckdb_schemas() WHERE database_name = current_database() AND NOT internal AND schema_name <> 'main' ORDER BY 1;")
+		if err != nil {
+			return err
+		}
+		for _, schema := range schemas {
+			fmt.Fprintf(w, "CREATE SCHEMA %s;\n", quoteIdent(schema))
+		}
+		types, err := showTypes(ctx, db)
+		if err != nil {
+			return err
+		}
+		slices.Sort(types)
+		for _, typ := range types {
+			values, err := enumValues(ctx, db, typ)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(w, "CREATE TYPE %s AS %s;\n", quoteIdent(typ), enumDefinition(values))
+		}
+		views, err := dumpTables(ctx, db, "SELECT schema_name, view_name, sql FROM duckdb_views() WHERE database_name = current_database() AND NOT internal ORDER BY view_oid;")
+		if err != nil {
+			return err
+		}
+		for _, t := range append(tables, views...) {
+			if cfg.includes(t) {
+				fmt.Fprintln(w, t.sql)
+			}
+		}
+	}
+	if !cfg.data {
+		return nil
+	}
+	for _, t := range tables {
+		if !cfg.includes(t) {
+			continue
+		}
+		if err := dumpRows(ctx, db, w, t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func dumpTables(ctx context.Context, db querier, stmt string, args ...any) ([]dumpTable, error) {
+	rows, err := db.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var tables []dumpTable
+	for rows.Next() {
+		var t dumpTable
+		if err := rows.Scan(&t.schema, &t.name, &t.sql); err != nil {
+			return nil, err
+		}
+		tables = append(tables, t)
+	}
+	return tables, rows.Err()
+}
+
+func queryStrings(ctx context.Context, db querier, stmt string, args ...any) ([]string, error) {
+	rows, err := db.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []string
+	for rows.Next() {
+		var s string
+		if err := rows.Scan(&s); err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
+type dumpColumn struct {
+	name, typ string
+	def       sql.NullString
+}
+
+// storedColumns lists the columns of t that INSERT takes, leaving out
+// generated ones. The catalog reports a generated column's expression as
+// its default, so they are told apart by the table's DDL.
+func storedColumns(ctx context.Context, db querier, t dumpTable) ([]dumpColumn, error) {
+	rows, err := db.QueryContext(ctx, "SELECT column_name, data_type, column_default FROM duckdb_columns() WHERE database_name = current_database() AND schema_name = ? AND table_name = ? ORDER BY column_index;", t.schema, t.name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var columns []dumpColumn
+	for rows.Next() {
+		var col dumpColumn
+		if err := rows.Scan(&col.name, &col.typ, &col.def); err != nil {
+			return nil, err
+		}
+		if col.def.Valid && (strings.Contains(t.sql, col.name+" "+col.typ+" GENERATED ALWAYS AS") ||
+			strings.Contains(t.sql, quoteIdent(col.name)+" "+col.typ+" GENERATED ALWAYS AS")) {
+			continue
+		}
+		columns = append(columns, col)
+	}
+	return columns, rows.Err()
+}
+
+func primaryKey(ctx context.Context, db querier, t dumpTable) ([]string, error) {
+	return queryStrings(ctx, db, "SELECT unnest(constraint_column_names) FROM duckdb_constraints() WHERE database_name = current_database() AND schema_name = ? AND table_name = ? AND constraint_type = 'PRIMARY KEY';", t.schema, t.name)
+}
+
+// literalFormat says how dumpRows writes a value of type typ given its text
+// form.
+func literalFormat(typ string) func(string) string {
+	switch typ {
+	case "BOOLEAN", "TINYINT", "SMALLINT", "INTEGER", "BIGINT", "HUGEINT",
+		"UTINYINT", "USMALLINT", "UINTEGER", "UBIGINT", "UHUGEINT":
+		return func(s string) string { return s }
+	case "VARCHAR":
+		return quoteLiteral
+	case "BLOB":
+		return func(s string) string { return "from_hex(" + quoteLiteral(s) + ")" }
+	}
+	return func(s string) string { return quoteLiteral(s) + "::" + typ }
+}
+
+func dumpRows(ctx context.Context, db querier, w io.Writer, t dumpTable) error {
+	columns, err := storedColumns(ctx, db, t)
+	if err != nil {
+		return err
+	}
+	key, err := primaryKey(ctx, db, t)
+	if err != nil {
+		return err
+	}
+	order := "ALL"
+	if len(key) > 0 {
+		quoted := make([]string, len(key))
+		for i, k := range key {
+			quoted[i] = quoteIdent(k)
+		}
+		order = strings.Join(quoted, ", ")
+	}
+	exprs := make([]string, len(columns))
+	formats := make([]func(string) string, len(columns))
+	for i, col := range columns {
+		exprs[i] = quoteIdent(col.name) + "::VARCHAR"
+		if col.typ == "BLOB" {
+			exprs[i] = "hex(" + quoteIdent(col.name) + ")"
+		}
+		formats[i] = literalFormat(col.typ)
+	}
+	rows, err := db.QueryContext(ctx, fmt.Sprintf("SELECT %s FROM %s ORDER BY %s;", strings.Join(exprs, ", "), t.ref(), order))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	values := make([]sql.NullString, len(columns))
+	dest := make([]any, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	literals := make([]string, len(columns))
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return err
+		}
+		for i, v := range values {
+			literals[i] = "NULL"
+			if v.Valid {
+				literals[i] = formats[i](v.String)
+			}
+		}
+		if _, err := fmt.Fprintf(w, "INSERT INTO %s VALUES (%s);\n", t.ref(), strings.Join(literals, ", ")); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
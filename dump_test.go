@@ -0,0 +1,101 @@
+package quack
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const dumpFixture = `
+CREATE SCHEMA other;
+CREATE TYPE mood AS ENUM ('ok', 'it''s bad');
+CREATE TABLE typed (
+	id INTEGER PRIMARY KEY,
+	b BOOLEAN, ti TINYINT, si SMALLINT, bi BIGINT, hi HUGEINT, ub UBIGINT,
+	f FLOAT, d DOUBLE, dec DECIMAL(18, 3),
+	s VARCHAR, bl BLOB,
+	dt DATE, tm TIME, ts TIMESTAMP, tz TIMESTAMPTZ, iv INTERVAL,
+	u UUID, j JSON, m mood,
+	twice INTEGER GENERATED ALWAYS AS (id * 2) VIRTUAL
+);
+INSERT INTO typed (id, b, ti, si, bi, hi, ub, f, d, dec, s, bl, dt, tm, ts, tz, iv, u, j, m) VALUES
+	(2, true, -128, 32767, -9223372036854775808, 170141183460469231731687303715884105727, 18446744073709551615,
+	 0.1, 1.0000000000000002, 12345.678, 'it''s "quoted"; -- not a comment
+line two ☃', '\x00\xFF\x27'::BLOB,
+	 '1970-01-01', '23:59:59.999999', '2024-02-29 12:34:56.789012', '2024-01-01 00:00:00+05', INTERVAL '1 month 2 days 3 seconds',
+	 'a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11', '{"k": [1, "it''s"]}', 'it''s bad'),
+	(1, NULL, NULL, NULL, NULL, NULL, NULL, 'nan', '-inf', NULL, '', ''::BLOB, NULL, NULL, NULL, NULL, NULL, NULL, 'null', NULL);
+CREATE TABLE other.nested (l VARCHAR[], st STRUCT(x VARCHAR, y INTEGER[]), mp MAP(VARCHAR, DOUBLE));
+INSERT INTO other.nested VALUES
+	(['a,b', NULL, 'c''d'], {'x': 'it''s \ back', 'y': [1, NULL]}, MAP {'k': 1.5, 'a''b': -2}),
+	(NULL, NULL, NULL),
+	([], {'x': NULL, 'y': []}, MAP {});
+CREATE VIEW ok_rows AS SELECT id FROM typed WHERE m = 'ok';
+`
+
+func dump(t *testing.T, c *Client, opts ...DumpOption) string {
+	t.Helper()
+	var buf bytes.Buffer
+	require.NoError(t, c.DumpSQL(t.Context(), &buf, opts...))
+	return buf.String()
+}
+
+func Test_DumpSQL(t *testing.T) {
+	src, err := New(t.TempDir(), 3)
+	require.NoError(t, err)
+	defer src.Close(t.Context())
+	_, err = src.db.ExecContext(t.Context(), dumpFixture)
+	require.NoError(t, err)
+	out := dump(t, src)
+	require.Contains(t, out, "from_hex('00FF27')")
+	require.Contains(t, out, "GENERATED ALWAYS AS")
+	require.Less(t, strings.Index(out, "VALUES (1,"), strings.Index(out, "VALUES (2,"), "rows are ordered by key")
+
+	dst, err := New(t.TempDir(), 3)
+	require.NoError(t, err)
+	defer dst.Close(t.Context())
+	_, err = dst.db.ExecContext(t.Context(), out)
+	require.NoError(t, err)
+	require.Equal(t, out, dump(t, dst))
+
+	var (
+		bl    []byte
+		s     string
+		ts    time.Time
+		twice int
+		m     string
+	)
+	require.NoError(t, dst.db.QueryRow("SELECT bl, s, ts, twice, m::VARCHAR FROM typed WHERE id = 2;").Scan(&bl, &s, &ts, &twice, &m))
+	require.Equal(t, []byte{0, 0xFF, 0x27}, bl)
+	require.Equal(t, "it's \"quoted\"; -- not a comment\nline two ☃", s)
+	require.Equal(t, time.Date(2024, 2, 29, 12, 34, 56, 789012000, time.UTC), ts)
+	require.Equal(t, 4, twice)
+	require.Equal(t, "it's bad", m)
+	var nulls int
+	require.NoError(t, dst.db.QueryRow("SELECT count(*) FROM other.nested WHERE l IS NULL AND st IS NULL AND mp IS NULL;").Scan(&nulls))
+	require.Equal(t, 1, nulls)
+	var same bool
+	require.NoError(t, dst.db.QueryRow(`SELECT st = {'x': 'it''s \ back', 'y': [1, NULL]} AND mp = MAP {'k': 1.5, 'a''b': -2} FROM other.nested WHERE len(l) = 3;`).Scan(&same))
+	require.True(t, same)
+
+	t.Run("schema only", func(t *testing.T) {
+		out := dump(t, src, SchemaOnly())
+		require.Contains(t, out, "CREATE TABLE typed")
+		require.Contains(t, out, "CREATE VIEW ok_rows")
+		require.NotContains(t, out, "INSERT")
+	})
+	t.Run("data only", func(t *testing.T) {
+		out := dump(t, src, DataOnly())
+		require.NotContains(t, out, "CREATE")
+		require.Equal(t, 5, strings.Count(out, "INSERT INTO"))
+	})
+	t.Run("tables", func(t *testing.T) {
+		out := dump(t, src, DumpTables("other.nested"))
+		require.NotContains(t, out, "typed")
+		require.Contains(t, out, "CREATE TABLE other.nested")
+		require.Equal(t, 3, strings.Count(out, `INSERT INTO "other"."nested"`))
+	})
+}
@@ -0,0 +1,92 @@
+package quack
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// dupReportTop is how many of the most duplicated keys DuplicateReport
+// lists.
+const dupReportTop = 10
+
+// DupReport describes how well a set of key columns identifies the rows of
+// a table.
+type DupReport struct {
+	Table string   `json:"table"`
+	Keys  []string `json:"keys"`
+	Rows  int64    `json:"rows"`
+	// DistinctKeys counts distinct key values and DuplicateKeys those held
+	// by more than one row.
+	DistinctKeys  int64 `json:"distinct_keys"`
+	DuplicateKeys int64 `json:"duplicate_keys"`
+	// ExcessRows is how many rows would go if each key kept one row.
+	ExcessRows int64 `json:"excess_rows"`
+	// Top lists the most duplicated keys, most rows first.
+	Top []DupKey `json:"top,omitempty"`
+}
+
+type DupKey struct {
+	// Values holds the key column values in their SQL text form, nil for
+	// NULL.
+	Values []any `json:"values"`
+	Count  int64 `json:"count"`
+}
+
+// DuplicateReport groups table by keyCols and reports how many rows share a
+// key. Without keys whole rows are compared, so ExcessRows is what
+// Deduplicate would remove.
+func (c *Client) DuplicateReport(ctx context.Context, table string, keyCols ...string) (_ *DupReport, err error) {
+	if err := c.lock("DuplicateReport"); err != nil {
+		return nil, err
+	}
+	defer c.unlock("DuplicateReport", &err)
+	columns, err := describe(ctx, c.db, table)
+	if err != nil {
+		return nil, err
+	}
+	known := make(map[string]bool, len(columns))
+	for _, col := range columns {
+		known[col.Name] = true
+	}
+	keys := keyCols
+	if len(keys) == 0 {
+		for _, col := range columns {
+			keys = append(keys, col.Name)
+		}
+	}
+	quoted := make([]string, len(keys))
+	values := make([]string, len(keys))
+	for i, key := range keys {
+		if !known[key] {
+			return nil, fmt.Errorf("table %s has no column %s", table, key)
+		}
+		quoted[i] = quoteIdent(key)
+		values[i] = quoted[i] + "::VARCHAR"
+	}
+	stmt := fmt.Sprintf(`WITH g AS (SELECT %[1]s, count(*) AS n FROM %[2]s GROUP BY %[1]s),
+s AS (SELECT coalesce(sum(n), 0) AS rows, count(*) AS keys, count(*) FILTER (WHERE n > 1) AS dups, coalesce(sum(n - 1), 0) AS excess FROM g),
+top AS (SELECT [%[3]s] AS key, n FROM g WHERE n > 1 ORDER BY n DESC LIMIT %[4]d)
+SELECT s.*, top.key, top.n FROM s LEFT JOIN top ON true ORDER BY top.n DESC;`,
+		strings.Join(quoted, ", "), table, strings.Join(values, ", "), dupReportTop)
+	rows, err := c.db.QueryContext(ctx, stmt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	report := &DupReport{Table: table, Keys: keys}
+	for rows.Next() {
+		var (
+			key any
+			n   sql.NullInt64
+		)
+		if err := rows.Scan(&report.Rows, &report.DistinctKeys, &report.DuplicateKeys, &report.ExcessRows, &key, &n); err != nil {
+			return nil, err
+		}
+		if n.Valid {
+			report.Top = append(report.Top, DupKey{Values: key.([]any), Count: n.Int64})
+		}
+	}
+	return report, rows.Err()
+}
@@ -0,0 +1,48 @@
+package quack
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_DuplicateReport(t *testing.T) {
+	client, err := New(t.TempDir(), 3)
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+	require.NoError(t, client.Insert(t.Context(), "events", strings.NewReader(`{"id":1,"v":"a"}
+{"id":1,"v":"a"}
+{"id":1,"v":"b"}
+{"id":2,"v":"c"}
+{"id":null,"v":"d"}
+{"id":null,"v":"d"}
+{"id":3,"v":"e"}`)))
+
+	report, err := client.DuplicateReport(t.Context(), "events", "id")
+	require.NoError(t, err)
+	require.Equal(t, &DupReport{
+		Table:         "events",
+		Keys:          []string{"id"},
+		Rows:          7,
+		DistinctKeys:  4,
+		DuplicateKeys: 2,
+		ExcessRows:    3,
+		Top:           []DupKey{{Values: []any{"1"}, Count: 3}, {Values: []any{nil}, Count: 2}},
+	}, report)
+
+	report, err = client.DuplicateReport(t.Context(), "events")
+	require.NoError(t, err)
+	require.Equal(t, []string{"id", "v"}, report.Keys)
+	require.Equal(t, int64(2), report.ExcessRows)
+	require.Len(t, report.Top, 2)
+
+	_, err = client.DuplicateReport(t.Context(), "events", "nope")
+	require.Error(t, err)
+	require.NoError(t, client.Deduplicate(t.Context(), "events"))
+	report, err = client.DuplicateReport(t.Context(), "events")
+	require.NoError(t, err)
+	require.Equal(t, int64(5), report.Rows)
+	require.Zero(t, report.ExcessRows)
+	require.Empty(t, report.Top)
+}
@@ -0,0 +1,299 @@
+package quack
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+func enumDefinition(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = quoteLiteral(v)
+	}
+	return "ENUM (" + strings.Join(quoted, ", ") + ")"
+}
+
+func enumValues(ctx context.Context, db querier, name string) ([]string, error) {
+	var values []string
+	rows, err := db.QueryContext(ctx, fmt.Sprintf("SELECT unnest(enum_range(NULL::%s));", quoteIdent(name)))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var v string
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, rows.Err()
+}
+
+func showTypes(ctx context.Context, db querier) ([]string, error) {
+	rows, err := db.QueryContext(ctx, "SELECT type_name FROM duckdb_types() WHERE NOT internal AND database_name = current_database();")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var types []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		types = append(types, name)
+	}
+	return types, rows.Err()
+}
+
+type enumColumn struct {
+	schema, table, column, typ string
+}
+
+// qualified is the quoted name of the table of the column.
+func (col enumColumn) qualified() string {
+	return quoteIdent(col.schema) + "." + quoteIdent(col.table)
+}
+
+func enumColumns(ctx context.Context, db querier, where string, args ...any) ([]enumColumn, error) {
+	rows, err := db.QueryContext(ctx, "SELECT schema_name, table_name, column_name, data_type FROM duckdb_columns() WHERE database_name = current_database() AND "+where+" ORDER BY schema_name, table_name, column_index;", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var columns []enumColumn
+	for rows.Next() {
+		var col enumColumn
+		if err := rows.Scan(&col.schema, &col.table, &col.column, &col.typ); err != nil {
+			return nil, err
+		}
+		columns = append(columns, col)
+	}
+	return columns, rows.Err()
+}
+
+// checkEnums reports values in the staged file that are not members of the
+// enum types of the matching columns of table.
+func checkEnums(ctx context.Context, db querier, table, source string) error {
+	schema, name := splitTable(table)
+	columns, err := enumColumns(ctx, db, "schema_name = coalesce(nullif(?, ''), current_schema()) AND table_name = ? AND data_type LIKE 'ENUM(%'", schema, name)
+	if err != nil || len(columns) == 0 {
+		return err
+	}
+	staged, err := describe(ctx, db, "SELECT * FROM "+source)
+	if err != nil {
+		return err
+	}
+	present := make(map[string]bool, len(staged))
+	for _, col := range staged {
+		present[col.Name] = true
+	}
+	for _, col := range columns {
+		if !present[col.column] {
+			continue
+		}
+		name := quoteIdent(col.column)
+		stmt := fmt.Sprintf("SELECT DISTINCT %s::VARCHAR FROM %s WHERE %s IS NOT NULL AND TRY_CAST(%s::VARCHAR AS %s) IS NULL ORDER BY 1 LIMIT 10;", name, source, name, name, col.typ)
+		rows, err := db.QueryContext(ctx, stmt)
+		if err != nil {
+			return err
+		}
+		var invalid []string
+		for rows.Next() {
+			var v string
+			if err := rows.Scan(&v); err != nil {
+				rows.Close()
+				return err
+			}
+			invalid = append(invalid, quoteLiteral(v))
+		}
+		if err := rows.Close(); err != nil {
+			return err
+		}
+		if len(invalid) > 0 {
+			return fmt.Errorf("column %s of %s: %s not in %s", col.column, table, strings.Join(invalid, ", "), col.typ)
+		}
+	}
+	return nil
+}
+
+// CreateEnum creates an ENUM type usable as Column.Type.
+func (c *Client) CreateEnum(ctx context.Context, name string, values []string) (err error) {
+	if err := c.lock("CreateEnum"); err != nil {
+		return err
+	}
+	defer c.unlock("CreateEnum", &err)
+	_, err = c.db.ExecContext(ctx, fmt.Sprintf("CREATE TYPE %s AS %s;", quoteIdent(name), enumDefinition(values)))
+	return err
+}
+
+// AlterEnumAdd appends value to the enum type name. DuckDB cannot alter enum
+// types in place, so every table with a column of the type is copied aside
+// as VARCHAR and recreated from its DDL, keeping its constraints, defaults,
+// generated columns, indexes and comments.
+func (c *Client) AlterEnumAdd(ctx context.Context, name, value string) (err error) {
+	if err := c.lock("AlterEnumAdd"); err != nil {
+		return err
+	}
+	defer c.unlock("AlterEnumAdd", &err)
+	values, err := enumValues(ctx, c.db, name)
+	if err != nil {
+		return err
+	}
+	for _, v := range values {
+		if v == value {
+			return nil
+		}
+	}
+	var literal string
+	if err := c.db.QueryRowContext(ctx, fmt.Sprintf("SELECT typeof(NULL::%s);", quoteIdent(name))).Scan(&literal); err != nil {
+		return err
+	}
+	columns, err := enumColumns(ctx, c.db, "data_type = ?", literal)
+	if err != nil {
+		return err
+	}
+	// Tables are keyed by their quoted qualified name, so same-named tables
+	// of different schemas are rebuilt apart.
+	byTable := make(map[string]*enumRebuild)
+	var rebuilds []*enumRebuild
+	for _, col := range columns {
+		r, ok := byTable[col.qualified()]
+		if !ok {
+			if r, err = newEnumRebuild(ctx, c.db, col.schema, col.table); err != nil {
+				return err
+			}
+			r.copy = fmt.Sprintf("quack_enum_%d", len(rebuilds))
+			byTable[col.qualified()] = r
+			rebuilds = append(rebuilds, r)
+		}
+		r.enums = append(r.enums, col.column)
+	}
+	var stmts []string
+	for _, r := range rebuilds {
+		stmts = append(stmts,
+			fmt.Sprintf("CREATE TABLE %s AS SELECT %s FROM %s;", r.copy, r.selection(), r.ref()),
+			fmt.Sprintf("DROP TABLE %s;", r.ref()),
+		)
+	}
+	stmts = append(stmts,
+		fmt.Sprintf("DROP TYPE %s;", quoteIdent(name)),
+		fmt.Sprintf("CREATE TYPE %s AS %s;", quoteIdent(name), enumDefinition(append(values, value))),
+	)
+	for _, r := range rebuilds {
+		stmts = append(stmts, r.restore(literal, quoteIdent(name))...)
+	}
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, stmt := range stmts {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	for _, r := range rebuilds {
+		if err := recordSchema(ctx, tx, r.table(), "alter_enum"); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	for _, r := range rebuilds {
+		c.counters.touch(r.table())
+	}
+	return nil
+}
+
+// enumRebuild is what recreating a table for AlterEnumAdd needs besides
+// its rows: the DDL of the table and its indexes, and its comments.
+type enumRebuild struct {
+	dumpTable
+	stored   []dumpColumn
+	indexes  []string
+	comments []string
+	// enums are the columns of the enum type.
+	enums []string
+	// copy is the table holding the rows meanwhile.
+	copy string
+}
+
+func newEnumRebuild(ctx context.Context, db querier, schema, table string) (*enumRebuild, error) {
+	tables, err := dumpTables(ctx, db, "SELECT schema_name, table_name, sql FROM duckdb_tables() WHERE database_name = current_database() AND schema_name = ? AND table_name = ?;", schema, table)
+	if err != nil {
+		return nil, err
+	}
+	if len(tables) != 1 {
+		return nil, fmt.Errorf("table %s.%s: %w", schema, table, os.ErrNotExist)
+	}
+	r := &enumRebuild{dumpTable: tables[0]}
+	if r.stored, err = storedColumns(ctx, db, r.dumpTable); err != nil {
+		return nil, err
+	}
+	if r.indexes, err = queryStrings(ctx, db, "SELECT sql FROM duckdb_indexes() WHERE database_name = current_database() AND schema_name = ? AND table_name = ? AND sql IS NOT NULL;", schema, table); err != nil {
+		return nil, err
+	}
+	tableComment, err := queryStrings(ctx, db, "SELECT comment FROM duckdb_tables() WHERE database_name = current_database() AND schema_name = ? AND table_name = ? AND comment IS NOT NULL;", schema, table)
+	if err != nil {
+		return nil, err
+	}
+	for _, comment := range tableComment {
+		r.comments = append(r.comments, commentStmt(r.ref(), "", comment))
+	}
+	rows, err := db.QueryContext(ctx, "SELECT column_name, comment FROM duckdb_columns() WHERE database_name = current_database() AND schema_name = ? AND table_name = ? AND comment IS NOT NULL;", schema, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var column, comment string
+		if err := rows.Scan(&column, &comment); err != nil {
+			return nil, err
+		}
+		r.comments = append(r.comments, commentStmt(r.ref(), column, comment))
+	}
+	return r, rows.Err()
+}
+
+// table is the name of the table as quack's other methods take it.
+func (r *enumRebuild) table() string {
+	if r.schema == "main" {
+		return r.name
+	}
+	return r.schema + "." + r.name
+}
+
+// selection lists the stored columns, the enum ones cast to VARCHAR.
+func (r *enumRebuild) selection() string {
+	exprs := make([]string, len(r.stored))
+	for i, col := range r.stored {
+		exprs[i] = quoteIdent(col.name)
+		if slices.Contains(r.enums, col.name) {
+			exprs[i] += "::VARCHAR AS " + quoteIdent(col.name)
+		}
+	}
+	return strings.Join(exprs, ", ")
+}
+
+// restore recreates the table with typ in place of the enum literal and
+// moves the rows back from the copy.
+func (r *enumRebuild) restore(literal, typ string) []string {
+	names := make([]string, len(r.stored))
+	for i, col := range r.stored {
+		names[i] = quoteIdent(col.name)
+	}
+	list := strings.Join(names, ", ")
+	stmts := []string{
+		strings.ReplaceAll(r.sql, literal, typ),
+		fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s;", r.ref(), list, list, r.copy),
+		fmt.Sprintf("DROP TABLE %s;", r.copy),
+	}
+	stmts = append(stmts, r.indexes...)
+	return append(stmts, r.comments...)
+}
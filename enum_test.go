@@ -0,0 +1,112 @@
+package quack
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Enum(t *testing.T) {
+	dir := t.TempDir()
+	client, err := New(dir, 2)
+	require.NoError(t, err)
+	require.NoError(t, client.CreateEnum(t.Context(), "status", []string{"ok", "bad"}))
+	require.NoError(t, client.CreateTable(t.Context(), "jobs", []Column{{Name: "id", Type: "INTEGER"}, {Name: "status", Type: "status"}}))
+	require.NoError(t, client.Insert(t.Context(), "jobs", strings.NewReader(`{"id":1,"status":"ok"}`)))
+	err = client.Insert(t.Context(), "jobs", strings.NewReader(`{"id":2,"status":"oops"}`))
+	require.ErrorContains(t, err, "'oops' not in ENUM('ok', 'bad')")
+
+	require.NoError(t, client.AlterEnumAdd(t.Context(), "status", "oops"))
+	require.NoError(t, client.Insert(t.Context(), "jobs", strings.NewReader(`{"id":2,"status":"oops"}`)))
+	require.NoError(t, client.Close(t.Context()))
+
+	client, err = New(dir, 2)
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+	require.NoError(t, client.RollbackSnapshot(t.Context(), 1))
+	values, err := enumValues(t.Context(), client.db, "status")
+	require.NoError(t, err)
+	require.Equal(t, []string{"ok", "bad", "oops"}, values)
+	require.NoError(t, client.AlterEnumAdd(t.Context(), "status", "late"))
+	require.NoError(t, client.Insert(t.Context(), "jobs", strings.NewReader(`{"id":3,"status":"late"}`)))
+	rows, err := client.Query(t.Context(), "SELECT count(*) FROM jobs;")
+	require.NoError(t, err)
+	defer rows.Close()
+	var count int
+	require.True(t, rows.Next())
+	require.NoError(t, rows.Scan(&count))
+	require.Equal(t, 3, count)
+}
+
+func Test_EnumSchemas(t *testing.T) {
+	client, err := New(t.TempDir(), 1)
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+	require.NoError(t, client.CreateEnum(t.Context(), "status", []string{"ok"}))
+	require.NoError(t, client.CreateTable(t.Context(), "jobs", []Column{{Name: "id", Type: "INTEGER"}, {Name: "status", Type: "status"}}))
+	require.NoError(t, client.CreateTable(t.Context(), "raw.jobs", []Column{{Name: "id", Type: "INTEGER"}, {Name: "status", Type: "VARCHAR"}}))
+	require.NoError(t, client.CreateTable(t.Context(), "other.jobs", []Column{{Name: "id", Type: "INTEGER"}, {Name: "status", Type: "status"}}))
+
+	// Only the columns of the table inserted into are checked.
+	require.NoError(t, client.Insert(t.Context(), "raw.jobs", strings.NewReader(`{"id":1,"status":"oops"}`)))
+	require.ErrorContains(t, client.Insert(t.Context(), "jobs", strings.NewReader(`{"id":1,"status":"oops"}`)), "'oops' not in")
+	require.NoError(t, client.Insert(t.Context(), "jobs", strings.NewReader(`{"id":1,"status":"ok"}`)))
+	require.NoError(t, client.Insert(t.Context(), "other.jobs", strings.NewReader(`{"id":2,"status":"ok"}`)))
+
+	require.NoError(t, client.AlterEnumAdd(t.Context(), "status", "late"))
+	ids := func(table string) string {
+		var s string
+		require.NoError(t, client.db.QueryRow("SELECT string_agg(id::VARCHAR || ':' || status::VARCHAR || ':' || typeof(status), ',') FROM "+table+";").Scan(&s))
+		return s
+	}
+	require.Equal(t, "1:ok:ENUM('ok', 'late')", ids("jobs"))
+	require.Equal(t, "2:ok:ENUM('ok', 'late')", ids("other.jobs"))
+	require.Equal(t, "1:oops:VARCHAR", ids("raw.jobs"))
+}
+
+func Test_EnumKeepsTableDefinition(t *testing.T) {
+	client, err := New(t.TempDir(), 1)
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+	require.NoError(t, client.CreateEnum(t.Context(), "status", []string{"ok", "bad"}))
+	require.NoError(t, client.CreateTable(t.Context(), "jobs", []Column{
+		{Name: "id", Type: "INTEGER"},
+		{Name: "status", Type: "status", Default: "'ok'"},
+		{Name: "label", Type: "VARCHAR", GeneratedAs: "status::VARCHAR || '!'"},
+	}))
+	for _, stmt := range []string{
+		"ALTER TABLE jobs ADD PRIMARY KEY (id);",
+		"CREATE INDEX jobs_status ON jobs (status);",
+	} {
+		_, err := client.db.Exec(stmt)
+		require.NoError(t, err)
+	}
+	require.NoError(t, client.SetComment(t.Context(), "jobs", "job queue"))
+	require.NoError(t, client.SetComment(t.Context(), "jobs.status", "state"))
+	require.NoError(t, client.Insert(t.Context(), "jobs", strings.NewReader(`{"id":1,"status":"bad"}`)))
+
+	before, err := client.Describe(t.Context(), "jobs")
+	require.NoError(t, err)
+	require.NoError(t, client.AlterEnumAdd(t.Context(), "status", "late"))
+	after, err := client.Describe(t.Context(), "jobs")
+	require.NoError(t, err)
+	before[1].Type = "ENUM('ok', 'bad', 'late')"
+	require.Equal(t, before, after)
+	require.Equal(t, Column{Name: "status", Type: "ENUM('ok', 'bad', 'late')", Default: "'ok'", Comment: "state"}, after[1])
+	comments, err := client.GetComments(t.Context(), "jobs")
+	require.NoError(t, err)
+	require.Equal(t, "job queue", comments.Table)
+	var label string
+	require.NoError(t, client.db.QueryRow("SELECT label FROM jobs WHERE id = 1;").Scan(&label))
+	require.Equal(t, "bad!", label)
+	_, err = client.db.Exec("INSERT INTO jobs (id) VALUES (1);")
+	require.ErrorContains(t, err, "primary key constraint")
+	var indexes int
+	require.NoError(t, client.db.QueryRow("SELECT count(*) FROM duckdb_indexes() WHERE index_name = 'jobs_status';").Scan(&indexes))
+	require.Equal(t, 1, indexes)
+
+	history, err := client.SchemaHistory(t.Context(), "jobs")
+	require.NoError(t, err)
+	require.Equal(t, "alter_enum", history[len(history)-1].Operation)
+}
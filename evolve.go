@@ -0,0 +1,50 @@
+package quack
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// AllowSchemaEvolution adds input columns that an existing table lacks as
+// nullable columns of their inferred type before appending, so earlier rows
+// read NULL in them. Input columns whose type does not widen to that of
+// their existing column fail the insert, as with WithStrictTypes, before
+// the table is altered.
+func AllowSchemaEvolution() InsertOption {
+	return func(c *insertConfig) { c.evolve = true }
+}
+
+// evolve adds the columns of source that table lacks.
+func evolve(ctx context.Context, db querier, table, source string) error {
+	if err := checkStrictTypes(ctx, db, table, source); err != nil {
+		return err
+	}
+	columns, err := describe(ctx, db, table)
+	if err != nil {
+		return err
+	}
+	present := make(map[string]bool, len(columns))
+	for _, col := range columns {
+		present[strings.ToLower(col.Name)] = true
+	}
+	staged, err := describe(ctx, db, "SELECT * FROM "+source)
+	if err != nil {
+		return err
+	}
+	added := false
+	for _, col := range staged {
+		if present[strings.ToLower(col.Name)] {
+			continue
+		}
+		col.Nullable = true
+		if _, err := db.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s;", table, col.definition())); err != nil {
+			return err
+		}
+		added = true
+	}
+	if !added {
+		return nil
+	}
+	return recordSchema(ctx, db, table, "add_column")
+}
@@ -0,0 +1,34 @@
+package quack
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_AllowSchemaEvolution(t *testing.T) {
+	client, err := New(t.TempDir(), 1)
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+	require.NoError(t, client.Insert(t.Context(), "events", strings.NewReader(`{"id":1,"name":"a"}`)))
+	require.NoError(t, client.Insert(t.Context(), "events", strings.NewReader(`{"id":2,"name":"b","score":1.5}`), AllowSchemaEvolution()))
+	columns, err := describe(t.Context(), client.db, "events")
+	require.NoError(t, err)
+	require.Equal(t, []Column{
+		{Name: "id", Type: "BIGINT", Nullable: true},
+		{Name: "name", Type: "VARCHAR", Nullable: true},
+		{Name: "score", Type: "DOUBLE", Nullable: true},
+	}, columns)
+	var got string
+	require.NoError(t, client.db.QueryRow("SELECT string_agg(concat_ws(':', id, name, coalesce(score::VARCHAR, 'null')), ',' ORDER BY rowid) FROM events;").Scan(&got))
+	require.Equal(t, "1:a:null,2:b:1.5", got)
+
+	var typeErr *TypeError
+	err = client.Insert(t.Context(), "events", strings.NewReader(`{"id":"x","extra":true}`), AllowSchemaEvolution())
+	require.ErrorAs(t, err, &typeErr)
+	require.Equal(t, "id", typeErr.Columns[0].Column)
+	columns, err = describe(t.Context(), client.db, "events")
+	require.NoError(t, err)
+	require.Len(t, columns, 3)
+}
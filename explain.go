@@ -0,0 +1,133 @@
+package quack
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Plan is the query plan of Explain.
+type Plan struct {
+	Root PlanNode
+	// Text is the plan as an indented tree of operators.
+	Text string
+	// Duration is how long EXPLAIN ANALYZE took, including the statement
+	// itself, or zero without analyze.
+	Duration time.Duration
+}
+
+// PlanNode is an operator of a Plan.
+type PlanNode struct {
+	Name string
+	// Extra holds operator details, such as "Table" and "Filters" of scans
+	// or "Estimated Cardinality".
+	Extra map[string]string
+	// Rows and Timing are the rows the operator produced and the time it
+	// took, known only with analyze.
+	Rows     int64
+	Timing   time.Duration
+	Children []PlanNode
+}
+
+// planJSON is a node of EXPLAIN (FORMAT JSON), whose analyzed variant names
+// operators with operator_name.
+type planJSON struct {
+	Name         string                     `json:"name"`
+	OperatorName string                     `json:"operator_name"`
+	Extra        map[string]json.RawMessage `json:"extra_info"`
+	Cardinality  int64                      `json:"operator_cardinality"`
+	Timing       float64                    `json:"operator_timing"`
+	Children     []planJSON                 `json:"children"`
+}
+
+func (p planJSON) node() PlanNode {
+	n := PlanNode{
+		Name:   strings.TrimSpace(p.Name + p.OperatorName),
+		Rows:   p.Cardinality,
+		Timing: time.Duration(p.Timing * float64(time.Second)),
+	}
+	if len(p.Extra) > 0 {
+		n.Extra = make(map[string]string, len(p.Extra))
+		for k, v := range p.Extra {
+			var s string
+			if json.Unmarshal(v, &s) != nil {
+				s = string(v)
+			}
+			n.Extra[k] = s
+		}
+	}
+	for _, c := range p.Children {
+		n.Children = append(n.Children, c.node())
+	}
+	return n
+}
+
+// Explain returns the plan of stmt with args bound to its placeholders.
+// With analyze the statement is run, at its full cost, to report the rows
+// and time of each operator; its result is discarded, but the changes of
+// a statement such as DELETE are kept.
+func (c *Client) Explain(ctx context.Context, stmt string, analyze bool, args ...any) (_ Plan, err error) {
+	format := "FORMAT JSON"
+	if analyze {
+		format = "ANALYZE, " + format
+	}
+	// EXPLAIN ANALYZE runs the statement, which may write.
+	lock, unlock := c.rlock, c.runlock
+	if analyze {
+		lock, unlock = c.lock, c.unlock
+	}
+	if err := lock("Explain"); err != nil {
+		return Plan{}, err
+	}
+	defer unlock("Explain", &err)
+	start := time.Now()
+	var key, value string
+	if err := c.db.QueryRowContext(c.opContext(ctx), fmt.Sprintf("EXPLAIN (%s) %s", format, stmt), args...).Scan(&key, &value); err != nil {
+		return Plan{}, err
+	}
+	var plan Plan
+	var root planJSON
+	if analyze {
+		plan.Duration = time.Since(start)
+		if err := json.Unmarshal([]byte(value), &root); err != nil {
+			return Plan{}, fmt.Errorf("explain: %w", err)
+		}
+		// Skip the query and EXPLAIN_ANALYZE operators wrapping the plan.
+		for len(root.Children) == 1 && (root.OperatorName == "" || root.OperatorName == "EXPLAIN_ANALYZE") {
+			root = root.Children[0]
+		}
+	} else {
+		var roots []planJSON
+		if err := json.Unmarshal([]byte(value), &roots); err != nil {
+			return Plan{}, fmt.Errorf("explain: %w", err)
+		}
+		if len(roots) != 1 {
+			return Plan{}, fmt.Errorf("explain: %d plans", len(roots))
+		}
+		root = roots[0]
+	}
+	plan.Root = root.node()
+	var b strings.Builder
+	writePlan(&b, plan.Root, 0, analyze)
+	plan.Text = b.String()
+	return plan, nil
+}
+
+func writePlan(b *strings.Builder, n PlanNode, depth int, analyze bool) {
+	b.WriteString(strings.Repeat("  ", depth))
+	b.WriteString(n.Name)
+	if analyze {
+		fmt.Fprintf(b, " (%d rows, %s)", n.Rows, n.Timing)
+	}
+	for _, k := range []string{"Table", "Filters", "Estimated Cardinality"} {
+		if v := n.Extra[k]; v != "" {
+			fmt.Fprintf(b, " %s: %s", k, v)
+		}
+	}
+	b.WriteByte('\n')
+	for _, c := range n.Children {
+		writePlan(b, c, depth+1, analyze)
+	}
+}
@@ -0,0 +1,48 @@
+package quack
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Explain(t *testing.T) {
+	client, err := New(t.TempDir(), 1)
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+	var data strings.Builder
+	for i := range 100 {
+		data.WriteString(`{"id":` + strings.Repeat("1", 1+i%3) + "}\n")
+	}
+	require.NoError(t, client.Insert(t.Context(), "events", strings.NewReader(data.String())))
+
+	plan, err := client.Explain(t.Context(), "SELECT count(*) FROM events WHERE id > ?;", false, 11)
+	require.NoError(t, err)
+	require.Equal(t, "UNGROUPED_AGGREGATE", plan.Root.Name)
+	require.Len(t, plan.Root.Children, 1)
+	scan := plan.Root.Children[0]
+	require.Equal(t, "SEQ_SCAN", scan.Name)
+	require.Equal(t, "events", scan.Extra["Table"])
+	require.Equal(t, "id>11", scan.Extra["Filters"])
+	require.Zero(t, plan.Duration)
+	require.True(t, strings.HasPrefix(plan.Text, "UNGROUPED_AGGREGATE\n  SEQ_SCAN Table: events Filters: id>11"), plan.Text)
+
+	plan, err = client.Explain(t.Context(), "SELECT count(*) FROM events WHERE id > ?;", true, 11)
+	require.NoError(t, err)
+	require.Equal(t, "UNGROUPED_AGGREGATE", plan.Root.Name)
+	require.Equal(t, int64(1), plan.Root.Rows)
+	require.Equal(t, int64(33), plan.Root.Children[0].Rows)
+	require.Positive(t, plan.Duration)
+	require.Contains(t, plan.Text, "(33 rows, ")
+
+	// Analyze runs the statement.
+	_, err = client.Explain(t.Context(), "DELETE FROM events WHERE id = ?;", true, 1)
+	require.NoError(t, err)
+	var n int
+	require.NoError(t, client.QueryRow(t.Context(), "SELECT count(*) FROM events;").Scan(&n))
+	require.Equal(t, 66, n)
+
+	_, err = client.Explain(t.Context(), "SELECT * FROM missing;", false)
+	require.Error(t, err)
+}
@@ -0,0 +1,56 @@
+package quack
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ExportFormat describes the files ExportTable writes.
+type ExportFormat struct {
+	// Format is FormatParquet or FormatCSV, which is written with a
+	// header.
+	Format Format
+	// Compression is the codec, such as "zstd" or "snappy" for Parquet or
+	// "gzip" for CSV. DuckDB's default is used when empty.
+	Compression string
+	// PartitionBy writes a directory tree at the path instead of a file,
+	// with a directory such as col=value for each value of the columns.
+	PartitionBy []string
+}
+
+// ExportTable writes table to path, failing with os.ErrNotExist when there
+// is no such table.
+func (c *Client) ExportTable(ctx context.Context, table, path string, format ExportFormat) (err error) {
+	params := []string{"FORMAT " + format.Format.String()}
+	switch format.Format {
+	case FormatParquet:
+	case FormatCSV:
+		params = append(params, "HEADER true")
+	default:
+		return fmt.Errorf("cannot export a table as %s", format.Format)
+	}
+	if format.Compression != "" {
+		params = append(params, "COMPRESSION "+quoteLiteral(format.Compression))
+	}
+	if len(format.PartitionBy) > 0 {
+		cols := make([]string, len(format.PartitionBy))
+		for i, col := range format.PartitionBy {
+			cols[i] = quoteIdent(col)
+		}
+		params = append(params, fmt.Sprintf("PARTITION_BY (%s)", strings.Join(cols, ", ")))
+	}
+	if err := c.lock("ExportTable"); err != nil {
+		return err
+	}
+	defer c.unlock("ExportTable", &err)
+	if err := tableExists(ctx, c.db, table); os.IsNotExist(err) {
+		return fmt.Errorf("table %s: %w", table, err)
+	} else if err != nil {
+		return err
+	}
+	stmt := fmt.Sprintf("COPY %s TO %s (%s);", quoteTable(table), quoteLiteral(path), strings.Join(params, ", "))
+	_, err = c.db.ExecContext(c.opContext(ctx), stmt)
+	return err
+}
@@ -0,0 +1,48 @@
+package quack
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ExportTable(t *testing.T) {
+	client, err := New(t.TempDir(), 1)
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+	data := "{\"id\":1,\"day\":\"a\"}\n{\"id\":2,\"day\":\"b\"}\n{\"id\":3,\"day\":\"a\"}\n"
+	require.NoError(t, client.Insert(t.Context(), "raw.events", strings.NewReader(data)))
+	dir := t.TempDir()
+	count := func(source string) int {
+		var n int
+		require.NoError(t, client.QueryRow(t.Context(), "SELECT count(*) FROM "+source+";").Scan(&n))
+		return n
+	}
+
+	file := filepath.Join(dir, "it's.parquet")
+	require.NoError(t, client.ExportTable(t.Context(), "raw.events", file, ExportFormat{Format: FormatParquet, Compression: "zstd"}))
+	require.Equal(t, 3, count("read_parquet("+quoteLiteral(file)+")"))
+	var codec string
+	require.NoError(t, client.QueryRow(t.Context(), "SELECT DISTINCT compression FROM parquet_metadata(?);", file).Scan(&codec))
+	require.Equal(t, "ZSTD", codec)
+
+	file = filepath.Join(dir, "events.csv")
+	require.NoError(t, client.ExportTable(t.Context(), "raw.events", file, ExportFormat{Format: FormatCSV}))
+	b, err := os.ReadFile(file)
+	require.NoError(t, err)
+	require.Equal(t, "id,day\n1,a\n2,b\n3,a\n", string(b))
+
+	tree := filepath.Join(dir, "tree")
+	require.NoError(t, client.ExportTable(t.Context(), "raw.events", tree, ExportFormat{Format: FormatParquet, PartitionBy: []string{"day"}}))
+	matches, err := filepath.Glob(filepath.Join(tree, "day=a", "*.parquet"))
+	require.NoError(t, err)
+	require.Len(t, matches, 1)
+	require.Equal(t, 2, count("read_parquet("+quoteLiteral(matches[0])+")"))
+
+	err = client.ExportTable(t.Context(), "missing", file, ExportFormat{Format: FormatCSV})
+	require.ErrorIs(t, err, os.ErrNotExist)
+	require.Error(t, client.ExportTable(t.Context(), "raw.events", file, ExportFormat{Format: FormatJSON}))
+}
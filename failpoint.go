@@ -0,0 +1,16 @@
+package quack
+
+// Failpoints mark the moments where a crash must not leave a snapshot or
+// the database half-written. They do nothing unless the package is built
+// with the quack_failpoints tag, in which case tests can make them fail.
+const (
+	// fpSnapshotExported is reached after EXPORT DATABASE, before the
+	// archive is finished.
+	fpSnapshotExported = "snapshot-exported"
+	// fpSnapshotWritten is reached after the archive is written to its
+	// temporary file, before it is renamed into place.
+	fpSnapshotWritten = "snapshot-written"
+	// fpRollbackDropped is reached after rollback drops the current
+	// tables, before it imports the snapshot.
+	fpRollbackDropped = "rollback-dropped"
+)
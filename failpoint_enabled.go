@@ -0,0 +1,22 @@
+//go:build quack_failpoints
+
+package quack
+
+import "sync"
+
+var failpoints sync.Map
+
+// armFailpoint makes failpoint name run fn, which may return an error or
+// panic, until the returned func disarms it.
+func armFailpoint(name string, fn func() error) (disarm func()) {
+	failpoints.Store(name, fn)
+	return func() { failpoints.Delete(name) }
+}
+
+func failpoint(name string) error {
+	fn, ok := failpoints.Load(name)
+	if !ok {
+		return nil
+	}
+	return fn.(func() error)()
+}
@@ -0,0 +1,164 @@
+//go:build quack_failpoints
+
+package quack
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errInjected = errors.New("injected")
+
+func failWith(err error) func() error { return func() error { return err } }
+
+func panics() error { panic(errInjected) }
+
+func setupFailpoints(t *testing.T) (*Client, string) {
+	t.Helper()
+	dir := t.TempDir()
+	client, err := New(dir, 3)
+	require.NoError(t, err)
+	require.NoError(t, client.CreateEnum(t.Context(), "mood", []string{"ok"}))
+	require.NoError(t, client.Insert(t.Context(), "events", strings.NewReader(`{"id":1}`)))
+	require.NoError(t, client.Close(t.Context()))
+	client, err = New(dir, 3)
+	require.NoError(t, err)
+	t.Cleanup(func() { client.Close(t.Context()) })
+	require.NoError(t, client.Insert(t.Context(), "events", strings.NewReader(`{"id":2}`)))
+	return client, dir
+}
+
+func countEvents(t *testing.T, c *Client) int {
+	t.Helper()
+	var n int
+	require.NoError(t, c.db.QueryRow("SELECT count(*) FROM events;").Scan(&n))
+	return n
+}
+
+func Test_SnapshotFailpoints(t *testing.T) {
+	for _, fp := range []string{fpSnapshotExported, fpSnapshotWritten} {
+		for name, fn := range map[string]func() error{"error": failWith(errInjected), "panic": panics} {
+			t.Run(fp+"/"+name, func(t *testing.T) {
+				client, dir := setupFailpoints(t)
+				before, err := os.ReadDir(filepath.Join(dir, "snapshot"))
+				require.NoError(t, err)
+
+				disarm := armFailpoint(fp, fn)
+				func() {
+					defer func() { recover() }()
+					require.ErrorIs(t, client.Close(t.Context()), errInjected)
+				}()
+				disarm()
+
+				after, err := os.ReadDir(filepath.Join(dir, "snapshot"))
+				require.NoError(t, err)
+				require.Equal(t, before, after)
+				// The client is still usable and the old snapshot intact.
+				require.Equal(t, 2, countEvents(t, client))
+				require.NoError(t, client.RollbackSnapshot(t.Context(), 1))
+				require.Equal(t, 1, countEvents(t, client))
+			})
+		}
+	}
+}
+
+func Test_SnapshotMethodFailpoints(t *testing.T) {
+	for _, fp := range []string{fpSnapshotExported, fpSnapshotWritten} {
+		for name, fn := range map[string]func() error{"error": failWith(errInjected), "panic": panics} {
+			t.Run(fp+"/"+name, func(t *testing.T) {
+				client, dir := setupFailpoints(t)
+				before, err := os.ReadDir(filepath.Join(dir, "snapshot"))
+				require.NoError(t, err)
+
+				disarm := armFailpoint(fp, fn)
+				func() {
+					defer func() { recover() }()
+					_, err := client.Snapshot(t.Context())
+					require.ErrorIs(t, err, errInjected)
+				}()
+				disarm()
+
+				after, err := os.ReadDir(filepath.Join(dir, "snapshot"))
+				require.NoError(t, err)
+				require.Equal(t, before, after)
+				require.Equal(t, 2, countEvents(t, client))
+				_, err = client.Snapshot(t.Context())
+				require.NoError(t, err)
+			})
+		}
+	}
+}
+
+func Test_SnapshotArchiveSerialized(t *testing.T) {
+	client, dir := setupFailpoints(t)
+	var active, most atomic.Int32
+	disarm := armFailpoint(fpSnapshotWritten, func() error {
+		n := active.Add(1)
+		defer active.Add(-1)
+		if n > most.Load() {
+			most.Store(n)
+		}
+		time.Sleep(50 * time.Millisecond)
+		return nil
+	})
+	defer disarm()
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := client.Snapshot(t.Context())
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+	require.Equal(t, int32(1), most.Load(), "archives were written concurrently")
+	infos, err := snapshotInfos(filepath.Join(dir, "snapshot"))
+	require.NoError(t, err)
+	require.Len(t, infos, 3)
+}
+
+func Test_RollbackFailpoints(t *testing.T) {
+	for name, fn := range map[string]func() error{"error": failWith(errInjected), "panic": panics} {
+		t.Run(name, func(t *testing.T) {
+			client, _ := setupFailpoints(t)
+			disarm := armFailpoint(fpRollbackDropped, fn)
+			func() {
+				defer func() { recover() }()
+				require.ErrorIs(t, client.RollbackSnapshot(t.Context(), 1), errInjected)
+			}()
+			disarm()
+
+			require.Equal(t, 2, countEvents(t, client))
+			types, err := showTypes(t.Context(), client.db)
+			require.NoError(t, err)
+			require.Equal(t, []string{"mood"}, types)
+			require.NoError(t, client.RollbackSnapshot(t.Context(), 1))
+			require.Equal(t, 1, countEvents(t, client))
+		})
+	}
+}
+
+func Test_RollbackParallelismFailpoint(t *testing.T) {
+	client, _ := setupFailpoints(t)
+	before := threads(t, client.db)
+	var during int
+	disarm := armFailpoint(fpRollbackDropped, func() error {
+		during = threads(t, client.db)
+		return errInjected
+	})
+	defer disarm()
+	require.ErrorIs(t, client.RollbackSnapshot(t.Context(), 1, WithRestoreParallelism(before+3)), errInjected)
+	require.Equal(t, before+3, during)
+	require.Equal(t, before, threads(t, client.db))
+	require.Equal(t, 2, countEvents(t, client))
+}
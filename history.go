@@ -0,0 +1,74 @@
+package quack
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"time"
+)
+
+const historyTable = "quack_schema_history"
+
+type SchemaChange struct {
+	Time      time.Time
+	Table     string
+	Operation string
+	Columns   []Column
+}
+
+// ensureHistory creates the history table. Concurrent transactions that
+// both create it conflict, so parallel writers call this up front.
+func ensureHistory(ctx context.Context, db querier) error {
+	_, err := db.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS "+historyTable+" (ts TIMESTAMP, table_name VARCHAR, operation VARCHAR, columns JSON);")
+	return err
+}
+
+// recordSchema appends the current columns of table to the schema history.
+func recordSchema(ctx context.Context, db querier, table, op string) error {
+	columns, err := describe(ctx, db, quoteTable(table))
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(columns)
+	if err != nil {
+		return err
+	}
+	if err := ensureHistory(ctx, db); err != nil {
+		return err
+	}
+	_, err = db.ExecContext(ctx, "INSERT INTO "+historyTable+" VALUES (?, ?, ?, ?);", time.Now().UTC(), table, op, string(b))
+	return err
+}
+
+// SchemaHistory lists the schema changes quack made to table, oldest first.
+func (c *Client) SchemaHistory(ctx context.Context, table string) (_ []SchemaChange, err error) {
+	if err := c.rlock("SchemaHistory"); err != nil {
+		return nil, err
+	}
+	defer c.runlock("SchemaHistory", &err)
+	if err := tableExists(ctx, c.db, historyTable); os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	rows, err := c.db.QueryContext(ctx, "SELECT ts, table_name, operation, columns::VARCHAR FROM "+historyTable+" WHERE table_name = ? ORDER BY ts;", table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var changes []SchemaChange
+	for rows.Next() {
+		var (
+			change  SchemaChange
+			columns string
+		)
+		if err := rows.Scan(&change.Time, &change.Table, &change.Operation, &columns); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(columns), &change.Columns); err != nil {
+			return nil, err
+		}
+		changes = append(changes, change)
+	}
+	return changes, rows.Err()
+}
@@ -0,0 +1,42 @@
+package quack
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_SchemaHistory(t *testing.T) {
+	dir := t.TempDir()
+	operations := func(t *testing.T, client *Client, table string) []string {
+		t.Helper()
+		changes, err := client.SchemaHistory(t.Context(), table)
+		require.NoError(t, err)
+		var ops []string
+		for _, c := range changes {
+			ops = append(ops, c.Operation)
+		}
+		return ops
+	}
+	client, err := New(dir, 3)
+	require.NoError(t, err)
+	require.NoError(t, client.CreateTable(t.Context(), "t", []Column{{Name: "id", Type: "INTEGER"}}))
+	require.NoError(t, client.Insert(t.Context(), "events", strings.NewReader(`{"name":"a"}`)))
+	require.NoError(t, client.Deduplicate(t.Context(), "events"))
+	require.Equal(t, []string{"create"}, operations(t, client, "t"))
+	require.Equal(t, []string{"create", "rewrite"}, operations(t, client, "events"))
+	require.NoError(t, client.Close(t.Context()))
+
+	client, err = New(dir, 3)
+	require.NoError(t, err)
+	require.NoError(t, client.AddColumn(t.Context(), "t", Column{Name: "note", Type: "VARCHAR", Nullable: true}))
+	changes, err := client.SchemaHistory(t.Context(), "t")
+	require.NoError(t, err)
+	require.Len(t, changes, 2)
+	require.Equal(t, []Column{{Name: "id", Type: "INTEGER"}, {Name: "note", Type: "VARCHAR", Nullable: true}}, changes[1].Columns)
+
+	require.NoError(t, client.RollbackSnapshot(t.Context(), 1))
+	require.Equal(t, []string{"create"}, operations(t, client, "t"))
+	require.NoError(t, client.Close(t.Context()))
+}
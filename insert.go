@@ -0,0 +1,247 @@
+package quack
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+type InsertResult struct {
+	// Table is the table the rows were loaded into.
+	Table string
+	Rows  int64
+	// Created is set when the insert created the table, and Columns holds
+	// the table's columns after the insert.
+	Created bool
+	Columns []Column
+	// Replaced counts the existing rows Upsert replaced, and Skipped the
+	// duplicate rows DedupOnInsert left out.
+	Replaced int64
+	Skipped  int64
+	// Bytes is the size of the staged input.
+	Bytes int64
+	// Truncated is set when a row limit cut the input short.
+	Truncated bool
+	// Violations counts the documents that failed WithJSONSchema
+	// validation, and ViolationSamples describes the first few.
+	Violations       int64
+	ViolationSamples []Violation
+}
+
+type insertConfig struct {
+	format Format
+	csv    *CSVOptions
+	// columns are the explicit input columns of WithColumns.
+	columns []Column
+	lenient bool
+	// timestamps maps columns to their TimestampFormat formats.
+	timestamps  map[string][]string
+	epochMillis []string
+	// mapping and selected rename and select input columns.
+	mapping  map[string]string
+	selected []string
+	// keys are the key columns of Upsert.
+	keys   []string
+	dedup  bool
+	limit  int64
+	sample float64
+	suffix string
+	// chunkSize is the staged input size of InsertStream.
+	chunkSize int64
+	// precheck is called with the staged input size before loading.
+	precheck func(size int64) error
+	// journal is called inside the loading transaction with the staged
+	// input. undo is called if the transaction does not commit.
+	journal func(ctx context.Context, tx querier, table, name string) (undo func(), err error)
+
+	jsonSchema   []byte
+	onViolation  ViolationPolicy
+	strictTypes  bool
+	evolve       bool
+	strictSchema bool
+	// continueOnError is ContinueOnInputError.
+	continueOnError bool
+}
+
+type InsertOption func(*insertConfig)
+
+func newInsertConfig(opts []InsertOption) insertConfig {
+	var cfg insertConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg
+}
+
+// WithRowLimit loads at most n rows of the input.
+func WithRowLimit(n int64) InsertOption {
+	return func(c *insertConfig) { c.limit = n }
+}
+
+// SampleFraction loads a random sample of about fraction of the input rows,
+// e.g. 0.01 for 1%, each row kept independently of the others. A journal
+// replay draws a new sample.
+func SampleFraction(fraction float64) InsertOption {
+	return func(c *insertConfig) { c.sample = fraction }
+}
+
+// WithTargetSuffix loads into the table named by the table argument plus
+// suffix, e.g. "_preview" to keep samples apart from the real table.
+func WithTargetSuffix(suffix string) InsertOption {
+	return func(c *insertConfig) { c.suffix = suffix }
+}
+
+// WithJSONSchema validates every document of JSON input against schema
+// before loading it. What happens to documents that fail is set with
+// OnViolation; by default the insert fails.
+func WithJSONSchema(schema []byte) InsertOption {
+	return func(c *insertConfig) { c.jsonSchema = schema }
+}
+
+// SkipJSONSchema skips validation set by an earlier WithJSONSchema, for
+// trusted sources sharing a list of options.
+func SkipJSONSchema() InsertOption {
+	return func(c *insertConfig) { c.jsonSchema = nil }
+}
+
+// OnViolation sets what happens to documents failing WithJSONSchema.
+func OnViolation(p ViolationPolicy) InsertOption {
+	return func(c *insertConfig) { c.onViolation = p }
+}
+
+// WithStrictTypes fails appends whose input columns have a different type
+// than the table's, instead of letting DuckDB cast them, e.g. "123" into an
+// INTEGER column. Only lossless widenings are accepted.
+func WithStrictTypes() InsertOption {
+	return func(c *insertConfig) { c.strictTypes = true }
+}
+
+// WithColumns reads the input columns with the given names and types
+// instead of inferring them, for JSON and CSV input. Other input columns
+// are ignored. A value that does not cast to its type fails the insert
+// unless LenientCasts is set.
+func WithColumns(columns []Column) InsertOption {
+	return func(c *insertConfig) { c.columns = columns }
+}
+
+// LenientCasts makes values that do not cast to their WithColumns type, or
+// do not parse as TimestampFormat or EpochMillisColumns timestamps, load as
+// NULL.
+func LenientCasts() InsertOption {
+	return func(c *insertConfig) { c.lenient = true }
+}
+
+// InsertWithSchema is Insert reading the columns of schema, which maps
+// column names to types, as WithColumns does. A new table gets the columns
+// in name order.
+func (c *Client) InsertWithSchema(ctx context.Context, table string, schema map[string]string, r io.Reader, opts ...InsertOption) error {
+	names := make([]string, 0, len(schema))
+	for name := range schema {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	columns := make([]Column, len(names))
+	for i, name := range names {
+		columns[i] = Column{Name: name, Type: schema[name]}
+	}
+	return c.Insert(ctx, table, r, append(opts, WithColumns(columns))...)
+}
+
+// InsertFile is Insert reading the file at path in place rather than from a
+// copy. path may be a glob pattern, such as /data/2024-*.json, to load
+// every matching file in one statement. Compressed files are read by
+// DuckDB, which recognizes them by their extension, e.g. .json.gz.
+func (c *Client) InsertFile(ctx context.Context, table, path string, format Format, opts ...InsertOption) error {
+	_, err := c.ingest(append(opts, withFormat(format)), func(cfg insertConfig) (InsertResult, error) {
+		return insertFile(ctx, c.db, c.stagingDir, table, path, cfg)
+	})
+	return err
+}
+
+// inputFiles lists the files matching pattern, or pattern itself when it
+// has no glob characters.
+func inputFiles(pattern string) ([]string, error) {
+	if !strings.ContainsAny(pattern, "*?[") {
+		return []string{pattern}, nil
+	}
+	files, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("%w: no files match %s", os.ErrNotExist, pattern)
+	}
+	return files, nil
+}
+
+// source returns the table function reading the staged input file.
+func (c insertConfig) source(file string) string {
+	if c.columns == nil {
+		if c.format == FormatCSV && c.csv != nil {
+			return fmt.Sprintf("read_csv_auto(%s, %s)", quoteLiteral(file), c.csv.readParams())
+		}
+		return c.format.reader(file)
+	}
+	// Lenient reads keep the values as JSON or text for TRY_CAST.
+	raw := "JSON"
+	if c.format == FormatCSV {
+		raw = "VARCHAR"
+	}
+	fields := make([]string, len(c.columns))
+	casts := make([]string, len(c.columns))
+	for i, col := range c.columns {
+		typ := col.Type
+		if c.lenient && !strings.EqualFold(typ, "VARCHAR") {
+			typ = raw
+		}
+		fields[i] = quoteLiteral(col.Name) + ": " + quoteLiteral(typ)
+		casts[i] = fmt.Sprintf("TRY_CAST(%s AS %s) AS %s", quoteIdent(col.Name), col.Type, quoteIdent(col.Name))
+	}
+	params := "columns = {" + strings.Join(fields, ", ") + "}"
+	reader := fmt.Sprintf("read_json(%s, %s)", quoteLiteral(file), params)
+	if c.format == FormatCSV {
+		if c.csv != nil {
+			params = c.csv.readParams() + ", " + params
+		}
+		reader = fmt.Sprintf("read_csv_auto(%s, %s)", quoteLiteral(file), params)
+	}
+	if !c.lenient {
+		return reader
+	}
+	return fmt.Sprintf("(SELECT %s FROM %s)", strings.Join(casts, ", "), reader)
+}
+
+// copyOptions returns the COPY options for the staged input.
+func (c insertConfig) copyOptions() string {
+	if c.format == FormatCSV && c.csv != nil {
+		return "FORMAT csv, " + c.csv.params(" ")
+	}
+	return "FORMAT " + c.format.String()
+}
+
+// copies reports whether the input can be loaded into an existing table
+// with COPY, which reads every input column by position for parquet.
+func (c insertConfig) copies() bool {
+	return c.limit <= 0 && c.sample == 0 && c.columns == nil && !c.parsesTimestamps() && !c.projects() && c.format != FormatParquet && (c.csv == nil || c.csv.Columns == nil)
+}
+
+// validate rejects configurations that cannot load, before any SQL runs.
+func (c insertConfig) validate() error {
+	if c.sample < 0 || c.sample > 1 {
+		return fmt.Errorf("sample fraction %g is not between 0 and 1", c.sample)
+	}
+	if c.csv == nil {
+		return nil
+	}
+	if err := c.csv.validate(); err != nil {
+		return err
+	}
+	if c.csv.Columns != nil && c.columns != nil {
+		return fmt.Errorf("csv column types cannot be combined with WithColumns")
+	}
+	return nil
+}
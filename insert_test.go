@@ -0,0 +1,156 @@
+package quack
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_IngestPreview(t *testing.T) {
+	client, err := New(t.TempDir(), 1)
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+	input := strings.Repeat(`{"id":1}`+"\n", 10)
+	cols := []Column{{Name: "id", Type: "BIGINT", Nullable: true}}
+	res, err := client.Ingest(t.Context(), "feed", strings.NewReader(input), WithRowLimit(3), WithTargetSuffix("_preview"))
+	require.NoError(t, err)
+	require.Equal(t, InsertResult{Table: "feed_preview", Rows: 3, Created: true, Columns: cols, Bytes: 90, Truncated: true}, res)
+	res, err = client.Ingest(t.Context(), "feed", strings.NewReader(input), WithRowLimit(4), WithTargetSuffix("_preview"))
+	require.NoError(t, err)
+	require.Equal(t, InsertResult{Table: "feed_preview", Rows: 4, Columns: cols, Bytes: 90, Truncated: true}, res)
+	res, err = client.Ingest(t.Context(), "feed", strings.NewReader(input), WithRowLimit(20))
+	require.NoError(t, err)
+	require.Equal(t, InsertResult{Table: "feed", Rows: 10, Created: true, Columns: cols, Bytes: 90}, res)
+	res, err = client.Ingest(t.Context(), "feed", strings.NewReader(input))
+	require.NoError(t, err)
+	require.Equal(t, InsertResult{Table: "feed", Rows: 10, Columns: cols, Bytes: 90}, res)
+}
+
+func Test_InsertWithSchema(t *testing.T) {
+	client, err := New(t.TempDir(), 1)
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+	schema := map[string]string{"id": "BIGINT", "name": "VARCHAR", "tags": "VARCHAR[]"}
+	input := `{"id":"1","name":"a","tags":["x"],"extra":true}` + "\n" + `{"id":2,"name":3}`
+	require.NoError(t, client.InsertWithSchema(t.Context(), "events", schema, strings.NewReader(input)))
+	columns, err := describe(t.Context(), client.db, "events")
+	require.NoError(t, err)
+	require.Equal(t, []Column{{Name: "id", Type: "BIGINT", Nullable: true}, {Name: "name", Type: "VARCHAR", Nullable: true}, {Name: "tags", Type: "VARCHAR[]", Nullable: true}}, columns)
+	rows := func(table string) string {
+		var s string
+		require.NoError(t, client.db.QueryRow(fmt.Sprintf("SELECT string_agg(concat_ws('|', coalesce(id::VARCHAR, 'NULL'), name), ',' ORDER BY rowid) FROM %s;", table)).Scan(&s))
+		return s
+	}
+	require.Equal(t, "1|a,2|3", rows("events"))
+
+	mixed := `{"id":"N/A","name":"b"}` + "\n" + `{"id":4,"name":"c"}`
+	require.ErrorContains(t, client.InsertWithSchema(t.Context(), "events", schema, strings.NewReader(mixed)), `"N/A"`)
+	require.ErrorContains(t, client.InsertWithSchema(t.Context(), "fresh", schema, strings.NewReader(mixed)), `"N/A"`)
+	require.Error(t, tableExists(t.Context(), client.db, "fresh"))
+	require.NoError(t, client.InsertWithSchema(t.Context(), "events", schema, strings.NewReader(mixed), LenientCasts()))
+	require.Equal(t, "1|a,2|3,NULL|b,4|c", rows("events"))
+
+	csvColumns := WithColumns([]Column{{Name: "id", Type: "BIGINT"}, {Name: "name", Type: "VARCHAR"}})
+	csv := "id,name\nN/A,d\n5,e\n"
+	require.Error(t, client.Insert(t.Context(), "people", strings.NewReader(csv), WithCSV(CSVOptions{Header: true}), csvColumns))
+	require.NoError(t, client.Insert(t.Context(), "people", strings.NewReader(csv), WithCSV(CSVOptions{Header: true}), csvColumns, LenientCasts()))
+	require.Equal(t, "NULL|d,5|e", rows("people"))
+	require.ErrorContains(t, client.InsertParquet(t.Context(), "people", strings.NewReader("PAR1"), csvColumns), "parquet input has its own column types")
+}
+
+func Test_InsertFile(t *testing.T) {
+	client, err := New(t.TempDir(), 1, WithJournal())
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"2024-01.json": `{"id":1}` + "\n",
+		"2024-02.json": `{"id":2}` + "\n" + `{"id":3}` + "\n",
+		"2023-12.json": `{"id":0}` + "\n",
+		"it's.json":    `{"id":4}` + "\n",
+		"data.csv":     "id\n5\n",
+	} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+	require.NoError(t, client.InsertFile(t.Context(), "events", filepath.Join(dir, "2024-*.json"), FormatJSON))
+	require.NoError(t, client.InsertFile(t.Context(), "events", filepath.Join(dir, "it's.json"), FormatJSON))
+	require.NoError(t, client.InsertFile(t.Context(), "events", filepath.Join(dir, "data.csv"), FormatCSV))
+	var ids string
+	require.NoError(t, client.db.QueryRow("SELECT string_agg(id::VARCHAR, ',' ORDER BY id) FROM events;").Scan(&ids))
+	require.Equal(t, "1,2,3,4,5", ids)
+	stats, err := client.Stats(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, int64(5), stats.Tables["events"].RowsInserted)
+	entries, err := client.journalEntries()
+	require.NoError(t, err)
+	require.Len(t, entries, 4)
+
+	err = client.InsertFile(t.Context(), "events", filepath.Join(dir, "2025-*.json"), FormatJSON)
+	require.ErrorIs(t, err, os.ErrNotExist)
+	require.Error(t, client.InsertFile(t.Context(), "events", filepath.Join(dir, "it's.json'); DROP TABLE events; --"), FormatJSON))
+	require.NoError(t, tableExists(t.Context(), client.db, "events"))
+	require.ErrorContains(t, client.InsertFile(t.Context(), "events", filepath.Join(dir, "2024-*.json"), FormatJSON, WithJSONSchema([]byte(`{}`))), "single input file")
+	for _, name := range []string{"2024-01.json", "it's.json"} {
+		_, err = os.Stat(filepath.Join(dir, name))
+		require.NoError(t, err)
+	}
+}
+
+func Test_InsertCount(t *testing.T) {
+	client, err := New(t.TempDir(), 1)
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+	for _, empty := range []string{"", "\n\n", "[]"} {
+		n, err := client.InsertCount(t.Context(), "events", strings.NewReader(empty))
+		require.NoError(t, err)
+		require.Zero(t, n)
+		require.Error(t, tableExists(t.Context(), client.db, "events"))
+	}
+	n, err := client.InsertCount(t.Context(), "events", strings.NewReader(`{"id":1}`+"\n"+`{"id":2}`))
+	require.NoError(t, err)
+	require.Equal(t, int64(2), n)
+	n, err = client.InsertCount(t.Context(), "events", strings.NewReader(`{"id":3}`))
+	require.NoError(t, err)
+	require.Equal(t, int64(1), n)
+	n, err = client.InsertCount(t.Context(), "events", strings.NewReader(""))
+	require.NoError(t, err)
+	require.Zero(t, n)
+	_, err = client.InsertCount(t.Context(), "events", strings.NewReader(`{"id":"x"}`))
+	require.Error(t, err)
+	columns, err := describe(t.Context(), client.db, "events")
+	require.NoError(t, err)
+	require.Equal(t, []Column{{Name: "id", Type: "BIGINT", Nullable: true}}, columns)
+}
+
+func Test_SampleFraction(t *testing.T) {
+	client, err := New(t.TempDir(), 1)
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+	var b strings.Builder
+	for i := range 2000 {
+		fmt.Fprintf(&b, `{"id":%d}`+"\n", i)
+	}
+	count := func() int64 {
+		var n int64
+		require.NoError(t, client.db.QueryRow("SELECT count(*) FROM events;").Scan(&n))
+		return n
+	}
+	created, err := client.InsertCount(t.Context(), "events", strings.NewReader(b.String()), SampleFraction(0.25))
+	require.NoError(t, err)
+	require.Equal(t, created, count())
+	require.InDelta(t, 500, created, 150)
+	appended, err := client.InsertCount(t.Context(), "events", strings.NewReader(b.String()), SampleFraction(0.25), WithRowLimit(100))
+	require.NoError(t, err)
+	require.LessOrEqual(t, appended, int64(100))
+	require.Equal(t, created+appended, count())
+	columns, err := describe(t.Context(), client.db, "events")
+	require.NoError(t, err)
+	require.Equal(t, []Column{{Name: "id", Type: "BIGINT", Nullable: true}}, columns)
+
+	_, err = client.InsertCount(t.Context(), "events", strings.NewReader(b.String()), SampleFraction(1.5))
+	require.ErrorContains(t, err, "not between 0 and 1")
+}
@@ -0,0 +1,240 @@
+package quack
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+	"sync"
+)
+
+// formatFromExt infers the input format from a file extension.
+func formatFromExt(name string) (Format, bool) {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".json", ".ndjson", ".jsonl":
+		return FormatJSON, true
+	case ".csv":
+		return FormatCSV, true
+	case ".parquet":
+		return FormatParquet, true
+	}
+	return 0, false
+}
+
+func sanitizeTable(name string) string {
+	b := []byte(name)
+	for i := range b {
+		if !isIdentPart(b[i]) {
+			b[i] = '_'
+		}
+	}
+	if len(b) > 0 && !isIdentStart(b[0]) {
+		return "t_" + string(b)
+	}
+	return string(b)
+}
+
+// TableFromFile names the table after the file's base name without its
+// extension. It is the default mapping of InsertFS.
+func TableFromFile(p string) string {
+	base := path.Base(p)
+	return sanitizeTable(strings.TrimSuffix(base, path.Ext(base)))
+}
+
+// TablePrefix maps files to the table named by the part of their base name
+// before sep, so events-001.json and events-002.json both load into events.
+func TablePrefix(sep string) func(string) string {
+	return func(p string) string {
+		base := path.Base(p)
+		base = strings.TrimSuffix(base, path.Ext(base))
+		prefix, _, _ := strings.Cut(base, sep)
+		return sanitizeTable(prefix)
+	}
+}
+
+type insertFSConfig struct {
+	table       func(string) string
+	transaction bool
+	parallelism int
+	insert      []InsertOption
+}
+
+type InsertFSOption func(*insertFSConfig)
+
+// WithTableName sets how file paths map to table names.
+func WithTableName(fn func(path string) string) InsertFSOption {
+	return func(c *insertFSConfig) { c.table = fn }
+}
+
+// InTransaction loads all files in one transaction. The first failing file
+// rolls back everything loaded so far and the remaining files are skipped.
+func InTransaction() InsertFSOption {
+	return func(c *insertFSConfig) { c.transaction = true }
+}
+
+// Parallel loads up to n tables concurrently. Files of the same table are
+// always loaded in order.
+func Parallel(n int) InsertFSOption {
+	return func(c *insertFSConfig) { c.parallelism = n }
+}
+
+// WithFileInsertOptions sets the options each file is inserted with. The
+// format always follows the file extension.
+func WithFileInsertOptions(opts ...InsertOption) InsertFSOption {
+	return func(c *insertFSConfig) { c.insert = opts }
+}
+
+type FileError struct {
+	Path  string
+	Table string
+	Err   error
+}
+
+func (e *FileError) Error() string {
+	return fmt.Sprintf("%s (table %s): %v", e.Path, e.Table, e.Err)
+}
+
+func (e *FileError) Unwrap() error { return e.Err }
+
+type InsertFSResult struct {
+	// Rows is the number of rows loaded per table.
+	Rows   map[string]int64
+	Errors []*FileError
+}
+
+type fsFile struct {
+	path, table string
+	format      Format
+}
+
+// InsertFS loads every file of fsys with a recognized extension (.json,
+// .ndjson, .jsonl, .csv, .parquet) into the table its path maps to. Failing
+// files are collected in the result instead of stopping the load; the
+// returned error joins them.
+func (c *Client) InsertFS(ctx context.Context, fsys fs.FS, opts ...InsertFSOption) (_ *InsertFSResult, err error) {
+	cfg := insertFSConfig{table: TableFromFile, parallelism: 1}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.transaction && cfg.parallelism > 1 {
+		return nil, errors.New("parallel loading cannot run in a single transaction")
+	}
+	if err := c.ingestConfig(cfg.insert).validate(); err != nil {
+		return nil, err
+	}
+	result := &InsertFSResult{Rows: make(map[string]int64)}
+	byTable := make(map[string][]fsFile)
+	var tables []string
+	err = fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		table := cfg.table(p)
+		format, ok := formatFromExt(p)
+		if !ok {
+			result.Errors = append(result.Errors, &FileError{Path: p, Table: table, Err: errors.New("unknown file format")})
+			return nil
+		}
+		if _, ok := byTable[table]; !ok {
+			tables = append(tables, table)
+		}
+		byTable[table] = append(byTable[table], fsFile{path: p, table: table, format: format})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := c.lock("InsertFS"); err != nil {
+		return nil, err
+	}
+	defer c.unlock("InsertFS", &err)
+	loadFile := func(db querier, f fsFile) (InsertResult, error) {
+		r, err := fsys.Open(f.path)
+		if err != nil {
+			return InsertResult{}, err
+		}
+		defer r.Close()
+		icfg := c.ingestConfig(append(cfg.insert[:len(cfg.insert):len(cfg.insert)], withFormat(f.format)))
+		return insert(ctx, db, c.stagingDir, f.table, r, icfg)
+	}
+	if cfg.transaction {
+		// A txn rather than a bare transaction, so the files are journaled
+		// and their entries dropped again should it roll back.
+		tx, err := begin(ctx, c.db)
+		if err != nil {
+			return nil, err
+		}
+		defer tx.rollback()
+		// The counters only learn of the files once they are committed.
+		var loaded []InsertResult
+		rows := make(map[string]int64)
+		var failed error
+		for _, table := range tables {
+			for _, f := range byTable[table] {
+				if failed != nil {
+					result.Errors = append(result.Errors, &FileError{Path: f.path, Table: table, Err: fmt.Errorf("skipped: %w", failed)})
+					continue
+				}
+				res, err := loadFile(tx, f)
+				if err != nil {
+					failed = fmt.Errorf("transaction rolled back after %s", f.path)
+					result.Errors = append(result.Errors, &FileError{Path: f.path, Table: table, Err: err})
+					continue
+				}
+				loaded = append(loaded, res)
+				rows[table] += res.Rows
+			}
+		}
+		if failed == nil {
+			if err := tx.commit(); err != nil {
+				return nil, err
+			}
+			for _, res := range loaded {
+				c.counters.insert(res)
+			}
+			result.Rows = rows
+		}
+		return result, result.err()
+	}
+	if err := ensureHistory(ctx, c.db); err != nil {
+		return nil, err
+	}
+	var (
+		mu  sync.Mutex
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, max(cfg.parallelism, 1))
+	)
+	for _, table := range tables {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(files []fsFile) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			for _, f := range files {
+				res, err := loadFile(c.db, f)
+				mu.Lock()
+				if err != nil {
+					result.Errors = append(result.Errors, &FileError{Path: f.path, Table: f.table, Err: err})
+				} else {
+					c.counters.insert(res)
+					result.Rows[f.table] += res.Rows
+				}
+				mu.Unlock()
+			}
+		}(byTable[table])
+	}
+	wg.Wait()
+	return result, result.err()
+}
+
+func (r *InsertFSResult) err() error {
+	errs := make([]error, len(r.Errors))
+	for i, e := range r.Errors {
+		errs[i] = e
+	}
+	return errors.Join(errs...)
+}
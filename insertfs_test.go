@@ -0,0 +1,101 @@
+package quack
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testFS() fstest.MapFS {
+	return fstest.MapFS{
+		"orders/orders-1.json": {Data: []byte("{\"id\":1}\n{\"id\":2}\n")},
+		"orders/orders-2.json": {Data: []byte("{\"id\":3}\n")},
+		"items-1.csv":          {Data: []byte("id,qty\n1,5\n2,7\n")},
+		"payments-1.ndjson":    {Data: []byte("{\"id\":1,\"amount\":9.5}\n")},
+		"README.md":            {Data: []byte("docs")},
+	}
+}
+
+func Test_InsertFS(t *testing.T) {
+	t.Run("per file report", func(t *testing.T) {
+		client, err := New(t.TempDir(), 1)
+		require.NoError(t, err)
+		defer client.Close(t.Context())
+		fsys := testFS()
+		fsys["broken-1.json"] = &fstest.MapFile{Data: []byte("{nope")}
+		result, err := client.InsertFS(t.Context(), fsys, WithTableName(TablePrefix("-")), Parallel(4))
+		require.Error(t, err)
+		require.Equal(t, map[string]int64{"orders": 3, "items": 2, "payments": 1}, result.Rows, err)
+		var failed []string
+		for _, e := range result.Errors {
+			failed = append(failed, e.Path)
+		}
+		require.ElementsMatch(t, []string{"README.md", "broken-1.json"}, failed)
+	})
+	t.Run("transaction", func(t *testing.T) {
+		client, err := New(t.TempDir(), 1)
+		require.NoError(t, err)
+		defer client.Close(t.Context())
+		fsys := testFS()
+		delete(fsys, "README.md")
+		result, err := client.InsertFS(t.Context(), fsys, WithTableName(TablePrefix("-")), InTransaction())
+		require.NoError(t, err)
+		require.Equal(t, map[string]int64{"orders": 3, "items": 2, "payments": 1}, result.Rows)
+		before, err := client.Stats(t.Context())
+		require.NoError(t, err)
+		require.Equal(t, int64(3), before.Tables["orders"].RowsInserted)
+
+		fsys = fstest.MapFS{
+			"orders-3.json": {Data: []byte("{\"id\":4}\n")},
+			"zzz-1.json":    {Data: []byte("{nope")},
+		}
+		result, err = client.InsertFS(t.Context(), fsys, WithTableName(TablePrefix("-")), InTransaction())
+		require.Error(t, err)
+		require.Empty(t, result.Rows)
+		after, err := client.Stats(t.Context())
+		require.NoError(t, err)
+		require.Equal(t, before.Tables, after.Tables, "a rolled-back load is not counted")
+		rows, err := client.Query(t.Context(), "SELECT count(*) FROM orders;")
+		require.NoError(t, err)
+		defer rows.Close()
+		var count int
+		require.True(t, rows.Next())
+		require.NoError(t, rows.Scan(&count))
+		require.Equal(t, 3, count)
+	})
+	t.Run("insert options", func(t *testing.T) {
+		for _, transaction := range []bool{false, true} {
+			client, err := New(t.TempDir(), 1, WithJournal())
+			require.NoError(t, err)
+			require.NoError(t, client.Close(t.Context()))
+			client, err = New(client.dir, 1, WithJournal())
+			require.NoError(t, err)
+			defer client.Close(t.Context())
+			fsys := testFS()
+			delete(fsys, "README.md")
+			opts := []InsertFSOption{WithTableName(TablePrefix("-")), WithFileInsertOptions(WithRowLimit(1))}
+			if transaction {
+				opts = append(opts, InTransaction())
+			}
+			result, err := client.InsertFS(t.Context(), fsys, opts...)
+			require.NoError(t, err)
+			require.Equal(t, map[string]int64{"orders": 2, "items": 1, "payments": 1}, result.Rows)
+
+			// The files were journaled.
+			require.NoError(t, client.RollbackSnapshot(t.Context(), 1))
+			n, err := client.RecoverJournal(t.Context())
+			require.NoError(t, err)
+			require.Equal(t, 4, n)
+			count, err := client.Count(t.Context(), "orders")
+			require.NoError(t, err)
+			require.Equal(t, int64(2), count)
+		}
+
+		client, err := New(t.TempDir(), 1, WithDiskBudget(1))
+		require.NoError(t, err)
+		defer client.Close(t.Context())
+		_, err = client.InsertFS(t.Context(), testFS())
+		require.ErrorIs(t, err, ErrQuotaExceeded)
+	})
+}
@@ -0,0 +1,127 @@
+package quack
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// InputError reports which of the inputs of InsertMany failed.
+type InputError struct {
+	Index int
+	Err   error
+}
+
+func (e *InputError) Error() string {
+	return fmt.Sprintf("input %d: %v", e.Index, e.Err)
+}
+
+func (e *InputError) Unwrap() error { return e.Err }
+
+// ContinueOnInputError makes InsertMany load the inputs that are fine when
+// others fail. The failures are still returned, joined.
+func ContinueOnInputError() InsertOption {
+	return func(c *insertConfig) { c.continueOnError = true }
+}
+
+// InsertMany is Insert for many inputs into one table. Up to parallelism
+// inputs are staged at once, outside the client lock, and the staged files
+// are then loaded in a single statement. Inputs that fail are reported as
+// *InputError; unless ContinueOnInputError is set, nothing is loaded then.
+func (c *Client) InsertMany(ctx context.Context, table string, readers []io.Reader, parallelism int, opts ...InsertOption) error {
+	if len(readers) == 0 {
+		return nil
+	}
+	cfg := c.ingestConfig(opts)
+	if err := cfg.validate(); err != nil {
+		return err
+	}
+	dir, err := os.MkdirTemp(c.stagingDir, insertPrefix)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	names := make([]string, len(readers))
+	errs := make([]error, len(readers))
+	sem := make(chan struct{}, max(parallelism, 1))
+	var wg sync.WaitGroup
+	for i, r := range readers {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			src, err := decompress(r)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			defer src.Close()
+			names[i], _, errs[i] = stage(dir, src, 0)
+		}()
+	}
+	wg.Wait()
+	failed := inputErrors(errs)
+	if len(failed) == len(readers) || (failed != nil && !cfg.continueOnError) {
+		return errors.Join(failed...)
+	}
+	_, err = c.ingest(opts, func(cfg insertConfig) (InsertResult, error) {
+		res, err := insertFile(ctx, c.db, c.stagingDir, table, filepath.Join(dir, insertPrefix+"*"), cfg)
+		if err == nil {
+			return res, nil
+		}
+		// Find the inputs that fail on their own, loading each in a
+		// transaction that is rolled back.
+		var bad []error
+		for i, name := range names {
+			if name == "" {
+				continue
+			}
+			if err := probe(ctx, c, table, name, cfg); err != nil {
+				bad = append(bad, &InputError{Index: i, Err: err})
+				os.Remove(name)
+				names[i] = ""
+			}
+		}
+		if bad == nil {
+			return res, err
+		}
+		failed = append(failed, bad...)
+		if !cfg.continueOnError || len(failed) == len(readers) {
+			return res, errors.Join(failed...)
+		}
+		return insertFile(ctx, c.db, c.stagingDir, table, filepath.Join(dir, insertPrefix+"*"), cfg)
+	})
+	if err != nil {
+		return err
+	}
+	return errors.Join(failed...)
+}
+
+// inputErrors wraps the non-nil errors of errs, indexed by input, or returns
+// nil when there are none.
+func inputErrors(errs []error) []error {
+	var failed []error
+	for i, err := range errs {
+		if err != nil {
+			failed = append(failed, &InputError{Index: i, Err: err})
+		}
+	}
+	return failed
+}
+
+// probe loads the staged input name into table and rolls it back. The
+// transaction keeps insertFile from journaling the input.
+func probe(ctx context.Context, c *Client, table, name string, cfg insertConfig) error {
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	_, err = insertFile(ctx, tx, c.stagingDir, table, name, cfg)
+	return err
+}
@@ -0,0 +1,57 @@
+package quack
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_InsertMany(t *testing.T) {
+	client, err := New(t.TempDir(), 1, WithJournal())
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+	count := func(table string) int64 {
+		var n int64
+		require.NoError(t, client.db.QueryRow("SELECT count(*) FROM "+table+";").Scan(&n))
+		return n
+	}
+	inputs := func(bad ...int) []io.Reader {
+		readers := make([]io.Reader, 20)
+		for i := range readers {
+			readers[i] = strings.NewReader(fmt.Sprintf(`{"id":%d,"name":"n%d"}`+"\n", i, i))
+		}
+		for _, i := range bad {
+			readers[i] = strings.NewReader(`{"id":"x`)
+		}
+		return readers
+	}
+	require.NoError(t, client.InsertMany(t.Context(), "events", inputs(), 4))
+	require.Equal(t, int64(20), count("events"))
+	stats, err := client.Stats(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, int64(20), stats.Tables["events"].RowsInserted)
+
+	err = client.InsertMany(t.Context(), "events", inputs(3, 11), 4)
+	var inputErr *InputError
+	require.ErrorAs(t, err, &inputErr)
+	require.Equal(t, 3, inputErr.Index)
+	require.ErrorContains(t, err, "input 11:")
+	require.Equal(t, int64(20), count("events"))
+
+	readers := inputs(5)
+	readers[7] = io.MultiReader(strings.NewReader(`{"id":7}`), iotestErrReader{})
+	err = client.InsertMany(t.Context(), "events", readers, 4, ContinueOnInputError())
+	require.ErrorContains(t, err, "input 5:")
+	require.ErrorContains(t, err, "input 7: broken")
+	require.Equal(t, int64(38), count("events"))
+	require.Equal(t, int64(1), count("events WHERE id = 5"))
+	require.Equal(t, int64(1), count("events WHERE id = 7"))
+}
+
+type iotestErrReader struct{}
+
+func (iotestErrReader) Read([]byte) (int, error) { return 0, errors.New("broken") }
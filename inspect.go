@@ -0,0 +1,223 @@
+package quack
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// SnapshotContents describes what a snapshot archive holds.
+type SnapshotContents struct {
+	SnapshotInfo
+	// Size is the size of the archive file.
+	Size int64
+	// Format is the format of the table data files, such as "json".
+	Format string
+	Tables []SnapshotTable
+	// Verified is set when every entry of the archive has a valid name and
+	// matches its checksum. VerifyError says what failed otherwise.
+	Verified    bool
+	VerifyError error
+}
+
+// SnapshotTable describes a table held by a snapshot.
+type SnapshotTable struct {
+	Name string
+	// File is the archive entry holding the table data, and Bytes its
+	// uncompressed size.
+	File  string
+	Bytes int64
+	// Rows counts the rows of the data file, or is -1 when they cannot be
+	// counted. CSV rows are counted by line, so values spanning lines make
+	// the count an estimate.
+	Rows int64
+}
+
+var (
+	createTable = regexp.MustCompile(`(?im)^CREATE TABLE ((?:"(?:[^"]|"")*"|[^\s(])+)`)
+	copyFormat  = regexp.MustCompile(`(?i)\bFORMAT\s+'?(\w+)'?`)
+)
+
+// InspectSnapshot describes the snapshot id without restoring it: the tables
+// it holds with their row counts, the data format and whether the archive
+// verifies. The archive is only read.
+func (c *Client) InspectSnapshot(ctx context.Context, id string) (_ *SnapshotContents, err error) {
+	infos, err := c.ListSnapshots()
+	if err != nil {
+		return nil, err
+	}
+	for _, info := range infos {
+		if info.ID != id {
+			continue
+		}
+		if err := c.lock("InspectSnapshot"); err != nil {
+			return nil, err
+		}
+		defer c.unlock("InspectSnapshot", &err)
+		return inspectArchive(ctx, c.db, c.stagingDir, info)
+	}
+	return nil, fmt.Errorf("%w: %s", ErrSnapshotNotFound, id)
+}
+
+func inspectArchive(ctx context.Context, db querier, staging string, info SnapshotInfo) (*SnapshotContents, error) {
+	zr, err := openArchive(info.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	contents := &SnapshotContents{SnapshotInfo: info}
+	if contents.Size, err = fileSize(info.Path); err != nil {
+		return nil, err
+	}
+	fail := func(err error) {
+		if contents.VerifyError == nil {
+			contents.VerifyError = err
+		}
+	}
+	var names []string
+	files := make(map[string]*zip.File)
+	for _, zf := range zr.File {
+		name, dir, err := entryName(zf.Name)
+		if err != nil {
+			fail(err)
+			continue
+		}
+		if !dir {
+			names = append(names, name)
+			files[name] = zf
+		}
+	}
+	root := archiveRoot(names)
+	script := func(name string) string {
+		zf := files[root+name]
+		if zf == nil {
+			fail(fmt.Errorf("archive has no %s", name))
+			return ""
+		}
+		r, err := zf.Open()
+		if err != nil {
+			fail(err)
+			return ""
+		}
+		defer r.Close()
+		b, err := io.ReadAll(r)
+		if err != nil {
+			fail(err)
+		}
+		return string(b)
+	}
+	// header marks the tables whose CSV data starts with a header line.
+	header := make(map[string]bool)
+	if stmts, ok := parseLoad(script("load.sql")); ok && len(stmts) > 0 {
+		for _, stmt := range stmts {
+			if m := copyFormat.FindStringSubmatch(stmt.rest); m != nil {
+				contents.Format = strings.ToLower(m[1])
+			}
+			header[stmt.file] = strings.Contains(strings.ToUpper(stmt.rest), "HEADER")
+			contents.Tables = append(contents.Tables, SnapshotTable{Name: stmt.table, File: stmt.file, Rows: -1})
+		}
+	} else {
+		for _, m := range createTable.FindAllStringSubmatch(script("schema.sql"), -1) {
+			contents.Tables = append(contents.Tables, SnapshotTable{Name: m[1], Rows: -1})
+		}
+	}
+	data := make(map[string]*SnapshotTable)
+	for i := range contents.Tables {
+		if t := &contents.Tables[i]; t.File != "" {
+			data[root+t.File] = t
+		}
+	}
+	for _, name := range names {
+		zf := files[name]
+		t := data[name]
+		if t == nil {
+			fail(verifyEntry(zf, io.Discard))
+			continue
+		}
+		t.Bytes = int64(zf.UncompressedSize64)
+		switch contents.Format {
+		case "json", "csv":
+			lines := &lineCounter{}
+			if err := verifyEntry(zf, lines); err != nil {
+				fail(err)
+				continue
+			}
+			t.Rows = lines.count()
+			if contents.Format == "csv" && header[t.File] && t.Rows > 0 {
+				t.Rows--
+			}
+		case "parquet":
+			rows, err := parquetRows(ctx, db, staging, zf)
+			fail(err)
+			if err == nil {
+				t.Rows = rows
+			}
+		default:
+			fail(verifyEntry(zf, io.Discard))
+		}
+	}
+	contents.Verified = contents.VerifyError == nil
+	return contents, nil
+}
+
+// verifyEntry reads zf into w, which makes the zip reader check its
+// checksum.
+func verifyEntry(zf *zip.File, w io.Writer) error {
+	r, err := zf.Open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	if _, err := io.Copy(w, r); err != nil {
+		return fmt.Errorf("%s: %w", path.Base(zf.Name), err)
+	}
+	return nil
+}
+
+// parquetRows reads the row count of a parquet entry from its metadata. The
+// entry is copied to staging, which also verifies it.
+func parquetRows(ctx context.Context, db querier, staging string, zf *zip.File) (int64, error) {
+	f, err := os.CreateTemp(staging, dumpPrefix)
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(f.Name())
+	err = verifyEntry(zf, f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return 0, err
+	}
+	var rows int64
+	err = db.QueryRowContext(ctx, "SELECT coalesce(sum(num_rows), 0) FROM parquet_file_metadata(?);", f.Name()).Scan(&rows)
+	return rows, err
+}
+
+// lineCounter counts the lines written to it, including a last one without
+// a newline.
+type lineCounter struct {
+	lines   int64
+	partial bool
+}
+
+func (l *lineCounter) Write(p []byte) (int, error) {
+	if len(p) > 0 {
+		l.lines += int64(bytes.Count(p, []byte{'\n'}))
+		l.partial = p[len(p)-1] != '\n'
+	}
+	return len(p), nil
+}
+
+func (l *lineCounter) count() int64 {
+	if l.partial {
+		return l.lines + 1
+	}
+	return l.lines
+}
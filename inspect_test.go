@@ -0,0 +1,89 @@
+package quack
+
+import (
+	"archive/zip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/require"
+)
+
+// corrupt rewrites the archive at p with a wrong checksum for name.
+func corrupt(t *testing.T, p, name string) {
+	t.Helper()
+	zr, err := zip.OpenReader(p)
+	require.NoError(t, err)
+	defer zr.Close()
+	out, err := os.Create(p + ".bad")
+	require.NoError(t, err)
+	zw := zip.NewWriter(out)
+	for _, zf := range zr.File {
+		h := zf.FileHeader
+		if strings.HasSuffix(h.Name, name) {
+			h.CRC32 ^= 1
+		}
+		w, err := zw.CreateRaw(&h)
+		require.NoError(t, err)
+		r, err := zf.OpenRaw()
+		require.NoError(t, err)
+		_, err = io.Copy(w, r)
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	require.NoError(t, out.Close())
+	require.NoError(t, os.Rename(p+".bad", p))
+}
+
+func Test_InspectSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	client, err := New(dir, 3)
+	require.NoError(t, err)
+	require.NoError(t, client.Insert(t.Context(), "events", strings.NewReader(`{"id":1}`+"\n"+`{"id":2}`+"\n"+`{"id":3}`)))
+	require.NoError(t, client.Insert(t.Context(), "people", strings.NewReader(`{"name":"a\nb"}`+"\n"+`{"name":"c"}`)))
+	require.NoError(t, client.Close(t.Context()))
+
+	client, err = New(dir, 3)
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+	infos, err := client.ListSnapshots()
+	require.NoError(t, err)
+	contents, err := client.InspectSnapshot(t.Context(), infos[0].ID)
+	require.NoError(t, err)
+	require.Equal(t, infos[0], contents.SnapshotInfo)
+	require.Positive(t, contents.Size)
+	require.Equal(t, "json", contents.Format)
+	require.True(t, contents.Verified)
+	require.NoError(t, contents.VerifyError)
+	rows := make(map[string]int64)
+	for _, table := range contents.Tables {
+		require.Positive(t, table.Bytes)
+		rows[table.Name] = table.Rows
+	}
+	require.Equal(t, int64(3), rows["events"])
+	require.Equal(t, int64(2), rows["people"])
+
+	_, err = client.InspectSnapshot(t.Context(), ulid.Make().String())
+	require.ErrorIs(t, err, ErrSnapshotNotFound)
+
+	t.Run("corrupt", func(t *testing.T) {
+		corrupt(t, infos[0].Path, "events.json")
+		contents, err := client.InspectSnapshot(t.Context(), infos[0].ID)
+		require.NoError(t, err)
+		require.False(t, contents.Verified)
+		require.ErrorContains(t, contents.VerifyError, "events.json")
+	})
+	t.Run("older archive", func(t *testing.T) {
+		data, err := os.ReadFile(filepath.Join("testdata", "snapshot_slash.zip"))
+		require.NoError(t, err)
+		id := ulid.Make().String()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "snapshot", id), data, 0644))
+		contents, err := client.InspectSnapshot(t.Context(), id)
+		require.NoError(t, err)
+		require.True(t, contents.Verified)
+		require.Equal(t, []SnapshotTable{{Name: "events", File: "events.json", Bytes: 42, Rows: 2}}, contents.Tables)
+	})
+}
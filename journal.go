@@ -0,0 +1,257 @@
+package quack
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+)
+
+// journalTable records the journal entries whose insert committed. Rows are
+// written in the same transaction as the insert, so an entry is applied
+// exactly when its row exists.
+const journalTable = "quack_journal"
+
+type journalEntry struct {
+	Table       string
+	Format      Format
+	CSV         *CSVOptions         `json:",omitempty"`
+	Columns     []Column            `json:",omitempty"`
+	Lenient     bool                `json:",omitempty"`
+	Timestamps  map[string][]string `json:",omitempty"`
+	EpochMillis []string            `json:",omitempty"`
+	Mapping     map[string]string   `json:",omitempty"`
+	Selected    []string            `json:",omitempty"`
+	Keys        []string            `json:",omitempty"`
+	Dedup       bool                `json:",omitempty"`
+	Evolve      bool                `json:",omitempty"`
+	Strict      bool                `json:",omitempty"`
+	Limit       int64
+	Sample      float64 `json:",omitempty"`
+}
+
+func (c *Client) journalDir() string {
+	return filepath.Join(c.dir, "journal")
+}
+
+func markApplied(ctx context.Context, db querier, id string) error {
+	if _, err := db.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS "+journalTable+" (id VARCHAR PRIMARY KEY, ts TIMESTAMP);"); err != nil {
+		return err
+	}
+	_, err := db.ExecContext(ctx, "INSERT INTO "+journalTable+" VALUES (?, ?);", id, time.Now().UTC())
+	return err
+}
+
+// journalHook writes the staged input to a new journal entry before it is
+// loaded. The entry directory is renamed into place only once complete.
+func (c *Client) journalHook(cfg insertConfig) func(context.Context, querier, string, string) (func(), error) {
+	return func(ctx context.Context, tx querier, table, name string) (func(), error) {
+		dir := c.journalDir()
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+		id := ulid.MustNewDefault(time.Now()).String()
+		tmp := filepath.Join(dir, id+".tmp")
+		if err := writeEntry(tmp, name, journalEntry{Table: table, Format: cfg.format, CSV: cfg.csv, Columns: cfg.columns, Lenient: cfg.lenient, Timestamps: cfg.timestamps, EpochMillis: cfg.epochMillis, Mapping: cfg.mapping, Selected: cfg.selected, Keys: cfg.keys, Dedup: cfg.dedup, Limit: cfg.limit, Sample: cfg.sample, Evolve: cfg.evolve, Strict: cfg.strictSchema}); err != nil {
+			os.RemoveAll(tmp)
+			return nil, err
+		}
+		entry := filepath.Join(dir, id)
+		if err := os.Rename(tmp, entry); err != nil {
+			os.RemoveAll(tmp)
+			return nil, err
+		}
+		if err := syncDir(dir); err != nil {
+			os.RemoveAll(entry)
+			return nil, err
+		}
+		undo := func() { os.RemoveAll(entry) }
+		if err := markApplied(ctx, tx, id); err != nil {
+			undo()
+			return nil, err
+		}
+		return undo, nil
+	}
+}
+
+func writeEntry(dir, payload string, entry journalEntry) error {
+	if err := os.Mkdir(dir, 0755); err != nil {
+		return err
+	}
+	src, err := os.Open(payload)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	if err := writeSynced(filepath.Join(dir, "data"), src); err != nil {
+		return err
+	}
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return writeSynced(filepath.Join(dir, "entry.json"), strings.NewReader(string(b)))
+}
+
+func writeSynced(name string, r io.Reader) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// journalEntries lists the complete journal entries, oldest first, and
+// removes entries left half-written by a crash.
+func (c *Client) journalEntries() ([]string, error) {
+	files, err := os.ReadDir(c.journalDir())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, f := range files {
+		if strings.HasSuffix(f.Name(), ".tmp") {
+			if err := os.RemoveAll(filepath.Join(c.journalDir(), f.Name())); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if _, err := ulid.ParseStrict(f.Name()); err == nil {
+			ids = append(ids, f.Name())
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+func appliedEntries(ctx context.Context, db querier) (map[string]bool, error) {
+	applied := make(map[string]bool)
+	if err := tableExists(ctx, db, journalTable); errors.Is(err, os.ErrNotExist) {
+		return applied, nil
+	} else if err != nil {
+		return nil, err
+	}
+	rows, err := db.QueryContext(ctx, "SELECT id FROM "+journalTable+";")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		applied[id] = true
+	}
+	return applied, rows.Err()
+}
+
+// RecoverJournal replays the journaled inserts the database does not
+// contain, oldest first, and reports how many it replayed. Run it after
+// restoring from a snapshot; New runs it when the journal is enabled.
+// Entries already applied are skipped, so it is safe to run repeatedly.
+func (c *Client) RecoverJournal(ctx context.Context) (_ int, err error) {
+	if err := c.lock("RecoverJournal"); err != nil {
+		return 0, err
+	}
+	defer c.unlock("RecoverJournal", &err)
+	ids, err := c.journalEntries()
+	if err != nil {
+		return 0, err
+	}
+	applied, err := appliedEntries(ctx, c.db)
+	if err != nil {
+		return 0, err
+	}
+	replayed := 0
+	for _, id := range ids {
+		if applied[id] {
+			continue
+		}
+		if err := c.replay(ctx, id); err != nil {
+			return replayed, fmt.Errorf("replay journal entry %s: %w", id, err)
+		}
+		replayed++
+	}
+	return replayed, nil
+}
+
+func (c *Client) replay(ctx context.Context, id string) error {
+	dir := filepath.Join(c.journalDir(), id)
+	b, err := os.ReadFile(filepath.Join(dir, "entry.json"))
+	if err != nil {
+		return err
+	}
+	var entry journalEntry
+	if err := json.Unmarshal(b, &entry); err != nil {
+		return err
+	}
+	f, err := os.Open(filepath.Join(dir, "data"))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	cfg := insertConfig{format: entry.Format, csv: entry.CSV, columns: entry.Columns, lenient: entry.Lenient, timestamps: entry.Timestamps, epochMillis: entry.EpochMillis, mapping: entry.Mapping, selected: entry.Selected, keys: entry.Keys, dedup: entry.Dedup, limit: entry.Limit, sample: entry.Sample, evolve: entry.Evolve, strictSchema: entry.Strict}
+	cfg.journal = func(ctx context.Context, tx querier, _, _ string) (func(), error) {
+		return func() {}, markApplied(ctx, tx, id)
+	}
+	res, err := insert(ctx, c.db, c.stagingDir, entry.Table, f, cfg)
+	if err != nil {
+		return err
+	}
+	c.counters.insert(res)
+	return nil
+}
+
+// truncateJournal drops the applied entries once a snapshot holds them.
+func (c *Client) truncateJournal(ctx context.Context) error {
+	ids, err := c.journalEntries()
+	if err != nil {
+		return err
+	}
+	applied, err := appliedEntries(ctx, c.db)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if !applied[id] {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(c.journalDir(), id)); err != nil {
+			return err
+		}
+	}
+	if len(applied) == 0 {
+		return nil
+	}
+	_, err = c.db.ExecContext(ctx, "DELETE FROM "+journalTable+";")
+	return err
+}
@@ -0,0 +1,61 @@
+package quack
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Journal(t *testing.T) {
+	dir := t.TempDir()
+	client, err := New(dir, 3, WithJournal())
+	require.NoError(t, err)
+	require.NoError(t, client.Insert(t.Context(), "events", strings.NewReader(`{"id":1}`)))
+	require.NoError(t, client.Close(t.Context()))
+	entries, err := os.ReadDir(client.journalDir())
+	require.NoError(t, err)
+	require.Empty(t, entries)
+
+	count := func(c *Client) int {
+		t.Helper()
+		var n int
+		require.NoError(t, c.db.QueryRow("SELECT count(*) FROM events;").Scan(&n))
+		return n
+	}
+	client, err = New(dir, 3, WithJournal())
+	require.NoError(t, err)
+	require.NoError(t, client.Insert(t.Context(), "events", strings.NewReader(`{"id":2}`)))
+	require.NoError(t, client.Insert(t.Context(), "events", strings.NewReader(`{"id":3}`)))
+	require.Error(t, client.Insert(t.Context(), "events", strings.NewReader(`{"id":"x"}`)))
+	require.NoError(t, client.Insert(t.Context(), "other", strings.NewReader(`{"v":"a"}`)))
+	ids, err := client.journalEntries()
+	require.NoError(t, err)
+	require.Len(t, ids, 3)
+
+	// Crash without a snapshot: every entry is applied already.
+	require.NoError(t, client.db.Close())
+	require.NoError(t, client.connecter.Close())
+	require.NoError(t, os.Mkdir(client.journalDir()+"/"+ids[0]+".tmp", 0755))
+	client, err = New(dir, 3, WithJournal())
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+	require.Equal(t, 3, count(client))
+	ids, err = client.journalEntries()
+	require.NoError(t, err)
+	require.Len(t, ids, 3)
+
+	require.NoError(t, client.RollbackSnapshot(t.Context(), 1))
+	require.Equal(t, 1, count(client))
+	require.Error(t, tableExists(t.Context(), client.db, "other"))
+	n, err := client.RecoverJournal(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, 3, n)
+	require.Equal(t, 3, count(client))
+	require.NoError(t, tableExists(t.Context(), client.db, "other"))
+	n, err = client.RecoverJournal(t.Context())
+	require.NoError(t, err)
+	require.Zero(t, n)
+	require.Equal(t, 3, count(client))
+}
@@ -0,0 +1,300 @@
+package quack
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+
+	"github.com/duckdb/duckdb-go/v2"
+)
+
+// jsonValue converts a value scanned from DuckDB into one encoding/json
+// can marshal without losing precision.
+func jsonValue(v any) any {
+	switch v := v.(type) {
+	case *big.Int:
+		return json.Number(v.String())
+	case duckdb.Decimal:
+		return json.Number(v.String())
+	case duckdb.UUID:
+		return v.String()
+	case float32:
+		return jsonFloat(float64(v))
+	case float64:
+		return jsonFloat(v)
+	case []any:
+		out := make([]any, len(v))
+		for i, e := range v {
+			out[i] = jsonValue(e)
+		}
+		return out
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for k, e := range v {
+			out[k] = jsonValue(e)
+		}
+		return out
+	case duckdb.Map:
+		out := make(map[string]any, len(v))
+		for k, e := range v {
+			out[fmt.Sprint(jsonValue(k))] = jsonValue(e)
+		}
+		return out
+	case duckdb.Union:
+		return map[string]any{v.Tag: jsonValue(v.Value)}
+	}
+	return v
+}
+
+// jsonFloat keeps NaN and infinities, which JSON cannot represent as
+// numbers, as strings.
+func jsonFloat(f float64) any {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return fmt.Sprint(f)
+	}
+	return f
+}
+
+// rowEncoder writes rows as JSON objects with keys in column order.
+type rowEncoder struct {
+	keys []json.RawMessage
+	vals []any
+	ptrs []any
+}
+
+func newRowEncoder(rows *sql.Rows) (*rowEncoder, error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	e := &rowEncoder{
+		keys: make([]json.RawMessage, len(columns)),
+		vals: make([]any, len(columns)),
+		ptrs: make([]any, len(columns)),
+	}
+	for i, col := range columns {
+		if e.keys[i], err = json.Marshal(col); err != nil {
+			return nil, err
+		}
+		e.ptrs[i] = &e.vals[i]
+	}
+	return e, nil
+}
+
+func (e *rowEncoder) encode(w *bufio.Writer, rows *sql.Rows) error {
+	if err := rows.Scan(e.ptrs...); err != nil {
+		return err
+	}
+	w.WriteByte('{')
+	for i, v := range e.vals {
+		if i > 0 {
+			w.WriteByte(',')
+		}
+		w.Write(e.keys[i])
+		w.WriteByte(':')
+		b, err := json.Marshal(jsonValue(v))
+		if err != nil {
+			return fmt.Errorf("column %s: %w", e.keys[i], err)
+		}
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+	}
+	return w.WriteByte('}')
+}
+
+// writeNDJSON writes every row of rows to w as one JSON object per line.
+func writeNDJSON(w io.Writer, rows *sql.Rows) error {
+	e, err := newRowEncoder(rows)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(w)
+	for rows.Next() {
+		if err := e.encode(bw, rows); err != nil {
+			return err
+		}
+		if err := bw.WriteByte('\n'); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// writeJSONArray writes every row of rows to w as an array of JSON objects.
+func writeJSONArray(w io.Writer, rows *sql.Rows) error {
+	e, err := newRowEncoder(rows)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(w)
+	bw.WriteByte('[')
+	for n := 0; rows.Next(); n++ {
+		if n > 0 {
+			bw.WriteByte(',')
+		}
+		if err := e.encode(bw, rows); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	bw.WriteString("]\n")
+	return bw.Flush()
+}
+
+// QueryJSON runs stmt with args and writes its result to w as a JSON array
+// of objects, or as NDJSON with AsNDJSON, keyed by column name. Rows are
+// encoded one at a time as they are read; the client lock is only held to
+// start the query.
+func (c *Client) QueryJSON(ctx context.Context, w io.Writer, stmt string, args ...any) error {
+	rows, cfg, err := c.streamQuery(ctx, "QueryJSON", stmt, args)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	if cfg.ndjson {
+		err = writeNDJSON(w, rows)
+	} else {
+		err = writeJSONArray(w, rows)
+	}
+	if err != nil {
+		return err
+	}
+	return rows.Close()
+}
+
+// defaultReaderBatch is the number of rows each query of QueryReader
+// fetches unless BatchRows says otherwise.
+const defaultReaderBatch = 100000
+
+type rowsReader struct {
+	*io.PipeReader
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func (r *rowsReader) Close() error {
+	r.PipeReader.Close()
+	r.cancel()
+	<-r.done
+	return nil
+}
+
+// QueryReader runs stmt and returns its result as NDJSON, one object per
+// row with keys in column order. The result is fetched BatchRows rows at a
+// time by re-running stmt with a LIMIT and OFFSET, so neither DuckDB nor Go
+// hold more than a batch however large it is. The statement must be a
+// single query without a LIMIT of its own, and it should order its rows,
+// as each batch is a separate query: rows written or deleted meanwhile can
+// shift them. A row limit fails the read once it is exceeded. Close stops
+// the query.
+func (c *Client) QueryReader(ctx context.Context, stmt string, args ...any) (_ io.ReadCloser, err error) {
+	stmts := splitStatements(stmt)
+	if len(stmts) != 1 || !isPlainQuery(stmts[0]) {
+		return nil, errors.New("reader needs a single query")
+	}
+	if hasKeyword(stmts[0], "LIMIT") {
+		return nil, errors.New("read statement cannot have a LIMIT")
+	}
+	stmt = stmts[0]
+	cfg, args := splitQueryArgs(args)
+	unlock, err := c.lockQuery("QueryReader", cfg)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(c.queryContext(ctx, cfg))
+	rows, err := c.readerBatch(ctx, stmt, args, cfg, 0)
+	unlock("QueryReader", &err)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	pr, pw := io.Pipe()
+	r := &rowsReader{PipeReader: pr, cancel: cancel, done: make(chan struct{})}
+	go func() {
+		defer close(r.done)
+		defer cancel()
+		pw.CloseWithError(c.writeBatches(ctx, pw, rows, stmt, args, cfg))
+	}()
+	return r, nil
+}
+
+// readerBatch runs the batch of stmt starting at offset. The caller holds
+// the lock cfg needs.
+func (c *Client) readerBatch(ctx context.Context, stmt string, args []any, cfg queryConfig, offset int) (*sql.Rows, error) {
+	var rows *sql.Rows
+	err := withSetting(ctx, c.db, "memory_limit", cfg.memoryLimit, func() (err error) {
+		rows, err = c.db.QueryContext(ctx, fmt.Sprintf("SELECT * FROM (%s) LIMIT %d OFFSET %d;", stmt, cfg.batchRows(), offset), args...)
+		return err
+	})
+	if err != nil && rows != nil {
+		rows.Close()
+	}
+	return rows, err
+}
+
+// writeBatches writes rows, the first batch of stmt, and the following
+// batches to w as NDJSON, taking the lock for each query.
+func (c *Client) writeBatches(ctx context.Context, w io.Writer, rows *sql.Rows, stmt string, args []any, cfg queryConfig) error {
+	limit := c.resultRows(cfg)
+	bw := bufio.NewWriter(w)
+	for offset := 0; ; offset += cfg.batchRows() {
+		if offset > 0 {
+			unlock, err := c.lockQuery("QueryReader", cfg)
+			if err != nil {
+				return err
+			}
+			rows, err = c.readerBatch(ctx, stmt, args, cfg, offset)
+			unlock("QueryReader", &err)
+			if err != nil {
+				return err
+			}
+		}
+		n, err := writeBatch(bw, rows, offset, limit)
+		if cerr := rows.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			// The rows written so far are still delivered before err.
+			bw.Flush()
+			return err
+		}
+		if n < cfg.batchRows() {
+			return bw.Flush()
+		}
+	}
+}
+
+// writeBatch writes rows to w as NDJSON, returning how many there were.
+// Rows past a positive limit, counting the offset ones before them, fail
+// with ErrResultTruncated.
+func writeBatch(w *bufio.Writer, rows *sql.Rows, offset, limit int) (int, error) {
+	e, err := newRowEncoder(rows)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for ; rows.Next(); n++ {
+		if limit > 0 && offset+n >= limit {
+			return n, fmt.Errorf("%w of %d rows", ErrResultTruncated, limit)
+		}
+		if err := e.encode(w, rows); err != nil {
+			return n, err
+		}
+		if err := w.WriteByte('\n'); err != nil {
+			return n, err
+		}
+	}
+	return n, rows.Err()
+}
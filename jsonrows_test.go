@@ -0,0 +1,142 @@
+package quack
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// rss is the resident set size of the process, which unlike the Go heap
+// includes the memory DuckDB allocates.
+func rss(t *testing.T) uint64 {
+	b, err := os.ReadFile("/proc/self/statm")
+	if err != nil {
+		t.Skip("no /proc/self/statm")
+	}
+	var size, resident uint64
+	_, err = fmt.Sscan(string(b), &size, &resident)
+	require.NoError(t, err)
+	return resident * uint64(os.Getpagesize())
+}
+
+func Test_QueryReader(t *testing.T) {
+	client, err := New(t.TempDir(), 1)
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+	t.Run("types", func(t *testing.T) {
+		r, err := client.QueryReader(t.Context(), `SELECT 1 AS b, 'x' AS a, 170141183460469231731687303715884105727::HUGEINT AS h, 1.50::DECIMAL(5,2) AS d,
+			[1, 2] AS l, {'k': 'v'} AS s, MAP {'m': 1} AS m, 'nan'::DOUBLE AS f, NULL AS n, TIMESTAMP '2024-01-02 03:04:05' AS ts`)
+		require.NoError(t, err)
+		b, err := io.ReadAll(r)
+		require.NoError(t, err)
+		require.NoError(t, r.Close())
+		require.Equal(t, `{"b":1,"a":"x","h":170141183460469231731687303715884105727,"d":1.5,"l":[1,2],"s":{"k":"v"},"m":{"m":1},"f":"NaN","n":null,"ts":"2024-01-02T03:04:05Z"}`+"\n", string(b))
+	})
+	t.Run("bounded memory", func(t *testing.T) {
+		base := rss(t)
+		r, err := client.QueryReader(t.Context(), "SELECT range AS i, md5(range::VARCHAR) AS s FROM range(2000000);")
+		require.NoError(t, err)
+		defer r.Close()
+		var (
+			ms             runtime.MemStats
+			heap, resident uint64
+			lines          int
+		)
+		sc := bufio.NewScanner(r)
+		for sc.Scan() {
+			lines++
+			if lines%100000 == 0 {
+				runtime.ReadMemStats(&ms)
+				heap = max(heap, ms.HeapInuse)
+				resident = max(resident, rss(t))
+			}
+		}
+		require.NoError(t, sc.Err())
+		require.Equal(t, 2000000, lines)
+		require.Less(t, heap, uint64(64<<20))
+		require.Less(t, max(resident, base)-base, uint64(64<<20))
+	})
+	t.Run("batches", func(t *testing.T) {
+		read := func(t *testing.T, opts ...any) (int, error) {
+			r, err := client.QueryReader(t.Context(), "SELECT range AS i FROM range(2500) ORDER BY i DESC;", opts...)
+			require.NoError(t, err)
+			defer r.Close()
+			sc := bufio.NewScanner(r)
+			n := 0
+			for ; sc.Scan(); n++ {
+				require.Equal(t, fmt.Sprintf(`{"i":%d}`, 2499-n), sc.Text())
+			}
+			return n, sc.Err()
+		}
+		n, err := read(t, BatchRows(1000))
+		require.NoError(t, err)
+		require.Equal(t, 2500, n)
+		n, err = read(t, BatchRows(500))
+		require.NoError(t, err)
+		require.Equal(t, 2500, n)
+		n, err = read(t, BatchRows(1000), ResultRowLimit(1500))
+		require.ErrorIs(t, err, ErrResultTruncated)
+		require.Equal(t, 1500, n)
+
+		_, err = client.QueryReader(t.Context(), "SELECT 1 LIMIT 1;")
+		require.ErrorContains(t, err, "cannot have a LIMIT")
+		_, err = client.QueryReader(t.Context(), "SELECT 1; SELECT 2;")
+		require.ErrorContains(t, err, "single query")
+	})
+	t.Run("memory limit", func(t *testing.T) {
+		_, err := client.QueryReader(t.Context(), "SELECT count(*) FROM (SELECT list(range) FROM range(20000000) GROUP BY range % 1000000);", WithQueryMemoryLimit("10MB"))
+		require.ErrorContains(t, err, "Out of Memory")
+		r, err := client.QueryReader(t.Context(), "SELECT 42 AS i;", WithQueryMemoryLimit("100MB"))
+		require.NoError(t, err)
+		b, err := io.ReadAll(r)
+		require.NoError(t, err)
+		require.NoError(t, r.Close())
+		require.Equal(t, `{"i":42}`+"\n", string(b))
+	})
+	t.Run("cancel", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(t.Context())
+		r, err := client.QueryReader(ctx, "SELECT range AS i FROM range(100000000);")
+		require.NoError(t, err)
+		line, err := bufio.NewReader(r).ReadString('\n')
+		require.NoError(t, err)
+		require.True(t, strings.HasPrefix(line, `{"i":`))
+		cancel()
+		_, err = io.ReadAll(r)
+		require.ErrorIs(t, err, context.Canceled)
+		r.Close()
+	})
+	t.Run("close early", func(t *testing.T) {
+		r, err := client.QueryReader(t.Context(), "SELECT range AS i FROM range(100000000);")
+		require.NoError(t, err)
+		_, err = r.Read(make([]byte, 16))
+		require.NoError(t, err)
+		require.NoError(t, r.Close())
+	})
+}
+
+func Test_QueryJSON(t *testing.T) {
+	client, err := New(t.TempDir(), 1)
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+	var buf strings.Builder
+	require.NoError(t, client.QueryJSON(t.Context(), &buf, `SELECT 170141183460469231731687303715884105727::HUGEINT AS h, 1.50::DECIMAL(5,2) AS d,
+		TIMESTAMP '2024-01-02 03:04:05' AS ts, [{'k': 'v'}] AS l, ? AS p FROM range(2);`, "it's"))
+	row := `{"h":170141183460469231731687303715884105727,"d":1.5,"ts":"2024-01-02T03:04:05Z","l":[{"k":"v"}],"p":"it's"}`
+	require.Equal(t, "["+row+","+row+"]\n", buf.String())
+
+	buf.Reset()
+	require.NoError(t, client.QueryJSON(t.Context(), &buf, "SELECT range AS i FROM range(?);", 3, AsNDJSON()))
+	require.Equal(t, "{\"i\":0}\n{\"i\":1}\n{\"i\":2}\n", buf.String())
+
+	buf.Reset()
+	require.NoError(t, client.QueryJSON(t.Context(), &buf, "SELECT 1 AS i WHERE false;"))
+	require.Equal(t, "[]\n", buf.String())
+	require.Error(t, client.QueryJSON(t.Context(), &buf, "SELECT * FROM missing;"))
+}
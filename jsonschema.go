@@ -0,0 +1,209 @@
+package quack
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+)
+
+type ViolationPolicy int
+
+const (
+	// ViolationsFail fails the insert if any document is invalid.
+	ViolationsFail ViolationPolicy = iota
+	// ViolationsDrop loads only the valid documents.
+	ViolationsDrop
+	// ViolationsReject loads the valid documents and appends the invalid
+	// ones, with their errors, to the table named after the target plus
+	// _rejects.
+	ViolationsReject
+)
+
+const (
+	rejectsSuffix = "_rejects"
+	// violationSamples caps the violations reported per insert.
+	violationSamples = 10
+)
+
+// Violation is one way a document failed JSON Schema validation.
+type Violation struct {
+	// Document is the index of the document in the input, from 0.
+	Document int64
+	// Pointer is the JSON pointer to the offending value in the document.
+	Pointer string
+	Message string
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("document %d at %q: %s", v.Document, v.Pointer, v.Message)
+}
+
+type SchemaViolationError struct {
+	Table      string
+	Violations int64
+	Samples    []Violation
+}
+
+func (e *SchemaViolationError) Error() string {
+	msgs := make([]string, len(e.Samples))
+	for i, v := range e.Samples {
+		msgs[i] = v.String()
+	}
+	return fmt.Sprintf("%s: %d documents violate the JSON Schema: %s", e.Table, e.Violations, strings.Join(msgs, "; "))
+}
+
+// schemaCheck is the outcome of validating a staged file: the valid
+// documents and the rejected ones, each as NDJSON in a staged file.
+type schemaCheck struct {
+	valid, rejects string
+	docs           int64
+	violations     int64
+	samples        []Violation
+}
+
+func (c *schemaCheck) remove() {
+	os.Remove(c.valid)
+	os.Remove(c.rejects)
+}
+
+func (c *schemaCheck) err(table string) error {
+	return &SchemaViolationError{Table: table, Violations: c.violations, Samples: c.samples}
+}
+
+func compileSchema(schema []byte) (*jsonschema.Schema, error) {
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(schema))
+	if err != nil {
+		return nil, fmt.Errorf("parse JSON Schema: %w", err)
+	}
+	c := jsonschema.NewCompiler()
+	if err := c.AddResource("schema.json", doc); err != nil {
+		return nil, err
+	}
+	return c.Compile("schema.json")
+}
+
+// jsonDocuments calls fn with each document of r, which holds either a JSON
+// array of documents or a sequence of them, without reading it all in.
+func jsonDocuments(r io.Reader, fn func(json.RawMessage) error) error {
+	br := bufio.NewReader(r)
+	dec := json.NewDecoder(br)
+	for {
+		b, err := br.Peek(1)
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if b[0] != ' ' && b[0] != '\t' && b[0] != '\r' && b[0] != '\n' {
+			break
+		}
+		br.ReadByte()
+	}
+	if b, _ := br.Peek(1); b[0] == '[' {
+		if _, err := dec.Token(); err != nil {
+			return err
+		}
+	}
+	for dec.More() {
+		var doc json.RawMessage
+		if err := dec.Decode(&doc); err != nil {
+			return err
+		}
+		if err := fn(doc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateStaged validates the JSON documents in the staged file name,
+// splitting them into a file of valid and a file of rejected documents.
+func validateStaged(staging, name string, cfg insertConfig) (*schemaCheck, error) {
+	if cfg.format != FormatJSON {
+		return nil, fmt.Errorf("JSON Schema validation needs JSON input, got %s", cfg.format)
+	}
+	schema, err := compileSchema(cfg.jsonSchema)
+	if err != nil {
+		return nil, err
+	}
+	in, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+	valid, err := os.CreateTemp(staging, insertPrefix)
+	if err != nil {
+		return nil, err
+	}
+	defer valid.Close()
+	rejects, err := os.CreateTemp(staging, insertPrefix)
+	if err != nil {
+		os.Remove(valid.Name())
+		return nil, err
+	}
+	defer rejects.Close()
+	check := &schemaCheck{valid: valid.Name(), rejects: rejects.Name()}
+	vw, rw := bufio.NewWriter(valid), bufio.NewWriter(rejects)
+	var index int64
+	err = jsonDocuments(in, func(doc json.RawMessage) error {
+		defer func() { index++ }()
+		v, err := jsonschema.UnmarshalJSON(bytes.NewReader(doc))
+		if err != nil {
+			return err
+		}
+		var ve *jsonschema.ValidationError
+		if err := schema.Validate(v); !errors.As(err, &ve) {
+			if err != nil {
+				return err
+			}
+			check.docs++
+			_, err := fmt.Fprintf(vw, "%s\n", doc)
+			return err
+		}
+		check.violations++
+		var found []Violation
+		for _, unit := range ve.BasicOutput().Errors {
+			if unit.Error != nil {
+				found = append(found, Violation{Document: index, Pointer: unit.InstanceLocation, Message: unit.Error.String()})
+			}
+		}
+		// The validator reports errors in no particular order.
+		slices.SortFunc(found, func(a, b Violation) int {
+			return cmp.Or(strings.Compare(a.Pointer, b.Pointer), strings.Compare(a.Message, b.Message))
+		})
+		msgs := make([]string, len(found))
+		for i, v := range found {
+			msgs[i] = v.Pointer + ": " + v.Message
+			if len(check.samples) < violationSamples {
+				check.samples = append(check.samples, v)
+			}
+		}
+		b, err := json.Marshal(map[string]any{"document": string(doc), "errors": msgs, "rejected_at": time.Now().UTC()})
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(rw, "%s\n", b)
+		return err
+	})
+	if err == nil {
+		err = vw.Flush()
+	}
+	if err == nil {
+		err = rw.Flush()
+	}
+	if err != nil {
+		check.remove()
+		return nil, err
+	}
+	return check, nil
+}
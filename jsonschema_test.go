@@ -0,0 +1,61 @@
+package quack
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_JSONSchema(t *testing.T) {
+	client, err := New(t.TempDir(), 3)
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+	schema := []byte(`{"type":"object","required":["id"],"properties":{"id":{"type":"integer"},"tags":{"type":"array","items":{"type":"string"}}}}`)
+	input := `{"id":1,"tags":["a"]}
+{"id":"x","tags":[1]}
+{"tags":[]}
+{"id":2}`
+	count := func(table string) int {
+		var n int
+		require.NoError(t, client.db.QueryRow("SELECT count(*) FROM "+table+";").Scan(&n))
+		return n
+	}
+
+	_, err = client.Ingest(t.Context(), "feed", strings.NewReader(input), WithJSONSchema(schema))
+	var verr *SchemaViolationError
+	require.ErrorAs(t, err, &verr)
+	require.Equal(t, int64(2), verr.Violations)
+	require.Equal(t, []Violation{
+		{Document: 1, Pointer: "/id", Message: "got string, want integer"},
+		{Document: 1, Pointer: "/tags/0", Message: "got number, want string"},
+		{Document: 2, Pointer: "", Message: "missing property 'id'"},
+	}, verr.Samples)
+	require.Error(t, tableExists(t.Context(), client.db, "feed"))
+
+	res, err := client.Ingest(t.Context(), "feed", strings.NewReader(input), WithJSONSchema(schema), OnViolation(ViolationsDrop))
+	require.NoError(t, err)
+	require.Equal(t, int64(2), res.Rows)
+	require.Equal(t, int64(2), res.Violations)
+	require.Len(t, res.ViolationSamples, 3)
+	require.Error(t, tableExists(t.Context(), client.db, "feed_rejects"))
+
+	res, err = client.Ingest(t.Context(), "feed", strings.NewReader("["+strings.ReplaceAll(input, "\n", ",")+"]"), WithJSONSchema(schema), OnViolation(ViolationsReject))
+	require.NoError(t, err)
+	require.Equal(t, int64(2), res.Rows)
+	require.Equal(t, 4, count("feed"))
+	require.Equal(t, 2, count("feed_rejects"))
+	var errs string
+	require.NoError(t, client.db.QueryRow("SELECT errors::VARCHAR FROM feed_rejects WHERE document->>'$.id' = 'x';").Scan(&errs))
+	require.Contains(t, errs, "/tags/0: got number, want string")
+
+	res, err = client.Ingest(t.Context(), "feed", strings.NewReader(`{"id":"y"}`), WithJSONSchema(schema), OnViolation(ViolationsDrop))
+	require.NoError(t, err)
+	require.Zero(t, res.Rows)
+	res, err = client.Ingest(t.Context(), "feed", strings.NewReader(`{"id":3}`), WithJSONSchema(schema), SkipJSONSchema())
+	require.NoError(t, err)
+	require.Zero(t, res.Violations)
+	require.Equal(t, 5, count("feed"))
+	_, err = client.Ingest(t.Context(), "feed", strings.NewReader(`{"id":3}`), WithJSONSchema([]byte(`{`)))
+	require.Error(t, err)
+}
@@ -0,0 +1,71 @@
+package quack
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// WithColumnMapping renames input columns before they reach the table,
+// mapping input names to column names, e.g. {"userId": "user_id"}. Input
+// columns not in mapping keep their name.
+func WithColumnMapping(mapping map[string]string) InsertOption {
+	return func(c *insertConfig) { c.mapping = mapping }
+}
+
+// SelectColumns loads only the named input columns and drops the rest.
+// Names are those of the input, before WithColumnMapping renames them.
+func SelectColumns(names ...string) InsertOption {
+	return func(c *insertConfig) { c.selected = names }
+}
+
+// projects reports whether the input columns are renamed or selected.
+func (c insertConfig) projects() bool {
+	return c.mapping != nil || c.selected != nil
+}
+
+// project wraps source in the projection of WithColumnMapping and
+// SelectColumns. Names of either that the input lacks fail with all of
+// them listed.
+func project(ctx context.Context, db querier, source string, cfg insertConfig) (string, error) {
+	columns, err := describe(ctx, db, "SELECT * FROM "+source)
+	if err != nil {
+		return "", csvError(err)
+	}
+	present := make(map[string]bool, len(columns))
+	for _, col := range columns {
+		present[strings.ToLower(col.Name)] = true
+	}
+	var missing []string
+	for name := range cfg.mapping {
+		if !present[strings.ToLower(name)] {
+			missing = append(missing, name)
+		}
+	}
+	for _, name := range cfg.selected {
+		if !present[strings.ToLower(name)] && !slices.Contains(missing, name) {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		slices.Sort(missing)
+		return "", fmt.Errorf("input has no column %s", strings.Join(missing, ", "))
+	}
+	rename := make(map[string]string, len(cfg.mapping))
+	for from, to := range cfg.mapping {
+		rename[strings.ToLower(from)] = to
+	}
+	var exprs []string
+	for _, col := range columns {
+		if cfg.selected != nil && !slices.ContainsFunc(cfg.selected, func(name string) bool { return strings.EqualFold(name, col.Name) }) {
+			continue
+		}
+		name := col.Name
+		if to, ok := rename[strings.ToLower(name)]; ok {
+			name = to
+		}
+		exprs = append(exprs, quoteIdent(col.Name)+" AS "+quoteIdent(name))
+	}
+	return fmt.Sprintf("(SELECT %s FROM %s)", strings.Join(exprs, ", "), source), nil
+}
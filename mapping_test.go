@@ -0,0 +1,30 @@
+package quack
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ColumnMapping(t *testing.T) {
+	client, err := New(t.TempDir(), 1)
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+	mapping := WithColumnMapping(map[string]string{"userId": "user_id", "eventName": "event_name"})
+	data := `{"userId":1,"eventName":"open","debug":"x"}`
+	require.NoError(t, client.Insert(t.Context(), "events", strings.NewReader(data), mapping, SelectColumns("userId", "eventName")))
+	columns, err := describe(t.Context(), client.db, "events")
+	require.NoError(t, err)
+	require.Equal(t, []Column{{Name: "user_id", Type: "BIGINT", Nullable: true}, {Name: "event_name", Type: "VARCHAR", Nullable: true}}, columns)
+
+	n, err := client.InsertCount(t.Context(), "events", strings.NewReader(`{"eventName":"close","userId":2}`), mapping)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), n)
+	var got string
+	require.NoError(t, client.db.QueryRow("SELECT string_agg(user_id || ':' || event_name, ',' ORDER BY rowid) FROM events;").Scan(&got))
+	require.Equal(t, "1:open,2:close", got)
+
+	err = client.Insert(t.Context(), "events", strings.NewReader(data), WithColumnMapping(map[string]string{"user": "user_id", "userId": "user_id"}), SelectColumns("name"))
+	require.EqualError(t, err, "input has no column name, user")
+}
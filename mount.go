@@ -0,0 +1,178 @@
+package quack
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+)
+
+// mainDatabase is the catalog name DuckDB derives from database.ddb.
+const mainDatabase = "database"
+
+func (c *Client) mountDir() string {
+	return filepath.Join(c.dir, "mount")
+}
+
+// MountSnapshot makes the tables of snapshot id queryable as schema.table
+// next to the live tables, e.g.
+//
+//	SELECT * FROM events e JOIN snap_x.events o USING (id)
+//
+// The snapshot is imported into a separately attached database, so the
+// mounted tables never take part in snapshots or rollback. The database is
+// attached read-only, and Insert, Deduplicate and DeleteWhere reject its
+// tables.
+// Mounts last until UnmountSnapshot or Close.
+func (c *Client) MountSnapshot(ctx context.Context, id, schema string) (err error) {
+	if err := c.lock("MountSnapshot"); err != nil {
+		return err
+	}
+	defer c.unlock("MountSnapshot", &err)
+	return c.mount(ctx, id, schema)
+}
+
+func (c *Client) mount(ctx context.Context, id, schema string) error {
+	if _, ok := c.mounts[schema]; ok {
+		return fmt.Errorf("schema %s is already mounted", schema)
+	}
+	infos, err := snapshotInfos(filepath.Join(c.dir, "snapshot"))
+	if err != nil {
+		return err
+	}
+	info, err := snapshotByID(infos, id)
+	if err != nil {
+		return err
+	}
+	dir, err := unzip(c.stagingDir, info.Path, nil)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	if err := os.MkdirAll(c.mountDir(), 0755); err != nil {
+		return err
+	}
+	// The file is not named after schema, which may be any identifier.
+	file := filepath.Join(c.mountDir(), ulid.MustNewDefault(time.Now()).String()+".ddb")
+	conn, err := c.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, fmt.Sprintf("ATTACH %s AS %s;", quoteLiteral(file), quoteIdent(schema))); err != nil {
+		return err
+	}
+	if err := func() error {
+		if _, err := conn.ExecContext(ctx, "USE "+quoteIdent(schema)+";"); err != nil {
+			return err
+		}
+		// The connection goes back to the pool, so always switch back
+		// even when ctx is already done.
+		defer conn.ExecContext(context.Background(), "USE "+mainDatabase+";")
+		return importDir(ctx, conn, dir, nil)
+	}(); err != nil {
+		conn.ExecContext(context.Background(), "DETACH "+quoteIdent(schema)+";")
+		os.Remove(file)
+		os.Remove(file + ".wal")
+		return err
+	}
+	// Reattaching read-only keeps every write away from the snapshot.
+	if _, err := conn.ExecContext(ctx, "DETACH "+quoteIdent(schema)+";"); err != nil {
+		return err
+	}
+	if _, err := conn.ExecContext(ctx, attachMount(file, schema)); err != nil {
+		os.Remove(file)
+		os.Remove(file + ".wal")
+		return err
+	}
+	c.mounts[schema] = file
+	return nil
+}
+
+func attachMount(file, schema string) string {
+	return fmt.Sprintf("ATTACH %s AS %s (READ_ONLY);", quoteLiteral(file), quoteIdent(schema))
+}
+
+// checkWritable fails for a table of a mounted snapshot, so writes naming
+// one are rejected before any SQL runs.
+func (c *Client) checkWritable(table string) error {
+	if schema, _ := splitTable(table); schema != "" {
+		if _, ok := c.mounts[schema]; ok {
+			return fmt.Errorf("table %s is in mounted snapshot %s, which is read-only", table, schema)
+		}
+	}
+	return nil
+}
+
+// snapPlaceholder marks the tables QueryAsOf reads from the snapshot.
+const snapPlaceholder = "{{snap}}"
+
+// QueryAsOf runs stmt with every {{snap}} placeholder replaced by the schema
+// of snapshot id, so
+//
+//	SELECT * FROM events e JOIN {{snap}}.events o USING (id)
+//
+// compares live events with those in the snapshot. Placeholders inside
+// string literals, quoted identifiers and comments are left alone, and stmt
+// must contain at least one. The snapshot is mounted on first use and stays
+// mounted as asof_<id> until UnmountSnapshot or Close.
+func (c *Client) QueryAsOf(ctx context.Context, id, stmt string, args ...any) (_ *sql.Rows, err error) {
+	schema := "asof_" + strings.ToLower(id)
+	stmt, n := replaceCode(stmt, snapPlaceholder, quoteIdent(schema))
+	if n == 0 {
+		return nil, fmt.Errorf("statement does not reference %s", snapPlaceholder)
+	}
+	// Only mounting takes the write lock; the query shares the lock with
+	// other reads, as Query does.
+	for {
+		if err := c.rlock("QueryAsOf"); err != nil {
+			return nil, err
+		}
+		if _, ok := c.mounts[schema]; ok {
+			break
+		}
+		c.mux.RUnlock()
+		if err := c.mountAsOf(ctx, id, schema); err != nil {
+			return nil, err
+		}
+	}
+	defer c.runlock("QueryAsOf", &err)
+	return c.db.QueryContext(c.opContext(ctx), stmt, args...)
+}
+
+// mountAsOf mounts snapshot id as schema for QueryAsOf unless another call
+// did so first.
+func (c *Client) mountAsOf(ctx context.Context, id, schema string) (err error) {
+	if err := c.lock("MountSnapshot"); err != nil {
+		return err
+	}
+	defer c.unlock("MountSnapshot", &err)
+	if _, ok := c.mounts[schema]; ok {
+		return nil
+	}
+	return c.mount(ctx, id, schema)
+}
+
+func (c *Client) UnmountSnapshot(ctx context.Context, schema string) (err error) {
+	if err := c.lock("UnmountSnapshot"); err != nil {
+		return err
+	}
+	defer c.unlock("UnmountSnapshot", &err)
+	file, ok := c.mounts[schema]
+	if !ok {
+		return fmt.Errorf("schema %s is not mounted", schema)
+	}
+	if _, err := c.db.ExecContext(ctx, "DETACH "+quoteIdent(schema)+";"); err != nil {
+		return err
+	}
+	delete(c.mounts, schema)
+	if err := os.Remove(file + ".wal"); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return os.Remove(file)
+}
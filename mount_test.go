@@ -0,0 +1,124 @@
+package quack
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_MountSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	client, err := New(dir, 3)
+	require.NoError(t, err)
+	require.NoError(t, client.Insert(t.Context(), "events", strings.NewReader(`{"id":1,"v":"old"}`)))
+	require.NoError(t, client.Close(t.Context()))
+	ids, err := listDir(filepath.Join(dir, "snapshot"))
+	require.NoError(t, err)
+	require.Len(t, ids, 1)
+
+	client, err = New(dir, 3)
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+	require.NoError(t, client.Insert(t.Context(), "events", strings.NewReader(`{"id":1,"v":"new"}`)))
+	require.ErrorIs(t, client.MountSnapshot(t.Context(), "../database.ddb", "snap_x"), ErrSnapshotNotFound)
+	require.ErrorIs(t, client.MountSnapshot(t.Context(), "../snapshot/"+ids[0], "snap_x"), ErrSnapshotNotFound)
+	// Schema names never reach the file system.
+	require.NoError(t, client.MountSnapshot(t.Context(), ids[0], "../it's"))
+	mounted, err := filepath.Glob(filepath.Join(client.mountDir(), "*.ddb"))
+	require.NoError(t, err)
+	require.Len(t, mounted, 1)
+	require.NoFileExists(t, filepath.Join(dir, "it's.ddb"))
+	require.NoError(t, client.UnmountSnapshot(t.Context(), "../it's"))
+	require.NoFileExists(t, mounted[0])
+	require.NoError(t, client.MountSnapshot(t.Context(), ids[0], "snap_x"))
+	require.Error(t, client.MountSnapshot(t.Context(), ids[0], "snap_x"))
+
+	rows, err := client.Query(t.Context(), "SELECT e.v, o.v FROM events e JOIN snap_x.events o USING (id) ORDER BY 1;")
+	require.NoError(t, err)
+	var pairs []string
+	for rows.Next() {
+		var a, b string
+		require.NoError(t, rows.Scan(&a, &b))
+		pairs = append(pairs, a+"/"+b)
+	}
+	require.NoError(t, rows.Err())
+	require.NoError(t, rows.Close())
+	require.Equal(t, []string{"new/old", "old/old"}, pairs)
+
+	require.ErrorContains(t, client.Deduplicate(t.Context(), "snap_x.events"), "read-only")
+	_, err = client.DeleteWhere(t.Context(), "snap_x.events", "true")
+	require.ErrorContains(t, err, "read-only")
+	require.ErrorContains(t, client.Insert(t.Context(), "snap_x.events", strings.NewReader(`{"id":2,"v":"x"}`)), "read-only")
+	_, err = client.Query(t.Context(), "DELETE FROM snap_x.events;")
+	require.ErrorContains(t, err, "read-only")
+
+	tables, err := showTables(t.Context(), client.db)
+	require.NoError(t, err)
+	require.NotContains(t, tables, "snap_x")
+	require.NoError(t, client.RollbackSnapshot(t.Context(), 1))
+	rows, err = client.Query(t.Context(), "SELECT count(*) FROM snap_x.events;")
+	require.NoError(t, err)
+	require.True(t, rows.Next())
+	require.NoError(t, rows.Close())
+
+	require.NoError(t, client.UnmountSnapshot(t.Context(), "snap_x"))
+	_, err = client.Query(t.Context(), "SELECT count(*) FROM snap_x.events;")
+	require.Error(t, err)
+}
+
+func Test_QueryAsOf(t *testing.T) {
+	dir := t.TempDir()
+	client, err := New(dir, 3)
+	require.NoError(t, err)
+	require.NoError(t, client.Insert(t.Context(), "events", strings.NewReader(`{"id":1,"v":"old"}`)))
+	require.NoError(t, client.Close(t.Context()))
+	ids, err := listDir(filepath.Join(dir, "snapshot"))
+	require.NoError(t, err)
+
+	client, err = New(dir, 3)
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+	require.NoError(t, client.Insert(t.Context(), "events", strings.NewReader(`{"id":2,"v":"new"}`)))
+
+	count := func(stmt string, args ...any) int {
+		t.Helper()
+		rows, err := client.QueryAsOf(t.Context(), ids[0], stmt, args...)
+		require.NoError(t, err)
+		defer rows.Close()
+		require.True(t, rows.Next())
+		var n int
+		require.NoError(t, rows.Scan(&n))
+		return n
+	}
+	require.Equal(t, 1, count("SELECT count(*) FROM {{snap}}.events;"))
+	require.Equal(t, 1, count(`WITH old AS (SELECT * FROM {{snap}}."events") SELECT count(*) FROM events WHERE id NOT IN (SELECT id FROM old) AND v <> '{{snap}}';`))
+	require.Equal(t, 1, count("SELECT count(*) FROM {{snap}}.events WHERE id = ?;", 1))
+	require.Len(t, client.mounts, 1)
+
+	// A running historical query holds the lock shared.
+	ctx, cancel := context.WithTimeout(t.Context(), 2*time.Second)
+	defer cancel()
+	slow := make(chan error, 1)
+	go func() {
+		_, err := client.QueryAsOf(ctx, ids[0], "SELECT count(*) FROM {{snap}}.events, range(100000000) a, range(100000000) b;")
+		slow <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	start := time.Now()
+	require.Equal(t, 1, count("SELECT count(*) FROM {{snap}}.events;"))
+	n, err := client.Count(t.Context(), "events")
+	require.NoError(t, err)
+	require.Equal(t, int64(2), n)
+	require.Less(t, time.Since(start), time.Second)
+	cancel()
+	require.ErrorIs(t, <-slow, context.Canceled)
+
+	_, err = client.QueryAsOf(t.Context(), ids[0], "SELECT count(*) FROM events;")
+	require.Error(t, err)
+	_, err = client.QueryAsOf(t.Context(), "nope", "SELECT * FROM {{snap}}.events;")
+	require.Error(t, err)
+}
@@ -0,0 +1,77 @@
+package quack
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+)
+
+// ErrQueryExists is returned by RegisterQuery for a name already taken.
+var ErrQueryExists = errors.New("query is already registered")
+
+// NamedQuery is a query of RegisterQuery.
+type NamedQuery struct {
+	Name string
+	SQL  string
+}
+
+type registerConfig struct {
+	replace bool
+}
+
+type RegisterOption func(*registerConfig)
+
+// ReplaceQuery makes RegisterQuery replace a query of the same name.
+func ReplaceQuery() RegisterOption {
+	return func(c *registerConfig) { c.replace = true }
+}
+
+// RegisterQuery registers stmt under name for RunNamed, failing with
+// ErrQueryExists when the name is taken unless ReplaceQuery is set. The
+// registry lives with the client; SaveQuery stores queries in the database
+// and its snapshots instead.
+func (c *Client) RegisterQuery(name, stmt string, opts ...RegisterOption) error {
+	var cfg registerConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if name == "" || len(splitStatements(stmt)) != 1 {
+		return fmt.Errorf("query %q needs a name and a single statement", name)
+	}
+	c.queriesMux.Lock()
+	defer c.queriesMux.Unlock()
+	if _, ok := c.queries[name]; ok && !cfg.replace {
+		return fmt.Errorf("query %s: %w", name, ErrQueryExists)
+	}
+	if c.queries == nil {
+		c.queries = make(map[string]string)
+	}
+	c.queries[name] = stmt
+	return nil
+}
+
+// ListQueries returns the registered queries ordered by name.
+func (c *Client) ListQueries() []NamedQuery {
+	c.queriesMux.Lock()
+	defer c.queriesMux.Unlock()
+	queries := make([]NamedQuery, 0, len(c.queries))
+	for _, name := range slices.Sorted(maps.Keys(c.queries)) {
+		queries = append(queries, NamedQuery{Name: name, SQL: c.queries[name]})
+	}
+	return queries
+}
+
+// RunNamed is Query for the query registered as name.
+func (c *Client) RunNamed(ctx context.Context, name string, args ...any) (*sql.Rows, error) {
+	c.queriesMux.Lock()
+	stmt, ok := c.queries[name]
+	c.queriesMux.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("query %s: %w", name, os.ErrNotExist)
+	}
+	return c.Query(ctx, stmt, args...)
+}
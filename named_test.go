@@ -0,0 +1,44 @@
+package quack
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_NamedQueries(t *testing.T) {
+	client, err := New(t.TempDir(), 1)
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+	require.NoError(t, client.Insert(t.Context(), "events", strings.NewReader("{\"id\":1}\n{\"id\":2}\n{\"id\":3}\n")))
+	require.NoError(t, client.RegisterQuery("count_after", "SELECT count(*) FROM events WHERE id > ?;"))
+	require.NoError(t, client.RegisterQuery("all", "SELECT id FROM events ORDER BY id;"))
+	require.ErrorIs(t, client.RegisterQuery("all", "SELECT 1;"), ErrQueryExists)
+	require.Error(t, client.RegisterQuery("two", "SELECT 1; SELECT 2;"))
+	require.Error(t, client.RegisterQuery("", "SELECT 1;"))
+
+	rows, err := client.RunNamed(t.Context(), "count_after", 1)
+	require.NoError(t, err)
+	require.True(t, rows.Next())
+	var n int
+	require.NoError(t, rows.Scan(&n))
+	require.NoError(t, rows.Close())
+	require.Equal(t, 2, n)
+
+	require.NoError(t, client.RegisterQuery("all", "SELECT max(id) FROM events;", ReplaceQuery()))
+	require.Equal(t, []NamedQuery{
+		{Name: "all", SQL: "SELECT max(id) FROM events;"},
+		{Name: "count_after", SQL: "SELECT count(*) FROM events WHERE id > ?;"},
+	}, client.ListQueries())
+	rows, err = client.RunNamed(t.Context(), "all")
+	require.NoError(t, err)
+	require.True(t, rows.Next())
+	require.NoError(t, rows.Scan(&n))
+	require.NoError(t, rows.Close())
+	require.Equal(t, 3, n)
+
+	_, err = client.RunNamed(t.Context(), "missing")
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
@@ -0,0 +1,231 @@
+package quack
+
+import (
+	"compress/flate"
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// ClientOption configures a Client during New. The With functions return
+// them; Option and ConnOption run against the freshly opened database.
+type ClientOption interface {
+	apply(context.Context, *Client, *sql.Conn) error
+}
+
+// Option runs against a connection of the freshly opened database, e.g. to
+// install extensions or change settings. It is the shape options had before
+// ConnOption; New also accepts a plain func(*sql.Conn) error.
+type Option func(conn *sql.Conn) error
+
+func (o Option) apply(_ context.Context, _ *Client, conn *sql.Conn) error { return o(conn) }
+
+// ConnOption is Option with the ctx passed to NewContext, so options running
+// SQL can honour its deadline. New also accepts a plain
+// func(context.Context, *sql.Conn) error.
+type ConnOption func(ctx context.Context, conn *sql.Conn) error
+
+func (o ConnOption) apply(ctx context.Context, _ *Client, conn *sql.Conn) error { return o(ctx, conn) }
+
+type clientOption func(*Client)
+
+func (o clientOption) apply(_ context.Context, c *Client, _ *sql.Conn) error {
+	o(c)
+	return nil
+}
+
+// errOption fails New, for an option given an invalid argument.
+type errOption struct{ err error }
+
+func (o errOption) apply(context.Context, *Client, *sql.Conn) error { return o.err }
+
+// clientOptions resolves the options given to New, adapting plain functions
+// of the Option and ConnOption shapes.
+func clientOptions(options []any) ([]ClientOption, error) {
+	opts := make([]ClientOption, len(options))
+	for i, o := range options {
+		switch o := o.(type) {
+		case ClientOption:
+			opts[i] = o
+		case func(*sql.Conn) error:
+			opts[i] = Option(o)
+		case func(context.Context, *sql.Conn) error:
+			opts[i] = ConnOption(o)
+		default:
+			return nil, fmt.Errorf("option %d: %T is not a ClientOption", i, o)
+		}
+	}
+	return opts, nil
+}
+
+// WithLogger sets the logger used for background housekeeping.
+func WithLogger(l *slog.Logger) ClientOption {
+	return clientOption(func(c *Client) { c.logger = l })
+}
+
+// WithStagingDir sets the directory used for temporary files created while
+// inserting, dumping and loading. Defaults to os.TempDir.
+func WithStagingDir(dir string) ClientOption {
+	return clientOption(func(c *Client) { c.stagingDir = dir })
+}
+
+// WithAutoCompact makes delete-heavy operations compact the database file
+// once the fraction of unused bytes in it exceeds ratio. Compaction rewrites
+// the whole file under the write lock, so leave it unset where write latency
+// matters and call Compact during quiet periods instead.
+func WithAutoCompact(ratio float64) ClientOption {
+	return clientOption(func(c *Client) { c.autoCompact = ratio })
+}
+
+// WithDiskBudget caps the total size of the client directory: the database
+// file, its WAL and all snapshots. Inserts, including InsertRows, PutBlob
+// and PutDoc, and snapshots that would exceed it fail with
+// ErrQuotaExceeded; snapshots first prune the oldest archives.
+func WithDiskBudget(bytes int64) ClientOption {
+	return clientOption(func(c *Client) { c.diskBudget = bytes })
+}
+
+// WithMaxBlobSize sets the largest blob PutBlob accepts. Defaults to 256 MiB;
+// 0 removes the limit.
+func WithMaxBlobSize(bytes int64) ClientOption {
+	return clientOption(func(c *Client) { c.maxBlobSize = bytes })
+}
+
+// WithQueryTimeout sets the Timeout of queries that do not set their own.
+func WithQueryTimeout(d time.Duration) ClientOption {
+	return clientOption(func(c *Client) { c.queryTimeout = d })
+}
+
+// WithMaxResultRows makes plain queries returning more than n rows fail with
+// ErrResultTruncated instead of returning them, so an unbounded SELECT
+// cannot exhaust memory. Statements that write, such as INSERT ... RETURNING,
+// are not limited. ResultRowLimit overrides it per query.
+func WithMaxResultRows(n int) ClientOption {
+	return clientOption(func(c *Client) { c.maxResultRows = n })
+}
+
+// WithJournal keeps a copy of every payload passed to Insert in the journal
+// directory until the next snapshot, so inserts made since then can be
+// replayed with RecoverJournal after restoring the database from a
+// snapshot. Inserts into named databases are not journaled.
+func WithJournal() ClientOption {
+	return clientOption(func(c *Client) { c.journal = true })
+}
+
+// WithSnapshotParallelism sets how many files of a snapshot are compressed
+// concurrently. Defaults to GOMAXPROCS.
+func WithSnapshotParallelism(n int) ClientOption {
+	return clientOption(func(c *Client) { c.snapshotCfg.workers = n })
+}
+
+// WithMaxSnapshotBytes makes rotation also delete the oldest snapshots until
+// those left total at most bytes. The newest snapshot is always kept, however
+// large.
+func WithMaxSnapshotBytes(bytes int64) ClientOption {
+	return clientOption(func(c *Client) { c.snapshotCfg.maxBytes = bytes })
+}
+
+// WithStoredSnapshots writes snapshot entries uncompressed, which is fastest
+// when the payload hardly compresses. Snapshots are read back whichever
+// compression they were written with.
+func WithStoredSnapshots() ClientOption {
+	return clientOption(func(c *Client) { c.snapshotCfg.codec = storeCodec })
+}
+
+// WithDeflateSnapshots compresses snapshot entries with deflate at level, as
+// for compress/flate: 1 (fastest) to 9 (smallest), or -1 for the default.
+// Other levels fail New; WithStoredSnapshots stands for level 0. Without an
+// option, snapshots are deflated at the default level.
+func WithDeflateSnapshots(level int) ClientOption {
+	if level != flate.DefaultCompression && (level < flate.BestSpeed || level > flate.BestCompression) {
+		return errOption{fmt.Errorf("snapshot deflate level %d out of range [%d, %d]", level, flate.BestSpeed, flate.BestCompression)}
+	}
+	return clientOption(func(c *Client) {
+		c.snapshotCfg.codec = deflateCodec
+		c.snapshotCfg.level = level
+	})
+}
+
+// WithZstdSnapshots compresses snapshot entries with Zstandard at level, as
+// for the zstd tool: 1 (fastest) to 22 (smallest), or 0 for the default.
+// Other levels fail New. Other zip tools may not read such archives.
+func WithZstdSnapshots(level int) ClientOption {
+	if level < 0 || level > 22 {
+		return errOption{fmt.Errorf("snapshot zstd level %d out of range [0, 22]", level)}
+	}
+	return clientOption(func(c *Client) {
+		c.snapshotCfg.codec = zstdCodec
+		c.snapshotCfg.level = level
+	})
+}
+
+// WithDeterministicSnapshots makes snapshots of identical database states
+// byte-identical, for content-addressed backup storage. Entries get a fixed
+// timestamp and mode and table data is exported sorted by all columns, which
+// costs a sort of every table per snapshot.
+func WithDeterministicSnapshots() ClientOption {
+	return clientOption(func(c *Client) { c.snapshotCfg.deterministic = true })
+}
+
+// WithCloseHooks runs before at the start of Close, ahead of the final
+// snapshot, and after once the client is closed, with the snapshot Close
+// wrote. Both run outside the client lock; before may still use the client,
+// after may not. An error from before aborts Close unless
+// ContinueCloseOnHookError is set, and one from after is returned by Close.
+// Either hook may be nil.
+func WithCloseHooks(before func(context.Context) error, after func(context.Context, SnapshotInfo) error) ClientOption {
+	return clientOption(func(c *Client) {
+		c.beforeClose = before
+		c.afterClose = after
+	})
+}
+
+// ContinueCloseOnHookError makes Close log an error from the before hook of
+// WithCloseHooks and carry on instead of aborting.
+func ContinueCloseOnHookError() ClientOption {
+	return clientOption(func(c *Client) { c.closeHookContinue = true })
+}
+
+// WithDedupOnClose makes Close deduplicate tables, or every user table of
+// the main database when none are given, before the final snapshot, so the
+// archive holds them clean. Each table is deduplicated with the options set
+// by WithTableDedup. A failure aborts Close unless ContinueCloseOnDedupError
+// is set.
+func WithDedupOnClose(tables ...string) ClientOption {
+	return clientOption(func(c *Client) {
+		c.dedupClose = true
+		c.dedupCloseTables = tables
+	})
+}
+
+// WithTableDedup sets the options Close deduplicates table with under
+// WithDedupOnClose.
+func WithTableDedup(table string, opts ...DedupOption) ClientOption {
+	return clientOption(func(c *Client) {
+		if c.dedupOpts == nil {
+			c.dedupOpts = make(map[string][]DedupOption)
+		}
+		c.dedupOpts[table] = opts
+	})
+}
+
+// ContinueCloseOnDedupError makes Close log a failure of WithDedupOnClose
+// and take the final snapshot of the tables as they are.
+func ContinueCloseOnDedupError() ClientOption {
+	return clientOption(func(c *Client) { c.dedupCloseContinue = true })
+}
+
+// WithPlanCache makes Query keep up to size prepared statements, keyed by
+// the statement with its literals turned into parameters, so queries that
+// differ only in the values they compare against are planned once. Only
+// single queries whose literals can be rewritten safely are cached; others
+// run as before. Stats reports the cache activity.
+func WithPlanCache(size int) ClientOption {
+	return clientOption(func(c *Client) {
+		if c.plans == nil && size > 0 {
+			c.plans = newPlanCache(size)
+		}
+	})
+}
@@ -0,0 +1,161 @@
+package quack
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// cursorColumn carries the key of each row of a keyset page.
+const cursorColumn = "quack_cursor"
+
+// PageRequest selects a page of QueryPage.
+type PageRequest struct {
+	// Limit is the number of rows per page.
+	Limit int
+	// Offset skips rows for the first page. Order the statement for pages
+	// to be stable.
+	Offset int
+	// KeyColumn pages by the values of a unique column of the result
+	// instead of by offset, which stays stable while rows are added. The
+	// rows are ordered by it.
+	KeyColumn string
+	// Cursor is the NextCursor of the previous page, and takes the place of
+	// Offset.
+	Cursor string
+	// Total counts the rows of the whole result, which runs the statement a
+	// second time.
+	Total bool
+}
+
+// PageResult is a page of QueryPage.
+type PageResult struct {
+	// Rows are keyed by column name.
+	Rows []map[string]any
+	// NextCursor requests the following page, and is empty on the last.
+	NextCursor string
+	// Total is the number of rows of the whole result, or -1 when not
+	// requested.
+	Total int64
+}
+
+// QueryPage runs stmt with args and returns one page of its rows. The
+// statement cannot have a LIMIT of its own.
+func (c *Client) QueryPage(ctx context.Context, stmt string, page PageRequest, args ...any) (_ PageResult, err error) {
+	if page.Limit <= 0 {
+		return PageResult{}, fmt.Errorf("page limit %d is not positive", page.Limit)
+	}
+	stmts := splitStatements(stmt)
+	if len(stmts) != 1 {
+		return PageResult{}, errors.New("page needs a single statement")
+	}
+	if hasKeyword(stmts[0], "LIMIT") {
+		return PageResult{}, errors.New("paged statement cannot have a LIMIT")
+	}
+	cfg, args := splitQueryArgs(args)
+	offset, key, err := decodeCursor(page)
+	if err != nil {
+		return PageResult{}, err
+	}
+	inner := stmts[0]
+	var query string
+	params := args
+	if page.KeyColumn != "" {
+		col := quoteIdent(page.KeyColumn)
+		var where string
+		if key != "" {
+			// The key is bound as a string, which DuckDB casts to the
+			// column's type.
+			where = fmt.Sprintf(" WHERE %s > ?", col)
+			params = append(args[:len(args):len(args)], key)
+		}
+		query = fmt.Sprintf("SELECT *, CAST(%s AS VARCHAR) AS %s FROM (%s)%s ORDER BY %s LIMIT %d;", col, cursorColumn, inner, where, col, page.Limit+1)
+	} else {
+		query = fmt.Sprintf("SELECT * FROM (%s) LIMIT %d OFFSET %d;", inner, page.Limit+1, offset)
+	}
+	if err := c.rlock("QueryPage"); err != nil {
+		return PageResult{}, err
+	}
+	defer c.runlock("QueryPage", &err)
+	ctx = c.queryContext(ctx, cfg)
+	res := PageResult{Total: -1}
+	rows, err := c.db.QueryContext(ctx, query, params...)
+	if err != nil {
+		return PageResult{}, err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return PageResult{}, err
+	}
+	var last string
+	for rows.Next() {
+		if len(res.Rows) == page.Limit {
+			if page.KeyColumn != "" {
+				res.NextCursor = encodeCursor("k" + last)
+			} else {
+				res.NextCursor = encodeCursor("o" + strconv.Itoa(offset+page.Limit))
+			}
+			break
+		}
+		vals := make([]any, len(columns))
+		ptrs := make([]any, len(columns))
+		for i := range vals {
+			ptrs[i] = &vals[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return PageResult{}, err
+		}
+		row := make(map[string]any, len(columns))
+		for i, col := range columns {
+			row[col] = vals[i]
+		}
+		if page.KeyColumn != "" {
+			last, _ = row[cursorColumn].(string)
+			delete(row, cursorColumn)
+		}
+		res.Rows = append(res.Rows, row)
+	}
+	if err := rows.Err(); err != nil {
+		return PageResult{}, err
+	}
+	rows.Close()
+	if page.Total {
+		if err := c.db.QueryRowContext(ctx, fmt.Sprintf("SELECT count(*) FROM (%s);", inner), args...).Scan(&res.Total); err != nil {
+			return PageResult{}, err
+		}
+	}
+	return res, nil
+}
+
+func encodeCursor(s string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(s))
+}
+
+// decodeCursor returns the offset or the key after which page starts.
+func decodeCursor(page PageRequest) (int, string, error) {
+	if page.Cursor == "" {
+		if page.Offset < 0 {
+			return 0, "", fmt.Errorf("page offset %d is negative", page.Offset)
+		}
+		return page.Offset, "", nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(page.Cursor)
+	if err != nil || len(b) == 0 {
+		return 0, "", errors.New("invalid page cursor")
+	}
+	kind, value := b[0], string(b[1:])
+	switch {
+	case kind == 'k' && page.KeyColumn != "":
+		return 0, value, nil
+	case kind == 'o' && page.KeyColumn == "":
+		offset, err := strconv.Atoi(value)
+		if err != nil || offset < 0 {
+			return 0, "", errors.New("invalid page cursor")
+		}
+		return offset, "", nil
+	}
+	return 0, "", errors.New("page cursor is for another kind of paging")
+}
@@ -0,0 +1,65 @@
+package quack
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_QueryPage(t *testing.T) {
+	client, err := New(t.TempDir(), 1)
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+	var data strings.Builder
+	for i := 1; i <= 7; i++ {
+		fmt.Fprintf(&data, "{\"id\":%d,\"at\":\"2024-01-%02d 00:00:00\"}\n", i, i)
+	}
+	require.NoError(t, client.Insert(t.Context(), "events", strings.NewReader(data.String())))
+	ids := func(res PageResult) []int64 {
+		var ids []int64
+		for _, row := range res.Rows {
+			ids = append(ids, row["id"].(int64))
+		}
+		return ids
+	}
+	t.Run("offset", func(t *testing.T) {
+		res, err := client.QueryPage(t.Context(), "SELECT id FROM events WHERE id > ? ORDER BY id;", PageRequest{Limit: 3, Total: true}, 1)
+		require.NoError(t, err)
+		require.Equal(t, []int64{2, 3, 4}, ids(res))
+		require.Equal(t, int64(6), res.Total)
+		res, err = client.QueryPage(t.Context(), "SELECT id FROM events WHERE id > ? ORDER BY id;", PageRequest{Limit: 3, Cursor: res.NextCursor}, 1)
+		require.NoError(t, err)
+		require.Equal(t, []int64{5, 6, 7}, ids(res))
+		require.Equal(t, int64(-1), res.Total)
+		require.Empty(t, res.NextCursor)
+	})
+	t.Run("keyset", func(t *testing.T) {
+		var got []int64
+		page := PageRequest{Limit: 2, KeyColumn: "at"}
+		for {
+			res, err := client.QueryPage(t.Context(), "SELECT * FROM events", page)
+			require.NoError(t, err)
+			require.NotContains(t, res.Rows[0], cursorColumn)
+			got = append(got, ids(res)...)
+			if res.NextCursor == "" {
+				break
+			}
+			page.Cursor = res.NextCursor
+			// Rows added before the cursor do not shift later pages.
+			require.NoError(t, client.Insert(t.Context(), "events", strings.NewReader(`{"id":0,"at":"2023-01-01 00:00:00"}`)))
+		}
+		require.Equal(t, []int64{1, 2, 3, 4, 5, 6, 7}, got)
+	})
+	t.Run("invalid", func(t *testing.T) {
+		_, err := client.QueryPage(t.Context(), "SELECT * FROM events LIMIT 5;", PageRequest{Limit: 2})
+		require.ErrorContains(t, err, "LIMIT")
+		_, err = client.QueryPage(t.Context(), "SELECT * FROM events;", PageRequest{})
+		require.Error(t, err)
+		_, err = client.QueryPage(t.Context(), "SELECT * FROM events;", PageRequest{Limit: 2, Cursor: "!"})
+		require.ErrorContains(t, err, "invalid page cursor")
+		_, err = client.QueryPage(t.Context(), "SELECT * FROM events;", PageRequest{Limit: 2, KeyColumn: "id", Cursor: encodeCursor("o2")})
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,64 @@
+package quack
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+)
+
+// InsertParquet is Insert for Parquet input.
+func (c *Client) InsertParquet(ctx context.Context, table string, r io.Reader, opts ...InsertOption) error {
+	return c.Insert(ctx, table, r, append(opts, withFormat(FormatParquet))...)
+}
+
+// InsertParquetFile is InsertParquet reading the file at path in place
+// rather than from a copy.
+func (c *Client) InsertParquetFile(ctx context.Context, table, path string, opts ...InsertOption) error {
+	return c.InsertFile(ctx, table, path, FormatParquet, opts...)
+}
+
+func withFormat(f Format) InsertOption {
+	return func(c *insertConfig) { c.format = f }
+}
+
+// checkParquetColumns checks that every column of source exists in table and
+// that its values cast to the column's type, so a mismatch names the column
+// instead of failing the load with a generic conversion error.
+func checkParquetColumns(ctx context.Context, db querier, table, source string) error {
+	columns, err := describe(ctx, db, table)
+	if err != nil {
+		return err
+	}
+	want := make(map[string]string, len(columns))
+	for _, col := range columns {
+		want[col.Name] = col.Type
+	}
+	staged, err := describe(ctx, db, "SELECT * FROM "+source)
+	if err != nil {
+		return err
+	}
+	var mismatches []TypeMismatch
+	for _, col := range staged {
+		typ, ok := want[col.Name]
+		if !ok {
+			return fmt.Errorf("%s has no column %s of the parquet input", table, col.Name)
+		}
+		if widens(col.Type, typ) {
+			continue
+		}
+		name := quoteIdent(col.Name)
+		var sample string
+		err := db.QueryRowContext(ctx, fmt.Sprintf("SELECT %s::VARCHAR FROM %s WHERE %s IS NOT NULL AND TRY_CAST(%s AS %s) IS NULL LIMIT 1;", name, source, name, name, typ)).Scan(&sample)
+		if err == sql.ErrNoRows {
+			continue
+		} else if err != nil {
+			return err
+		}
+		mismatches = append(mismatches, TypeMismatch{Column: col.Name, Want: typ, Got: col.Type, Sample: sample})
+	}
+	if len(mismatches) > 0 {
+		return &TypeError{Table: table, Columns: mismatches}
+	}
+	return nil
+}
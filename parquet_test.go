@@ -0,0 +1,49 @@
+package quack
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_InsertParquet(t *testing.T) {
+	client, err := New(t.TempDir(), 1)
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+	dir := t.TempDir()
+	write := func(name, query string) string {
+		p := filepath.Join(dir, name+".parquet")
+		_, err := client.db.Exec(fmt.Sprintf("COPY (%s) TO %s (FORMAT parquet);", query, quoteLiteral(p)))
+		require.NoError(t, err)
+		return p
+	}
+	first := write("first", "SELECT 1::BIGINT AS id, 'a' AS name")
+	b, err := os.ReadFile(first)
+	require.NoError(t, err)
+	require.NoError(t, client.InsertParquet(t.Context(), "people", bytes.NewReader(b)))
+	require.NoError(t, client.InsertParquetFile(t.Context(), "people", write("reordered", "SELECT 'b' AS name, 2 AS id")))
+	require.NoError(t, client.InsertParquetFile(t.Context(), "people", write("partial", "SELECT 3 AS id")))
+	var names string
+	require.NoError(t, client.db.QueryRow("SELECT string_agg(coalesce(name, 'NULL'), ',' ORDER BY id) FROM people;").Scan(&names))
+	require.Equal(t, "a,b,NULL", names)
+	columns, err := describe(t.Context(), client.db, "people")
+	require.NoError(t, err)
+	require.Equal(t, []Column{{Name: "id", Type: "BIGINT", Nullable: true}, {Name: "name", Type: "VARCHAR", Nullable: true}}, columns)
+
+	err = client.InsertParquetFile(t.Context(), "people", write("mistyped", "SELECT 'x' AS id"))
+	var typeErr *TypeError
+	require.ErrorAs(t, err, &typeErr)
+	require.Equal(t, []TypeMismatch{{Column: "id", Want: "BIGINT", Got: "VARCHAR", Sample: "x"}}, typeErr.Columns)
+	require.ErrorContains(t, client.InsertParquetFile(t.Context(), "people", write("extra", "SELECT 4 AS id, 1 AS age")), "people has no column age")
+	require.NoError(t, client.InsertParquetFile(t.Context(), "people", write("castable", "SELECT '5' AS id")))
+	require.Error(t, client.InsertParquetFile(t.Context(), "people", filepath.Join(dir, "missing.parquet")))
+	var n int
+	require.NoError(t, client.db.QueryRow("SELECT count(*) FROM people;").Scan(&n))
+	require.Equal(t, 4, n)
+	_, err = os.Stat(first)
+	require.NoError(t, err)
+}
@@ -0,0 +1,207 @@
+package quack
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// partitionColumn is the column of the staged input naming the partition of
+// each row.
+const partitionColumn = "quack_partition"
+
+// InsertPartitioned loads r into monthly tables named after baseTable and
+// the month of timestampColumn, e.g. events_2024_01, creating them as
+// needed, in a single transaction. Rows without a timestamp fail the insert.
+// The view baseTable is then refreshed as with RefreshPartitionView. opts
+// choose how the input is read, and WithRowLimit and SampleFraction which
+// rows are loaded; options that act on a single target table, such as
+// UpsertOn or StrictSchema, are rejected. Partitioned inputs are not
+// journaled.
+func (c *Client) InsertPartitioned(ctx context.Context, baseTable, timestampColumn string, r io.Reader, opts ...InsertOption) (err error) {
+	cfg := c.ingestConfig(opts)
+	if err := cfg.validate(); err != nil {
+		return err
+	}
+	if unsupported := cfg.unpartitionable(); len(unsupported) > 0 {
+		return fmt.Errorf("%s: partitioned inserts do not support %s", baseTable, strings.Join(unsupported, ", "))
+	}
+	if err := c.lock("InsertPartitioned"); err != nil {
+		return err
+	}
+	defer c.unlock("InsertPartitioned", &err)
+	src, err := decompress(r)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	name, _, err := stage(c.stagingDir, src, 0)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(name)
+	if cfg.precheck != nil {
+		size, err := fileSize(name)
+		if err != nil {
+			return err
+		}
+		if err := cfg.precheck(size); err != nil {
+			return err
+		}
+	}
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	source := cfg.source(name)
+	if cfg.projects() {
+		if source, err = project(ctx, tx, source, cfg); err != nil {
+			return err
+		}
+	}
+	if cfg.sample > 0 {
+		source = fmt.Sprintf("(SELECT * FROM %s USING SAMPLE %g PERCENT (bernoulli))", source, cfg.sample*100)
+	}
+	if cfg.limit > 0 {
+		source = fmt.Sprintf("(SELECT * FROM %s LIMIT %d)", source, cfg.limit)
+	}
+	ts := quoteIdent(timestampColumn)
+	stmt := fmt.Sprintf("CREATE OR REPLACE TEMP TABLE %s AS SELECT *, strftime(CAST(%s AS TIMESTAMP), '%%Y_%%m') AS %s FROM %s;", stagedTable, ts, partitionColumn, source)
+	if _, err := tx.ExecContext(ctx, stmt); err != nil {
+		return csvError(err)
+	}
+	var missing int64
+	if err := tx.QueryRowContext(ctx, fmt.Sprintf("SELECT count(*) FROM %s WHERE %s IS NULL;", stagedTable, partitionColumn)).Scan(&missing); err != nil {
+		return err
+	}
+	if missing > 0 {
+		return fmt.Errorf("%s: %d rows have no %s", baseTable, missing, timestampColumn)
+	}
+	rows, err := tx.QueryContext(ctx, fmt.Sprintf("SELECT DISTINCT %s FROM %s ORDER BY 1;", partitionColumn, stagedTable))
+	if err != nil {
+		return err
+	}
+	var partitions []string
+	for rows.Next() {
+		var p string
+		if err := rows.Scan(&p); err != nil {
+			rows.Close()
+			return err
+		}
+		partitions = append(partitions, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	var results []InsertResult
+	for _, p := range partitions {
+		table := baseTable + "_" + p
+		slice := fmt.Sprintf("SELECT * EXCLUDE (%s) FROM %s WHERE %s = %s", partitionColumn, stagedTable, partitionColumn, quoteLiteral(p))
+		res, err := insertSelect(ctx, tx, table, slice)
+		if err != nil {
+			return err
+		}
+		results = append(results, res)
+	}
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf("DROP TABLE %s;", stagedTable)); err != nil {
+		return err
+	}
+	if err := partitionView(ctx, tx, baseTable); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	for _, res := range results {
+		c.counters.insert(res)
+	}
+	return nil
+}
+
+// unpartitionable names the options set in c that InsertPartitioned cannot
+// apply across its monthly tables.
+func (c insertConfig) unpartitionable() []string {
+	var names []string
+	for _, o := range []struct {
+		name string
+		set  bool
+	}{
+		{"UpsertOn", c.keys != nil},
+		{"DedupOnInsert", c.dedup},
+		{"StrictSchema", c.strictSchema},
+		{"WithStrictTypes", c.strictTypes},
+		{"AllowSchemaEvolution", c.evolve},
+		{"WithJSONSchema", c.jsonSchema != nil},
+		{"WithTargetSuffix", c.suffix != ""},
+	} {
+		if o.set {
+			names = append(names, o.name)
+		}
+	}
+	return names
+}
+
+// insertSelect appends the rows of query to table by name, creating the
+// table from them when it does not exist.
+func insertSelect(ctx context.Context, db querier, table, query string) (InsertResult, error) {
+	res := InsertResult{Table: table}
+	if err := tableExists(ctx, db, table); !os.IsNotExist(err) {
+		if err != nil {
+			return res, err
+		}
+		result, err := db.ExecContext(ctx, fmt.Sprintf("INSERT INTO %s BY NAME %s;", table, query))
+		if err != nil {
+			return res, err
+		}
+		res.Rows, err = result.RowsAffected()
+		return res, err
+	}
+	if err := createSchema(ctx, db, table); err != nil {
+		return res, err
+	}
+	if _, err := db.ExecContext(ctx, fmt.Sprintf("CREATE TABLE %s AS %s;", table, query)); err != nil {
+		return res, err
+	}
+	if err := recordSchema(ctx, db, table, "create"); err != nil {
+		return res, err
+	}
+	res.Created = true
+	err := db.QueryRowContext(ctx, fmt.Sprintf("SELECT count(*) FROM %s;", table)).Scan(&res.Rows)
+	return res, err
+}
+
+// RefreshPartitionView replaces the view baseTable with the UNION ALL BY
+// NAME of the monthly tables of InsertPartitioned, for after partitions are
+// dropped. Without partitions left the view is dropped.
+func (c *Client) RefreshPartitionView(ctx context.Context, baseTable string) (err error) {
+	if err := c.lock("RefreshPartitionView"); err != nil {
+		return err
+	}
+	defer c.unlock("RefreshPartitionView", &err)
+	return partitionView(ctx, c.db, baseTable)
+}
+
+func partitionView(ctx context.Context, db querier, baseTable string) error {
+	tables, err := listTables(ctx, db, tablesConfig{}, nil)
+	if err != nil {
+		return err
+	}
+	partition := regexp.MustCompile("^" + regexp.QuoteMeta(baseTable) + `_\d{4}_\d{2}$`)
+	var selects []string
+	for _, t := range tables {
+		if !t.View && t.Schema == "main" && partition.MatchString(t.Name) {
+			selects = append(selects, "SELECT * FROM "+t.Name)
+		}
+	}
+	if len(selects) == 0 {
+		_, err := db.ExecContext(ctx, fmt.Sprintf("DROP VIEW IF EXISTS %s;", baseTable))
+		return err
+	}
+	_, err = db.ExecContext(ctx, fmt.Sprintf("CREATE OR REPLACE VIEW %s AS %s;", baseTable, strings.Join(selects, " UNION ALL BY NAME ")))
+	return err
+}
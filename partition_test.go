@@ -0,0 +1,65 @@
+package quack
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_InsertPartitioned(t *testing.T) {
+	client, err := New(t.TempDir(), 1)
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+	data := strings.Join([]string{
+		`{"id":1,"at":"2024-01-05T10:00:00"}`,
+		`{"id":2,"at":"2024-02-01T00:00:00"}`,
+		`{"id":3,"at":"2024-01-31T23:59:59"}`,
+	}, "\n")
+	require.NoError(t, client.InsertPartitioned(t.Context(), "events", "at", strings.NewReader(data)))
+	require.NoError(t, client.InsertPartitioned(t.Context(), "events", "at", strings.NewReader(`{"id":4,"at":"2024-02-10T00:00:00"}`)))
+	ids := func(table string) string {
+		var s string
+		require.NoError(t, client.db.QueryRow("SELECT string_agg(id::VARCHAR, ',' ORDER BY id) FROM "+table+";").Scan(&s))
+		return s
+	}
+	require.Equal(t, "1,3", ids("events_2024_01"))
+	require.Equal(t, "2,4", ids("events_2024_02"))
+	require.Equal(t, "1,2,3,4", ids("events"))
+
+	err = client.InsertPartitioned(t.Context(), "events", "at", strings.NewReader(`{"id":5,"at":"2024-03-01T00:00:00"}`+"\n"+`{"id":6}`))
+	require.ErrorContains(t, err, "1 rows have no at")
+	require.Error(t, tableExists(t.Context(), client.db, "events_2024_03"))
+
+	_, err = client.db.Exec("DROP TABLE events_2024_01;")
+	require.NoError(t, err)
+	require.NoError(t, client.RefreshPartitionView(t.Context(), "events"))
+	require.Equal(t, "2,4", ids("events"))
+	_, err = client.db.Exec("DROP TABLE events_2024_02;")
+	require.NoError(t, err)
+	require.NoError(t, client.RefreshPartitionView(t.Context(), "events"))
+	require.Error(t, tableExists(t.Context(), client.db, "events"))
+}
+
+func Test_InsertPartitionedOptions(t *testing.T) {
+	client, err := New(t.TempDir(), 1)
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+	data := `{"id":1,"at":"2024-01-05T10:00:00"}
+{"id":2,"at":"2024-02-01T00:00:00"}
+{"id":3,"at":"2024-01-31T23:59:59"}`
+	require.NoError(t, client.InsertPartitioned(t.Context(), "events", "at", strings.NewReader(data), WithRowLimit(2)))
+	n, err := client.Count(t.Context(), "events")
+	require.NoError(t, err)
+	require.Equal(t, int64(2), n)
+
+	err = client.InsertPartitioned(t.Context(), "events", "at", strings.NewReader(data), UpsertOn("id"), StrictSchema())
+	require.ErrorContains(t, err, "partitioned inserts do not support UpsertOn, StrictSchema")
+	err = client.InsertPartitioned(t.Context(), "events", "at", strings.NewReader(data), DedupOnInsert())
+	require.ErrorContains(t, err, "do not support DedupOnInsert")
+
+	budget, err := New(t.TempDir(), 1, WithDiskBudget(1))
+	require.NoError(t, err)
+	defer budget.Close(t.Context())
+	require.ErrorIs(t, budget.InsertPartitioned(t.Context(), "events", "at", strings.NewReader(data)), ErrQuotaExceeded)
+}
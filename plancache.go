@@ -0,0 +1,317 @@
+package quack
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// PlanCacheStats reports the activity of the cache set up by WithPlanCache.
+type PlanCacheStats struct {
+	// Hits and Misses count statements run from a cached plan and ones
+	// prepared for the first time. Bypassed counts statements run as given
+	// because they could not be normalized or prepared.
+	Hits, Misses, Bypassed int64
+	// Size is the number of plans held.
+	Size int
+}
+
+type plan struct {
+	key  string
+	stmt *sql.Stmt
+}
+
+// planCache keeps the most recently used prepared statements by fingerprint.
+// Queries share the client lock, so the cache has a lock of its own, held
+// while a statement starts so a plan is not closed while in use.
+type planCache struct {
+	mux   sync.Mutex
+	size  int
+	order *list.List
+	plans map[string]*list.Element
+	stats PlanCacheStats
+}
+
+func newPlanCache(size int) *planCache {
+	return &planCache{size: size, order: list.New(), plans: make(map[string]*list.Element)}
+}
+
+// query runs stmt from the plan of its fingerprint, preparing one if
+// needed. A statement given with args is its own fingerprint. A nil cache
+// runs stmt as given.
+func (p *planCache) query(ctx context.Context, db *sql.DB, stmt string, params ...any) (*sql.Rows, error) {
+	if p == nil {
+		return db.QueryContext(ctx, stmt, params...)
+	}
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	key, args, ok := stmt, params, true
+	if len(params) == 0 {
+		key, args, ok = normalize(stmt)
+	}
+	if !ok {
+		p.stats.Bypassed++
+		return db.QueryContext(ctx, stmt)
+	}
+	if e, ok := p.plans[key]; ok {
+		p.stats.Hits++
+		p.order.MoveToFront(e)
+		return e.Value.(*plan).stmt.QueryContext(ctx, args...)
+	}
+	s, err := db.PrepareContext(ctx, key)
+	if err != nil {
+		// Let the original statement report its own error.
+		p.stats.Bypassed++
+		return db.QueryContext(ctx, stmt, params...)
+	}
+	p.stats.Misses++
+	p.plans[key] = p.order.PushFront(&plan{key: key, stmt: s})
+	for p.order.Len() > p.size {
+		old := p.order.Remove(p.order.Back()).(*plan)
+		delete(p.plans, old.key)
+		old.stmt.Close()
+	}
+	return s.QueryContext(ctx, args...)
+}
+
+func (p *planCache) snapshot() PlanCacheStats {
+	if p == nil {
+		return PlanCacheStats{}
+	}
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	s := p.stats
+	s.Size = p.order.Len()
+	return s
+}
+
+// reset drops every plan, for when the database they belong to closes.
+func (p *planCache) reset() {
+	if p == nil {
+		return
+	}
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	for e := p.order.Front(); e != nil; e = e.Next() {
+		e.Value.(*plan).stmt.Close()
+	}
+	p.order.Init()
+	clear(p.plans)
+}
+
+type tokenKind int
+
+const (
+	tokSpace tokenKind = iota
+	tokWord
+	tokQuoted
+	tokString
+	tokNumber
+	tokOp
+	tokOther
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	// param marks a literal normalize turns into a parameter.
+	param bool
+}
+
+// tokenize splits a single statement into tokens, or reports false for
+// anything normalize should not touch: parameters, several statements or
+// unterminated literals.
+func tokenize(stmt string) ([]token, bool) {
+	var tokens []token
+	for i := 0; i < len(stmt); {
+		if j := skipNonCode(stmt, i); j > i {
+			text := stmt[i:j]
+			kind := tokOther
+			switch {
+			case text[0] == '\'':
+				kind = tokString
+			case text[0] == '"':
+				kind = tokQuoted
+			case text[0] == '-' || text[0] == '/':
+				kind = tokSpace
+			}
+			if (kind == tokString || kind == tokQuoted) && (len(text) < 2 || text[len(text)-1] != text[0]) {
+				return nil, false
+			}
+			tokens = append(tokens, token{kind: kind, text: text})
+			i = j
+			continue
+		}
+		c := stmt[i]
+		j := i + 1
+		kind := tokOther
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			for j < len(stmt) && strings.IndexByte(" \t\n\r", stmt[j]) >= 0 {
+				j++
+			}
+			kind = tokSpace
+		case isIdentStart(c):
+			for j < len(stmt) && isIdentPart(stmt[j]) {
+				j++
+			}
+			kind = tokWord
+		case c >= '0' && c <= '9':
+			for j < len(stmt) && (isIdentPart(stmt[j]) || stmt[j] == '.') {
+				j++
+			}
+			kind = tokNumber
+		case c == '?' || c == '$':
+			return nil, false
+		case c == ';':
+			if strings.TrimSpace(stmt[j:]) != "" {
+				return nil, false
+			}
+			return tokens, true
+		case strings.IndexByte("<>=!|:&+-*/%^~@#", c) >= 0:
+			for j < len(stmt) && strings.IndexByte("<>=!|:&+-*/%^~@#", stmt[j]) >= 0 {
+				j++
+			}
+			kind = tokOp
+		}
+		tokens = append(tokens, token{kind: kind, text: stmt[i:j]})
+		i = j
+	}
+	return tokens, true
+}
+
+var (
+	comparisons = []string{"=", "==", "<>", "!=", "<", ">", "<=", ">="}
+	// operandEnds are the keywords that may follow a literal compared to a
+	// column without being part of the same expression.
+	operandEnds = []string{
+		"AND", "OR", "ORDER", "GROUP", "LIMIT", "OFFSET", "HAVING", "UNION", "EXCEPT", "INTERSECT",
+		"WINDOW", "QUALIFY", "THEN", "WHEN", "ELSE", "END", "FROM", "AS",
+	}
+	// keywords that cannot name the column a literal is compared to.
+	notOperands = []string{
+		"AND", "OR", "NOT", "WHERE", "ON", "WHEN", "THEN", "ELSE", "CASE", "SELECT", "HAVING", "BY",
+		"IS", "NULL", "TRUE", "FALSE", "ALL", "ANY", "SOME", "EXISTS", "IN", "LIKE", "ILIKE",
+	}
+	// bypassWords mark statements normalize leaves alone: writes, and
+	// PIVOT, whose IN lists name output columns.
+	bypassWords = []string{"INSERT", "UPDATE", "DELETE", "CREATE", "ALTER", "DROP", "COPY", "PIVOT", "UNPIVOT", "EXPORT", "ATTACH"}
+)
+
+func isWord(t token, words ...string) bool {
+	return t.kind == tokWord && slices.Contains(words, strings.ToUpper(t.text))
+}
+
+// normalize rewrites the literals of a query that are compared to a column
+// into parameters and returns the rewritten statement, which fingerprints
+// the query, with the literal values. Only plain string and integer
+// literals are rewritten, and only where the comparison is an expression of
+// its own: column = 'a' AND ..., column IN (1, 2) or column LIKE 'a%'.
+// DuckDB types such a parameter after the column just as it does the
+// literal, so the statement keeps its meaning. Other literals stay in the
+// fingerprint. ok is false for anything but a single query without
+// parameters of its own.
+func normalize(stmt string) (key string, args []any, ok bool) {
+	all, ok := tokenize(strings.TrimSpace(stmt))
+	if !ok {
+		return "", nil, false
+	}
+	var sig []*token
+	for i := range all {
+		if all[i].kind != tokSpace {
+			sig = append(sig, &all[i])
+		}
+	}
+	if len(sig) == 0 || !isWord(*sig[0], "SELECT", "WITH", "FROM") {
+		return "", nil, false
+	}
+	for _, t := range sig {
+		if isWord(*t, bypassWords...) {
+			return "", nil, false
+		}
+	}
+	at := func(i int) token {
+		if i < 0 || i >= len(sig) {
+			return token{}
+		}
+		return *sig[i]
+	}
+	ends := func(i int) bool {
+		t := at(i)
+		return i >= len(sig) || t.text == ")" || t.text == "," || isWord(t, operandEnds...)
+	}
+	for i, t := range sig {
+		switch {
+		case t.kind == tokOp && slices.Contains(comparisons, t.text), isWord(*t, "LIKE", "ILIKE"):
+			if literal(at(i+1)) && ends(i+2) && columnOperand(sig, i-1) {
+				sig[i+1].param = true
+			}
+		case isWord(*t, "IN") && at(i+1).text == "(":
+			left := i - 1
+			if isWord(at(left), "NOT") {
+				left--
+			}
+			j := i + 2
+			for literal(at(j)) && at(j+1).text == "," {
+				j += 2
+			}
+			if !literal(at(j)) || at(j+1).text != ")" || !ends(j+2) || !columnOperand(sig, left) {
+				continue
+			}
+			for k := i + 2; k <= j; k += 2 {
+				sig[k].param = true
+			}
+		}
+	}
+	var b strings.Builder
+	for _, t := range all {
+		if !t.param {
+			b.WriteString(t.text)
+			continue
+		}
+		b.WriteByte('?')
+		if t.kind == tokString {
+			args = append(args, strings.ReplaceAll(t.text[1:len(t.text)-1], "''", "'"))
+		} else {
+			n, _ := strconv.ParseInt(t.text, 10, 64)
+			args = append(args, n)
+		}
+	}
+	return b.String(), args, true
+}
+
+// literal reports a plain string literal or an integer fitting int64.
+func literal(t token) bool {
+	switch t.kind {
+	case tokString:
+		return true
+	case tokNumber:
+		_, err := strconv.ParseInt(t.text, 10, 64)
+		return err == nil
+	}
+	return false
+}
+
+// columnOperand reports whether sig[i] ends the left operand of a
+// comparison that is an expression of its own: a possibly qualified column
+// or a parenthesized expression, not an argument of a function call such as
+// read_csv(file, sep = ',').
+func columnOperand(sig []*token, i int) bool {
+	if i < 0 {
+		return false
+	}
+	switch t := sig[i]; {
+	case t.text == ")" || t.text == "]":
+		return true
+	case t.kind == tokQuoted, t.kind == tokWord && !isWord(*t, notOperands...):
+		for i >= 2 && sig[i-1].text == "." && (sig[i-2].kind == tokWord || sig[i-2].kind == tokQuoted) {
+			i -= 2
+		}
+		return i == 0 || (sig[i-1].text != "(" && sig[i-1].text != ",")
+	}
+	return false
+}
@@ -0,0 +1,199 @@
+package quack
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_normalize(t *testing.T) {
+	for _, tc := range []struct {
+		stmt string
+		key  string
+		args []any
+	}{
+		{"SELECT * FROM t WHERE id = 5", "SELECT * FROM t WHERE id = ?", []any{int64(5)}},
+		{"select * from t where name = 'it''s' and id > 3;", "select * from t where name = ? and id > ?", []any{"it's", int64(3)}},
+		{`SELECT * FROM t WHERE t."id" <> 5 ORDER BY 1 LIMIT 10`, `SELECT * FROM t WHERE t."id" <> ? ORDER BY 1 LIMIT 10`, []any{int64(5)}},
+		{"SELECT * FROM t WHERE id IN (1, 2, 3)", "SELECT * FROM t WHERE id IN (?, ?, ?)", []any{int64(1), int64(2), int64(3)}},
+		{"SELECT * FROM t WHERE id NOT IN (1) AND name LIKE 'a%'", "SELECT * FROM t WHERE id NOT IN (?) AND name LIKE ?", []any{int64(1), "a%"}},
+		{"SELECT * FROM t WHERE lower(name) = 'a'", "SELECT * FROM t WHERE lower(name) = ?", []any{"a"}},
+		{"SELECT CASE WHEN id = 1 THEN 'one' ELSE 'other' END FROM t", "SELECT CASE WHEN id = ? THEN 'one' ELSE 'other' END FROM t", []any{int64(1)}},
+		{"WITH x AS (SELECT * FROM t WHERE id = 1) SELECT * FROM x", "WITH x AS (SELECT * FROM t WHERE id = ?) SELECT * FROM x", []any{int64(1)}},
+		// Literals that are not the whole operand, or not compared to a
+		// column, stay put.
+		{"SELECT * FROM t WHERE ts > '2024-01-01'::DATE", "SELECT * FROM t WHERE ts > '2024-01-01'::DATE", nil},
+		{"SELECT * FROM t WHERE id = 1 + 1", "SELECT * FROM t WHERE id = 1 + 1", nil},
+		{"SELECT * FROM t WHERE id = -1", "SELECT * FROM t WHERE id = -1", nil},
+		{"SELECT * FROM t WHERE price = 1.5", "SELECT * FROM t WHERE price = 1.5", nil},
+		{"SELECT * FROM t WHERE id = 99999999999999999999", "SELECT * FROM t WHERE id = 99999999999999999999", nil},
+		{"SELECT * FROM t WHERE 1 = 1", "SELECT * FROM t WHERE 1 = 1", nil},
+		{"SELECT * FROM t WHERE id BETWEEN 1 AND 2", "SELECT * FROM t WHERE id BETWEEN 1 AND 2", nil},
+		{"SELECT * FROM t WHERE name = E'a\\'b'", "SELECT * FROM t WHERE name = E'a\\'b'", nil},
+		{"SELECT * FROM read_csv('x.csv', sep = ',')", "SELECT * FROM read_csv('x.csv', sep = ',')", nil},
+		{"SELECT * FROM t WHERE id IN (1, id)", "SELECT * FROM t WHERE id IN (1, id)", nil},
+		{"SELECT 'x' -- id = 1\nFROM t", "SELECT 'x' -- id = 1\nFROM t", nil},
+	} {
+		key, args, ok := normalize(tc.stmt)
+		require.True(t, ok, tc.stmt)
+		require.Equal(t, tc.key, key, tc.stmt)
+		require.Equal(t, tc.args, args, tc.stmt)
+	}
+	for _, stmt := range []string{
+		"",
+		"INSERT INTO t VALUES (1)",
+		"SELECT * FROM t WHERE id = ?",
+		"SELECT * FROM t WHERE id = $1",
+		"SELECT 1; SELECT 2",
+		"SELECT * FROM t WHERE name = 'open",
+		"PIVOT t ON name IN ('a', 'b') USING sum(id)",
+		"SELECT * FROM (PIVOT t ON name IN ('a') USING sum(id))",
+		"CREATE TABLE x AS SELECT * FROM t WHERE id = 1",
+	} {
+		_, _, ok := normalize(stmt)
+		require.False(t, ok, stmt)
+	}
+}
+
+// planCorpus holds queries whose results must not change when run from the
+// plan cache. Queries of the same shape share a plan.
+var planCorpus = []string{
+	"SELECT * FROM p WHERE i = 1 ORDER BY ALL",
+	"SELECT * FROM p WHERE i = 2 ORDER BY ALL",
+	"SELECT * FROM p WHERE i = 3000000000 ORDER BY ALL",
+	"SELECT * FROM p WHERE ti = 300 ORDER BY ALL",
+	"SELECT * FROM p WHERE ti = '1' ORDER BY ALL",
+	"SELECT * FROM p WHERE v = 1 ORDER BY ALL",
+	"SELECT * FROM p WHERE v = '01' ORDER BY ALL",
+	"SELECT * FROM p WHERE v = 'it''s' ORDER BY ALL",
+	"SELECT * FROM p WHERE v LIKE 'a%' ORDER BY ALL",
+	"SELECT * FROM p WHERE v ILIKE 'A%' ORDER BY ALL",
+	"SELECT * FROM p WHERE d = '2024-01-01' ORDER BY ALL",
+	"SELECT * FROM p WHERE d >= '2024-01-02' ORDER BY ALL",
+	"SELECT * FROM p WHERE ts < '2024-01-01 12:00:00' ORDER BY ALL",
+	"SELECT * FROM p WHERE dec = 1 ORDER BY ALL",
+	"SELECT * FROM p WHERE dec = '1.10' ORDER BY ALL",
+	"SELECT * FROM p WHERE f = 0 ORDER BY ALL",
+	"SELECT * FROM p WHERE bo = 'true' ORDER BY ALL",
+	"SELECT * FROM p WHERE e = 'b' ORDER BY ALL",
+	"SELECT * FROM p WHERE e <> 'c' ORDER BY ALL",
+	"SELECT * FROM p WHERE u = '00000000-0000-0000-0000-000000000001' ORDER BY ALL",
+	"SELECT * FROM p WHERE l = '[1, 2]' ORDER BY ALL",
+	"SELECT * FROM p WHERE i IN (1, 3) ORDER BY ALL",
+	"SELECT * FROM p WHERE i NOT IN (1) ORDER BY ALL",
+	"SELECT * FROM p WHERE v IN ('a', 'it''s') ORDER BY ALL",
+	"SELECT * FROM p WHERE (i = 1 OR i = 2) AND v <> 'x' ORDER BY ALL",
+	"SELECT i = 1 AS one, v FROM p ORDER BY ALL",
+	"SELECT CASE WHEN i = 1 THEN 'one' ELSE 'many' END AS c FROM p ORDER BY ALL",
+	"SELECT count(*) FROM p GROUP BY v HAVING count(*) > 0 ORDER BY ALL",
+	"SELECT * FROM p WHERE length(v) = 1 ORDER BY ALL",
+	"SELECT * FROM p WHERE i = (SELECT max(i) FROM p WHERE v = 'a') ORDER BY ALL",
+	"WITH x AS (SELECT * FROM p WHERE i = 2) SELECT * FROM x ORDER BY ALL",
+	"SELECT * FROM p WHERE list_filter(l, x -> x = 2) = [2] ORDER BY ALL",
+	"SELECT * FROM p WHERE v = 'a' UNION ALL SELECT * FROM p WHERE i = 2 ORDER BY ALL",
+	"SELECT * FROM p WHERE i = 1 ORDER BY i = 1, v LIMIT 1",
+	"SELECT * FROM p a JOIN p b ON a.i = b.i AND b.v = 'a' ORDER BY ALL",
+	"FROM p WHERE i = 2 SELECT v",
+	"SELECT * FROM p WHERE v = 'a' ORDER BY 1",
+	"SELECT * FROM p WHERE s.x = 'a' ORDER BY ALL",
+	"SELECT * FROM p WHERE m['k'] = 1 ORDER BY ALL",
+}
+
+func scanStrings(t *testing.T, rows interface {
+	Columns() ([]string, error)
+	Next() bool
+	Scan(...any) error
+	Err() error
+	Close() error
+}) []string {
+	t.Helper()
+	defer rows.Close()
+	cols, err := rows.Columns()
+	require.NoError(t, err)
+	values := make([]any, len(cols))
+	dest := make([]any, len(cols))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	var out []string
+	for rows.Next() {
+		require.NoError(t, rows.Scan(dest...))
+		out = append(out, fmt.Sprint(values...))
+	}
+	require.NoError(t, rows.Err())
+	return out
+}
+
+func Test_PlanCache(t *testing.T) {
+	client, err := New(t.TempDir(), 1, WithPlanCache(64))
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+	for _, stmt := range []string{
+		"CREATE TYPE letters AS ENUM ('a', 'b', 'c');",
+		`CREATE TABLE p (i BIGINT, ti TINYINT, v VARCHAR, d DATE, ts TIMESTAMP, dec DECIMAL(10, 2), f DOUBLE,
+			bo BOOLEAN, e letters, u UUID, l INTEGER[], s STRUCT(x VARCHAR), m MAP(VARCHAR, INTEGER));`,
+		`INSERT INTO p VALUES
+			(1, 1, 'a', '2024-01-01', '2024-01-01 10:00:00', 1.10, 0.0, true, 'a', '00000000-0000-0000-0000-000000000001', [1, 2], {'x': 'a'}, MAP {'k': 1}),
+			(2, 2, '01', '2024-01-02', '2024-01-02 10:00:00', 2.50, -0.0, false, 'b', '00000000-0000-0000-0000-000000000002', [2], {'x': 'b'}, MAP {'k': 2}),
+			(3, 3, 'it''s', NULL, NULL, NULL, 'nan', NULL, NULL, NULL, [], NULL, NULL),
+			(3000000000, NULL, 'A', '2024-01-03', '2024-01-03 00:00:00', 1.00, 1e10, true, 'c', NULL, NULL, {'x': NULL}, MAP {});`,
+	} {
+		_, err := client.db.ExecContext(t.Context(), stmt)
+		require.NoError(t, err)
+	}
+	for range 2 {
+		for _, stmt := range planCorpus {
+			want, wantErr := client.db.QueryContext(t.Context(), stmt)
+			got, err := client.Query(t.Context(), stmt)
+			if wantErr != nil {
+				require.Error(t, err, stmt)
+				continue
+			}
+			require.NoError(t, err, stmt)
+			require.Equal(t, scanStrings(t, want), scanStrings(t, got), stmt)
+		}
+	}
+	stats, err := client.Stats(t.Context())
+	require.NoError(t, err)
+	cache := stats.PlanCache
+	require.Equal(t, int64(len(planCorpus)*2), cache.Hits+cache.Misses+cache.Bypassed)
+	require.Greater(t, cache.Hits, int64(len(planCorpus)), "same-shape queries share plans")
+	require.Less(t, cache.Misses, int64(len(planCorpus)))
+
+	t.Run("schema changes", func(t *testing.T) {
+		stmt := "SELECT * FROM p WHERE i = 1"
+		rows, err := client.Query(t.Context(), stmt)
+		require.NoError(t, err)
+		require.NoError(t, rows.Close())
+		_, err = client.db.ExecContext(t.Context(), "ALTER TABLE p ADD COLUMN extra INTEGER DEFAULT 7;")
+		require.NoError(t, err)
+		rows, err = client.Query(t.Context(), stmt)
+		require.NoError(t, err)
+		cols, err := rows.Columns()
+		require.NoError(t, err)
+		require.NoError(t, rows.Close())
+		require.Contains(t, cols, "extra")
+	})
+	t.Run("errors", func(t *testing.T) {
+		_, err := client.Query(t.Context(), "SELECT * FROM missing WHERE id = 1")
+		require.ErrorContains(t, err, "missing")
+	})
+	t.Run("eviction", func(t *testing.T) {
+		small, err := New(t.TempDir(), 1, WithPlanCache(2))
+		require.NoError(t, err)
+		defer small.Close(t.Context())
+		for _, col := range []string{"a", "b", "c", "a"} {
+			rows, err := small.Query(t.Context(), fmt.Sprintf("SELECT '%s' AS c", col))
+			require.NoError(t, err)
+			require.NoError(t, rows.Close())
+		}
+		stats, err := small.Stats(t.Context())
+		require.NoError(t, err)
+		require.Equal(t, PlanCacheStats{Misses: 4, Size: 2}, stats.PlanCache)
+		require.NoError(t, small.Compact(t.Context()))
+		stats, err = small.Stats(t.Context())
+		require.NoError(t, err)
+		require.Zero(t, stats.PlanCache.Size)
+	})
+}
@@ -0,0 +1,216 @@
+package quack
+
+import (
+	"context"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"strings"
+	"text/template"
+	"unicode/utf8"
+)
+
+// Profile is a data profiling report for one table.
+type Profile struct {
+	Table string `json:"table"`
+	Rows  int64  `json:"rows"`
+	// Sample is the number of rows the statistics were computed over, 0
+	// when the whole table was scanned.
+	Sample int64 `json:"sample,omitempty"`
+	// Duplicates counts rows identical to an earlier row, within the sample
+	// when sampling.
+	Duplicates int64           `json:"duplicates"`
+	Columns    []ColumnProfile `json:"columns"`
+	Anomalies  []Anomaly       `json:"anomalies,omitempty"`
+}
+
+type ColumnProfile struct {
+	Column
+	Stats ColumnStats `json:"stats"`
+	// Values is the full value distribution of low-cardinality columns,
+	// most frequent first.
+	Values []ValueCount `json:"values,omitempty"`
+}
+
+type ValueCount struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+type Anomaly struct {
+	Column string `json:"column"`
+	// Kind is "all_null" or "constant".
+	Kind string `json:"kind"`
+}
+
+type profileConfig struct {
+	sample    int64
+	maxValues int
+	maxLength int
+	topK      int
+}
+
+type ProfileOption func(*profileConfig)
+
+// ProfileSample profiles a random sample of n rows instead of the whole
+// table. The row count is always exact.
+func ProfileSample(n int64) ProfileOption {
+	return func(c *profileConfig) { c.sample = n }
+}
+
+// MaxDistinctValues sets the cardinality up to which a column's full value
+// distribution is reported. Defaults to 20; 0 disables distributions.
+func MaxDistinctValues(n int) ProfileOption {
+	return func(c *profileConfig) { c.maxValues = n }
+}
+
+// MaxValueLength truncates reported values to n characters. Defaults to 80.
+func MaxValueLength(n int) ProfileOption {
+	return func(c *profileConfig) { c.maxLength = n }
+}
+
+func truncateValue(s string, n int) string {
+	if n <= 0 || utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n]) + "…"
+}
+
+// Profile reports the schema, row count, duplicates and per-column
+// statistics of table, with value distributions for low-cardinality
+// columns and columns that are entirely NULL or constant flagged.
+func (c *Client) Profile(ctx context.Context, table string, opts ...ProfileOption) (_ *Profile, err error) {
+	cfg := profileConfig{maxValues: 20, maxLength: 80, topK: 5}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if err := c.lock("Profile"); err != nil {
+		return nil, err
+	}
+	defer c.unlock("Profile", &err)
+	columns, err := describe(ctx, c.db, table)
+	if err != nil {
+		return nil, err
+	}
+	p := &Profile{Table: table, Sample: cfg.sample}
+	if err := c.db.QueryRowContext(ctx, fmt.Sprintf("SELECT count(*) FROM %s;", table)).Scan(&p.Rows); err != nil {
+		return nil, err
+	}
+	source := table
+	if cfg.sample > 0 {
+		source = fmt.Sprintf("(SELECT * FROM %s USING SAMPLE %d ROWS)", table, cfg.sample)
+	}
+	if err := c.db.QueryRowContext(ctx, fmt.Sprintf(
+		"SELECT count(*) - (SELECT count(*) FROM (SELECT DISTINCT * FROM %[1]s)) FROM %[1]s;", source,
+	)).Scan(&p.Duplicates); err != nil {
+		return nil, err
+	}
+	stats, err := columnStats(ctx, c.db, table, nil, columnStatsConfig{sample: cfg.sample, topK: cfg.topK})
+	if err != nil {
+		return nil, err
+	}
+	for i, col := range columns {
+		s := stats[i]
+		s.Min, s.Max = truncateValue(s.Min, cfg.maxLength), truncateValue(s.Max, cfg.maxLength)
+		for j, v := range s.TopValues {
+			s.TopValues[j] = truncateValue(v, cfg.maxLength)
+		}
+		cp := ColumnProfile{Column: col, Stats: s}
+		if s.Rows > 0 && s.Nulls == s.Rows {
+			p.Anomalies = append(p.Anomalies, Anomaly{Column: col.Name, Kind: "all_null"})
+		} else if s.Rows > 0 && s.Nulls == 0 && s.Min == s.Max {
+			p.Anomalies = append(p.Anomalies, Anomaly{Column: col.Name, Kind: "constant"})
+		}
+		if s.Distinct > 0 && s.Distinct <= int64(cfg.maxValues) {
+			if cp.Values, err = valueCounts(ctx, c.db, source, col.Name, cfg); err != nil {
+				return nil, err
+			}
+		}
+		p.Columns = append(p.Columns, cp)
+	}
+	return p, nil
+}
+
+func valueCounts(ctx context.Context, db querier, source, column string, cfg profileConfig) ([]ValueCount, error) {
+	col := quoteIdent(column)
+	value := col + "::VARCHAR"
+	if cfg.maxLength > 0 {
+		// One character more than the limit is enough to tell it was cut.
+		value = fmt.Sprintf("left(%s, %d)", value, cfg.maxLength+1)
+	}
+	rows, err := db.QueryContext(ctx, fmt.Sprintf(
+		"SELECT coalesce(%s, 'NULL'), count(*) FROM %s GROUP BY %s ORDER BY 2 DESC, 1 LIMIT %d;",
+		value, source, col, cfg.maxValues,
+	))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var values []ValueCount
+	for rows.Next() {
+		var v ValueCount
+		if err := rows.Scan(&v.Value, &v.Count); err != nil {
+			return nil, err
+		}
+		v.Value = truncateValue(v.Value, cfg.maxLength)
+		values = append(values, v)
+	}
+	return values, rows.Err()
+}
+
+var profileFuncs = map[string]any{
+	"percent": func(f float64) string { return fmt.Sprintf("%.1f%%", f*100) },
+	"join":    strings.Join,
+	"cell":    strings.NewReplacer("|", `\|`, "\n", " ", "\r", " ").Replace,
+}
+
+const markdownProfile = `# {{.Table}}
+
+{{.Rows}} rows{{if .Sample}}, statistics over a sample of {{.Sample}}{{end}}, {{.Duplicates}} duplicate rows.
+
+| Column | Type | Nullable | Nulls | Distinct | Min | Max | Top values |
+|---|---|---|---|---|---|---|---|
+{{range .Columns}}| {{cell .Name}} | {{.Type}} | {{.Nullable}} | {{percent .Stats.NullFraction}} | {{.Stats.Distinct}} | {{cell .Stats.Min}} | {{cell .Stats.Max}} | {{cell (join .Stats.TopValues ", ")}} |
+{{end}}{{range .Columns}}{{if .Values}}
+## {{.Name}}
+
+| Value | Count |
+|---|---|
+{{range .Values}}| {{cell .Value}} | {{.Count}} |
+{{end}}{{end}}{{end}}{{if .Anomalies}}
+## Anomalies
+
+{{range .Anomalies}}- {{.Column}}: {{.Kind}}
+{{end}}{{end}}`
+
+const htmlProfile = `<h1>{{.Table}}</h1>
+<p>{{.Rows}} rows{{if .Sample}}, statistics over a sample of {{.Sample}}{{end}}, {{.Duplicates}} duplicate rows.</p>
+<table>
+<tr><th>Column</th><th>Type</th><th>Nullable</th><th>Nulls</th><th>Distinct</th><th>Min</th><th>Max</th><th>Top values</th></tr>
+{{range .Columns}}<tr><td>{{.Name}}</td><td>{{.Type}}</td><td>{{.Nullable}}</td><td>{{percent .Stats.NullFraction}}</td><td>{{.Stats.Distinct}}</td><td>{{.Stats.Min}}</td><td>{{.Stats.Max}}</td><td>{{join .Stats.TopValues ", "}}</td></tr>
+{{end}}</table>
+{{range .Columns}}{{if .Values}}<h2>{{.Name}}</h2>
+<table>
+<tr><th>Value</th><th>Count</th></tr>
+{{range .Values}}<tr><td>{{.Value}}</td><td>{{.Count}}</td></tr>
+{{end}}</table>
+{{end}}{{end}}{{if .Anomalies}}<h2>Anomalies</h2>
+<ul>
+{{range .Anomalies}}<li>{{.Column}}: {{.Kind}}</li>
+{{end}}</ul>
+{{end}}`
+
+var (
+	markdownTemplate = template.Must(template.New("profile").Funcs(profileFuncs).Parse(markdownProfile))
+	htmlTemplate     = htmltemplate.Must(htmltemplate.New("profile").Funcs(profileFuncs).Parse(htmlProfile))
+)
+
+// WriteMarkdown renders the profile as a Markdown document.
+func (p *Profile) WriteMarkdown(w io.Writer) error {
+	return markdownTemplate.Execute(w, p)
+}
+
+// WriteHTML renders the profile as an HTML fragment.
+func (p *Profile) WriteHTML(w io.Writer) error {
+	return htmlTemplate.Execute(w, p)
+}
@@ -0,0 +1,47 @@
+package quack
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Profile(t *testing.T) {
+	client, err := New(t.TempDir(), 3)
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+	var b strings.Builder
+	long := strings.Repeat("x|", 100)
+	for i := range 50 {
+		fmt.Fprintf(&b, "{\"id\":%d,\"kind\":%q,\"src\":\"api\",\"gone\":null,\"body\":%q}\n", i, []string{"a", "b"}[i%2], long)
+	}
+	b.WriteString("{\"id\":0,\"kind\":\"a\",\"src\":\"api\",\"gone\":null,\"body\":\"" + long + "\"}\n")
+	require.NoError(t, client.Insert(t.Context(), "events", strings.NewReader(b.String())))
+
+	p, err := client.Profile(t.Context(), "events", MaxValueLength(10))
+	require.NoError(t, err)
+	require.Equal(t, int64(51), p.Rows)
+	require.Equal(t, int64(1), p.Duplicates)
+	require.Len(t, p.Columns, 5)
+	require.Equal(t, []ValueCount{{"a", 26}, {"b", 25}}, p.Columns[1].Values)
+	require.Nil(t, p.Columns[0].Values)
+	require.Equal(t, "x|x|x|x|x|…", p.Columns[4].Stats.Max)
+	require.Equal(t, []Anomaly{{"src", "constant"}, {"gone", "all_null"}, {"body", "constant"}}, p.Anomalies)
+
+	var md, html bytes.Buffer
+	require.NoError(t, p.WriteMarkdown(&md))
+	require.Contains(t, md.String(), "# events")
+	require.Contains(t, md.String(), `| x\|x\|x\|x\|x\|… |`)
+	require.Contains(t, md.String(), "- gone: all_null")
+	require.NoError(t, p.WriteHTML(&html))
+	require.Contains(t, html.String(), "<td>a</td><td>26</td>")
+
+	p, err = client.Profile(t.Context(), "events", ProfileSample(10), MaxDistinctValues(0))
+	require.NoError(t, err)
+	require.Equal(t, int64(51), p.Rows)
+	require.Equal(t, int64(10), p.Columns[0].Stats.Rows)
+	require.Nil(t, p.Columns[1].Values)
+}
@@ -7,11 +7,14 @@ import (
 	"database/sql/driver"
 	"fmt"
 	"io"
+	"log/slog"
 	"os"
 	"path"
 	"path/filepath"
 	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/duckdb/duckdb-go/v2"
@@ -25,82 +28,90 @@ func listDir(dir string) ([]string, error) {
 	}
 	names := make([]string, 0, len(infos))
 	for _, info := range infos {
+		// Skip snapshots still being written.
+		if strings.HasSuffix(info.Name(), ".tmp") {
+			continue
+		}
 		names = append(names, info.Name())
 	}
 	sort.Strings(names)
 	return names, nil
 }
 
-func rotate(root string, n int) error {
-	matches, err := listDir(root)
+// rotate keeps the newest n snapshots in root and, with maxBytes set, drops
+// the oldest of those until they total at most maxBytes, keeping at least
+// the newest.
+func rotate(root string, n int, maxBytes int64) error {
+	infos, err := snapshotInfos(root)
 	if err != nil {
 		return err
 	}
-	if len(matches) > n {
-		sort.Strings(matches)
-		for _, m := range matches[n:] {
-			if err := os.Remove(filepath.Join(root, m)); err != nil {
-				return err
-			}
+	keep := min(n, len(infos))
+	var total int64
+	for _, info := range infos[:keep] {
+		total += info.Size
+	}
+	for maxBytes > 0 && total > maxBytes && keep > 1 {
+		keep--
+		total -= infos[keep].Size
+	}
+	for _, info := range infos[keep:] {
+		if err := os.Remove(info.Path); err != nil {
+			return err
 		}
 	}
 	return nil
 }
 
-func dumpAndZip(ctx context.Context, db *sql.DB, w io.Writer) error {
-	dir, err := os.MkdirTemp("", "dump")
+func dumpAndZip(ctx context.Context, db querier, staging string, w io.Writer, cfg snapshotConfig) error {
+	dir, err := exportDump(ctx, db, staging, cfg)
 	if err != nil {
 		return err
 	}
 	defer os.RemoveAll(dir)
-	if _, err := db.ExecContext(ctx, fmt.Sprintf("EXPORT DATABASE '%s' (FORMAT JSON);", dir)); err != nil {
-		return err
-	}
-	zw := zip.NewWriter(w)
-	if err := zw.AddFS(os.DirFS(dir)); err != nil {
-		return err
-	}
-	return zw.Close()
+	return zipDump(w, dir, staging, cfg)
 }
 
-func unzipAndLoad(ctx context.Context, db *sql.DB, file string) error {
-	dir, err := os.MkdirTemp("", "load")
+// exportDump exports db into a new directory in staging and returns it.
+func exportDump(ctx context.Context, db querier, staging string, cfg snapshotConfig) (string, error) {
+	dir, err := os.MkdirTemp(staging, dumpPrefix)
 	if err != nil {
-		return err
+		return "", err
 	}
-	defer os.RemoveAll(dir)
-	zr, err := zip.OpenReader(file)
-	if err != nil {
-		return err
+	if _, err := db.ExecContext(ctx, fmt.Sprintf("EXPORT DATABASE '%s' (FORMAT JSON);", dir)); err != nil {
+		os.RemoveAll(dir)
+		return "", err
 	}
-	defer zr.Close()
-	for _, zf := range zr.File {
-		r, err := zf.Open()
-		if err != nil {
-			return err
-		}
-		f, err := os.Create(filepath.Join(dir, zf.Name))
-		if err != nil {
-			return err
-		}
-		if _, err := io.Copy(f, r); err != nil {
-			return err
-		}
-		if err := f.Close(); err != nil {
-			return err
-		}
-		if err := r.Close(); err != nil {
-			return err
+	if cfg.deterministic {
+		if err := stabilizeExport(ctx, db, dir); err != nil {
+			os.RemoveAll(dir)
+			return "", err
 		}
 	}
-	if _, err := db.ExecContext(ctx, fmt.Sprintf("IMPORT DATABASE '%s';", dir)); err != nil {
+	return dir, nil
+}
+
+// zipDump archives the export dir to w.
+func zipDump(w io.Writer, dir, staging string, cfg snapshotConfig) error {
+	zw := zip.NewWriter(w)
+	if err := addDir(zw, dir, staging, cfg); err != nil {
 		return err
 	}
-	return nil
+	if err := failpoint(fpSnapshotExported); err != nil {
+		return err
+	}
+	return zw.Close()
 }
 
-func showTables(ctx context.Context, db *sql.DB) ([]string, error) {
-	rows, err := db.QueryContext(ctx, "SHOW TABLES;")
+// showTables lists the tables and views of the database, qualified with
+// their schema outside the current one, e.g. raw.events.
+func showTables(ctx context.Context, db querier) ([]string, error) {
+	rows, err := db.QueryContext(ctx, `SELECT CASE WHEN schema_name = current_schema() THEN table_name ELSE schema_name || '.' || table_name END AS name
+FROM duckdb_tables() WHERE database_name = current_database()
+UNION ALL
+SELECT CASE WHEN schema_name = current_schema() THEN view_name ELSE schema_name || '.' || view_name END
+FROM duckdb_views() WHERE database_name = current_database() AND NOT internal
+ORDER BY name;`)
 	if err != nil {
 		return nil, err
 	}
@@ -116,165 +127,952 @@ func showTables(ctx context.Context, db *sql.DB) ([]string, error) {
 	return tables, rows.Err()
 }
 
-func tableExists(ctx context.Context, db *sql.DB, table string) error {
-	tables, err := showTables(ctx, db)
+// showSchemas lists the schemas of the database other than the current one.
+func showSchemas(ctx context.Context, db querier) ([]string, error) {
+	rows, err := db.QueryContext(ctx, "SELECT schema_name FROM duckdb_schemas() WHERE database_name = current_database() AND NOT internal AND schema_name <> current_schema() ORDER BY 1;")
 	if err != nil {
-		return err
+		return nil, err
 	}
-	for _, t := range tables {
-		if t == table {
-			return nil
+	defer rows.Close()
+	var schemas []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
 		}
+		schemas = append(schemas, name)
 	}
-	return os.ErrNotExist
+	return schemas, rows.Err()
 }
 
-func dedup(ctx context.Context, db *sql.DB, table string) error {
-	dedup := fmt.Sprintf("CREATE OR REPLACE TABLE %s AS SELECT DISTINCT * FROM %s", table, table)
-	if _, err := db.ExecContext(ctx, dedup); err != nil {
+func tableExists(ctx context.Context, db querier, table string) error {
+	schema, name := splitTable(table)
+	var n int
+	err := db.QueryRowContext(ctx, `SELECT count(*) FROM (
+SELECT schema_name, table_name AS name FROM duckdb_tables() WHERE database_name = current_database()
+UNION ALL
+SELECT schema_name, view_name FROM duckdb_views() WHERE database_name = current_database() AND NOT internal
+) WHERE schema_name = coalesce(nullif(?, ''), current_schema()) AND name = ?;`, schema, name).Scan(&n)
+	if err != nil {
 		return err
 	}
+	if n == 0 {
+		return os.ErrNotExist
+	}
 	return nil
 }
 
-func insert(ctx context.Context, db *sql.DB, table string, r io.Reader) error {
-	f, err := os.CreateTemp("", "insert")
+// splitTable splits a table name qualified with its schema, such as
+// raw.events. schema is empty for an unqualified name.
+func splitTable(table string) (schema, name string) {
+	if i := strings.IndexByte(table, '.'); i >= 0 {
+		return table[:i], table[i+1:]
+	}
+	return "", table
+}
+
+// createSchema creates the schema of a qualified table name if needed.
+func createSchema(ctx context.Context, db querier, table string) error {
+	schema, _ := splitTable(table)
+	if schema == "" {
+		return nil
+	}
+	_, err := db.ExecContext(ctx, fmt.Sprintf("CREATE SCHEMA IF NOT EXISTS %s;", quoteIdent(schema)))
+	return err
+}
+
+// dedup rewrites table without duplicate rows, in orderBy order when
+// given, and returns how many rows were removed.
+func dedup(ctx context.Context, db querier, table string, orderBy []string) (int64, error) {
+	return rewrite(ctx, db, table, "DISTINCT *", orderBy)
+}
+
+// dedupTx runs dedup in a transaction, so concurrent readers see either
+// the old or the new table and a failure leaves the old one in place.
+func dedupTx(ctx context.Context, db *sql.DB, table string, orderBy []string) (int64, error) {
+	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
-		return err
+		return 0, err
+	}
+	defer tx.Rollback()
+	removed, err := dedup(ctx, tx, table, orderBy)
+	if err != nil {
+		return 0, err
+	}
+	return removed, tx.Commit()
+}
+
+func hasKeys(ctx context.Context, db querier, table string) (bool, error) {
+	schema, name := splitTable(table)
+	var keys int
+	err := db.QueryRowContext(ctx,
+		"SELECT count(*) FROM duckdb_constraints() WHERE database_name = current_database() AND schema_name = coalesce(nullif(?, ''), current_schema()) AND table_name = ? AND constraint_type IN ('PRIMARY KEY', 'UNIQUE');",
+		schema, name,
+	).Scan(&keys)
+	return keys > 0, err
+}
+
+// rewrite replaces table with SELECT selection FROM table, ordered by
+// orderBy, and returns how many rows fewer it holds afterwards.
+func rewrite(ctx context.Context, db querier, table, selection string, orderBy []string) (int64, error) {
+	// Rows of a table with a primary key or unique constraint are distinct
+	// already, and rewriting it would drop the constraint.
+	keyed, err := hasKeys(ctx, db, table)
+	if err != nil {
+		return 0, err
+	}
+	if keyed && len(orderBy) > 0 {
+		return 0, fmt.Errorf("cannot reorder %s: rewriting it would drop its key constraints", table)
+	}
+	if keyed {
+		return 0, nil
 	}
-	defer os.Remove(f.Name())
-	if _, err := io.Copy(f, r); err != nil {
+	order, err := orderClause(ctx, db, table, orderBy)
+	if err != nil {
+		return 0, err
+	}
+	var before, after int64
+	if err := db.QueryRowContext(ctx, fmt.Sprintf("SELECT count(*) FROM %s;", table)).Scan(&before); err != nil {
+		return 0, err
+	}
+	stmt := fmt.Sprintf("CREATE OR REPLACE TABLE %s AS SELECT %s FROM %s%s", table, selection, table, order)
+	if _, err := db.ExecContext(ctx, stmt); err != nil {
+		return 0, err
+	}
+	if err := applyComments(ctx, db, table); err != nil {
+		return 0, err
+	}
+	if err := recordSchema(ctx, db, table, "rewrite"); err != nil {
+		return 0, err
+	}
+	if err := db.QueryRowContext(ctx, fmt.Sprintf("SELECT count(*) FROM %s;", table)).Scan(&after); err != nil {
+		return 0, err
+	}
+	return before - after, nil
+}
+
+// querier is satisfied by *sql.DB, *sql.Conn and *sql.Tx so helpers can run
+// inside or outside a transaction.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// insert stages r and loads it into table, creating the table from the
+// staged data when it does not exist.
+func insert(ctx context.Context, db querier, staging, table string, r io.Reader, cfg insertConfig) (InsertResult, error) {
+	src, err := decompress(r)
+	if err != nil {
+		return InsertResult{}, err
+	}
+	defer src.Close()
+	name, _, err := stage(staging, src, 0)
+	if err != nil {
+		return InsertResult{}, err
+	}
+	defer os.Remove(name)
+	return insertFile(ctx, db, staging, table, name, cfg)
+}
+
+// insertFile is insert for input already in the file name, or the files
+// matching it as a glob pattern, which it only reads.
+func insertFile(ctx context.Context, db querier, staging, table, name string, cfg insertConfig) (InsertResult, error) {
+	files, err := inputFiles(name)
+	if err != nil {
+		return InsertResult{}, err
+	}
+	var size int64
+	for _, file := range files {
+		n, err := fileSize(file)
+		if err != nil {
+			return InsertResult{}, err
+		}
+		size += n
+	}
+	if cfg.precheck != nil {
+		if err := cfg.precheck(size); err != nil {
+			return InsertResult{}, err
+		}
+	}
+	table += cfg.suffix
+	var check *schemaCheck
+	if cfg.jsonSchema != nil {
+		if name != files[0] {
+			return InsertResult{}, fmt.Errorf("JSON Schema validation needs a single input file, got %s", name)
+		}
+		if check, err = validateStaged(staging, name, cfg); err != nil {
+			return InsertResult{}, err
+		}
+		defer check.remove()
+		if check.violations > 0 && cfg.onViolation == ViolationsFail {
+			return InsertResult{}, check.err(table)
+		}
+		name = check.valid
+	}
+	run := func(db querier) (InsertResult, error) {
+		res := InsertResult{Table: table}
+		if check == nil || check.docs > 0 {
+			if res, err = load(ctx, db, table, name, cfg); err != nil {
+				return res, err
+			}
+		}
+		res.Bytes = size
+		if check != nil {
+			res.Violations, res.ViolationSamples = check.violations, check.samples
+			if check.violations > 0 && cfg.onViolation == ViolationsReject {
+				_, err = load(ctx, db, table+rejectsSuffix, check.rejects, insertConfig{})
+			}
+		}
+		return res, err
+	}
+	tx, shared := db.(*txn)
+	if !shared {
+		sqlDB, ok := db.(*sql.DB)
+		if !ok {
+			return run(db)
+		}
+		if tx, err = begin(ctx, sqlDB); err != nil {
+			return InsertResult{}, err
+		}
+		defer tx.rollback()
+	}
+	if cfg.journal != nil {
+		// A glob is journaled file by file; loading the files one at a
+		// time adds the same rows.
+		if check != nil {
+			files = []string{name}
+		}
+		for _, file := range files {
+			undo, err := cfg.journal(ctx, tx.Tx, table, file)
+			if err != nil {
+				return InsertResult{}, err
+			}
+			tx.undos = append(tx.undos, undo)
+		}
+	}
+	res, err := run(tx.Tx)
+	if err == nil && !shared {
+		err = tx.commit()
+	}
+	if err != nil {
+		return InsertResult{}, err
+	}
+	return res, nil
+}
+
+// txn is a transaction loading inputs, holding the undos that remove their
+// journal entries should it not commit.
+type txn struct {
+	*sql.Tx
+	undos []func()
+}
+
+func begin(ctx context.Context, db *sql.DB) (*txn, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &txn{Tx: tx}, nil
+}
+
+func (t *txn) commit() error {
+	if err := t.Commit(); err != nil {
 		return err
 	}
+	t.undos = nil
+	return nil
+}
+
+// rollback rolls back a transaction that did not commit.
+func (t *txn) rollback() {
+	t.Rollback()
+	for _, undo := range t.undos {
+		undo()
+	}
+	t.undos = nil
+}
+
+// emptyJSON reports JSON input without any document, which DuckDB reads as
+// a single json column rather than failing. Inserting it creates no table.
+func emptyJSON(ctx context.Context, db querier, source string, cfg insertConfig) (bool, error) {
+	if cfg.format != FormatJSON || cfg.columns != nil {
+		return false, nil
+	}
+	columns, err := describe(ctx, db, "SELECT * FROM "+source)
+	if err != nil || len(columns) != 1 || columns[0].Name != "json" || columns[0].Type != "JSON" {
+		return false, err
+	}
+	var n int64
+	err = db.QueryRowContext(ctx, fmt.Sprintf("SELECT count(*) FROM %s;", source)).Scan(&n)
+	return n == 0, err
+}
+
+func load(ctx context.Context, db querier, table, name string, cfg insertConfig) (InsertResult, error) {
+	result := InsertResult{Table: table}
+	if cfg.columns != nil && cfg.format == FormatParquet {
+		return result, fmt.Errorf("%s: parquet input has its own column types", table)
+	}
+	source := cfg.source(name)
+	if cfg.parsesTimestamps() {
+		var err error
+		if source, err = parseTimestamps(ctx, db, source, cfg); err != nil {
+			return result, err
+		}
+	}
+	if cfg.projects() {
+		var err error
+		if source, err = project(ctx, db, source, cfg); err != nil {
+			return result, err
+		}
+	}
+	if cfg.sample > 0 {
+		source = fmt.Sprintf("(SELECT * FROM %s USING SAMPLE %g PERCENT (bernoulli))", source, cfg.sample*100)
+	}
+	if cfg.limit > 0 {
+		if err := db.QueryRowContext(ctx, fmt.Sprintf("SELECT count(*) > %d FROM (SELECT 1 FROM %s LIMIT %d);", cfg.limit, source, cfg.limit+1)).Scan(&result.Truncated); err != nil {
+			return result, err
+		}
+		source = fmt.Sprintf("(SELECT * FROM %s LIMIT %d)", source, cfg.limit)
+	}
+	if cfg.keys != nil {
+		if cfg.dedup {
+			return result, fmt.Errorf("%s: an upsert cannot also skip duplicates", table)
+		}
+		if err := checkKeys(ctx, db, source, cfg.keys); err != nil {
+			return result, err
+		}
+		source = lastByKeys(source, cfg.keys)
+	}
 	if err := tableExists(ctx, db, table); os.IsNotExist(err) {
-		stmt := fmt.Sprintf("CREATE TABLE %s AS SELECT * FROM read_json_auto('%s');", table, f.Name())
+		if empty, err := emptyJSON(ctx, db, source, cfg); err != nil || empty {
+			return result, err
+		}
+		if err := createSchema(ctx, db, table); err != nil {
+			return result, err
+		}
+		selection := "*"
+		if cfg.dedup {
+			if err := db.QueryRowContext(ctx, fmt.Sprintf("SELECT count(*) FROM %s;", source)).Scan(&result.Skipped); err != nil {
+				return result, csvError(err)
+			}
+			selection = "DISTINCT *"
+		}
+		stmt := fmt.Sprintf("CREATE TABLE %s AS SELECT %s FROM %s;", table, selection, source)
 		if _, err := db.ExecContext(ctx, stmt); err != nil {
-			return err
+			return result, csvError(err)
+		}
+		if err := recordSchema(ctx, db, table, "create"); err != nil {
+			return result, err
+		}
+		// DuckDB does not report affected rows for CREATE TABLE AS.
+		if err := db.QueryRowContext(ctx, fmt.Sprintf("SELECT count(*) FROM %s;", table)).Scan(&result.Rows); err != nil {
+			return result, err
 		}
+		if cfg.dedup {
+			result.Skipped -= result.Rows
+		}
+		result.Created = true
+		result.Columns, err = describe(ctx, db, table)
+		return result, err
 	} else if err != nil {
-		return err
-	} else {
-		stmt := fmt.Sprintf("COPY %s FROM '%s' (FORMAT json);", table, f.Name())
-		if _, err := db.ExecContext(ctx, stmt); err != nil {
-			return err
+		return result, err
+	}
+	if cfg.strictSchema {
+		if cfg.evolve {
+			return result, fmt.Errorf("%s: a strict schema cannot also evolve", table)
+		}
+		if err := checkSchema(ctx, db, table, source); err != nil {
+			return result, err
 		}
 	}
-	return nil
+	if cfg.strictTypes {
+		if err := checkStrictTypes(ctx, db, table, source); err != nil {
+			return result, err
+		}
+	}
+	if cfg.evolve {
+		if err := evolve(ctx, db, table, source); err != nil {
+			return result, err
+		}
+	}
+	if err := checkEnums(ctx, db, table, source); err != nil {
+		return result, err
+	}
+	if cfg.format == FormatParquet {
+		if err := checkParquetColumns(ctx, db, table, source); err != nil {
+			return result, err
+		}
+	}
+	if cfg.keys != nil || cfg.dedup {
+		var err error
+		if cfg.dedup {
+			result.Rows, result.Skipped, err = insertNew(ctx, db, table, source)
+		} else {
+			result.Rows, result.Replaced, err = upsert(ctx, db, table, source, cfg.keys)
+		}
+		if err != nil {
+			return result, err
+		}
+		result.Columns, err = describe(ctx, db, table)
+		return result, err
+	}
+	stmt := fmt.Sprintf("COPY %s FROM %s (%s);", table, quoteLiteral(name), cfg.copyOptions())
+	if !cfg.copies() {
+		stmt = fmt.Sprintf("INSERT INTO %s BY NAME SELECT * FROM %s;", table, source)
+	}
+	res, err := db.ExecContext(ctx, stmt)
+	if err != nil {
+		return result, csvError(err)
+	}
+	if result.Rows, err = res.RowsAffected(); err != nil {
+		return result, err
+	}
+	result.Columns, err = describe(ctx, db, table)
+	return result, err
 }
 
 type Client struct {
-	mux         sync.Mutex
+	mux         sync.RWMutex
+	lockedAt    time.Time
+	openedAt    time.Time
+	closing     atomic.Bool
+	closed      bool
+	ops         context.Context
+	cancelOps   context.CancelFunc
 	dir, prefix string
 	n           int
 
+	options     []ClientOption
+	logger      *slog.Logger
+	stagingDir  string
+	autoCompact float64
+	mounts      map[string]string
+	databases   map[string]*sql.DB
+	diskBudget  int64
+	maxBlobSize int64
+	journal     bool
+	httpfs      bool
+
+	queryTimeout  time.Duration
+	maxResultRows int
+	counters      counters
+	plans         *planCache
+	appenders     atomic.Int64
+
+	snapshotCfg snapshotConfig
+	// snapshotMux serializes everything that writes or prunes snapshot
+	// archives, since Snapshot archives and rotates outside the client lock.
+	// It is always taken before the client lock.
+	snapshotMux sync.Mutex
+
+	beforeClose       func(context.Context) error
+	afterClose        func(context.Context, SnapshotInfo) error
+	closeHookContinue bool
+
+	dedupClose         bool
+	dedupCloseTables   []string
+	dedupCloseContinue bool
+	dedupOpts          map[string][]DedupOption
+
+	cacheMux      sync.Mutex
+	caches        map[string]*external
+	cacheAttached bool
+
+	queriesMux sync.Mutex
+	queries    map[string]string
+
 	connecter *duckdb.Connector
 	conn      driver.Conn
 	db        *sql.DB
 }
 
-type Option func(*sql.Conn) error
+// New opens the client stored in dir, keeping the newest n snapshots. Each
+// option is a ClientOption, such as those returned by the With functions,
+// or a plain function of the Option or ConnOption shape; anything else fails
+// New.
+func New(dir string, n int, options ...any) (*Client, error) {
+	return NewContext(context.Background(), dir, n, options...)
+}
 
-func New(dir string, n int, options ...Option) (*Client, error) {
+// NewContext is New with a context bounding the work of opening the
+// client: running options, attaching databases and replaying the journal.
+func NewContext(ctx context.Context, dir string, n int, options ...any) (*Client, error) {
+	opts, err := clientOptions(options)
+	if err != nil {
+		return nil, err
+	}
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return nil, err
 	}
 	if err := os.MkdirAll(path.Join(dir, "snapshot"), 0755); err != nil {
 		return nil, err
 	}
-	c, err := duckdb.NewConnector(filepath.Join(dir, "database.ddb"), nil)
-	if err != nil {
+	client := &Client{
+		dir:     dir,
+		n:       n,
+		logger:  slog.New(slog.DiscardHandler),
+		options: opts,
+		mounts:  make(map[string]string),
+		caches:  make(map[string]*external),
+
+		maxBlobSize: defaultMaxBlobSize,
+	}
+	client.ops, client.cancelOps = context.WithCancel(context.Background())
+	client.openedAt = time.Now()
+	if err := os.RemoveAll(client.mountDir()); err != nil {
 		return nil, err
 	}
-	client := &Client{
-		dir:       dir,
-		n:         n,
-		connecter: c,
-		db:        sql.OpenDB(c),
+	if err := removeDatabaseFile(client.cacheFile()); err != nil {
+		return nil, err
 	}
-	conn, err := client.db.Conn(context.Background())
-	if err != nil {
+	if err := client.init(ctx); err != nil {
+		client.abandon()
 		return nil, err
 	}
+	return client, nil
+}
+
+func (c *Client) init(ctx context.Context) error {
+	if err := c.open(ctx); err != nil {
+		return err
+	}
+	if c.stagingDir != "" {
+		if err := os.MkdirAll(c.stagingDir, 0755); err != nil {
+			return err
+		}
+	}
+	if err := c.sweepOrphans(time.Now()); err != nil {
+		return err
+	}
+	if c.journal {
+		if _, err := c.RecoverJournal(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// abandon releases a client that failed to open, so the database file is
+// not left locked.
+func (c *Client) abandon() {
+	c.closeDatabases()
+	if c.db != nil {
+		c.db.Close()
+	}
+	if c.connecter != nil {
+		c.connecter.Close()
+	}
+}
+
+func (c *Client) dbPath() string {
+	return filepath.Join(c.dir, "database.ddb")
+}
+
+func (c *Client) open(ctx context.Context) error {
+	connecter, err := duckdb.NewConnector(c.dbPath(), nil)
+	if err != nil {
+		return err
+	}
+	c.connecter = connecter
+	c.db = sql.OpenDB(connecter)
+	conn, err := c.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
 	defer conn.Close()
-	for _, opt := range options {
-		if err := opt(conn); err != nil {
-			return nil, err
+	for _, opt := range c.options {
+		if err := opt.apply(ctx, c, conn); err != nil {
+			return err
 		}
 	}
-	return client, nil
+	for schema, file := range c.mounts {
+		if _, err := conn.ExecContext(ctx, attachMount(file, schema)); err != nil {
+			return err
+		}
+	}
+	if c.cacheAttached {
+		if _, err := conn.ExecContext(ctx, fmt.Sprintf("ATTACH %s AS %s;", quoteLiteral(c.cacheFile()), cacheDatabase)); err != nil {
+			return err
+		}
+	}
+	return c.openDatabases(ctx, conn)
 }
 
-func (c *Client) RollbackSnapshot(ctx context.Context, n int) error {
+func (c *Client) RollbackSnapshot(ctx context.Context, n int, opts ...RestoreOption) (err error) {
 	if n > c.n {
 		return fmt.Errorf("cannot rollback to last %d snapshot (max: %d)", n, c.n)
 	}
-	c.mux.Lock()
-	defer c.mux.Unlock()
-	matches, err := listDir(filepath.Join(c.dir, "snapshot"))
+	cfg := newRestoreConfig(opts)
+	p := cfg.notifier()
+	defer p.wait()
+	if err := c.lock("RollbackSnapshot"); err != nil {
+		return err
+	}
+	defer c.unlock("RollbackSnapshot", &err)
+	return withSetting(ctx, c.db, "threads", cfg.threads(), func() error {
+		return rollback(ctx, c.db, c.stagingDir, filepath.Join(c.dir, "snapshot"), n, p)
+	})
+}
+
+// rollback replaces everything in db with the n-th newest snapshot in dir.
+func rollback(ctx context.Context, db *sql.DB, staging, dir string, n int, p *notifier) error {
+	infos, err := snapshotInfos(dir)
 	if err != nil {
 		return err
 	}
-	if len(matches) == 0 {
+	if len(infos) == 0 {
 		return fmt.Errorf("no snapshot to rollback to.")
 	}
-	tables, err := showTables(ctx, c.db)
+	if n < 1 || n > len(infos) {
+		return fmt.Errorf("%w: cannot rollback to last %d snapshot (have: %d)", ErrSnapshotNotFound, n, len(infos))
+	}
+	return restoreSnapshot(ctx, db, staging, dir, infos[n-1].ID, p)
+}
+
+// restoreSnapshot replaces everything in db with the snapshot id in dir.
+func restoreSnapshot(ctx context.Context, db *sql.DB, staging, dir, id string, p *notifier) error {
+	infos, err := snapshotInfos(dir)
 	if err != nil {
 		return err
 	}
-	tx, err := c.db.BeginTx(ctx, nil)
+	info, err := snapshotByID(infos, id)
+	if err != nil {
+		return err
+	}
+	return restoreArchive(ctx, db, staging, info.Path, p)
+}
+
+// restoreArchive replaces everything in db with the snapshot archive.
+func restoreArchive(ctx context.Context, db *sql.DB, staging, archive string, p *notifier) error {
+	extracted, err := unzip(staging, archive, p)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(extracted)
+	tables, err := showTables(ctx, db)
+	if err != nil {
+		return err
+	}
+	types, err := showTypes(ctx, db)
+	if err != nil {
+		return err
+	}
+	schemas, err := showSchemas(ctx, db)
+	if err != nil {
+		return err
+	}
+	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 	for _, table := range tables {
-		if _, err := tx.QueryContext(ctx, fmt.Sprintf("DROP TABLE %s;", table)); err != nil {
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf("DROP TABLE %s;", table)); err != nil {
 			return err
 		}
 	}
-	if err := tx.Commit(); err != nil {
+	for _, typ := range types {
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf("DROP TYPE %s;", quoteIdent(typ))); err != nil {
+			return err
+		}
+	}
+	// The snapshot creates the schemas again.
+	for _, schema := range schemas {
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf("DROP SCHEMA %s CASCADE;", quoteIdent(schema))); err != nil {
+			return err
+		}
+	}
+	if err := failpoint(fpRollbackDropped); err != nil {
+		return err
+	}
+	if err := importDir(ctx, tx, extracted, p); err != nil {
+		return err
+	}
+	if err := applyComments(ctx, tx, ""); err != nil {
 		return err
 	}
-	sort.Strings(matches)
-	return unzipAndLoad(ctx, c.db, filepath.Join(c.dir, "snapshot", matches[len(matches)-n]))
+	return tx.Commit()
 }
 
-func (c *Client) Insert(ctx context.Context, table string, r io.Reader) error {
-	c.mux.Lock()
-	defer c.mux.Unlock()
-	return insert(ctx, c.db, table, r)
+// Insert loads r into table, creating the table when it does not exist.
+// Input compressed with gzip or zstd is decompressed.
+func (c *Client) Insert(ctx context.Context, table string, r io.Reader, opts ...InsertOption) error {
+	_, err := c.Ingest(ctx, table, r, opts...)
+	return err
 }
 
-func (c *Client) Query(ctx context.Context, stmt string) (*sql.Rows, error) {
-	c.mux.Lock()
-	defer c.mux.Unlock()
-	return c.db.QueryContext(ctx, stmt)
+// InsertCount is Insert returning the number of rows that landed in the
+// table.
+func (c *Client) InsertCount(ctx context.Context, table string, r io.Reader, opts ...InsertOption) (int64, error) {
+	res, err := c.Ingest(ctx, table, r, opts...)
+	return res.Rows, err
 }
 
-func (c *Client) Deduplicate(ctx context.Context, table string) error {
-	c.mux.Lock()
-	defer c.mux.Unlock()
-	return dedup(ctx, c.db, table)
+// Ingest is Insert reporting what was loaded.
+func (c *Client) Ingest(ctx context.Context, table string, r io.Reader, opts ...InsertOption) (InsertResult, error) {
+	return c.ingest(opts, func(cfg insertConfig) (InsertResult, error) {
+		if err := c.checkWritable(table); err != nil {
+			return InsertResult{}, err
+		}
+		return insert(ctx, c.db, c.stagingDir, table, r, cfg)
+	})
 }
 
-func (c *Client) Close(ctx context.Context) error {
-	id := ulid.MustNewDefault(time.Now())
-	f, err := os.Create(filepath.Join(c.dir, "snapshot", id.String()))
+// ingest runs load with the insert configuration of opts under the client
+// lock and counts what it loaded.
+func (c *Client) ingest(opts []InsertOption, load func(insertConfig) (InsertResult, error)) (_ InsertResult, err error) {
+	cfg := c.ingestConfig(opts)
+	if err := cfg.validate(); err != nil {
+		return InsertResult{}, err
+	}
+	if err := c.lock("Insert"); err != nil {
+		return InsertResult{}, err
+	}
+	defer c.unlock("Insert", &err)
+	res, err := load(cfg)
+	if err != nil {
+		return res, err
+	}
+	c.counters.insert(res)
+	return res, nil
+}
+
+// ingestConfig is the insert configuration of opts with the client's disk
+// budget and journal hooks.
+func (c *Client) ingestConfig(opts []InsertOption) insertConfig {
+	cfg := newInsertConfig(opts)
+	if c.diskBudget > 0 {
+		cfg.precheck = c.checkBudget
+	}
+	if c.journal {
+		cfg.journal = c.journalHook(cfg)
+	}
+	return cfg
+}
+
+// Query runs stmt with args bound to its placeholders, positional ? or $1
+// and sql.Named for $name. QueryOptions may be given among args. Cancelling
+// ctx interrupts the statement in DuckDB and releases the client lock.
+//
+// The client lock is released before the rows are read, so writes do not
+// wait for open rows and do not show up in them. Compaction is the
+// exception, as it replaces the database file: while rows are open, Compact
+// fails with ErrResultsOpen and WithAutoCompact skips compacting until a
+// later delete.
+func (c *Client) Query(ctx context.Context, stmt string, args ...any) (_ *sql.Rows, err error) {
+	cfg, args := splitQueryArgs(args)
+	unlock, err := c.lockQuery("Query", cfg)
 	if err != nil {
+		return nil, err
+	}
+	defer unlock("Query", &err)
+	var rows *sql.Rows
+	err = withSetting(ctx, c.db, "memory_limit", cfg.memoryLimit, func() (err error) {
+		rows, err = c.plans.query(c.queryContext(ctx, cfg), c.db, c.limitRows(stmt, cfg), args...)
 		return err
+	})
+	if err != nil {
+		if rows != nil {
+			rows.Close()
+		}
+		return nil, resultError(err)
 	}
-	c.mux.Lock()
-	defer c.mux.Unlock()
-	if err := dumpAndZip(ctx, c.db, f); err != nil {
+	return rows, nil
+}
+
+// QueryRow runs stmt, which is expected to return at most one row, with args
+// bound to its placeholders; a Timeout may be given among them. As with
+// database/sql, errors are deferred until Scan, which returns sql.ErrNoRows
+// when there is no row.
+func (c *Client) QueryRow(ctx context.Context, stmt string, args ...any) *sql.Row {
+	if err := c.rlock("QueryRow"); err != nil {
+		return errRow(err)
+	}
+	cfg, args := splitQueryArgs(args)
+	row := c.db.QueryRowContext(c.queryContext(ctx, cfg), stmt, args...)
+	err := row.Err()
+	c.runlock("QueryRow", &err)
+	return row
+}
+
+// Exec runs stmt, such as DDL or a DELETE, with args for its placeholders.
+func (c *Client) Exec(ctx context.Context, stmt string, args ...any) (_ sql.Result, err error) {
+	if err := c.lock("Exec"); err != nil {
+		return nil, err
+	}
+	defer c.unlock("Exec", &err)
+	return c.db.ExecContext(c.opContext(ctx), stmt, args...)
+}
+
+func (c *Client) Deduplicate(ctx context.Context, table string, opts ...DedupOption) (err error) {
+	cfg := newDedupConfig(opts)
+	if err := c.lock("Deduplicate"); err != nil {
 		return err
 	}
-	if err := f.Close(); err != nil {
+	defer c.unlock("Deduplicate", &err)
+	if err := c.checkWritable(table); err != nil {
 		return err
 	}
-	if err := rotate(filepath.Join(c.dir, "snapshot"), c.n); err != nil {
+	removed, err := dedupTx(ctx, c.db, table, cfg.orderBy)
+	if err != nil {
 		return err
 	}
-	if err := c.db.Close(); err != nil {
+	c.counters.remove(table, removed)
+	return c.maybeCompact(ctx)
+}
+
+func (c *Client) DeleteWhere(ctx context.Context, table, cond string, args ...any) (_ int64, err error) {
+	if err := c.lock("DeleteWhere"); err != nil {
+		return 0, err
+	}
+	defer c.unlock("DeleteWhere", &err)
+	if err := c.checkWritable(table); err != nil {
+		return 0, err
+	}
+	res, err := c.db.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE %s;", table, cond), args...)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	c.counters.remove(table, n)
+	return n, c.maybeCompact(ctx)
+}
+
+// snapshot writes a new snapshot of db into dir and keeps the newest n.
+func snapshot(ctx context.Context, db *sql.DB, staging, dir string, n int, cfg snapshotConfig) (SnapshotInfo, error) {
+	id := ulid.MustNewDefault(time.Now())
+	export, err := exportDump(ctx, db, staging, cfg)
+	if err != nil {
+		return SnapshotInfo{}, err
+	}
+	defer os.RemoveAll(export)
+	return archiveSnapshot(id, export, staging, dir, n, cfg)
+}
+
+// archiveSnapshot archives the export as snapshot id in dir and keeps the
+// newest n.
+func archiveSnapshot(id ulid.ULID, export, staging, dir string, n int, cfg snapshotConfig) (SnapshotInfo, error) {
+	name := filepath.Join(dir, id.String())
+	f, err := os.Create(name + ".tmp")
+	if err != nil {
+		return SnapshotInfo{}, err
+	}
+	// Only complete archives get a snapshot name, so a failed snapshot never
+	// shadows the previous one.
+	defer os.Remove(name + ".tmp")
+	if err := zipDump(f, export, staging, cfg); err != nil {
+		f.Close()
+		return SnapshotInfo{}, err
+	}
+	stat, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return SnapshotInfo{}, err
+	}
+	if err := f.Close(); err != nil {
+		return SnapshotInfo{}, err
+	}
+	if err := failpoint(fpSnapshotWritten); err != nil {
+		return SnapshotInfo{}, err
+	}
+	if err := os.Rename(name+".tmp", name); err != nil {
+		return SnapshotInfo{}, err
+	}
+	return SnapshotInfo{ID: id.String(), CreatedAt: id.Timestamp(), Size: stat.Size(), Path: name}, rotate(dir, n, cfg.maxBytes)
+}
+
+// Close stops admitting operations, which then fail with ErrClosed, and
+// waits for the ones in flight, including result sets and readers not yet
+// closed, until ctx is done; a ctx that is never done gives them no grace.
+// Those still running are canceled. Close then takes the final snapshot
+// regardless of ctx, since giving up on it would lose the data written since
+// the previous one. With WithDedupOnClose, tables are deduplicated ahead
+// of the final snapshot within ctx.
+func (c *Client) Close(ctx context.Context) error {
+	if c.beforeClose != nil {
+		if err := c.beforeClose(ctx); err != nil {
+			if !c.closeHookContinue {
+				return err
+			}
+			c.logger.Warn("close hook failed", "err", err)
+		}
+	}
+	c.stopCaches()
+	c.closing.Store(true)
+	if n := c.drain(ctx); n > 0 {
+		c.logger.Warn("canceled in-flight operations", "count", n)
+	}
+	info, err := c.close(ctx)
+	if err != nil {
 		return err
 	}
-	return c.connecter.Close()
+	if c.afterClose != nil {
+		return c.afterClose(ctx, info)
+	}
+	return nil
+}
+
+func (c *Client) close(ctx context.Context) (SnapshotInfo, error) {
+	c.snapshotMux.Lock()
+	defer c.snapshotMux.Unlock()
+	c.acquire()
+	defer c.release()
+	if c.closed {
+		return SnapshotInfo{}, ErrClosed
+	}
+	defer func() {
+		// A Close failing or panicking before the final snapshot is taken
+		// leaves the client usable.
+		if !c.closed {
+			c.reopen()
+		}
+	}()
+	if err := c.dedupOnClose(ctx); err != nil {
+		if !c.dedupCloseContinue {
+			return SnapshotInfo{}, err
+		}
+		c.logger.Warn("deduplicate on close failed", "err", err)
+	}
+	info, err := c.finalSnapshots(context.WithoutCancel(ctx))
+	if err != nil {
+		return info, err
+	}
+	c.closed = true
+	if err := c.closeDatabases(); err != nil {
+		return info, err
+	}
+	c.plans.reset()
+	if err := c.db.Close(); err != nil {
+		return info, err
+	}
+	if err := c.connecter.Close(); err != nil {
+		return info, err
+	}
+	return info, os.RemoveAll(c.mountDir())
+}
+
+// finalSnapshots snapshots the client and its databases on Close.
+func (c *Client) finalSnapshots(ctx context.Context) (SnapshotInfo, error) {
+	if err := c.makeRoomForSnapshot(ctx); err != nil {
+		return SnapshotInfo{}, err
+	}
+	info, err := snapshot(ctx, c.db, c.stagingDir, filepath.Join(c.dir, "snapshot"), c.n, c.snapshotCfg)
+	if err != nil {
+		return SnapshotInfo{}, err
+	}
+	if c.journal {
+		if err := c.truncateJournal(ctx); err != nil {
+			return info, err
+		}
+	}
+	for name, db := range c.databases {
+		if _, err := snapshot(ctx, db, c.stagingDir, c.databaseSnapshotDir(name), c.n, c.snapshotCfg); err != nil {
+			return info, err
+		}
+	}
+	return info, nil
 }
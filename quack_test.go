@@ -2,10 +2,20 @@ package quack
 
 import (
 	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
@@ -84,6 +94,400 @@ func Test_Client(t *testing.T) {
 		}
 		require.NoError(t, rows.Err())
 		require.NoError(t, rows.Close())
-		require.Equal(t, 3, count)
+		// The newest snapshot, from before the dedup.
+		require.Equal(t, 4, count)
 	})
 }
+
+func Test_DeduplicateConcurrentReads(t *testing.T) {
+	client, err := New(t.TempDir(), 3)
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+	var b strings.Builder
+	for i := range 2000 {
+		fmt.Fprintf(&b, "{\"id\":%d}\n", i%1000)
+	}
+	require.NoError(t, client.Insert(t.Context(), "events", strings.NewReader(b.String())))
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				rows, err := client.Query(t.Context(), "SELECT id FROM events;")
+				if !assert.NoError(t, err) {
+					return
+				}
+				n := 0
+				for rows.Next() {
+					n++
+				}
+				assert.NoError(t, rows.Err())
+				assert.NoError(t, rows.Close())
+				assert.Contains(t, []int{1000, 2000}, n)
+			}
+		}()
+	}
+	for range 5 {
+		require.NoError(t, client.Deduplicate(t.Context(), "events"))
+		require.NoError(t, client.Insert(t.Context(), "events", strings.NewReader(b.String()[:len(b.String())/2])))
+		require.NoError(t, client.Deduplicate(t.Context(), "events"))
+	}
+	close(done)
+	wg.Wait()
+}
+
+func Test_NewContext(t *testing.T) {
+	dir := t.TempDir()
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	_, err := NewContext(ctx, dir, 3)
+	require.ErrorIs(t, err, context.Canceled)
+
+	type key struct{}
+	ctx = context.WithValue(t.Context(), key{}, "value")
+	var got any
+	client, err := NewContext(ctx, dir, 3, ConnOption(func(ctx context.Context, conn *sql.Conn) error {
+		got = ctx.Value(key{})
+		_, err := conn.ExecContext(ctx, "SET threads = 2;")
+		return err
+	}))
+	require.NoError(t, err, "a failed open releases the database")
+	defer client.Close(t.Context())
+	require.Equal(t, "value", got)
+	require.Equal(t, 2, threads(t, client.db))
+}
+
+func Test_NewOptionShapes(t *testing.T) {
+	var ran []string
+	client, err := New(t.TempDir(), 1,
+		func(conn *sql.Conn) error {
+			ran = append(ran, "plain")
+			return nil
+		},
+		func(ctx context.Context, conn *sql.Conn) error {
+			ran = append(ran, "context")
+			return nil
+		},
+		Option(func(conn *sql.Conn) error {
+			ran = append(ran, "option")
+			return nil
+		}),
+		WithQueryTimeout(time.Second),
+	)
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+	require.Equal(t, []string{"plain", "context", "option"}, ran)
+	require.Equal(t, time.Second, client.queryTimeout)
+
+	_, err = New(t.TempDir(), 1, "threads=2")
+	require.ErrorContains(t, err, "option 0: string is not a ClientOption")
+}
+
+func Test_CloseHooks(t *testing.T) {
+	dir := t.TempDir()
+	errHook := errors.New("not yet")
+	var (
+		fail  = true
+		rows  int
+		infos []SnapshotInfo
+	)
+	before := func(ctx context.Context) error {
+		if fail {
+			return errHook
+		}
+		return nil
+	}
+	var client *Client
+	after := func(ctx context.Context, info SnapshotInfo) error {
+		infos = append(infos, info)
+		return nil
+	}
+	client, err := New(dir, 3, WithCloseHooks(func(ctx context.Context) error {
+		r, err := client.Query(ctx, "SELECT * FROM events;")
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		for r.Next() {
+			rows++
+		}
+		return before(ctx)
+	}, after))
+	require.NoError(t, err)
+	require.NoError(t, client.Insert(t.Context(), "events", strings.NewReader(`{"id":1}`)))
+
+	require.ErrorIs(t, client.Close(t.Context()), errHook)
+	require.Empty(t, infos)
+	expectSnapshots(t, dir, 0)
+	require.NoError(t, client.Insert(t.Context(), "events", strings.NewReader(`{"id":2}`)), "an aborted Close leaves the client usable")
+
+	fail = false
+	require.NoError(t, client.Close(t.Context()))
+	require.Equal(t, 1+2, rows)
+	require.Len(t, infos, 1)
+	listed, err := client.ListSnapshots()
+	require.NoError(t, err)
+	require.Equal(t, listed, infos)
+	_, err = os.Stat(infos[0].Path)
+	require.NoError(t, err)
+
+	t.Run("continue on error", func(t *testing.T) {
+		client, err := New(t.TempDir(), 3,
+			WithCloseHooks(func(context.Context) error { return errHook }, nil),
+			ContinueCloseOnHookError())
+		require.NoError(t, err)
+		require.NoError(t, client.Close(t.Context()))
+	})
+}
+
+func Test_SchemaQualifiedTables(t *testing.T) {
+	dir := t.TempDir()
+	client, err := New(dir, 2)
+	require.NoError(t, err)
+	data := `{"id":1}` + "\n" + `{"id":1}`
+	for _, table := range []string{"raw.events", "raw.events", "mart.events"} {
+		require.NoError(t, client.Insert(t.Context(), table, strings.NewReader(data)))
+	}
+	require.NoError(t, client.Insert(t.Context(), "events", strings.NewReader(`{"id":2}`)))
+	tables, err := showTables(t.Context(), client.db)
+	require.NoError(t, err)
+	require.Equal(t, []string{"events", "mart.events", "quack_schema_history", "raw.events"}, tables)
+	require.NoError(t, tableExists(t.Context(), client.db, "raw.events"))
+	require.ErrorIs(t, tableExists(t.Context(), client.db, "raw.missing"), os.ErrNotExist)
+	require.NoError(t, client.Deduplicate(t.Context(), "raw.events"))
+	count := func(c *Client, table string) int {
+		rows, err := c.Query(t.Context(), "SELECT count(*) FROM "+table+";")
+		require.NoError(t, err)
+		defer rows.Close()
+		require.True(t, rows.Next())
+		var n int
+		require.NoError(t, rows.Scan(&n))
+		return n
+	}
+	require.Equal(t, 1, count(client, "raw.events"))
+	require.Equal(t, 2, count(client, "mart.events"))
+	require.NoError(t, client.Close(t.Context()))
+
+	client, err = New(dir, 2)
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+	require.NoError(t, client.Insert(t.Context(), "raw.events", strings.NewReader(data)))
+	require.NoError(t, client.RollbackSnapshot(t.Context(), 1))
+	require.Equal(t, 1, count(client, "raw.events"))
+	require.Equal(t, 2, count(client, "mart.events"))
+	require.Equal(t, 1, count(client, "events"))
+}
+
+func Test_Exec(t *testing.T) {
+	dir := t.TempDir()
+	client, err := New(dir, 2)
+	require.NoError(t, err)
+	require.NoError(t, client.Insert(t.Context(), "events", strings.NewReader("{\"id\":1}\n{\"id\":2}\n{\"id\":3}\n")))
+	res, err := client.Exec(t.Context(), "DELETE FROM events WHERE id >= ?;", 2)
+	require.NoError(t, err)
+	n, err := res.RowsAffected()
+	require.NoError(t, err)
+	require.Equal(t, int64(2), n)
+	_, err = client.Exec(t.Context(), "CREATE VIEW recent AS SELECT * FROM events;")
+	require.NoError(t, err)
+	tables, err := showTables(t.Context(), client.db)
+	require.NoError(t, err)
+	require.Contains(t, tables, "recent")
+	_, err = client.Exec(t.Context(), "DROP VIEW recent;")
+	require.NoError(t, err)
+	_, err = client.Exec(t.Context(), "DROP TABLE missing;")
+	require.Error(t, err)
+	require.NoError(t, client.Close(t.Context()))
+
+	client, err = New(dir, 2)
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+	require.NoError(t, client.Insert(t.Context(), "events", strings.NewReader(`{"id":4}`)))
+	require.NoError(t, client.RollbackSnapshot(t.Context(), 1))
+	rows, err := client.Query(t.Context(), "SELECT count(*) FROM events;")
+	require.NoError(t, err)
+	defer rows.Close()
+	require.True(t, rows.Next())
+	require.NoError(t, rows.Scan(&n))
+	require.Equal(t, int64(1), n)
+}
+
+func Test_SharedReadLock(t *testing.T) {
+	client, err := New(t.TempDir(), 1)
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+	require.NoError(t, client.Insert(t.Context(), "events", strings.NewReader("{\"id\":1}\n{\"id\":2}\n{\"id\":3}\n")))
+	require.NoError(t, client.PutBlob(t.Context(), "files", "a", strings.NewReader("blob"), nil))
+	require.NoError(t, client.PutDoc(t.Context(), "docs", "a", map[string]int{"n": 1}))
+
+	t.Run("reads run together", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(t.Context(), 2*time.Second)
+		defer cancel()
+		slow := make(chan error, 1)
+		go func() {
+			_, err := client.Query(ctx, "SELECT count(*) FROM range(100000000) a, range(100000000) b;")
+			slow <- err
+		}()
+		time.Sleep(50 * time.Millisecond)
+		start := time.Now()
+		var n int
+		require.NoError(t, client.QueryRow(t.Context(), "SELECT count(*) FROM events;").Scan(&n))
+		require.Equal(t, 3, n)
+		_, err := client.Describe(t.Context(), "events")
+		require.NoError(t, err)
+		_, err = client.Stats(t.Context())
+		require.NoError(t, err)
+		_, err = client.SchemaHistory(t.Context(), "events")
+		require.NoError(t, err)
+		_, err = client.GetComments(t.Context(), "events")
+		require.NoError(t, err)
+		_, err = client.ListSaved(t.Context())
+		require.NoError(t, err)
+		blob, err := client.GetBlob(t.Context(), "files", "a")
+		require.NoError(t, err)
+		b, err := io.ReadAll(blob)
+		require.NoError(t, err)
+		require.Equal(t, "blob", string(b))
+		_, err = client.ListBlobs(t.Context(), "files", "")
+		require.NoError(t, err)
+		var doc map[string]int
+		require.NoError(t, client.GetDoc(t.Context(), "docs", "a", &doc))
+		_, err = client.QueryDocs(t.Context(), "docs", "")
+		require.NoError(t, err)
+		_, err = InferSchema(t.Context(), client, strings.NewReader(`{"id":1}`), FormatJSON, 0)
+		require.NoError(t, err)
+		require.NoError(t, ValidateSchema[struct{ ID *int64 }](t.Context(), client, "events"))
+		require.Less(t, time.Since(start), time.Second)
+		cancel()
+		require.ErrorIs(t, <-slow, context.Canceled)
+	})
+	t.Run("writes while iterating", func(t *testing.T) {
+		// Rows keep reading the state the query started from; writes made
+		// meanwhile neither wait for them nor show up in them.
+		var ids []int64
+		for row, err := range client.Rows(t.Context(), "SELECT id FROM events ORDER BY id;") {
+			require.NoError(t, err)
+			var id int64
+			require.NoError(t, row.Scan(&id))
+			ids = append(ids, id)
+			if len(ids) == 1 {
+				require.NoError(t, client.Insert(t.Context(), "events", strings.NewReader("{\"id\":1}\n{\"id\":4}\n")))
+				require.NoError(t, client.Deduplicate(t.Context(), "events"))
+			}
+		}
+		require.Equal(t, []int64{1, 2, 3}, ids)
+		n, err := client.Count(t.Context(), "events")
+		require.NoError(t, err)
+		require.Equal(t, int64(4), n)
+	})
+	t.Run("compaction while iterating", func(t *testing.T) {
+		client, err := New(t.TempDir(), 1, WithAutoCompact(0.01))
+		require.NoError(t, err)
+		defer client.Close(t.Context())
+		require.NoError(t, client.Insert(t.Context(), "events", strings.NewReader("{\"id\":1}\n{\"id\":2}\n{\"id\":3}\n")))
+		rows, err := client.Query(t.Context(), "SELECT id FROM events ORDER BY id;")
+		require.NoError(t, err)
+		require.True(t, rows.Next())
+		n, err := client.DeleteWhere(t.Context(), "events", "id > 1")
+		require.NoError(t, err)
+		require.Equal(t, int64(2), n)
+		require.ErrorIs(t, client.Compact(t.Context()), ErrResultsOpen)
+		var ids []int64
+		for ok := true; ok; ok = rows.Next() {
+			var id int64
+			require.NoError(t, rows.Scan(&id))
+			ids = append(ids, id)
+		}
+		require.NoError(t, rows.Close())
+		require.Equal(t, []int64{1, 2, 3}, ids)
+		require.NoError(t, client.Compact(t.Context()))
+		n, err = client.Count(t.Context(), "events")
+		require.NoError(t, err)
+		require.Equal(t, int64(1), n)
+	})
+}
+
+func Test_RotateKeepsNewest(t *testing.T) {
+	const n = 3
+	dir := t.TempDir()
+	client, err := New(dir, n)
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+	var ids []string
+	for range n + 3 {
+		require.NoError(t, client.Insert(t.Context(), "events", strings.NewReader(`{"id":1}`)))
+		id, err := client.Snapshot(t.Context())
+		require.NoError(t, err)
+		ids = append(ids, id)
+		// Distinct timestamps, so the order does not rest on ULID entropy.
+		time.Sleep(2 * time.Millisecond)
+	}
+	infos, err := client.ListSnapshots()
+	require.NoError(t, err)
+	require.Len(t, infos, n)
+	for i, info := range infos {
+		require.Equal(t, ids[len(ids)-1-i], info.ID)
+	}
+
+	count := func() int {
+		var c int
+		require.NoError(t, client.db.QueryRow("SELECT count(*) FROM events;").Scan(&c))
+		return c
+	}
+	require.NoError(t, client.RollbackSnapshot(t.Context(), 1))
+	require.Equal(t, n+3, count())
+	require.NoError(t, client.RollbackSnapshot(t.Context(), n))
+	require.Equal(t, 4, count())
+}
+
+func Test_RotateMaxBytes(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Now()
+	var ids []string
+	for i, size := range []int64{100, 200, 300, 400} {
+		id := ulid.MustNewDefault(start.Add(time.Duration(i) * time.Second)).String()
+		f, err := os.Create(filepath.Join(dir, id))
+		require.NoError(t, err)
+		require.NoError(t, f.Truncate(size))
+		require.NoError(t, f.Close())
+		ids = append(ids, id)
+	}
+	kept := func() []string {
+		infos, err := snapshotInfos(dir)
+		require.NoError(t, err)
+		var names []string
+		for _, info := range infos {
+			names = append(names, info.ID)
+		}
+		return names
+	}
+
+	require.NoError(t, rotate(dir, 10, 1000))
+	require.Equal(t, []string{ids[3], ids[2], ids[1], ids[0]}, kept())
+	require.NoError(t, rotate(dir, 10, 750))
+	require.Equal(t, []string{ids[3], ids[2]}, kept())
+	require.NoError(t, rotate(dir, 10, 10))
+	require.Equal(t, []string{ids[3]}, kept())
+
+	client, err := New(t.TempDir(), 5, WithMaxSnapshotBytes(1))
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+	var newest string
+	for range 3 {
+		require.NoError(t, client.Insert(t.Context(), "events", strings.NewReader(`{"id":1}`)))
+		newest, err = client.Snapshot(t.Context())
+		require.NoError(t, err)
+	}
+	infos, err := client.ListSnapshots()
+	require.NoError(t, err)
+	require.Len(t, infos, 1)
+	require.Equal(t, newest, infos[0].ID)
+}
@@ -0,0 +1,215 @@
+package quack
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+type queryConfig struct {
+	memoryLimit string
+	ndjson      bool
+	strict      bool
+	timeout     time.Duration
+	// maxRows overrides the client's WithMaxResultRows when limitsRows is set.
+	maxRows    int
+	limitsRows bool
+	batch      int
+}
+
+// ErrResultTruncated is returned for a query whose result has more rows
+// than WithMaxResultRows allows.
+var ErrResultTruncated = errors.New("result exceeds the row limit")
+
+// resultLimitError marks the DuckDB error raised for ErrResultTruncated.
+const resultLimitError = "quack: result exceeds "
+
+// QueryOption tunes a single Query call.
+type QueryOption func(*queryConfig)
+
+// WithQueryMemoryLimit caps the memory DuckDB may use while running the
+// statement, e.g. "512MB". A statement exceeding it fails with an out of
+// memory error and the previous limit is restored afterwards. The driver
+// materializes results before Query returns, so the limit covers the whole
+// execution.
+func WithQueryMemoryLimit(limit string) QueryOption {
+	return func(cfg *queryConfig) { cfg.memoryLimit = limit }
+}
+
+// Timeout interrupts the statement once it runs for longer than d, failing
+// it with an error matching context.DeadlineExceeded. It overrides the
+// client's WithQueryTimeout. For methods returning rows, d also bounds
+// reading them.
+func Timeout(d time.Duration) QueryOption {
+	return func(cfg *queryConfig) { cfg.timeout = d }
+}
+
+// ResultRowLimit overrides the client's WithMaxResultRows for one query; n <= 0
+// lifts the limit.
+func ResultRowLimit(n int) QueryOption {
+	return func(cfg *queryConfig) { cfg.maxRows, cfg.limitsRows = n, true }
+}
+
+// BatchRows sets how many rows each query of QueryReader fetches, 100000
+// by default.
+func BatchRows(n int) QueryOption {
+	return func(cfg *queryConfig) { cfg.batch = n }
+}
+
+func (cfg queryConfig) batchRows() int {
+	if cfg.batch <= 0 {
+		return defaultReaderBatch
+	}
+	return cfg.batch
+}
+
+// AsNDJSON makes QueryJSON write one object per line instead of an array.
+func AsNDJSON() QueryOption {
+	return func(cfg *queryConfig) { cfg.ndjson = true }
+}
+
+// StrictColumns makes QueryStructs fail on result columns no field takes.
+func StrictColumns() QueryOption {
+	return func(cfg *queryConfig) { cfg.strict = true }
+}
+
+// splitQueryArgs applies the QueryOptions among args and returns the rest.
+func splitQueryArgs(args []any) (queryConfig, []any) {
+	var cfg queryConfig
+	params := args[:0:0]
+	for _, arg := range args {
+		if opt, ok := arg.(QueryOption); ok {
+			opt(&cfg)
+			continue
+		}
+		params = append(params, arg)
+	}
+	return cfg, params
+}
+
+// withSetting runs fn with the DuckDB setting name changed to value and
+// restores the previous value afterwards, even when ctx is done. Settings
+// such as memory_limit and threads are instance-wide, so callers hold the
+// client lock. DuckDB reports memory sizes rounded to a tenth of their
+// unit, which is what gets restored.
+func withSetting(ctx context.Context, db *sql.DB, name, value string, fn func() error) (err error) {
+	if value == "" {
+		return fn()
+	}
+	var prev string
+	if err := db.QueryRowContext(ctx, "SELECT value FROM duckdb_settings() WHERE name = ?;", name).Scan(&prev); err != nil {
+		return err
+	}
+	if _, err := db.ExecContext(ctx, fmt.Sprintf("SET %s = %s;", name, quoteLiteral(value))); err != nil {
+		return err
+	}
+	defer func() {
+		_, rerr := db.ExecContext(context.WithoutCancel(ctx), fmt.Sprintf("SET %s = %s;", name, quoteLiteral(prev)))
+		if rerr != nil && err == nil {
+			err = fmt.Errorf("restore %s: %w", name, rerr)
+		}
+	}()
+	return fn()
+}
+
+// queryContext is the context of a query run with cfg, bounded by its
+// timeout or the client's default.
+func (c *Client) queryContext(ctx context.Context, cfg queryConfig) context.Context {
+	ctx = c.opContext(ctx)
+	d := cfg.timeout
+	if d == 0 {
+		d = c.queryTimeout
+	}
+	if d <= 0 {
+		return ctx
+	}
+	// The rows may outlive the call, so the context is released by its
+	// deadline rather than by the caller.
+	ctx, cancel := context.WithTimeout(ctx, d)
+	context.AfterFunc(ctx, cancel)
+	return ctx
+}
+
+// limitRows wraps stmt, when it is a plain query, to fail with
+// ErrResultTruncated once its result exceeds the row limit of cfg. At most
+// one row more than the limit is buffered.
+func (c *Client) limitRows(stmt string, cfg queryConfig) string {
+	n := c.resultRows(cfg)
+	stmts := splitStatements(stmt)
+	if n <= 0 || len(stmts) != 1 || !isPlainQuery(stmts[0]) {
+		return stmt
+	}
+	return fmt.Sprintf(`WITH quack_result AS MATERIALIZED (SELECT * FROM (%s) LIMIT %d)
+SELECT * FROM quack_result WHERE CASE WHEN (SELECT count(*) FROM quack_result) > %d THEN error('%s%d rows') ELSE true END;`,
+		stmts[0], n+1, n, resultLimitError, n)
+}
+
+// resultRows is the row limit of a query run with cfg, 0 or less for none.
+func (c *Client) resultRows(cfg queryConfig) int {
+	if cfg.limitsRows {
+		return cfg.maxRows
+	}
+	return c.maxResultRows
+}
+
+// isPlainQuery reports whether stmt only reads, unlike INSERT ... RETURNING.
+func isPlainQuery(stmt string) bool {
+	switch leadingKeyword(stmt) {
+	case "SELECT", "FROM", "VALUES":
+		return true
+	case "WITH":
+		return !hasKeyword(stmt, "INSERT") && !hasKeyword(stmt, "UPDATE") && !hasKeyword(stmt, "DELETE")
+	}
+	return false
+}
+
+// resultError turns the error of a query wrapped by limitRows into
+// ErrResultTruncated.
+func resultError(err error) error {
+	if err != nil && strings.Contains(err.Error(), resultLimitError) {
+		_, limit, _ := strings.Cut(err.Error(), resultLimitError)
+		return fmt.Errorf("%w of %s", ErrResultTruncated, limit)
+	}
+	return err
+}
+
+// streamQuery runs stmt for a result read row by row after the client lock
+// is released, returning the QueryOptions among args with the rows.
+func (c *Client) streamQuery(ctx context.Context, op, stmt string, args []any) (_ *sql.Rows, _ queryConfig, err error) {
+	cfg, args := splitQueryArgs(args)
+	unlock, err := c.lockQuery(op, cfg)
+	if err != nil {
+		return nil, cfg, err
+	}
+	defer unlock(op, &err)
+	var rows *sql.Rows
+	err = withSetting(ctx, c.db, "memory_limit", cfg.memoryLimit, func() (err error) {
+		rows, err = c.db.QueryContext(c.queryContext(ctx, cfg), c.limitRows(stmt, cfg), args...)
+		return err
+	})
+	if err != nil {
+		if rows != nil {
+			rows.Close()
+		}
+		return nil, cfg, resultError(err)
+	}
+	return rows, cfg, nil
+}
+
+// errRow returns a row whose Scan fails with err.
+func errRow(err error) *sql.Row {
+	db := sql.OpenDB(errConnector{err})
+	defer db.Close()
+	return db.QueryRow("")
+}
+
+// errConnector fails every connection with err.
+type errConnector struct{ err error }
+
+func (c errConnector) Connect(context.Context) (driver.Conn, error) { return nil, c.err }
+
+func (c errConnector) Driver() driver.Driver { return nil }
@@ -0,0 +1,185 @@
+package quack
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func memoryLimit(t *testing.T, c *Client) string {
+	t.Helper()
+	rows, err := c.Query(t.Context(), "SELECT current_setting('memory_limit');")
+	require.NoError(t, err)
+	defer rows.Close()
+	require.True(t, rows.Next())
+	var limit string
+	require.NoError(t, rows.Scan(&limit))
+	return limit
+}
+
+func Test_QueryMemoryLimit(t *testing.T) {
+	client, err := New(t.TempDir(), 3, func(conn *sql.Conn) error {
+		_, err := conn.ExecContext(context.Background(), "SET memory_limit = '1GiB';")
+		return err
+	})
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+	before := memoryLimit(t, client)
+	heavy := "SELECT count(*) FROM (SELECT list(range) FROM range(20000000) GROUP BY range % 1000000);"
+
+	t.Run("exceeded", func(t *testing.T) {
+		_, err := client.Query(t.Context(), heavy, WithQueryMemoryLimit("10MB"))
+		require.ErrorContains(t, err, "Out of Memory")
+		require.Equal(t, before, memoryLimit(t, client))
+	})
+	t.Run("within limit", func(t *testing.T) {
+		rows, err := client.Query(t.Context(), "SELECT 42;", WithQueryMemoryLimit("100MB"))
+		require.NoError(t, err)
+		require.NoError(t, rows.Close())
+		require.Equal(t, before, memoryLimit(t, client))
+	})
+	t.Run("canceled", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(t.Context(), 20*time.Millisecond)
+		defer cancel()
+		_, err := client.Query(ctx, "SELECT count(*) FROM range(100000000) a, range(100000000) b;", WithQueryMemoryLimit("500MB"))
+		require.Error(t, err)
+		require.Equal(t, before, memoryLimit(t, client))
+	})
+}
+
+func Test_QueryArgs(t *testing.T) {
+	at := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+	data := `{"id":1,"name":"it's","at":"2024-03-01 12:30:00"}
+{"id":2,"name":null,"at":"2024-03-02 00:00:00"}
+{"id":3,"name":"plain","at":"2024-03-03 00:00:00"}
+`
+	for name, opts := range map[string][]any{"direct": nil, "plan cache": {WithPlanCache(4)}} {
+		t.Run(name, func(t *testing.T) {
+			client, err := New(t.TempDir(), 3, opts...)
+			require.NoError(t, err)
+			defer client.Close(t.Context())
+			require.NoError(t, client.Insert(t.Context(), "events", strings.NewReader(data)))
+			ids := func(stmt string, args ...any) []int {
+				rows, err := client.Query(t.Context(), stmt, args...)
+				require.NoError(t, err)
+				defer rows.Close()
+				var ids []int
+				for rows.Next() {
+					var id int
+					require.NoError(t, rows.Scan(&id))
+					ids = append(ids, id)
+				}
+				require.NoError(t, rows.Err())
+				return ids
+			}
+			require.Equal(t, []int{1}, ids("SELECT id FROM events WHERE name = ?;", "it's"))
+			require.Equal(t, []int{3}, ids("SELECT id FROM events WHERE name = ?;", "plain"))
+			require.Empty(t, ids("SELECT id FROM events WHERE name = ?;", "' OR 1=1 --"))
+			require.Equal(t, []int{2}, ids("SELECT id FROM events WHERE name IS NOT DISTINCT FROM ?;", nil))
+			require.Equal(t, []int{1}, ids("SELECT id FROM events WHERE \"at\" = ?;", at))
+			require.Equal(t, []int{2, 3}, ids("SELECT id FROM events WHERE \"at\" > $since AND id <= $max ORDER BY id;", sql.Named("since", at), sql.Named("max", 3)))
+			require.Equal(t, []int{1, 2, 3}, ids("SELECT id FROM events ORDER BY id;"))
+			require.Equal(t, []int{1}, ids("SELECT id FROM events WHERE id = ?;", 1, WithQueryMemoryLimit("100MB")))
+		})
+	}
+}
+
+func Test_QueryRow(t *testing.T) {
+	client, err := New(t.TempDir(), 3)
+	require.NoError(t, err)
+	require.NoError(t, client.Insert(t.Context(), "events", strings.NewReader("{\"id\":1}\n{\"id\":5}\n")))
+	var n int
+	require.NoError(t, client.QueryRow(t.Context(), "SELECT max(id) FROM events;").Scan(&n))
+	require.Equal(t, 5, n)
+	require.NoError(t, client.QueryRow(t.Context(), "SELECT count(*) FROM events WHERE id > ?;", 1).Scan(&n))
+	require.Equal(t, 1, n)
+	err = client.QueryRow(t.Context(), "SELECT id FROM events WHERE id = ?;", 3).Scan(&n)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+	require.Error(t, client.QueryRow(t.Context(), "SELECT id FROM missing;").Scan(&n))
+	// The lock is not held once QueryRow returns.
+	_, err = client.Exec(t.Context(), "DELETE FROM events;")
+	require.NoError(t, err)
+	require.NoError(t, client.Close(t.Context()))
+	require.ErrorIs(t, client.QueryRow(t.Context(), "SELECT 1;").Scan(&n), ErrClosed)
+}
+
+func Test_QueryTimeout(t *testing.T) {
+	heavy := "SELECT count(*) FROM range(100000000) a, range(100000000) b;"
+	client, err := New(t.TempDir(), 1, WithQueryTimeout(50*time.Millisecond))
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+	start := time.Now()
+	_, err = client.Query(t.Context(), heavy)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Less(t, time.Since(start), 5*time.Second)
+	var n int
+	require.ErrorIs(t, client.QueryRow(t.Context(), heavy).Scan(&n), context.DeadlineExceeded)
+	_, err = client.Query(t.Context(), heavy, Timeout(20*time.Millisecond), WithQueryMemoryLimit("500MB"))
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// A query overriding the default runs past it, and the lock is free
+	// after a timeout.
+	rows, err := client.Query(t.Context(), "SELECT count(*) FROM range(300000000);", Timeout(time.Minute))
+	require.NoError(t, err)
+	require.NoError(t, rows.Close())
+	require.NoError(t, client.Insert(t.Context(), "events", strings.NewReader(`{"id":1}`)))
+}
+
+func Test_MaxResultRows(t *testing.T) {
+	client, err := New(t.TempDir(), 1, WithMaxResultRows(5))
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+	count := func(rows *sql.Rows, err error) int {
+		require.NoError(t, err)
+		defer rows.Close()
+		n := 0
+		for rows.Next() {
+			n++
+		}
+		require.NoError(t, rows.Err())
+		return n
+	}
+	require.Equal(t, 5, count(client.Query(t.Context(), "SELECT range FROM range(?);", 5)))
+	_, err = client.Query(t.Context(), "SELECT * FROM range(1000000000);")
+	require.ErrorIs(t, err, ErrResultTruncated)
+	_, err = client.Query(t.Context(), "WITH r AS (SELECT range FROM range(6)) SELECT * FROM r;")
+	require.ErrorIs(t, err, ErrResultTruncated)
+	require.ErrorIs(t, client.QueryJSON(t.Context(), io.Discard, "FROM range(6);"), ErrResultTruncated)
+	require.Equal(t, 6, count(client.Query(t.Context(), "SELECT range FROM range(6);", ResultRowLimit(10))))
+	require.Equal(t, 100, count(client.Query(t.Context(), "SELECT range FROM range(100);", ResultRowLimit(0))))
+
+	// Statements that write are left alone.
+	_, err = client.Exec(t.Context(), "CREATE TABLE events (id BIGINT);")
+	require.NoError(t, err)
+	require.Equal(t, 8, count(client.Query(t.Context(), "INSERT INTO events SELECT range FROM range(8) RETURNING id;")))
+	require.Equal(t, 1, count(client.Query(t.Context(), "SELECT count(*) FROM events;")))
+
+	var buf strings.Builder
+	require.NoError(t, client.QueryJSON(t.Context(), &buf, "SELECT id FROM events ORDER BY id DESC LIMIT 2;"))
+	require.Equal(t, "[{\"id\":7},{\"id\":6}]\n", buf.String())
+}
+
+func Test_QueryCancel(t *testing.T) {
+	for name, opts := range map[string][]any{"direct": nil, "plan cache": {WithPlanCache(4)}} {
+		t.Run(name, func(t *testing.T) {
+			client, err := New(t.TempDir(), 1, opts...)
+			require.NoError(t, err)
+			defer client.Close(t.Context())
+			ctx, cancel := context.WithCancel(t.Context())
+			time.AfterFunc(50*time.Millisecond, cancel)
+			start := time.Now()
+			_, err = client.Query(ctx, "SELECT count(*) FROM range(100000000) a, range(100000000) b WHERE a.range + b.range = 7;")
+			require.ErrorIs(t, err, context.Canceled)
+			require.Less(t, time.Since(start), 2*time.Second)
+
+			start = time.Now()
+			require.NoError(t, client.Insert(t.Context(), "events", strings.NewReader(`{"id":1}`)))
+			require.Less(t, time.Since(start), time.Second)
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package quack
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+var ErrQuotaExceeded = errors.New("disk budget exceeded")
+
+func dirSize(dir string) (int64, error) {
+	var total int64
+	err := filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if os.IsNotExist(err) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if os.IsNotExist(err) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		total += info.Size()
+		return nil
+	})
+	return total, err
+}
+
+// checkBudget fails when adding extra bytes to the client directory would
+// exceed the disk budget.
+func (c *Client) checkBudget(extra int64) error {
+	usage, err := dirSize(c.dir)
+	if err != nil {
+		return err
+	}
+	if usage+extra > c.diskBudget {
+		return fmt.Errorf("%w: %d bytes used, %d more needed, budget %d", ErrQuotaExceeded, usage, extra, c.diskBudget)
+	}
+	return nil
+}
+
+// precheck is checkBudget for the write paths that do not load through an
+// insertConfig. It passes when no disk budget is set.
+func (c *Client) precheck(extra int64) error {
+	if c.diskBudget <= 0 {
+		return nil
+	}
+	return c.checkBudget(extra)
+}
+
+// makeRoomForSnapshot estimates the size of the next snapshot from the
+// newest one (or the live data when there is none) and prunes the oldest
+// snapshots, always keeping the newest, until it fits the budget.
+func (c *Client) makeRoomForSnapshot(ctx context.Context) error {
+	if c.diskBudget <= 0 {
+		return nil
+	}
+	infos, err := snapshotInfos(filepath.Join(c.dir, "snapshot"))
+	if err != nil {
+		return err
+	}
+	var estimate int64
+	if len(infos) > 0 {
+		estimate = infos[0].Size
+	} else if estimate, err = usedSize(ctx, c.db); err != nil {
+		return err
+	}
+	for {
+		err := c.checkBudget(estimate)
+		if err == nil || !errors.Is(err, ErrQuotaExceeded) || len(infos) <= 1 {
+			return err
+		}
+		oldest := infos[len(infos)-1]
+		if err := os.Remove(oldest.Path); err != nil {
+			return err
+		}
+		c.logger.Info("pruned snapshot to fit disk budget", "path", oldest.Path)
+		infos = infos[:len(infos)-1]
+	}
+}
@@ -0,0 +1,71 @@
+package quack
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_DiskBudget(t *testing.T) {
+	dir := t.TempDir()
+	client, err := New(dir, 10)
+	require.NoError(t, err)
+	require.NoError(t, client.Insert(t.Context(), "events", strings.NewReader(`{"id":1}`)))
+	require.NoError(t, client.Close(t.Context()))
+	usage, err := dirSize(dir)
+	require.NoError(t, err)
+
+	var fakes []string
+	for i := range 3 {
+		id := ulid.MustNewDefault(time.Now().Add(-time.Duration(3-i) * time.Hour)).String()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "snapshot", id), make([]byte, 100<<10), 0644))
+		fakes = append(fakes, id)
+	}
+	budget := usage + 150<<10
+	client, err = New(dir, 10, WithDiskBudget(budget))
+	require.NoError(t, err)
+	stats, err := client.Stats(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, budget, stats.DiskBudget)
+	require.Greater(t, stats.DiskUsage, budget)
+
+	err = client.Insert(t.Context(), "events", strings.NewReader(`{"id":2}`))
+	require.ErrorIs(t, err, ErrQuotaExceeded)
+	require.NoError(t, client.Close(t.Context()))
+	names, err := listDir(filepath.Join(dir, "snapshot"))
+	require.NoError(t, err)
+	require.NotContains(t, names, fakes[0])
+	require.NotContains(t, names, fakes[1])
+	usage, err = dirSize(dir)
+	require.NoError(t, err)
+	require.LessOrEqual(t, usage, budget)
+}
+
+func Test_DiskBudgetWrites(t *testing.T) {
+	dir := t.TempDir()
+	client, err := New(dir, 1)
+	require.NoError(t, err)
+	require.NoError(t, client.Close(t.Context()))
+	usage, err := dirSize(dir)
+	require.NoError(t, err)
+	client, err = New(dir, 1, WithDiskBudget(usage+1<<20))
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+
+	big := strings.Repeat("x", 2<<20)
+	err = client.PutBlob(t.Context(), "files", "big", strings.NewReader(big), nil)
+	require.ErrorIs(t, err, ErrQuotaExceeded)
+	err = client.PutDoc(t.Context(), "docs", "big", map[string]string{"body": big})
+	require.ErrorIs(t, err, ErrQuotaExceeded)
+	type row struct{ Body string }
+	err = InsertRows(t.Context(), client, "rows", []row{{Body: big}})
+	require.ErrorIs(t, err, ErrQuotaExceeded)
+
+	require.NoError(t, client.PutDoc(t.Context(), "docs", "small", map[string]string{"body": "x"}))
+	require.NoError(t, InsertRows(t.Context(), client, "rows", []row{{Body: "x"}}))
+}
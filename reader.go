@@ -0,0 +1,224 @@
+package quack
+
+import (
+	"archive/zip"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/duckdb/duckdb-go/v2"
+)
+
+// ErrReadOnly is returned by a Reader for statements that would write.
+var ErrReadOnly = errors.New("snapshot reader is read-only")
+
+// Reader is a read-only view of a snapshot archive, opened with
+// OpenSnapshot. It is safe for concurrent use.
+type Reader struct {
+	dir       string
+	connecter *duckdb.Connector
+	db        *sql.DB
+}
+
+type readerConfig struct {
+	staging string
+}
+
+type ReaderOption func(*readerConfig)
+
+// WithReaderStagingDir sets the directory the temporary database of
+// OpenSnapshot is created in, like WithStagingDir does for a client, so
+// the sweep of a client using it removes one left behind by a crash.
+// Defaults to os.TempDir.
+func WithReaderStagingDir(dir string) ReaderOption {
+	return func(c *readerConfig) { c.staging = dir }
+}
+
+// OpenSnapshot restores the snapshot archive at archivePath into a
+// temporary database, leaving any client directory alone, and opens it
+// read-only. Table data is extracted one file at a time, so beyond the
+// database itself the restore needs temporary space for the largest table
+// only. Close removes the temporary database.
+func OpenSnapshot(ctx context.Context, archivePath string, opts ...ReaderOption) (*Reader, error) {
+	var cfg readerConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.staging != "" {
+		if err := os.MkdirAll(cfg.staging, 0755); err != nil {
+			return nil, err
+		}
+	}
+	dir, err := os.MkdirTemp(cfg.staging, loadPrefix)
+	if err != nil {
+		return nil, err
+	}
+	r := &Reader{dir: dir}
+	if err := r.open(ctx, archivePath); err != nil {
+		r.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *Reader) open(ctx context.Context, archivePath string) error {
+	file := filepath.Join(r.dir, "database.ddb")
+	connecter, err := duckdb.NewConnector(file, nil)
+	if err != nil {
+		return err
+	}
+	db := sql.OpenDB(connecter)
+	err = streamRestore(ctx, db, r.dir, archivePath)
+	if cerr := db.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := connecter.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	if r.connecter, err = duckdb.NewConnector(file+"?access_mode=read_only", nil); err != nil {
+		return err
+	}
+	r.db = sql.OpenDB(r.connecter)
+	return nil
+}
+
+// streamRestore loads a snapshot archive into the empty database db,
+// extracting each data file into staging only while it is loaded. Archives
+// with a load.sql it does not recognise are extracted whole and imported.
+func streamRestore(ctx context.Context, db *sql.DB, staging, archive string) error {
+	zr, err := openArchive(archive)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+	var names []string
+	files := make(map[string]*zip.File)
+	for _, zf := range zr.File {
+		name, dir, err := entryName(zf.Name)
+		if err != nil {
+			return err
+		}
+		if !dir {
+			names = append(names, name)
+			files[name] = zf
+		}
+	}
+	root := archiveRoot(names)
+	read := func(name string) (string, error) {
+		zf := files[root+name]
+		if zf == nil {
+			return "", fmt.Errorf("archive has no %s", name)
+		}
+		rc, err := zf.Open()
+		if err != nil {
+			return "", err
+		}
+		defer rc.Close()
+		b, err := io.ReadAll(rc)
+		return string(b), err
+	}
+	load, err := read("load.sql")
+	if err != nil {
+		return err
+	}
+	stmts, ok := parseLoad(load)
+	if !ok {
+		dir, err := unzip(staging, archive, nil)
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(dir)
+		return importDir(ctx, db, dir, nil)
+	}
+	schema, err := read("schema.sql")
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(schema) != "" {
+		if _, err := db.ExecContext(ctx, schema); err != nil {
+			return err
+		}
+	}
+	for _, stmt := range stmts {
+		zf := files[root+stmt.file]
+		if zf == nil {
+			return fmt.Errorf("archive has no %s", stmt.file)
+		}
+		target := filepath.Join(staging, loadPrefix+stmt.file)
+		_, err := extract(target, zf)
+		if err == nil {
+			_, err = db.ExecContext(ctx, fmt.Sprintf("COPY %s FROM %s%s", stmt.table, quoteLiteral(target), stmt.rest))
+		}
+		os.Remove(target)
+		if err != nil {
+			return err
+		}
+		if strings.Contains(strings.ToLower(stmt.rest), "'json'") {
+			if err := decodeBlobs(ctx, db, stmt.table); err != nil {
+				return err
+			}
+		}
+	}
+	return applyComments(ctx, db, "")
+}
+
+// Query runs stmt against the snapshot. Statements that would write fail
+// with ErrReadOnly.
+func (r *Reader) Query(ctx context.Context, stmt string, args ...any) (*sql.Rows, error) {
+	rows, err := r.db.QueryContext(ctx, stmt, args...)
+	if err != nil && strings.Contains(err.Error(), "read-only mode") {
+		return nil, fmt.Errorf("%w: %v", ErrReadOnly, err)
+	}
+	return rows, err
+}
+
+// Describe lists the columns of table with their comments.
+func (r *Reader) Describe(ctx context.Context, table string) ([]Column, error) {
+	columns, err := describe(ctx, r.db, table)
+	if err != nil {
+		return nil, err
+	}
+	return withComments(ctx, r.db, table, columns)
+}
+
+// Tables lists the tables and views of the snapshot like Client.Tables.
+func (r *Reader) Tables(ctx context.Context, opts ...TablesOption) ([]TableInfo, error) {
+	return listTables(ctx, r.db, newTablesConfig(opts), nil)
+}
+
+// Stats reports the size of the restored database.
+func (r *Reader) Stats(ctx context.Context) (Stats, error) {
+	var (
+		s   Stats
+		err error
+	)
+	if s.UsedSize, err = usedSize(ctx, r.db); err != nil {
+		return s, err
+	}
+	if s.FileSize, err = fileSize(filepath.Join(r.dir, "database.ddb")); err != nil {
+		return s, err
+	}
+	s.DiskUsage, err = dirSize(r.dir)
+	return s, err
+}
+
+// Close closes the snapshot and removes its temporary database.
+func (r *Reader) Close() error {
+	var errs []error
+	if r.db != nil {
+		errs = append(errs, r.db.Close())
+	}
+	if r.connecter != nil {
+		errs = append(errs, r.connecter.Close())
+	}
+	errs = append(errs, os.RemoveAll(r.dir))
+	return errors.Join(errs...)
+}
@@ -0,0 +1,74 @@
+package quack
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_OpenSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	client, err := New(dir, 3)
+	require.NoError(t, err)
+	require.NoError(t, client.Insert(t.Context(), "events", strings.NewReader(`{"id":1,"name":"a"}`+"\n"+`{"id":2,"name":"b"}`)))
+	require.NoError(t, client.SetComment(t.Context(), "events", "all events"))
+	require.NoError(t, client.Close(t.Context()))
+	infos, err := snapshotInfos(filepath.Join(dir, "snapshot"))
+	require.NoError(t, err)
+
+	r, err := OpenSnapshot(t.Context(), infos[0].Path)
+	require.NoError(t, err)
+	var n int
+	rows, err := r.Query(t.Context(), "SELECT count(*) FROM events WHERE id > ?;", 0)
+	require.NoError(t, err)
+	require.True(t, rows.Next())
+	require.NoError(t, rows.Scan(&n))
+	require.NoError(t, rows.Close())
+	require.Equal(t, 2, n)
+	columns, err := r.Describe(t.Context(), "events")
+	require.NoError(t, err)
+	require.Equal(t, []Column{{Name: "id", Type: "BIGINT", Nullable: true}, {Name: "name", Type: "VARCHAR", Nullable: true}}, columns)
+	tables, err := r.Tables(t.Context(), ExcludeInternal())
+	require.NoError(t, err)
+	require.Len(t, tables, 1)
+	require.Equal(t, "events", tables[0].Name)
+	stats, err := r.Stats(t.Context())
+	require.NoError(t, err)
+	require.Positive(t, stats.FileSize)
+	var comment string
+	require.NoError(t, r.db.QueryRow("SELECT comment FROM duckdb_tables() WHERE table_name = 'events';").Scan(&comment))
+	require.Equal(t, "all events", comment)
+
+	for _, stmt := range []string{"INSERT INTO events VALUES (3, 'c');", "CREATE TABLE other (x INTEGER);", "DROP TABLE events;"} {
+		_, err = r.Query(t.Context(), stmt)
+		require.ErrorIs(t, err, ErrReadOnly, stmt)
+	}
+	require.NoError(t, r.Close())
+	_, err = os.Stat(r.dir)
+	require.True(t, os.IsNotExist(err))
+
+	t.Run("portable archive", func(t *testing.T) {
+		r, err := OpenSnapshot(t.Context(), filepath.Join("testdata", "snapshot_backslash.zip"))
+		require.NoError(t, err)
+		defer r.Close()
+		var names string
+		require.NoError(t, r.db.QueryRow("SELECT string_agg(name, ',' ORDER BY id) FROM events;").Scan(&names))
+		require.Equal(t, "a,b's", names)
+	})
+	t.Run("staging dir", func(t *testing.T) {
+		staging := filepath.Join(t.TempDir(), "staging")
+		r, err := OpenSnapshot(t.Context(), infos[0].Path, WithReaderStagingDir(staging))
+		require.NoError(t, err)
+		defer r.Close()
+		require.Equal(t, staging, filepath.Dir(r.dir))
+		// Swept like the client's own temporary files.
+		require.Regexp(t, stagingPattern, filepath.Base(r.dir))
+	})
+	t.Run("missing", func(t *testing.T) {
+		_, err := OpenSnapshot(t.Context(), filepath.Join(t.TempDir(), "missing"))
+		require.Error(t, err)
+	})
+}
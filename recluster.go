@@ -0,0 +1,85 @@
+package quack
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+type dedupConfig struct {
+	orderBy []string
+}
+
+type DedupOption func(*dedupConfig)
+
+func newDedupConfig(opts []DedupOption) dedupConfig {
+	var cfg dedupConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg
+}
+
+// OrderBy makes Deduplicate write the table back sorted by columns, as
+// Recluster does.
+func OrderBy(columns ...string) DedupOption {
+	return func(c *dedupConfig) { c.orderBy = columns }
+}
+
+// orderClause builds the ORDER BY clause for orderBy, whose entries are
+// column names of table optionally followed by ASC or DESC.
+func orderClause(ctx context.Context, db querier, table string, orderBy []string) (string, error) {
+	if len(orderBy) == 0 {
+		return "", nil
+	}
+	columns, err := describe(ctx, db, table)
+	if err != nil {
+		return "", err
+	}
+	known := make(map[string]bool, len(columns))
+	for _, col := range columns {
+		known[col.Name] = true
+	}
+	terms := make([]string, len(orderBy))
+	for i, term := range orderBy {
+		name, dir := term, ""
+		if j := strings.LastIndexByte(term, ' '); j >= 0 {
+			switch d := strings.ToUpper(term[j+1:]); d {
+			case "ASC", "DESC":
+				name, dir = strings.TrimSpace(term[:j]), " "+d
+			}
+		}
+		if !known[name] {
+			return "", fmt.Errorf("table %s has no column %s to order by", table, name)
+		}
+		terms[i] = quoteIdent(name) + dir
+	}
+	return " ORDER BY " + strings.Join(terms, ", "), nil
+}
+
+// Recluster rewrites table sorted by orderBy, so range filters on those
+// columns can skip row groups through DuckDB's min/max zone maps. Entries
+// are column names optionally followed by ASC or DESC. The rewrite runs in
+// a single transaction; tables with key constraints cannot be reclustered.
+func (c *Client) Recluster(ctx context.Context, table string, orderBy ...string) (err error) {
+	if len(orderBy) == 0 {
+		return fmt.Errorf("recluster %s: no order columns", table)
+	}
+	if err := c.lock("Recluster"); err != nil {
+		return err
+	}
+	defer c.unlock("Recluster", &err)
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := rewrite(ctx, tx, table, "*", orderBy); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	c.counters.touch(table)
+	return nil
+}
@@ -0,0 +1,60 @@
+package quack
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Recluster(t *testing.T) {
+	client, err := New(t.TempDir(), 3)
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+	require.NoError(t, client.Insert(t.Context(), "events", strings.NewReader(`{"id":3,"ts":"2024-01-03"}
+{"id":1,"ts":"2024-01-01"}
+{"id":2,"ts":"2024-01-02"}
+{"id":1,"ts":"2024-01-01"}`)))
+	ids := func() string {
+		var s string
+		require.NoError(t, client.db.QueryRow("SELECT string_agg(id::VARCHAR, ',') FROM events;").Scan(&s))
+		return s
+	}
+
+	require.NoError(t, client.Recluster(t.Context(), "events", "ts"))
+	require.Equal(t, "1,1,2,3", ids())
+	require.NoError(t, client.Deduplicate(t.Context(), "events", OrderBy("ts desc")))
+	require.Equal(t, "3,2,1", ids())
+	require.NoError(t, client.Recluster(t.Context(), "events", "id ASC"))
+	require.Equal(t, "1,2,3", ids())
+
+	require.Error(t, client.Recluster(t.Context(), "events"))
+	require.Error(t, client.Recluster(t.Context(), "events", "nope"))
+	require.Error(t, client.Recluster(t.Context(), "events", "id; DROP TABLE events"))
+	require.Equal(t, "1,2,3", ids())
+
+	require.NoError(t, client.PutDoc(t.Context(), "docs", "k", map[string]int{"a": 1}))
+	require.Error(t, client.Recluster(t.Context(), "docs", "key"))
+}
+
+// BenchmarkRecluster compares a one-day range scan over a year of randomly
+// ordered timestamps before and after clustering by timestamp.
+func BenchmarkRecluster(b *testing.B) {
+	client, err := New(b.TempDir(), 3)
+	require.NoError(b, err)
+	defer client.Close(b.Context())
+	_, err = client.db.Exec(`CREATE TABLE events AS SELECT range AS id, TIMESTAMP '2024-01-01' + to_seconds((hash(range) % 31536000)::BIGINT) AS ts, md5(range::VARCHAR) AS payload FROM range(4000000);
+CHECKPOINT;`)
+	require.NoError(b, err)
+	scan := func(b *testing.B) {
+		for b.Loop() {
+			var n int
+			require.NoError(b, client.db.QueryRow("SELECT count(*), max(payload) FROM events WHERE ts BETWEEN '2024-03-01' AND '2024-03-02';").Scan(&n, new(string)))
+		}
+	}
+	b.Run("unordered", scan)
+	require.NoError(b, client.Recluster(b.Context(), "events", "ts"))
+	_, err = client.db.Exec("CHECKPOINT;")
+	require.NoError(b, err)
+	b.Run("clustered", scan)
+}
@@ -0,0 +1,74 @@
+package quack
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"slices"
+)
+
+// remoteSchemes are the URL schemes the httpfs extension reads.
+var remoteSchemes = []string{"http", "https", "s3", "s3a", "s3n", "gcs", "gs", "r2", "hf"}
+
+// InsertURL is Insert reading the file at rawURL, such as an https:// or
+// s3:// URL, straight from DuckDB through the httpfs extension, which is
+// installed and loaded on first use. With WithDiskBudget the size of the
+// remote files is checked against the budget before loading. Remote inputs
+// are not journaled, and JSON Schema validation is not supported.
+func (c *Client) InsertURL(ctx context.Context, table, rawURL string, format Format, opts ...InsertOption) (err error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(remoteSchemes, u.Scheme) {
+		return fmt.Errorf("unsupported URL scheme %q in %s", u.Scheme, rawURL)
+	}
+	cfg := c.ingestConfig(append(opts, withFormat(format)))
+	if err := cfg.validate(); err != nil {
+		return err
+	}
+	if cfg.jsonSchema != nil {
+		return fmt.Errorf("JSON Schema validation needs a local input, got %s", rawURL)
+	}
+	if err := c.lock("InsertURL"); err != nil {
+		return err
+	}
+	defer c.unlock("InsertURL", &err)
+	if !c.httpfs {
+		if _, err := c.db.ExecContext(ctx, "INSTALL httpfs; LOAD httpfs;"); err != nil {
+			return fmt.Errorf("read %s: load httpfs: %w", rawURL, err)
+		}
+		c.httpfs = true
+	}
+	if cfg.precheck != nil {
+		size, err := remoteSize(ctx, c.db, rawURL)
+		if err != nil {
+			return fmt.Errorf("read %s: %w", rawURL, err)
+		}
+		if err := cfg.precheck(size); err != nil {
+			return err
+		}
+	}
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	res, err := load(ctx, tx, table+cfg.suffix, rawURL, cfg)
+	if err != nil {
+		return fmt.Errorf("read %s: %w", rawURL, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	c.counters.insert(res)
+	return nil
+}
+
+// remoteSize returns the size of the files at rawURL, which may be a glob,
+// for the disk budget. Only their metadata is read.
+func remoteSize(ctx context.Context, db querier, rawURL string) (int64, error) {
+	var size int64
+	err := db.QueryRowContext(ctx, "SELECT coalesce(sum(size), 0) FROM read_blob(?);", rawURL).Scan(&size)
+	return size, err
+}
@@ -0,0 +1,39 @@
+package quack
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_InsertURL(t *testing.T) {
+	client, err := New(t.TempDir(), 1)
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+	require.ErrorContains(t, client.InsertURL(t.Context(), "events", "ftp://example.com/events.json", FormatJSON), `unsupported URL scheme "ftp"`)
+	require.ErrorContains(t, client.InsertURL(t.Context(), "events", "/tmp/events.json", FormatJSON), "unsupported URL scheme")
+	require.ErrorContains(t, client.InsertURL(t.Context(), "events", "https://example.com/events.json", FormatJSON, WithJSONSchema([]byte(`{}`))), "local input")
+
+	// Nothing listens on port 1; without network access loading httpfs
+	// fails first. Either way the error names the URL.
+	u := "http://127.0.0.1:1/events.json"
+	err = client.InsertURL(t.Context(), "events", u, FormatJSON)
+	require.ErrorContains(t, err, "read "+u+": ")
+	require.Error(t, tableExists(t.Context(), client.db, "events"))
+	require.NoError(t, client.Insert(t.Context(), "events", strings.NewReader(`{"id":1}`)))
+}
+
+func Test_remoteSize(t *testing.T) {
+	client, err := New(t.TempDir(), 1)
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.json"), []byte(`{"id":1}`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "b.json"), []byte(`{"id":10}`), 0644))
+	size, err := remoteSize(t.Context(), client.db, filepath.Join(dir, "*.json"))
+	require.NoError(t, err)
+	require.Equal(t, int64(17), size)
+}
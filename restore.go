@@ -0,0 +1,354 @@
+package quack
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Progress reports how far a restore has come.
+type Progress struct {
+	// Phase is "verify", "extract" or "import".
+	Phase string
+	// Item is the archive entry or table just completed, empty for the
+	// event starting a phase.
+	Item string
+	// Done and Total count bytes of the phase.
+	Done, Total int64
+}
+
+type restoreConfig struct {
+	progress    func(Progress)
+	parallelism int
+}
+
+type RestoreOption func(*restoreConfig)
+
+// WithProgress reports restore progress to fn. Events are delivered on a
+// separate goroutine while the restore runs, so fn may call back into the
+// Client; such calls wait until the restore has finished. The restoring
+// call returns only after fn has seen every event.
+func WithProgress(fn func(Progress)) RestoreOption {
+	return func(c *restoreConfig) { c.progress = fn }
+}
+
+// WithRestoreParallelism runs the import with n DuckDB threads and restores
+// the configured thread count afterwards. The import stays a single
+// transaction, so a failed restore still leaves the database untouched.
+// Defaults to the configured thread count.
+func WithRestoreParallelism(n int) RestoreOption {
+	return func(c *restoreConfig) { c.parallelism = n }
+}
+
+func (c restoreConfig) threads() string {
+	if c.parallelism < 1 {
+		return ""
+	}
+	return strconv.Itoa(c.parallelism)
+}
+
+func newRestoreConfig(opts []RestoreOption) restoreConfig {
+	var cfg restoreConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg
+}
+
+func (c restoreConfig) notifier() *notifier {
+	if c.progress == nil {
+		return nil
+	}
+	n := &notifier{fn: c.progress, done: make(chan struct{})}
+	n.cond = sync.NewCond(&n.mux)
+	go n.run()
+	return n
+}
+
+// notifier queues progress events for delivery outside the client lock.
+// A nil notifier drops events.
+type notifier struct {
+	fn     func(Progress)
+	mux    sync.Mutex
+	cond   *sync.Cond
+	queue  []Progress
+	closed bool
+	done   chan struct{}
+}
+
+func (n *notifier) run() {
+	defer close(n.done)
+	for {
+		n.mux.Lock()
+		for len(n.queue) == 0 && !n.closed {
+			n.cond.Wait()
+		}
+		queue := n.queue
+		n.queue = nil
+		closed := n.closed
+		n.mux.Unlock()
+		for _, p := range queue {
+			n.fn(p)
+		}
+		if closed && len(queue) == 0 {
+			return
+		}
+	}
+}
+
+func (n *notifier) send(p Progress) {
+	if n == nil {
+		return
+	}
+	n.mux.Lock()
+	n.queue = append(n.queue, p)
+	n.mux.Unlock()
+	n.cond.Signal()
+}
+
+// wait delivers the remaining events. Call it after releasing the lock.
+func (n *notifier) wait() {
+	if n == nil {
+		return
+	}
+	n.mux.Lock()
+	n.closed = true
+	n.mux.Unlock()
+	n.cond.Signal()
+	<-n.done
+}
+
+// entryName validates the name of an archive entry and returns it with
+// forward slashes, so archives written on Windows extract anywhere. Names
+// that are absolute, carry a drive or would escape the target directory are
+// rejected. dir reports a directory entry.
+func entryName(name string) (clean string, dir bool, err error) {
+	slashed := strings.ReplaceAll(name, `\`, "/")
+	if dir = strings.HasSuffix(slashed, "/"); dir {
+		slashed = strings.TrimSuffix(slashed, "/")
+	}
+	clean = path.Clean(slashed)
+	if clean != slashed || clean == "." || clean == ".." || strings.HasPrefix(clean, "../") || path.IsAbs(clean) || strings.Contains(clean, ":") {
+		return "", false, fmt.Errorf("invalid archive entry %q", name)
+	}
+	return clean, dir, nil
+}
+
+// archiveRoot returns the directory wrapping every file of an archive, as
+// added by zip tools that archive a folder rather than its contents, or ""
+// when files sit at the top level.
+func archiveRoot(files []string) string {
+	var root string
+	for _, name := range files {
+		top, _, ok := strings.Cut(name, "/")
+		if !ok || (root != "" && top != root) {
+			return ""
+		}
+		root = top
+	}
+	if root == "" {
+		return ""
+	}
+	return root + "/"
+}
+
+// openArchive opens a snapshot archive, decompressing entries written with
+// any of the snapshot codecs, whatever the options of the client.
+func openArchive(file string) (*zip.ReadCloser, error) {
+	zr, err := zip.OpenReader(file)
+	if err != nil {
+		return nil, err
+	}
+	zr.RegisterDecompressor(zipZstd, zstdDecompressor)
+	return zr, nil
+}
+
+func zstdDecompressor(r io.Reader) io.ReadCloser {
+	d, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+	if err != nil {
+		return io.NopCloser(errReader{err})
+	}
+	return d.IOReadCloser()
+}
+
+type errReader struct{ err error }
+
+func (r errReader) Read([]byte) (int, error) { return 0, r.err }
+
+// unzip verifies the snapshot archive file and extracts it into a new
+// directory under staging. The caller removes the directory.
+func unzip(staging, file string, p *notifier) (string, error) {
+	zr, err := openArchive(file)
+	if err != nil {
+		return "", err
+	}
+	defer zr.Close()
+	var (
+		total int64
+		files []string
+	)
+	names := make([]string, len(zr.File))
+	p.send(Progress{Phase: "verify"})
+	for i, zf := range zr.File {
+		name, dir, err := entryName(zf.Name)
+		if err != nil {
+			return "", err
+		}
+		if !dir {
+			names[i] = name
+			files = append(files, name)
+		}
+		total += int64(zf.UncompressedSize64)
+	}
+	root := archiveRoot(files)
+	p.send(Progress{Phase: "verify", Item: filepath.Base(file), Done: total, Total: total})
+	dir, err := os.MkdirTemp(staging, loadPrefix)
+	if err != nil {
+		return "", err
+	}
+	p.send(Progress{Phase: "extract", Total: total})
+	var done int64
+	for i, zf := range zr.File {
+		if names[i] == "" {
+			continue
+		}
+		n, err := extract(filepath.Join(dir, filepath.FromSlash(strings.TrimPrefix(names[i], root))), zf)
+		if err != nil {
+			os.RemoveAll(dir)
+			return "", err
+		}
+		done += n
+		p.send(Progress{Phase: "extract", Item: names[i], Done: done, Total: total})
+	}
+	return dir, nil
+}
+
+func extract(target string, zf *zip.File) (int64, error) {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return 0, err
+	}
+	r, err := zf.Open()
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+	f, err := os.Create(target)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(f, r)
+	if err != nil {
+		f.Close()
+		return n, err
+	}
+	return n, f.Close()
+}
+
+var copyStmt = regexp.MustCompile(`^COPY (.+) FROM '((?:[^']|'')*)'(.*;)$`)
+
+type loadStmt struct {
+	table, file, rest string
+}
+
+// parseLoad splits the load.sql written by EXPORT DATABASE into its COPY
+// statements, or reports false if it contains anything else.
+func parseLoad(script string) ([]loadStmt, bool) {
+	var stmts []loadStmt
+	for _, line := range strings.Split(script, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		m := copyStmt.FindStringSubmatch(line)
+		if m == nil {
+			return nil, false
+		}
+		// The path is the one the archive was exported to, possibly on
+		// another OS, so split it on either separator.
+		file := path.Base(strings.ReplaceAll(strings.ReplaceAll(m[2], "''", "'"), `\`, "/"))
+		stmts = append(stmts, loadStmt{table: m[1], file: file, rest: m[3]})
+	}
+	return stmts, true
+}
+
+// importDir loads a directory written by EXPORT DATABASE into db. It runs
+// the schema and the per-table COPY statements itself so progress can be
+// reported per table, falling back to IMPORT DATABASE when load.sql has a
+// shape it does not recognise.
+func importDir(ctx context.Context, db querier, dir string, p *notifier) error {
+	load, err := os.ReadFile(filepath.Join(dir, "load.sql"))
+	if err != nil {
+		return err
+	}
+	stmts, ok := parseLoad(string(load))
+	if !ok {
+		p.send(Progress{Phase: "import"})
+		_, err := db.ExecContext(ctx, fmt.Sprintf("IMPORT DATABASE '%s';", dir))
+		return err
+	}
+	var total int64
+	sizes := make([]int64, len(stmts))
+	for i, stmt := range stmts {
+		if sizes[i], err = fileSize(filepath.Join(dir, stmt.file)); err != nil {
+			return err
+		}
+		total += sizes[i]
+	}
+	p.send(Progress{Phase: "import", Total: total})
+	schema, err := os.ReadFile(filepath.Join(dir, "schema.sql"))
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(string(schema)) != "" {
+		if _, err := db.ExecContext(ctx, string(schema)); err != nil {
+			return err
+		}
+	}
+	var done int64
+	for i, stmt := range stmts {
+		copy := fmt.Sprintf("COPY %s FROM %s%s", stmt.table, quoteLiteral(filepath.Join(dir, stmt.file)), stmt.rest)
+		if _, err := db.ExecContext(ctx, copy); err != nil {
+			return err
+		}
+		if strings.Contains(strings.ToLower(stmt.rest), "'json'") {
+			if err := decodeBlobs(ctx, db, stmt.table); err != nil {
+				return err
+			}
+		}
+		done += sizes[i]
+		p.send(Progress{Phase: "import", Item: stmt.table, Done: done, Total: total})
+	}
+	return nil
+}
+
+// decodeBlobs repairs the BLOB columns of a table loaded from JSON. EXPORT
+// writes blobs as their escaped text form, \xFF and so on, which COPY reads
+// back as the bytes of that text rather than the bytes it encodes.
+func decodeBlobs(ctx context.Context, db querier, table string) error {
+	columns, err := describe(ctx, db, table)
+	if err != nil {
+		return err
+	}
+	var sets []string
+	for _, col := range columns {
+		if col.Type == "BLOB" {
+			name := quoteIdent(col.Name)
+			sets = append(sets, fmt.Sprintf("%s = decode(%s)::BLOB", name, name))
+		}
+	}
+	if len(sets) == 0 {
+		return nil
+	}
+	_, err = db.ExecContext(ctx, fmt.Sprintf("UPDATE %s SET %s;", table, strings.Join(sets, ", ")))
+	return err
+}
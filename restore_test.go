@@ -0,0 +1,147 @@
+package quack
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_RollbackProgress(t *testing.T) {
+	dir := t.TempDir()
+	client, err := New(dir, 3)
+	require.NoError(t, err)
+	require.NoError(t, client.Insert(t.Context(), "a", strings.NewReader(`{"id":1}`)))
+	require.NoError(t, client.Insert(t.Context(), "b", strings.NewReader(`{"id":2}`)))
+	require.NoError(t, client.Close(t.Context()))
+
+	client, err = New(dir, 3)
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+	require.NoError(t, client.Insert(t.Context(), "a", strings.NewReader(`{"id":3}`)))
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+	var events []Progress
+	var counts []int
+	err = client.RollbackSnapshot(t.Context(), 1, WithProgress(func(p Progress) {
+		logger.Info("restore", "phase", p.Phase, "item", p.Item, "done", p.Done, "total", p.Total)
+		events = append(events, p)
+		rows, err := client.Query(t.Context(), "SELECT 1;")
+		require.NoError(t, err)
+		require.NoError(t, rows.Close())
+		counts = append(counts, len(events))
+	}))
+	require.NoError(t, err)
+	require.Len(t, counts, len(events))
+
+	var phases, tables []string
+	for _, p := range events {
+		require.LessOrEqual(t, p.Done, p.Total)
+		if len(phases) == 0 || phases[len(phases)-1] != p.Phase {
+			phases = append(phases, p.Phase)
+		}
+		if p.Phase == "import" && p.Item != "" {
+			tables = append(tables, p.Item)
+		}
+	}
+	require.Equal(t, []string{"verify", "extract", "import"}, phases)
+	require.ElementsMatch(t, []string{"a", "b", historyTable}, tables)
+	last := events[len(events)-1]
+	require.Equal(t, last.Total, last.Done)
+	require.Contains(t, buf.String(), "phase=import")
+
+	var n int
+	require.NoError(t, client.db.QueryRow("SELECT count(*) FROM a;").Scan(&n))
+	require.Equal(t, 1, n)
+}
+
+func Test_entryName(t *testing.T) {
+	for _, name := range []string{"../x", "/etc/passwd", ".", `..\x`, "a/../b", `C:\x`, "C:x", `\\server\share\x`, `\x`, "a//b"} {
+		_, _, err := entryName(name)
+		require.Error(t, err, name)
+	}
+	for name, want := range map[string]string{"load.sql": "load.sql", "a/b": "a/b", `export\load.sql`: "export/load.sql"} {
+		got, dir, err := entryName(name)
+		require.NoError(t, err)
+		require.False(t, dir)
+		require.Equal(t, want, got)
+	}
+	got, dir, err := entryName(`export\`)
+	require.NoError(t, err)
+	require.True(t, dir)
+	require.Equal(t, "export", got)
+}
+
+func Test_archiveRoot(t *testing.T) {
+	require.Equal(t, "export/", archiveRoot([]string{"export/load.sql", "export/schema.sql"}))
+	require.Equal(t, "", archiveRoot([]string{"export/load.sql", "schema.sql"}))
+	require.Equal(t, "", archiveRoot([]string{"a/load.sql", "b/schema.sql"}))
+	require.Equal(t, "", archiveRoot(nil))
+}
+
+func Test_RollbackPortableArchives(t *testing.T) {
+	for _, fixture := range []string{"snapshot_slash.zip", "snapshot_backslash.zip"} {
+		t.Run(fixture, func(t *testing.T) {
+			dir := t.TempDir()
+			require.NoError(t, os.MkdirAll(filepath.Join(dir, "snapshot"), 0755))
+			data, err := os.ReadFile(filepath.Join("testdata", fixture))
+			require.NoError(t, err)
+			id := ulid.Make().String()
+			require.NoError(t, os.WriteFile(filepath.Join(dir, "snapshot", id), data, 0644))
+			client, err := New(dir, 3)
+			require.NoError(t, err)
+			defer client.Close(t.Context())
+			require.NoError(t, client.RollbackSnapshot(t.Context(), 1))
+			var names []string
+			rows, err := client.db.Query("SELECT name FROM events ORDER BY id;")
+			require.NoError(t, err)
+			defer rows.Close()
+			for rows.Next() {
+				var name string
+				require.NoError(t, rows.Scan(&name))
+				names = append(names, name)
+			}
+			require.NoError(t, rows.Err())
+			require.Equal(t, []string{"a", "b's"}, names)
+		})
+	}
+}
+
+func threads(t *testing.T, db querier) int {
+	t.Helper()
+	var n int
+	require.NoError(t, db.QueryRowContext(t.Context(), "SELECT current_setting('threads');").Scan(&n))
+	return n
+}
+
+func Test_RollbackParallelism(t *testing.T) {
+	dir := t.TempDir()
+	opt := ConnOption(func(ctx context.Context, conn *sql.Conn) error {
+		_, err := conn.ExecContext(ctx, "SET threads = 1;")
+		return err
+	})
+	client, err := New(dir, 3, opt)
+	require.NoError(t, err)
+	for _, table := range []string{"a", "b", "c"} {
+		require.NoError(t, client.Insert(t.Context(), table, strings.NewReader(`{"id":1}`)))
+	}
+	require.NoError(t, client.Close(t.Context()))
+
+	client, err = New(dir, 3, opt)
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+	require.NoError(t, client.Insert(t.Context(), "a", strings.NewReader(`{"id":2}`)))
+	require.NoError(t, client.RollbackSnapshot(t.Context(), 1, WithRestoreParallelism(4)))
+	require.Equal(t, 1, threads(t, client.db))
+	var n int
+	require.NoError(t, client.db.QueryRow("SELECT count(*) FROM a;").Scan(&n))
+	require.Equal(t, 1, n)
+}
@@ -0,0 +1,71 @@
+package quack
+
+import (
+	"context"
+	"database/sql"
+	"iter"
+)
+
+// Row is the current row of a Rows iteration. It is only valid within the
+// loop body that received it.
+type Row struct {
+	rows    *sql.Rows
+	columns []string
+}
+
+// Scan copies the columns of the row into dest as sql.Rows.Scan does.
+func (r *Row) Scan(dest ...any) error {
+	return r.rows.Scan(dest...)
+}
+
+// Map returns the row keyed by column name. Like Scan, it consumes the
+// row, so use one or the other.
+func (r *Row) Map() (map[string]any, error) {
+	vals := make([]any, len(r.columns))
+	ptrs := make([]any, len(r.columns))
+	for i := range vals {
+		ptrs[i] = &vals[i]
+	}
+	if err := r.rows.Scan(ptrs...); err != nil {
+		return nil, err
+	}
+	m := make(map[string]any, len(r.columns))
+	for i, col := range r.columns {
+		m[col] = vals[i]
+	}
+	return m, nil
+}
+
+// Rows runs stmt with args once the iteration starts and yields its rows.
+// A failure ends the iteration with a nil row and the error. Breaking out
+// of the loop closes the result. The client lock is only held to start the
+// query, so the loop body may call the client; Close waits for iterations
+// still running and then cancels them.
+func (c *Client) Rows(ctx context.Context, stmt string, args ...any) iter.Seq2[*Row, error] {
+	return func(yield func(*Row, error) bool) {
+		rows, _, err := c.streamQuery(ctx, "Rows", stmt, args)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		defer rows.Close()
+		columns, err := rows.Columns()
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		row := &Row{rows: rows, columns: columns}
+		for rows.Next() {
+			if !yield(row, nil) {
+				return
+			}
+		}
+		if err := rows.Err(); err != nil {
+			yield(nil, err)
+			return
+		}
+		if err := rows.Close(); err != nil {
+			yield(nil, err)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package quack
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Rows(t *testing.T) {
+	client, err := New(t.TempDir(), 1)
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+	require.NoError(t, client.Insert(t.Context(), "events", strings.NewReader("{\"id\":1,\"name\":\"a\"}\n{\"id\":2,\"name\":null}\n{\"id\":3,\"name\":\"c\"}\n")))
+	t.Run("scan", func(t *testing.T) {
+		var ids []int
+		for row, err := range client.Rows(t.Context(), "SELECT id FROM events WHERE id > ? ORDER BY id;", 1) {
+			require.NoError(t, err)
+			var id int
+			require.NoError(t, row.Scan(&id))
+			ids = append(ids, id)
+		}
+		require.Equal(t, []int{2, 3}, ids)
+	})
+	t.Run("map", func(t *testing.T) {
+		var maps []map[string]any
+		for row, err := range client.Rows(t.Context(), "SELECT id, name FROM events ORDER BY id LIMIT 2;") {
+			require.NoError(t, err)
+			m, err := row.Map()
+			require.NoError(t, err)
+			maps = append(maps, m)
+		}
+		require.Equal(t, []map[string]any{{"id": int64(1), "name": "a"}, {"id": int64(2), "name": nil}}, maps)
+	})
+	t.Run("break", func(t *testing.T) {
+		for row, err := range client.Rows(t.Context(), "SELECT range FROM range(100000000);") {
+			require.NoError(t, err)
+			var n int64
+			require.NoError(t, row.Scan(&n))
+			// The loop body may use the client.
+			require.NoError(t, client.Insert(t.Context(), "events", strings.NewReader(`{"id":4}`)))
+			break
+		}
+		require.Equal(t, 0, client.db.Stats().InUse)
+	})
+	t.Run("error", func(t *testing.T) {
+		var errs int
+		for row, err := range client.Rows(t.Context(), "SELECT * FROM missing;") {
+			require.Nil(t, row)
+			require.Error(t, err)
+			errs++
+		}
+		require.Equal(t, 1, errs)
+	})
+	t.Run("cancel", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(t.Context())
+		defer cancel()
+		var err error
+		for _, err = range client.Rows(ctx, "SELECT range FROM range(100000000);") {
+			if err != nil {
+				break
+			}
+			cancel()
+		}
+		require.ErrorIs(t, err, context.Canceled)
+	})
+}
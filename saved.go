@@ -0,0 +1,152 @@
+package quack
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+)
+
+const savedTable = "quack_saved_queries"
+
+type SavedQuery struct {
+	Name   string
+	SQL    string
+	Params []string
+}
+
+func checkParams(stmt string, params []string) error {
+	used, err := namedParams(stmt)
+	if err != nil {
+		return err
+	}
+	var undeclared, unused []string
+	for _, p := range used {
+		if !slices.Contains(params, p) {
+			undeclared = append(undeclared, p)
+		}
+	}
+	for _, p := range params {
+		if !slices.Contains(used, p) {
+			unused = append(unused, p)
+		}
+	}
+	switch {
+	case len(undeclared) > 0:
+		return fmt.Errorf("undeclared parameters %s", strings.Join(undeclared, ", "))
+	case len(unused) > 0:
+		return fmt.Errorf("declared parameters %s are not used", strings.Join(unused, ", "))
+	}
+	return nil
+}
+
+// SaveQuery stores stmt under name, replacing any previous query of that
+// name. params must list exactly the $name parameters stmt uses.
+func (c *Client) SaveQuery(ctx context.Context, name, stmt string, params []string) (err error) {
+	if err := checkParams(stmt, params); err != nil {
+		return fmt.Errorf("saved query %s: %w", name, err)
+	}
+	b, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	if err := c.lock("SaveQuery"); err != nil {
+		return err
+	}
+	defer c.unlock("SaveQuery", &err)
+	if _, err := c.db.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS "+savedTable+" (name VARCHAR PRIMARY KEY, stmt VARCHAR, params JSON);"); err != nil {
+		return err
+	}
+	_, err = c.db.ExecContext(ctx, "INSERT OR REPLACE INTO "+savedTable+" VALUES (?, ?, ?);", name, stmt, string(b))
+	return err
+}
+
+func savedQueries(ctx context.Context, db querier, name string) ([]SavedQuery, error) {
+	if err := tableExists(ctx, db, savedTable); os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	rows, err := db.QueryContext(ctx, "SELECT name, stmt, params::VARCHAR FROM "+savedTable+" WHERE ? = '' OR name = ? ORDER BY name;", name, name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var queries []SavedQuery
+	for rows.Next() {
+		var (
+			q      SavedQuery
+			params string
+		)
+		if err := rows.Scan(&q.Name, &q.SQL, &params); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(params), &q.Params); err != nil {
+			return nil, err
+		}
+		queries = append(queries, q)
+	}
+	return queries, rows.Err()
+}
+
+func (c *Client) ListSaved(ctx context.Context) (_ []SavedQuery, err error) {
+	if err := c.rlock("ListSaved"); err != nil {
+		return nil, err
+	}
+	defer c.runlock("ListSaved", &err)
+	return savedQueries(ctx, c.db, "")
+}
+
+func (c *Client) DeleteSaved(ctx context.Context, name string) (err error) {
+	if err := c.lock("DeleteSaved"); err != nil {
+		return err
+	}
+	defer c.unlock("DeleteSaved", &err)
+	if err := tableExists(ctx, c.db, savedTable); err != nil {
+		return err
+	}
+	_, err = c.db.ExecContext(ctx, "DELETE FROM "+savedTable+" WHERE name = ?;", name)
+	return err
+}
+
+// RunSaved runs the saved query name with args bound to its parameters.
+// args must provide every declared parameter and nothing else.
+func (c *Client) RunSaved(ctx context.Context, name string, args map[string]any) (_ *sql.Rows, err error) {
+	if err := c.rlock("RunSaved"); err != nil {
+		return nil, err
+	}
+	defer c.runlock("RunSaved", &err)
+	queries, err := savedQueries(ctx, c.db, name)
+	if err != nil {
+		return nil, err
+	}
+	if len(queries) == 0 {
+		return nil, fmt.Errorf("saved query %s: %w", name, os.ErrNotExist)
+	}
+	q := queries[0]
+	var missing, unknown []string
+	for _, p := range q.Params {
+		if _, ok := args[p]; !ok {
+			missing = append(missing, p)
+		}
+	}
+	named := make([]any, 0, len(args))
+	for k, v := range args {
+		if !slices.Contains(q.Params, k) {
+			unknown = append(unknown, k)
+		}
+		named = append(named, sql.Named(k, v))
+	}
+	sort.Strings(unknown)
+	switch {
+	case len(missing) > 0:
+		return nil, fmt.Errorf("saved query %s: missing arguments %s", name, strings.Join(missing, ", "))
+	case len(unknown) > 0:
+		return nil, fmt.Errorf("saved query %s: unknown arguments %s", name, strings.Join(unknown, ", "))
+	}
+	return c.db.QueryContext(c.opContext(ctx), q.SQL, named...)
+}
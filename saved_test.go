@@ -0,0 +1,46 @@
+package quack
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_SavedQueries(t *testing.T) {
+	dir := t.TempDir()
+	client, err := New(dir, 2)
+	require.NoError(t, err)
+	require.NoError(t, client.Insert(t.Context(), "events", strings.NewReader("{\"region\":\"eu\",\"v\":1}\n{\"region\":\"us\",\"v\":2}\n")))
+	require.ErrorContains(t, client.SaveQuery(t.Context(), "broken", "SELECT * FROM events WHERE region = $region", nil), "undeclared parameters region")
+	require.ErrorContains(t, client.SaveQuery(t.Context(), "broken", "SELECT * FROM events", []string{"region"}), "not used")
+	require.NoError(t, client.SaveQuery(t.Context(), "by_region", "SELECT sum(v) FROM events WHERE region = $region", []string{"region"}))
+	require.NoError(t, client.SaveQuery(t.Context(), "total", "SELECT sum(v) FROM events", nil))
+	require.NoError(t, client.Close(t.Context()))
+
+	client, err = New(dir, 2)
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+	saved, err := client.ListSaved(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, []SavedQuery{
+		{Name: "by_region", SQL: "SELECT sum(v) FROM events WHERE region = $region", Params: []string{"region"}},
+		{Name: "total", SQL: "SELECT sum(v) FROM events"},
+	}, saved)
+
+	_, err = client.RunSaved(t.Context(), "by_region", nil)
+	require.ErrorContains(t, err, "missing arguments region")
+	_, err = client.RunSaved(t.Context(), "by_region", map[string]any{"region": "eu", "x": 1})
+	require.ErrorContains(t, err, "unknown arguments x")
+	rows, err := client.RunSaved(t.Context(), "by_region", map[string]any{"region": "us"})
+	require.NoError(t, err)
+	var sum int
+	require.True(t, rows.Next())
+	require.NoError(t, rows.Scan(&sum))
+	require.NoError(t, rows.Close())
+	require.Equal(t, 2, sum)
+
+	require.NoError(t, client.DeleteSaved(t.Context(), "total"))
+	_, err = client.RunSaved(t.Context(), "total", nil)
+	require.Error(t, err)
+}
@@ -0,0 +1,252 @@
+package quack
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+type Format int
+
+const (
+	FormatJSON Format = iota
+	FormatCSV
+	FormatParquet
+)
+
+func (f Format) String() string {
+	switch f {
+	case FormatJSON:
+		return "json"
+	case FormatCSV:
+		return "csv"
+	case FormatParquet:
+		return "parquet"
+	}
+	return fmt.Sprintf("Format(%d)", int(f))
+}
+
+// reader returns the table function reading file in this format.
+func (f Format) reader(file string) string {
+	switch f {
+	case FormatCSV:
+		return fmt.Sprintf("read_csv_auto(%s)", quoteLiteral(file))
+	case FormatParquet:
+		return fmt.Sprintf("read_parquet(%s)", quoteLiteral(file))
+	}
+	return fmt.Sprintf("read_json_auto(%s)", quoteLiteral(file))
+}
+
+type Column struct {
+	Name     string
+	Type     string
+	Nullable bool
+	// Default is the expression of the column's DEFAULT, if any.
+	Default string `json:",omitempty"`
+	// GeneratedAs is the expression of a virtual generated column. It is only
+	// used when creating tables; Describe does not report it.
+	GeneratedAs string `json:",omitempty"`
+	// Comment is the column's comment as set by SetComment. It is reported
+	// by Describe and ignored when creating tables.
+	Comment string `json:",omitempty"`
+}
+
+// stage copies r into a temporary file under staging. A positive limit caps
+// the number of bytes copied; when the input is longer, the copy is cut back
+// to the last complete line and truncated is reported.
+func stage(staging string, r io.Reader, limit int64) (name string, truncated bool, err error) {
+	f, err := os.CreateTemp(staging, insertPrefix)
+	if err != nil {
+		return "", false, err
+	}
+	defer func() {
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			os.Remove(f.Name())
+		}
+	}()
+	if limit <= 0 {
+		_, err = io.Copy(f, r)
+		return f.Name(), false, err
+	}
+	br := bufio.NewReader(r)
+	n, err := io.CopyN(f, br, limit)
+	if err == io.EOF {
+		return f.Name(), false, nil
+	} else if err != nil {
+		return "", false, err
+	}
+	if _, err := br.Peek(1); err == io.EOF {
+		return f.Name(), false, nil
+	}
+	tail := make([]byte, min(n, 1<<20))
+	if _, err := f.ReadAt(tail, n-int64(len(tail))); err != nil {
+		return "", false, err
+	}
+	if i := bytes.LastIndexByte(tail, '\n'); i >= 0 {
+		if err := f.Truncate(n - int64(len(tail)) + int64(i) + 1); err != nil {
+			return "", false, err
+		}
+	}
+	return f.Name(), true, nil
+}
+
+func describe(ctx context.Context, db querier, source string) ([]Column, error) {
+	rows, err := db.QueryContext(ctx, fmt.Sprintf("DESCRIBE %s;", source))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var columns []Column
+	for rows.Next() {
+		var (
+			col             Column
+			null            string
+			key, def, extra sql.NullString
+		)
+		if err := rows.Scan(&col.Name, &col.Type, &null, &key, &def, &extra); err != nil {
+			return nil, err
+		}
+		col.Nullable = null == "YES"
+		col.Default = def.String
+		columns = append(columns, col)
+	}
+	return columns, rows.Err()
+}
+
+func inferSchema(ctx context.Context, db querier, staging string, r io.Reader, sampleBytes int64, cfg insertConfig) ([]Column, error) {
+	if cfg.format == FormatParquet {
+		// Parquet keeps its metadata at the end of the file, so a prefix of
+		// it cannot be read.
+		sampleBytes = 0
+	}
+	src, err := decompress(r)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+	name, _, err := stage(staging, src, sampleBytes)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(name)
+	return describe(ctx, db, "SELECT * FROM "+cfg.source(name))
+}
+
+// InferSchema reports the columns Insert would create for r, reading at most
+// sampleBytes of it (0 reads everything). Parquet input is always read in
+// full. Compressed input is read like Insert reads it, and opts such as
+// WithCSV or WithColumns shape the columns the same way.
+func InferSchema(ctx context.Context, c *Client, r io.Reader, format Format, sampleBytes int64, opts ...InsertOption) (_ []Column, err error) {
+	cfg := newInsertConfig(append([]InsertOption{withFormat(format)}, opts...))
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	if err := c.rlock("InferSchema"); err != nil {
+		return nil, err
+	}
+	defer c.runlock("InferSchema", &err)
+	return inferSchema(ctx, c.db, c.stagingDir, r, sampleBytes, cfg)
+}
+
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// quoteTable quotes a table name that may be qualified with its schema.
+func quoteTable(table string) string {
+	schema, name := splitTable(table)
+	if schema == "" {
+		return quoteIdent(name)
+	}
+	return quoteIdent(schema) + "." + quoteIdent(name)
+}
+
+func (col Column) definition() string {
+	def := quoteIdent(col.Name) + " " + col.Type
+	if col.GeneratedAs != "" {
+		return def + " GENERATED ALWAYS AS (" + col.GeneratedAs + ") VIRTUAL"
+	}
+	if col.Default != "" {
+		def += " DEFAULT " + col.Default
+	}
+	if !col.Nullable {
+		def += " NOT NULL"
+	}
+	return def
+}
+
+// Describe lists the columns of table or view, failing with os.ErrNotExist
+// when there is neither. Types are given as DuckDB spells them, e.g.
+// STRUCT(a INTEGER, b VARCHAR[]). Column comments are included.
+func (c *Client) Describe(ctx context.Context, table string) (_ []Column, err error) {
+	if err := c.rlock("Describe"); err != nil {
+		return nil, err
+	}
+	defer c.runlock("Describe", &err)
+	if err := tableExists(ctx, c.db, table); os.IsNotExist(err) {
+		return nil, fmt.Errorf("table %s: %w", table, err)
+	} else if err != nil {
+		return nil, err
+	}
+	columns, err := describe(ctx, c.db, quoteTable(table))
+	if err != nil {
+		return nil, err
+	}
+	return withComments(ctx, c.db, table, columns)
+}
+
+// CreateTable creates table with the given columns. Columns not marked
+// Nullable are created NOT NULL, mirroring what Describe reports.
+func (c *Client) CreateTable(ctx context.Context, table string, columns []Column) (err error) {
+	defs := make([]string, len(columns))
+	for i, col := range columns {
+		defs[i] = col.definition()
+	}
+	if err := c.lock("CreateTable"); err != nil {
+		return err
+	}
+	defer c.unlock("CreateTable", &err)
+	if err := createSchema(ctx, c.db, table); err != nil {
+		return err
+	}
+	if _, err := c.db.ExecContext(ctx, fmt.Sprintf("CREATE TABLE %s (%s);", quoteTable(table), strings.Join(defs, ", "))); err != nil {
+		return err
+	}
+	if err := recordSchema(ctx, c.db, table, "create"); err != nil {
+		return err
+	}
+	c.counters.touch(table)
+	return nil
+}
+
+// AddColumn adds col to table. DuckDB cannot add generated columns to an
+// existing table; declare those in CreateTable instead.
+func (c *Client) AddColumn(ctx context.Context, table string, col Column) (err error) {
+	if col.GeneratedAs != "" {
+		return fmt.Errorf("cannot add generated column %s to existing table %s", col.Name, table)
+	}
+	if err := c.lock("AddColumn"); err != nil {
+		return err
+	}
+	defer c.unlock("AddColumn", &err)
+	if _, err := c.db.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s;", quoteTable(table), col.definition())); err != nil {
+		return err
+	}
+	if err := recordSchema(ctx, c.db, table, "add_column"); err != nil {
+		return err
+	}
+	c.counters.touch(table)
+	return nil
+}
+
+func quoteLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
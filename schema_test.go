@@ -0,0 +1,159 @@
+package quack
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_InferSchema(t *testing.T) {
+	dir := t.TempDir()
+	client, err := New(dir, 1)
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+	names := func(cols []Column) []string {
+		var out []string
+		for _, c := range cols {
+			out = append(out, c.Name+" "+c.Type)
+		}
+		return out
+	}
+	t.Run("json", func(t *testing.T) {
+		cols, err := InferSchema(t.Context(), client, strings.NewReader(`{"name":"a","value":10}`), FormatJSON, 0)
+		require.NoError(t, err)
+		require.Equal(t, []string{"name VARCHAR", "value BIGINT"}, names(cols))
+	})
+	t.Run("json sample", func(t *testing.T) {
+		line := `{"name":"a","value":10}` + "\n"
+		input := strings.Repeat(line, 10) + `{"name":"a","value":10,"extra":true}` + "\n"
+		cols, err := InferSchema(t.Context(), client, strings.NewReader(input), FormatJSON, int64(len(line)*3+5))
+		require.NoError(t, err)
+		require.Equal(t, []string{"name VARCHAR", "value BIGINT"}, names(cols))
+	})
+	t.Run("csv", func(t *testing.T) {
+		cols, err := InferSchema(t.Context(), client, strings.NewReader("name,value\na,10\n"), FormatCSV, 0)
+		require.NoError(t, err)
+		require.Equal(t, []string{"name VARCHAR", "value BIGINT"}, names(cols))
+	})
+	t.Run("gzip", func(t *testing.T) {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		_, err := zw.Write([]byte(`{"name":"a","value":10}`))
+		require.NoError(t, err)
+		require.NoError(t, zw.Close())
+		cols, err := InferSchema(t.Context(), client, &buf, FormatJSON, 0)
+		require.NoError(t, err)
+		require.Equal(t, []string{"name VARCHAR", "value BIGINT"}, names(cols))
+	})
+	t.Run("csv options", func(t *testing.T) {
+		cols, err := InferSchema(t.Context(), client, strings.NewReader("name;value\na;10\n"), FormatCSV, 0, WithCSV(CSVOptions{Delimiter: ';', Header: true, Columns: map[string]string{"value": "VARCHAR"}}))
+		require.NoError(t, err)
+		require.Equal(t, []string{"name VARCHAR", "value VARCHAR"}, names(cols))
+	})
+	t.Run("parquet", func(t *testing.T) {
+		file := filepath.Join(dir, "in.parquet")
+		rows, err := client.Query(t.Context(), fmt.Sprintf("COPY (SELECT 'a' AS name, 10::INTEGER AS value) TO '%s' (FORMAT parquet);", file))
+		require.NoError(t, err)
+		require.NoError(t, rows.Close())
+		b, err := os.ReadFile(file)
+		require.NoError(t, err)
+		cols, err := InferSchema(t.Context(), client, bytes.NewReader(b), FormatParquet, 16)
+		require.NoError(t, err)
+		require.Equal(t, []string{"name VARCHAR", "value INTEGER"}, names(cols))
+	})
+}
+
+func Test_GeneratedColumn(t *testing.T) {
+	dir := t.TempDir()
+	day := func(t *testing.T, client *Client) []string {
+		t.Helper()
+		rows, err := client.Query(t.Context(), "SELECT day::VARCHAR FROM events ORDER BY ts;")
+		require.NoError(t, err)
+		defer rows.Close()
+		var days []string
+		for rows.Next() {
+			var d string
+			require.NoError(t, rows.Scan(&d))
+			days = append(days, d)
+		}
+		require.NoError(t, rows.Err())
+		return days
+	}
+	client, err := New(dir, 2)
+	require.NoError(t, err)
+	require.NoError(t, client.CreateTable(t.Context(), "events", []Column{
+		{Name: "ts", Type: "TIMESTAMP"},
+		{Name: "day", Type: "DATE", GeneratedAs: "date_trunc('day', ts)"},
+	}))
+	require.NoError(t, client.Insert(t.Context(), "events", strings.NewReader(`{"ts":"2024-01-02 03:04:05"}`)))
+	require.Equal(t, []string{"2024-01-02"}, day(t, client))
+	require.Error(t, client.AddColumn(t.Context(), "events", Column{Name: "month", Type: "DATE", GeneratedAs: "date_trunc('month', ts)"}))
+	require.NoError(t, client.Close(t.Context()))
+
+	client, err = New(dir, 2)
+	require.NoError(t, err)
+	require.NoError(t, client.RollbackSnapshot(t.Context(), 1))
+	require.NoError(t, client.Insert(t.Context(), "events", strings.NewReader(`{"ts":"2024-02-03 03:04:05"}`)))
+	require.Equal(t, []string{"2024-01-02", "2024-02-03"}, day(t, client))
+	require.NoError(t, client.Close(t.Context()))
+}
+
+func Test_Describe(t *testing.T) {
+	client, err := New(t.TempDir(), 1)
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+	require.NoError(t, client.CreateTable(t.Context(), "raw.events", []Column{
+		{Name: "id", Type: "BIGINT"},
+		{Name: "status", Type: "VARCHAR", Nullable: true, Default: "'new'"},
+		{Name: "tags", Type: "VARCHAR[]", Nullable: true},
+		{Name: "meta", Type: "STRUCT(a INTEGER, b VARCHAR[])", Nullable: true},
+		{Name: "attrs", Type: "MAP(VARCHAR, DOUBLE)", Nullable: true},
+	}))
+	want := []Column{
+		{Name: "id", Type: "BIGINT"},
+		{Name: "status", Type: "VARCHAR", Nullable: true, Default: "'new'"},
+		{Name: "tags", Type: "VARCHAR[]", Nullable: true},
+		{Name: "meta", Type: "STRUCT(a INTEGER, b VARCHAR[])", Nullable: true},
+		{Name: "attrs", Type: "MAP(VARCHAR, DOUBLE)", Nullable: true},
+	}
+	columns, err := client.Describe(t.Context(), "raw.events")
+	require.NoError(t, err)
+	require.Equal(t, want, columns)
+
+	_, err = client.Exec(t.Context(), "CREATE VIEW tagged AS SELECT id, tags FROM raw.events;")
+	require.NoError(t, err)
+	columns, err = client.Describe(t.Context(), "tagged")
+	require.NoError(t, err)
+	require.Equal(t, []Column{{Name: "id", Type: "BIGINT"}, {Name: "tags", Type: "VARCHAR[]", Nullable: true}}, columns)
+
+	_, err = client.Describe(t.Context(), "events")
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func Test_QuotedTableNames(t *testing.T) {
+	client, err := New(t.TempDir(), 1)
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+	for _, table := range []string{"order items", "raw data.select"} {
+		require.NoError(t, client.CreateTable(t.Context(), table, []Column{{Name: "id", Type: "BIGINT"}}))
+		require.NoError(t, client.AddColumn(t.Context(), table, Column{Name: "note", Type: "VARCHAR", Nullable: true}))
+		require.NoError(t, ValidateSchema[struct {
+			ID    int64
+			Note  *string
+			Extra *string
+		}](t.Context(), client, table, AutoMigrate()))
+		columns, err := client.Describe(t.Context(), table)
+		require.NoError(t, err)
+		require.Equal(t, []Column{
+			{Name: "id", Type: "BIGINT"},
+			{Name: "note", Type: "VARCHAR", Nullable: true},
+			{Name: "Extra", Type: "VARCHAR", Nullable: true},
+		}, columns, table)
+	}
+}
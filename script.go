@@ -0,0 +1,119 @@
+package quack
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+)
+
+type scriptConfig struct {
+	perStatement bool
+}
+
+type ScriptOption func(*scriptConfig)
+
+// PerStatement commits every statement of the script on its own, so a
+// failing statement leaves the ones before it applied. Use it for scripts
+// with statements DuckDB refuses to run inside a transaction.
+func PerStatement() ScriptOption {
+	return func(c *scriptConfig) { c.perStatement = true }
+}
+
+// ScriptError reports the statement of a script or batch that failed.
+type ScriptError struct {
+	// Index is the 1-based position of the statement in the script.
+	Index     int
+	Statement string
+	Err       error
+}
+
+func (e *ScriptError) Error() string {
+	return fmt.Sprintf("statement %d (%s): %v", e.Index, snippet(e.Statement, 60), e.Err)
+}
+
+func (e *ScriptError) Unwrap() error { return e.Err }
+
+func snippet(stmt string, n int) string {
+	stmt = strings.Join(strings.Fields(stmt), " ")
+	if len(stmt) <= n {
+		return stmt
+	}
+	return stmt[:n] + "..."
+}
+
+// ExecScript runs the SQL script read from r, such as one written by
+// DumpSQL, statement by statement. Statements are split at semicolons
+// outside string literals, quoted identifiers and comments, and run in a
+// single transaction unless PerStatement is given.
+func (c *Client) ExecScript(ctx context.Context, r io.Reader, opts ...ScriptOption) (err error) {
+	var cfg scriptConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	script, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	stmts := splitStatements(string(script))
+	if err := c.lock("ExecScript"); err != nil {
+		return err
+	}
+	defer c.unlock("ExecScript", &err)
+	if cfg.perStatement {
+		return execStatements(ctx, c.db, stmts)
+	}
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := execStatements(ctx, tx, stmts); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func execStatements(ctx context.Context, db querier, stmts []string) error {
+	for i, stmt := range stmts {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			return &ScriptError{Index: i + 1, Statement: stmt, Err: err}
+		}
+	}
+	return nil
+}
+
+// Statement is a SQL statement with the arguments bound to its parameters.
+type Statement struct {
+	SQL  string
+	Args []any
+}
+
+// ExecBatch runs stmts in order in a single transaction and returns the
+// rows each one affected. The first failing statement rolls the batch back
+// and is reported as a *ScriptError. An empty batch does nothing.
+func (c *Client) ExecBatch(ctx context.Context, stmts []Statement) (_ []int64, err error) {
+	if len(stmts) == 0 {
+		return nil, nil
+	}
+	if err := c.lock("ExecBatch"); err != nil {
+		return nil, err
+	}
+	defer c.unlock("ExecBatch", &err)
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	affected := make([]int64, len(stmts))
+	for i, stmt := range stmts {
+		res, err := tx.ExecContext(ctx, stmt.SQL, stmt.Args...)
+		if err == nil {
+			affected[i], err = res.RowsAffected()
+		}
+		if err != nil {
+			return nil, &ScriptError{Index: i + 1, Statement: stmt.SQL, Err: err}
+		}
+	}
+	return affected, tx.Commit()
+}
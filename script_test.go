@@ -0,0 +1,99 @@
+package quack
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ExecScript(t *testing.T) {
+	client, err := New(t.TempDir(), 3)
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+	count := func() int {
+		var n int
+		require.NoError(t, client.db.QueryRow("SELECT count(*) FROM duckdb_tables() WHERE table_name LIKE 's_%';").Scan(&n))
+		return n
+	}
+
+	script := `CREATE TABLE s_a (x VARCHAR);
+INSERT INTO s_a VALUES ('semi;colon'), ('-- not a comment'), ($$dollar;quoted$$);
+-- trailing comment; with a semicolon
+`
+	require.NoError(t, client.ExecScript(t.Context(), strings.NewReader(script)))
+	var n int
+	require.NoError(t, client.db.QueryRow("SELECT count(*) FROM s_a;").Scan(&n))
+	require.Equal(t, 3, n)
+
+	t.Run("rolls back", func(t *testing.T) {
+		err := client.ExecScript(t.Context(), strings.NewReader("CREATE TABLE s_b (x INT);\nINSERT INTO s_b VALUES\n  ('not a number');"))
+		var serr *ScriptError
+		require.True(t, errors.As(err, &serr))
+		require.Equal(t, 2, serr.Index)
+		require.Contains(t, err.Error(), "statement 2 (INSERT INTO s_b VALUES ('not a number'))")
+		require.Equal(t, 1, count())
+	})
+	t.Run("per statement", func(t *testing.T) {
+		err := client.ExecScript(t.Context(), strings.NewReader("CREATE TABLE s_c (x INT); SELECT * FROM missing;"), PerStatement())
+		var serr *ScriptError
+		require.True(t, errors.As(err, &serr))
+		require.Equal(t, 2, serr.Index)
+		require.Equal(t, 2, count())
+	})
+	t.Run("dump", func(t *testing.T) {
+		src, err := New(t.TempDir(), 3)
+		require.NoError(t, err)
+		defer src.Close(t.Context())
+		_, err = src.db.ExecContext(t.Context(), dumpFixture)
+		require.NoError(t, err)
+		var buf bytes.Buffer
+		require.NoError(t, src.DumpSQL(t.Context(), &buf))
+
+		dst, err := New(t.TempDir(), 3)
+		require.NoError(t, err)
+		defer dst.Close(t.Context())
+		require.NoError(t, dst.ExecScript(t.Context(), bytes.NewReader(buf.Bytes())))
+		require.Equal(t, buf.String(), dump(t, dst))
+	})
+}
+
+func Test_ExecBatch(t *testing.T) {
+	client, err := New(t.TempDir(), 3)
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+
+	affected, err := client.ExecBatch(t.Context(), nil)
+	require.NoError(t, err)
+	require.Empty(t, affected)
+
+	affected, err = client.ExecBatch(t.Context(), []Statement{
+		{SQL: "CREATE TABLE b_a (id INTEGER, name VARCHAR);"},
+		{SQL: "CREATE INDEX b_a_id ON b_a (id);"},
+		{SQL: "INSERT INTO b_a VALUES (?, ?), (?, ?);", Args: []any{1, "a", 2, "it's"}},
+		{SQL: "UPDATE b_a SET name = upper(name) WHERE id = ?;", Args: []any{2}},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []int64{0, 0, 2, 1}, affected)
+	var name string
+	require.NoError(t, client.db.QueryRow("SELECT name FROM b_a WHERE id = 2;").Scan(&name))
+	require.Equal(t, "IT'S", name)
+
+	t.Run("rolls back", func(t *testing.T) {
+		_, err := client.ExecBatch(t.Context(), []Statement{
+			{SQL: "CREATE TABLE b_b (id INTEGER);"},
+			{SQL: "INSERT INTO b_a VALUES (?, ?);", Args: []any{3, "c"}},
+			{SQL: "INSERT INTO b_a VALUES (?);", Args: []any{"not a number"}},
+		})
+		var serr *ScriptError
+		require.True(t, errors.As(err, &serr))
+		require.Equal(t, 3, serr.Index)
+		var n int
+		require.NoError(t, client.db.QueryRow("SELECT count(*) FROM b_a;").Scan(&n))
+		require.Equal(t, 2, n)
+		require.NoError(t, client.db.QueryRow("SELECT count(*) FROM duckdb_tables() WHERE table_name = 'b_b';").Scan(&n))
+		require.Zero(t, n)
+	})
+}
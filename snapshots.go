@@ -0,0 +1,237 @@
+package quack
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+)
+
+var ErrSnapshotNotFound = errors.New("snapshot not found")
+
+// ErrLastSnapshot is returned by DeleteSnapshot for the only snapshot left.
+var ErrLastSnapshot = errors.New("cannot delete the only snapshot")
+
+type SnapshotInfo struct {
+	ID string
+	// CreatedAt is decoded from the ID, to millisecond precision.
+	CreatedAt time.Time
+	// Size is the size of the archive in bytes.
+	Size int64
+	// Path is the archive file.
+	Path string
+}
+
+// snapshotInfos lists the snapshots in dir, newest first. Names that are not
+// snapshot IDs, such as editor backups, and directories are skipped. All
+// snapshot lookups go through it.
+func snapshotInfos(dir string) ([]SnapshotInfo, error) {
+	names, err := listDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]SnapshotInfo, 0, len(names))
+	for i := len(names) - 1; i >= 0; i-- {
+		id, err := ulid.ParseStrict(names[i])
+		if err != nil {
+			continue
+		}
+		path := filepath.Join(dir, names[i])
+		stat, err := os.Stat(path)
+		if os.IsNotExist(err) {
+			// Pruned since the directory was read.
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		if !stat.Mode().IsRegular() {
+			continue
+		}
+		infos = append(infos, SnapshotInfo{ID: names[i], CreatedAt: id.Timestamp(), Size: stat.Size(), Path: path})
+	}
+	return infos, nil
+}
+
+// ListSnapshots lists the snapshots of the client, newest first. Files in
+// the snapshot directory not named by a snapshot ID are skipped.
+func (c *Client) ListSnapshots() ([]SnapshotInfo, error) {
+	return snapshotInfos(filepath.Join(c.dir, "snapshot"))
+}
+
+// pendingSnapshot is an export taken under the client lock, still to be
+// archived into dir.
+type pendingSnapshot struct {
+	id     ulid.ULID
+	export string
+	dir    string
+}
+
+// Snapshot takes a snapshot of the client and its databases without closing
+// it and returns the ID of the client snapshot. The client is locked only
+// while the databases are exported; archiving and rotation follow outside
+// the lock, one Snapshot at a time. The journal is left as is, since its
+// entries are only dropped on Close.
+func (c *Client) Snapshot(ctx context.Context) (string, error) {
+	c.snapshotMux.Lock()
+	defer c.snapshotMux.Unlock()
+	pending, err := c.exportSnapshots(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		for _, p := range pending {
+			os.RemoveAll(p.export)
+		}
+	}()
+	for _, p := range pending {
+		if _, err := archiveSnapshot(p.id, p.export, c.stagingDir, p.dir, c.n, c.snapshotCfg); err != nil {
+			return "", err
+		}
+	}
+	return pending[0].id.String(), nil
+}
+
+// exportSnapshots exports the client, then its databases, under the client
+// lock.
+func (c *Client) exportSnapshots(ctx context.Context) (pending []pendingSnapshot, err error) {
+	if err := c.lock("Snapshot"); err != nil {
+		return nil, err
+	}
+	defer c.unlock("Snapshot", &err)
+	defer func() {
+		if err != nil {
+			for _, p := range pending {
+				os.RemoveAll(p.export)
+			}
+		}
+	}()
+	if err := c.makeRoomForSnapshot(ctx); err != nil {
+		return nil, err
+	}
+	add := func(db *sql.DB, dir string) error {
+		id := ulid.MustNewDefault(time.Now())
+		export, err := exportDump(ctx, db, c.stagingDir, c.snapshotCfg)
+		if err != nil {
+			return err
+		}
+		pending = append(pending, pendingSnapshot{id: id, export: export, dir: dir})
+		return nil
+	}
+	if err := add(c.db, filepath.Join(c.dir, "snapshot")); err != nil {
+		return pending, err
+	}
+	for name, db := range c.databases {
+		if err := add(db, c.databaseSnapshotDir(name)); err != nil {
+			return pending, err
+		}
+	}
+	return pending, nil
+}
+
+type deleteSnapshotConfig struct {
+	force bool
+}
+
+type DeleteSnapshotOption func(*deleteSnapshotConfig)
+
+// ForceDelete lets DeleteSnapshot delete the only snapshot left.
+func ForceDelete() DeleteSnapshotOption {
+	return func(c *deleteSnapshotConfig) { c.force = true }
+}
+
+// DeleteSnapshot deletes the snapshot id of the client. An id that names no
+// snapshot fails with ErrSnapshotNotFound, and the only snapshot left is
+// kept with ErrLastSnapshot unless ForceDelete is set.
+func (c *Client) DeleteSnapshot(id string, opts ...DeleteSnapshotOption) (err error) {
+	var cfg deleteSnapshotConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	c.snapshotMux.Lock()
+	defer c.snapshotMux.Unlock()
+	if err := c.lock("DeleteSnapshot"); err != nil {
+		return err
+	}
+	defer c.unlock("DeleteSnapshot", &err)
+	infos, err := snapshotInfos(filepath.Join(c.dir, "snapshot"))
+	if err != nil {
+		return err
+	}
+	info, err := snapshotByID(infos, id)
+	if err != nil {
+		return err
+	}
+	if len(infos) == 1 && !cfg.force {
+		return fmt.Errorf("%w: %s", ErrLastSnapshot, id)
+	}
+	return os.Remove(info.Path)
+}
+
+// snapshotByID returns the snapshot id. Only names listed by snapshotInfos
+// match, so an id cannot reach outside the snapshot directory.
+func snapshotByID(infos []SnapshotInfo, id string) (SnapshotInfo, error) {
+	for _, info := range infos {
+		if info.ID == id {
+			return info, nil
+		}
+	}
+	return SnapshotInfo{}, fmt.Errorf("%w: %q", ErrSnapshotNotFound, id)
+}
+
+// RestoreSnapshot replaces everything in the client with the snapshot id,
+// as listed by ListSnapshots. An id that names no snapshot fails with
+// ErrSnapshotNotFound and leaves the client as is.
+func (c *Client) RestoreSnapshot(ctx context.Context, id string, opts ...RestoreOption) (err error) {
+	cfg := newRestoreConfig(opts)
+	p := cfg.notifier()
+	defer p.wait()
+	if err := c.lock("RestoreSnapshot"); err != nil {
+		return err
+	}
+	defer c.unlock("RestoreSnapshot", &err)
+	return withSetting(ctx, c.db, "threads", cfg.threads(), func() error {
+		return restoreSnapshot(ctx, c.db, c.stagingDir, filepath.Join(c.dir, "snapshot"), id, p)
+	})
+}
+
+// snapshotAt returns the newest snapshot taken at or before t.
+func snapshotAt(infos []SnapshotInfo, t time.Time) (SnapshotInfo, error) {
+	for _, info := range infos {
+		if !info.CreatedAt.After(t) {
+			return info, nil
+		}
+	}
+	if len(infos) == 0 {
+		return SnapshotInfo{}, fmt.Errorf("%w: no snapshots", ErrSnapshotNotFound)
+	}
+	earliest := infos[len(infos)-1].CreatedAt
+	return SnapshotInfo{}, fmt.Errorf("%w: none taken at or before %s, earliest is %s", ErrSnapshotNotFound, t.Format(time.RFC3339), earliest.Format(time.RFC3339Nano))
+}
+
+// RollbackToTime restores the newest snapshot taken at or before t.
+func (c *Client) RollbackToTime(ctx context.Context, t time.Time, opts ...RestoreOption) (err error) {
+	cfg := newRestoreConfig(opts)
+	p := cfg.notifier()
+	defer p.wait()
+	if err := c.lock("RollbackToTime"); err != nil {
+		return err
+	}
+	defer c.unlock("RollbackToTime", &err)
+	dir := filepath.Join(c.dir, "snapshot")
+	infos, err := snapshotInfos(dir)
+	if err != nil {
+		return err
+	}
+	info, err := snapshotAt(infos, t)
+	if err != nil {
+		return err
+	}
+	return withSetting(ctx, c.db, "threads", cfg.threads(), func() error {
+		return restoreSnapshot(ctx, c.db, c.stagingDir, dir, info.ID, p)
+	})
+}
@@ -0,0 +1,234 @@
+package quack
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_RollbackToTime(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Now()
+	time.Sleep(2 * time.Millisecond)
+	var marks []time.Time
+	for range 3 {
+		client, err := New(dir, 5)
+		require.NoError(t, err)
+		require.NoError(t, client.Insert(t.Context(), "events", strings.NewReader(`{"id":1}`)))
+		require.NoError(t, client.Close(t.Context()))
+		time.Sleep(2 * time.Millisecond)
+		marks = append(marks, time.Now())
+		time.Sleep(2 * time.Millisecond)
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "snapshot", "notes.txt"), nil, 0644))
+
+	client, err := New(dir, 5)
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+	infos, err := client.ListSnapshots()
+	require.NoError(t, err)
+	require.Len(t, infos, 3)
+	for i, info := range infos {
+		require.True(t, info.CreatedAt.Before(marks[2-i]))
+		if i > 0 {
+			require.True(t, info.CreatedAt.Before(infos[i-1].CreatedAt))
+		}
+	}
+
+	count := func() int {
+		var n int
+		require.NoError(t, client.db.QueryRow("SELECT count(*) FROM events;").Scan(&n))
+		return n
+	}
+	require.NoError(t, client.RollbackToTime(t.Context(), marks[0]))
+	require.Equal(t, 1, count())
+	require.NoError(t, client.RollbackToTime(t.Context(), marks[1]))
+	require.Equal(t, 2, count())
+	require.NoError(t, client.RollbackToTime(t.Context(), time.Now()))
+	require.Equal(t, 3, count())
+
+	err = client.RollbackToTime(t.Context(), start)
+	require.ErrorIs(t, err, ErrSnapshotNotFound)
+	require.ErrorContains(t, err, infos[2].CreatedAt.Format(time.RFC3339Nano))
+	require.Equal(t, 3, count())
+}
+
+func Test_Snapshot(t *testing.T) {
+	dir := t.TempDir()
+	staging := t.TempDir()
+	client, err := New(dir, 5, WithStagingDir(staging))
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+	count := func() int {
+		var n int
+		require.NoError(t, client.db.QueryRow("SELECT count(*) FROM events;").Scan(&n))
+		return n
+	}
+
+	var ids []string
+	for range 3 {
+		require.NoError(t, client.Insert(t.Context(), "events", strings.NewReader(`{"id":1}`)))
+		id, err := client.Snapshot(t.Context())
+		require.NoError(t, err)
+		ids = append(ids, id)
+	}
+	infos, err := client.ListSnapshots()
+	require.NoError(t, err)
+	require.Len(t, infos, 3)
+	for i, info := range infos {
+		require.Equal(t, ids[2-i], info.ID)
+	}
+	staged, err := os.ReadDir(staging)
+	require.NoError(t, err)
+	for _, e := range staged {
+		require.False(t, strings.HasPrefix(e.Name(), dumpPrefix), e.Name())
+	}
+
+	require.NoError(t, client.Insert(t.Context(), "events", strings.NewReader(`{"id":1}`)))
+	require.Equal(t, 4, count())
+	require.NoError(t, client.RollbackSnapshot(t.Context(), 1))
+	require.Equal(t, 3, count())
+
+	require.NoError(t, client.Close(t.Context()))
+	_, err = client.Snapshot(t.Context())
+	require.ErrorIs(t, err, ErrClosed)
+}
+
+func Test_SnapshotConcurrent(t *testing.T) {
+	client, err := New(t.TempDir(), 2)
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+	require.NoError(t, client.Insert(t.Context(), "events", strings.NewReader(`{"id":1}`)))
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := client.Snapshot(t.Context())
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+	infos, err := client.ListSnapshots()
+	require.NoError(t, err)
+	require.Len(t, infos, 2)
+}
+
+func Test_ListSnapshots(t *testing.T) {
+	dir := t.TempDir()
+	for i := range 2 {
+		client, err := New(dir, 5)
+		require.NoError(t, err)
+		require.NoError(t, client.Insert(t.Context(), "events", strings.NewReader(`{"id":1}`)))
+		if i == 0 {
+			require.NoError(t, client.Insert(t.Context(), "events", strings.NewReader(`{"id":1}`)))
+		}
+		require.NoError(t, client.Close(t.Context()))
+	}
+	snapshots := filepath.Join(dir, "snapshot")
+	names, err := listDir(snapshots)
+	require.NoError(t, err)
+	// Editor leftovers and a directory named like a snapshot.
+	newest := names[len(names)-1]
+	require.NoError(t, os.WriteFile(filepath.Join(snapshots, newest+"~"), nil, 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(snapshots, "."+newest+".swp"), nil, 0644))
+	require.NoError(t, os.Mkdir(filepath.Join(snapshots, "01ARZ3NDEKTSV4RRFFQ69G5FAV"), 0755))
+
+	client, err := New(dir, 5)
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+	infos, err := client.ListSnapshots()
+	require.NoError(t, err)
+	require.Len(t, infos, 2)
+	require.Equal(t, newest, infos[0].ID)
+	require.Equal(t, names[0], infos[1].ID)
+	for _, info := range infos {
+		stat, err := os.Stat(info.Path)
+		require.NoError(t, err)
+		require.Equal(t, stat.Size(), info.Size)
+		require.Equal(t, filepath.Join(snapshots, info.ID), info.Path)
+	}
+
+	// Rollback counts snapshots only.
+	var n int
+	require.NoError(t, client.RollbackSnapshot(t.Context(), 2))
+	require.NoError(t, client.db.QueryRow("SELECT count(*) FROM events;").Scan(&n))
+	require.Equal(t, 2, n)
+	require.NoError(t, client.RollbackSnapshot(t.Context(), 1))
+	require.NoError(t, client.db.QueryRow("SELECT count(*) FROM events;").Scan(&n))
+	require.Equal(t, 3, n)
+	require.ErrorIs(t, client.RollbackSnapshot(t.Context(), 3), ErrSnapshotNotFound)
+}
+
+func Test_DeleteSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	client, err := New(dir, 5)
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+	var ids []string
+	for range 3 {
+		require.NoError(t, client.Insert(t.Context(), "events", strings.NewReader(`{"id":1}`)))
+		id, err := client.Snapshot(t.Context())
+		require.NoError(t, err)
+		ids = append(ids, id)
+	}
+	outside := filepath.Join(dir, "outside")
+	require.NoError(t, os.WriteFile(outside, nil, 0644))
+
+	for _, id := range []string{"", "../outside", "../snapshot/" + ids[0], strings.ToLower(ids[0]), "01ARZ3NDEKTSV4RRFFQ69G5FAV"} {
+		require.ErrorIs(t, client.DeleteSnapshot(id), ErrSnapshotNotFound, id)
+	}
+	require.FileExists(t, outside)
+
+	require.NoError(t, client.DeleteSnapshot(ids[1]))
+	infos, err := client.ListSnapshots()
+	require.NoError(t, err)
+	require.Len(t, infos, 2)
+	require.Equal(t, ids[2], infos[0].ID)
+	require.Equal(t, ids[0], infos[1].ID)
+	require.ErrorIs(t, client.DeleteSnapshot(ids[1]), ErrSnapshotNotFound)
+
+	require.NoError(t, client.DeleteSnapshot(ids[0]))
+	require.ErrorIs(t, client.DeleteSnapshot(ids[2]), ErrLastSnapshot)
+	require.NoError(t, client.DeleteSnapshot(ids[2], ForceDelete()))
+	infos, err = client.ListSnapshots()
+	require.NoError(t, err)
+	require.Empty(t, infos)
+}
+
+func Test_RestoreSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	client, err := New(dir, 5)
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+	count := func() int {
+		var n int
+		require.NoError(t, client.db.QueryRow("SELECT count(*) FROM events;").Scan(&n))
+		return n
+	}
+	var ids []string
+	for range 3 {
+		require.NoError(t, client.Insert(t.Context(), "events", strings.NewReader(`{"id":1}`)))
+		id, err := client.Snapshot(t.Context())
+		require.NoError(t, err)
+		ids = append(ids, id)
+	}
+
+	require.NoError(t, client.RestoreSnapshot(t.Context(), ids[0]))
+	require.Equal(t, 1, count())
+	// A snapshot taken since does not shift the one restored.
+	_, err = client.Snapshot(t.Context())
+	require.NoError(t, err)
+	require.NoError(t, client.RestoreSnapshot(t.Context(), ids[1]))
+	require.Equal(t, 2, count())
+
+	require.ErrorIs(t, client.RestoreSnapshot(t.Context(), "../"+ids[2]), ErrSnapshotNotFound)
+	require.ErrorIs(t, client.RestoreSnapshot(t.Context(), "01ARZ3NDEKTSV4RRFFQ69G5FAV"), ErrSnapshotNotFound)
+	require.Equal(t, 2, count())
+}
@@ -0,0 +1,225 @@
+package quack
+
+import (
+	"fmt"
+	"strings"
+)
+
+func isIdentStart(b byte) bool {
+	return b == '_' || (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z')
+}
+
+func isIdentPart(b byte) bool {
+	return isIdentStart(b) || (b >= '0' && b <= '9')
+}
+
+// dollarTag returns the $tag$ opening a dollar-quoted string at s[i:], if any.
+func dollarTag(s string, i int) string {
+	if s[i] != '$' {
+		return ""
+	}
+	j := i + 1
+	if j < len(s) && isIdentStart(s[j]) {
+		for j < len(s) && isIdentPart(s[j]) {
+			j++
+		}
+	}
+	if j < len(s) && s[j] == '$' {
+		return s[i : j+1]
+	}
+	return ""
+}
+
+// skipNonCode returns the index just past the string literal, quoted
+// identifier or comment starting at s[i:], or i when none starts there. An
+// unterminated one runs to the end of s.
+func skipNonCode(s string, i int) int {
+	switch {
+	case (s[i] == 'E' || s[i] == 'e') && i+1 < len(s) && s[i+1] == '\'' && (i == 0 || !isIdentPart(s[i-1])):
+		// E'...' strings escape with backslashes.
+		for j := i + 2; j < len(s); j++ {
+			switch {
+			case s[j] == '\\':
+				j++
+			case s[j] == '\'' && j+1 < len(s) && s[j+1] == '\'':
+				j++
+			case s[j] == '\'':
+				return j + 1
+			}
+		}
+		return len(s)
+	case s[i] == '\'' || s[i] == '"':
+		q := s[i]
+		for j := i + 1; j < len(s); j++ {
+			if s[j] != q {
+				continue
+			}
+			if j+1 < len(s) && s[j+1] == q {
+				j++
+				continue
+			}
+			return j + 1
+		}
+		return len(s)
+	case strings.HasPrefix(s[i:], "--"):
+		j := strings.IndexByte(s[i:], '\n')
+		if j < 0 {
+			return len(s)
+		}
+		return i + j + 1
+	case strings.HasPrefix(s[i:], "/*"):
+		j := strings.Index(s[i+2:], "*/")
+		if j < 0 {
+			return len(s)
+		}
+		return i + 2 + j + 2
+	}
+	if tag := dollarTag(s, i); tag != "" {
+		j := strings.Index(s[i+len(tag):], tag)
+		if j < 0 {
+			return len(s)
+		}
+		return i + len(tag) + j + len(tag)
+	}
+	return i
+}
+
+// namedParams lists the distinct $name parameters referenced by stmt in
+// order of first use. Positional parameters are rejected.
+func namedParams(stmt string) ([]string, error) {
+	var names []string
+	seen := make(map[string]bool)
+	for i := 0; i < len(stmt); {
+		if j := skipNonCode(stmt, i); j > i {
+			i = j
+			continue
+		}
+		if stmt[i] == '?' || (stmt[i] == '$' && i+1 < len(stmt) && stmt[i+1] >= '0' && stmt[i+1] <= '9') {
+			return nil, fmt.Errorf("positional parameter at offset %d, use $name parameters", i)
+		}
+		if stmt[i] != '$' || i+1 >= len(stmt) || !isIdentStart(stmt[i+1]) {
+			i++
+			continue
+		}
+		j := i + 1
+		for j < len(stmt) && isIdentPart(stmt[j]) {
+			j++
+		}
+		if name := stmt[i+1 : j]; !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+		i = j
+	}
+	return names, nil
+}
+
+// leadingKeyword returns the first word of stmt outside comments and
+// parentheses, in upper case.
+func leadingKeyword(stmt string) string {
+	for i := 0; i < len(stmt); {
+		if j := skipNonCode(stmt, i); j > i {
+			i = j
+			continue
+		}
+		if !isIdentStart(stmt[i]) {
+			if c := stmt[i]; c == '(' || c == ' ' || c == '\t' || c == '\n' || c == '\r' {
+				i++
+				continue
+			}
+			return ""
+		}
+		j := i + 1
+		for j < len(stmt) && isIdentPart(stmt[j]) {
+			j++
+		}
+		return strings.ToUpper(stmt[i:j])
+	}
+	return ""
+}
+
+// hasKeyword reports whether stmt uses the keyword word, in any case,
+// outside string literals, quoted identifiers and comments.
+func hasKeyword(stmt, word string) bool {
+	for i := 0; i < len(stmt); {
+		if j := skipNonCode(stmt, i); j > i {
+			i = j
+			continue
+		}
+		if !isIdentPart(stmt[i]) {
+			i++
+			continue
+		}
+		j := i + 1
+		for j < len(stmt) && isIdentPart(stmt[j]) {
+			j++
+		}
+		// Numbers and $name parameters are not keywords.
+		param := i > 0 && stmt[i-1] == '$'
+		if isIdentStart(stmt[i]) && !param && strings.EqualFold(stmt[i:j], word) {
+			return true
+		}
+		i = j
+	}
+	return false
+}
+
+// replaceCode replaces old with new in stmt outside string literals, quoted
+// identifiers and comments, and reports how many were replaced.
+func replaceCode(stmt, old, new string) (string, int) {
+	var (
+		b strings.Builder
+		n int
+	)
+	for i := 0; i < len(stmt); {
+		if j := skipNonCode(stmt, i); j > i {
+			b.WriteString(stmt[i:j])
+			i = j
+			continue
+		}
+		if strings.HasPrefix(stmt[i:], old) {
+			b.WriteString(new)
+			i += len(old)
+			n++
+			continue
+		}
+		b.WriteByte(stmt[i])
+		i++
+	}
+	return b.String(), n
+}
+
+// splitStatements splits script into its statements at semicolons outside
+// string literals, quoted identifiers and comments. Statements are returned
+// without their terminating semicolon, and empty or comment-only ones are
+// dropped.
+func splitStatements(script string) []string {
+	var (
+		stmts   []string
+		start   int
+		hasCode bool
+	)
+	for i := 0; i < len(script); {
+		if j := skipNonCode(script, i); j > i {
+			if script[i] != '-' && script[i] != '/' {
+				hasCode = true
+			}
+			i = j
+			continue
+		}
+		switch c := script[i]; {
+		case c == ';':
+			if hasCode {
+				stmts = append(stmts, strings.TrimSpace(script[start:i]))
+			}
+			start, hasCode = i+1, false
+		case c != ' ' && c != '\t' && c != '\n' && c != '\r':
+			hasCode = true
+		}
+		i++
+	}
+	if hasCode {
+		stmts = append(stmts, strings.TrimSpace(script[start:]))
+	}
+	return stmts
+}
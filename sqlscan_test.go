@@ -0,0 +1,68 @@
+package quack
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_namedParams(t *testing.T) {
+	cases := map[string][]string{
+		"SELECT * FROM t WHERE a = $a AND b = $b OR a = $a":        {"a", "b"},
+		"SELECT '$not', \"$col\" FROM t -- $comment\nWHERE x = $x": {"x"},
+		"SELECT /* $c */ $$ $quoted $$, $tag$ $q $tag$ + $y":       {"y"},
+		"SELECT 'it''s $no' || $yes":                               {"yes"},
+		"SELECT 1":                                                 nil,
+	}
+	for stmt, expected := range cases {
+		got, err := namedParams(stmt)
+		require.NoError(t, err, stmt)
+		require.Equal(t, expected, got, stmt)
+	}
+	_, err := namedParams("SELECT * FROM t WHERE a = ?")
+	require.Error(t, err)
+	_, err = namedParams("SELECT * FROM t WHERE a = $1")
+	require.Error(t, err)
+}
+
+func Test_replaceCode(t *testing.T) {
+	for _, tc := range []struct {
+		in, want string
+		n        int
+	}{
+		{"SELECT * FROM {{snap}}.t", `SELECT * FROM "s".t`, 1},
+		{`SELECT '{{snap}}', "{{snap}}" FROM {{snap}}."we ird"`, `SELECT '{{snap}}', "{{snap}}" FROM "s"."we ird"`, 1},
+		{"-- {{snap}}\nSELECT /* {{snap}} */ $${{snap}}$$", "-- {{snap}}\nSELECT /* {{snap}} */ $${{snap}}$$", 0},
+		{"WITH x AS (SELECT * FROM {{snap}}.t) SELECT * FROM x JOIN {{snap}}.u USING (id)", `WITH x AS (SELECT * FROM "s".t) SELECT * FROM x JOIN "s".u USING (id)`, 2},
+	} {
+		got, n := replaceCode(tc.in, "{{snap}}", `"s"`)
+		require.Equal(t, tc.want, got)
+		require.Equal(t, tc.n, n)
+	}
+}
+
+func Test_hasKeyword(t *testing.T) {
+	for stmt, want := range map[string]bool{
+		"SELECT * FROM t LIMIT 10":                       true,
+		"select * from (select 1 limit 1)":               true,
+		"SELECT 'limit', \"limit\" FROM t -- limit":      false,
+		"SELECT limits, x_limit FROM t WHERE n < $limit": false,
+	} {
+		require.Equal(t, want, hasKeyword(stmt, "LIMIT"), stmt)
+	}
+}
+
+func Test_splitStatements(t *testing.T) {
+	for script, want := range map[string][]string{
+		"SELECT 1; SELECT 2":                      {"SELECT 1", "SELECT 2"},
+		"SELECT 'a;b'; SELECT \"c;d\" FROM t;":    {"SELECT 'a;b'", `SELECT "c;d" FROM t`},
+		"SELECT 'it''s;'; SELECT E'it\\'s;';":     {"SELECT 'it''s;'", `SELECT E'it\'s;'`},
+		"SELECT $$a;b$$; SELECT $x$ ; $y$ ; $x$":  {"SELECT $$a;b$$", "SELECT $x$ ; $y$ ; $x$"},
+		"-- a; b\nSELECT 1; /* c; d */ SELECT 2;": {"-- a; b\nSELECT 1", "/* c; d */ SELECT 2"},
+		"CREATE TABLE a(x INT);;\n\n;  -- done;":  {"CREATE TABLE a(x INT)"},
+		"SELECT 'unterminated; SELECT 2":          {"SELECT 'unterminated; SELECT 2"},
+		"  \n ":                                   nil,
+	} {
+		require.Equal(t, want, splitStatements(script), script)
+	}
+}
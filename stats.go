@@ -0,0 +1,258 @@
+package quack
+
+import (
+	"context"
+	"os"
+	"sync"
+	"time"
+)
+
+type Stats struct {
+	// FileSize and WALSize are the on-disk sizes of the database file and
+	// its write-ahead log.
+	FileSize int64
+	WALSize  int64
+	// UsedSize is the number of bytes of the database file holding live
+	// blocks, as reported by pragma database_size.
+	UsedSize int64
+	// DiskUsage is the size of everything under the client directory and
+	// DiskBudget the limit set by WithDiskBudget, 0 when unlimited.
+	DiskUsage  int64
+	DiskBudget int64
+	// InFlight is the number of statements running and result sets or
+	// readers left open by other callers.
+	InFlight int
+	// Tables holds per-table counters since New or the last ResetStats.
+	Tables map[string]TableStats
+	// OpenedAt is when the client was opened.
+	OpenedAt time.Time
+	// Operations counts calls by method name, such as "Insert" or
+	// "Database.Query", BytesIngested the input read by inserts and LockHeld
+	// the time operations held the client lock exclusively, all since New or the last
+	// ResetCounters. Ingest counts as Insert and every chunk read from a
+	// blob as ReadBlob.
+	Operations    map[string]OpStats
+	BytesIngested int64
+	LockHeld      time.Duration
+	// PlanCache reports the cache set up by WithPlanCache.
+	PlanCache PlanCacheStats
+}
+
+// OpStats counts the calls to one operation.
+type OpStats struct {
+	Calls  int64
+	Errors int64
+}
+
+// Uptime is how long the client has been open.
+func (s Stats) Uptime() time.Duration {
+	return time.Since(s.OpenedAt)
+}
+
+// WasteRatio is the fraction of the database file not holding live data.
+func (s Stats) WasteRatio() float64 {
+	if s.FileSize == 0 || s.UsedSize >= s.FileSize {
+		return 0
+	}
+	return 1 - float64(s.UsedSize)/float64(s.FileSize)
+}
+
+func fileSize(p string) (int64, error) {
+	info, err := os.Stat(p)
+	if os.IsNotExist(err) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+func usedSize(ctx context.Context, db querier) (int64, error) {
+	var blockSize, usedBlocks int64
+	row := db.QueryRowContext(ctx, "SELECT block_size, used_blocks FROM pragma_database_size() WHERE database_name = current_database();")
+	if err := row.Scan(&blockSize, &usedBlocks); err != nil {
+		return 0, err
+	}
+	return blockSize * usedBlocks, nil
+}
+
+func (c *Client) stats(ctx context.Context) (Stats, error) {
+	var (
+		s   Stats
+		err error
+	)
+	s.InFlight = c.inFlight()
+	if s.UsedSize, err = usedSize(ctx, c.db); err != nil {
+		return s, err
+	}
+	if s.FileSize, err = fileSize(c.dbPath()); err != nil {
+		return s, err
+	}
+	if s.WALSize, err = fileSize(c.dbPath() + ".wal"); err != nil {
+		return s, err
+	}
+	if s.DiskUsage, err = dirSize(c.dir); err != nil {
+		return s, err
+	}
+	s.DiskBudget = c.diskBudget
+	s.Tables = c.counters.snapshot()
+	s.OpenedAt = c.openedAt
+	s.Operations, s.BytesIngested, s.LockHeld = c.counters.totals()
+	s.PlanCache = c.plans.snapshot()
+	return s, nil
+}
+
+func (c *Client) Stats(ctx context.Context) (_ Stats, err error) {
+	if err := c.rlock("Stats"); err != nil {
+		return Stats{}, err
+	}
+	defer c.runlock("Stats", &err)
+	return c.stats(ctx)
+}
+
+type TableStats struct {
+	Inserts       int64
+	RowsInserted  int64
+	BytesInserted int64
+	// RowsRemoved counts rows dropped by Deduplicate and DeleteWhere.
+	RowsRemoved int64
+	LastWrite   time.Time
+}
+
+// counters tracks per-table activity in memory. It has its own lock so
+// bookkeeping never waits on database work.
+type counters struct {
+	mux    sync.Mutex
+	tables map[string]*TableStats
+	// modified records the last write to each table and survives reset.
+	modified map[string]time.Time
+
+	ops      map[string]*OpStats
+	ingested int64
+	lockHeld time.Duration
+}
+
+func (c *counters) table(name string) *TableStats {
+	if c.tables == nil {
+		c.tables = make(map[string]*TableStats)
+	}
+	t, ok := c.tables[name]
+	if !ok {
+		t = &TableStats{}
+		c.tables[name] = t
+	}
+	return t
+}
+
+func (c *counters) insert(res InsertResult) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	t := c.table(res.Table)
+	t.Inserts++
+	t.RowsInserted += res.Rows
+	t.BytesInserted += res.Bytes
+	c.ingested += res.Bytes
+	t.LastWrite = c.touched(res.Table)
+}
+
+func (c *counters) remove(table string, rows int64) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	t := c.table(table)
+	t.RowsRemoved += rows
+	t.LastWrite = c.touched(table)
+}
+
+func (c *counters) touched(table string) time.Time {
+	if c.modified == nil {
+		c.modified = make(map[string]time.Time)
+	}
+	now := time.Now()
+	c.modified[table] = now
+	return now
+}
+
+// touch records a write that is not an insert or a removal.
+func (c *counters) touch(table string) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.touched(table)
+}
+
+func (c *counters) lastModified() map[string]time.Time {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	out := make(map[string]time.Time, len(c.modified))
+	for name, t := range c.modified {
+		out[name] = t
+	}
+	return out
+}
+
+func (c *counters) snapshot() map[string]TableStats {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	out := make(map[string]TableStats, len(c.tables))
+	for name, t := range c.tables {
+		out[name] = *t
+	}
+	return out
+}
+
+func (c *counters) operation(op string, err error) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if c.ops == nil {
+		c.ops = make(map[string]*OpStats)
+	}
+	o, ok := c.ops[op]
+	if !ok {
+		o = &OpStats{}
+		c.ops[op] = o
+	}
+	o.Calls++
+	if err != nil {
+		o.Errors++
+	}
+}
+
+func (c *counters) held(d time.Duration) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.lockHeld += d
+}
+
+func (c *counters) totals() (map[string]OpStats, int64, time.Duration) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	ops := make(map[string]OpStats, len(c.ops))
+	for name, o := range c.ops {
+		ops[name] = *o
+	}
+	return ops, c.ingested, c.lockHeld
+}
+
+func (c *counters) resetTotals() {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.ops = nil
+	c.ingested = 0
+	c.lockHeld = 0
+}
+
+func (c *counters) reset() {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.tables = nil
+}
+
+// ResetStats clears the per-table counters.
+func (c *Client) ResetStats() {
+	c.counters.reset()
+}
+
+// ResetCounters clears the operation counts, BytesIngested and LockHeld.
+// OpenedAt and the per-table counters are kept.
+func (c *Client) ResetCounters() {
+	c.counters.resetTotals()
+}
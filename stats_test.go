@@ -0,0 +1,68 @@
+package quack
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_TableStats(t *testing.T) {
+	client, err := New(t.TempDir(), 1)
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			require.NoError(t, client.Insert(t.Context(), "events", strings.NewReader(`{"id":1}`)))
+		}()
+	}
+	wg.Wait()
+	require.NoError(t, client.Deduplicate(t.Context(), "events"))
+	_, err = client.DeleteWhere(t.Context(), "events", "id = 1")
+	require.NoError(t, err)
+
+	stats, err := client.Stats(t.Context())
+	require.NoError(t, err)
+	events := stats.Tables["events"]
+	require.False(t, events.LastWrite.IsZero())
+	require.Equal(t, int64(8), events.Inserts)
+	require.Equal(t, int64(8), events.RowsInserted)
+	require.Equal(t, int64(64), events.BytesInserted)
+	require.Equal(t, int64(8), events.RowsRemoved)
+
+	client.ResetStats()
+	stats, err = client.Stats(t.Context())
+	require.NoError(t, err)
+	require.Empty(t, stats.Tables)
+}
+
+func Test_Counters(t *testing.T) {
+	client, err := New(t.TempDir(), 1)
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+	require.NoError(t, client.Insert(t.Context(), "events", strings.NewReader(`{"id":1}`)))
+	require.NoError(t, client.Insert(t.Context(), "events", strings.NewReader(`{"id":2}`)))
+	_, err = client.Query(t.Context(), "SELECT * FROM missing;")
+	require.Error(t, err)
+
+	stats, err := client.Stats(t.Context())
+	require.NoError(t, err)
+	require.WithinDuration(t, time.Now(), stats.OpenedAt, time.Minute)
+	require.Positive(t, stats.Uptime())
+	require.Equal(t, OpStats{Calls: 2}, stats.Operations["Insert"])
+	require.Equal(t, OpStats{Calls: 1, Errors: 1}, stats.Operations["Query"])
+	require.Equal(t, int64(16), stats.BytesIngested)
+	require.Positive(t, stats.LockHeld)
+
+	client.ResetCounters()
+	stats, err = client.Stats(t.Context())
+	require.NoError(t, err)
+	require.Empty(t, stats.Operations)
+	require.Zero(t, stats.BytesIngested)
+	require.Contains(t, stats.Tables, "events", "per-table counters are kept")
+}
@@ -0,0 +1,135 @@
+package quack
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"os"
+)
+
+const defaultChunkSize = 16 << 20
+
+// WithChunkSize sets how many bytes of input InsertStream stages at a time.
+func WithChunkSize(bytes int64) InsertOption {
+	return func(c *insertConfig) { c.chunkSize = bytes }
+}
+
+// InsertStream is Insert for newline-delimited JSON, loading the input in
+// chunks of about WithChunkSize bytes as it is read, so it needs temporary
+// space for one chunk rather than the whole input. All chunks load in a
+// single transaction: if r fails midway, nothing is inserted. A new table
+// takes the column types inferred from the first chunk. Row limits and
+// JSON Schema validation are not supported.
+func (c *Client) InsertStream(ctx context.Context, table string, r io.Reader, opts ...InsertOption) (InsertResult, error) {
+	return c.ingest(opts, func(cfg insertConfig) (InsertResult, error) {
+		return insertStream(ctx, c.db, c.stagingDir, table, r, cfg)
+	})
+}
+
+func insertStream(ctx context.Context, db *sql.DB, staging, table string, r io.Reader, cfg insertConfig) (_ InsertResult, err error) {
+	switch {
+	case cfg.format != FormatJSON:
+		return InsertResult{}, errors.New("streaming inserts read JSON only")
+	case cfg.limit > 0, cfg.jsonSchema != nil:
+		return InsertResult{}, errors.New("streaming inserts support neither row limits nor JSON Schema validation")
+	}
+	if cfg.chunkSize <= 0 {
+		cfg.chunkSize = defaultChunkSize
+	}
+	table += cfg.suffix
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return InsertResult{}, err
+	}
+	defer tx.Rollback()
+	var undos []func()
+	defer func() {
+		if err != nil {
+			for _, undo := range undos {
+				undo()
+			}
+		}
+	}()
+	src, err := decompress(r)
+	if err != nil {
+		return InsertResult{}, err
+	}
+	defer src.Close()
+	res := InsertResult{Table: table}
+	br := bufio.NewReader(src)
+	for {
+		name, size, err := stageChunk(staging, br, cfg.chunkSize)
+		if err != nil {
+			return InsertResult{}, err
+		}
+		if size == 0 {
+			break
+		}
+		chunk, err := loadChunk(ctx, tx, table, name, size, cfg, &undos)
+		os.Remove(name)
+		if err != nil {
+			return InsertResult{}, err
+		}
+		res.Rows += chunk.Rows
+		res.Bytes += size
+		res.Created = res.Created || chunk.Created
+		res.Columns = chunk.Columns
+	}
+	return res, tx.Commit()
+}
+
+func loadChunk(ctx context.Context, tx *sql.Tx, table, name string, size int64, cfg insertConfig, undos *[]func()) (InsertResult, error) {
+	if cfg.precheck != nil {
+		if err := cfg.precheck(size); err != nil {
+			return InsertResult{}, err
+		}
+	}
+	if cfg.journal != nil {
+		undo, err := cfg.journal(ctx, tx, table, name)
+		if err != nil {
+			return InsertResult{}, err
+		}
+		*undos = append(*undos, undo)
+	}
+	return load(ctx, tx, table, name, cfg)
+}
+
+// stageChunk copies whole lines of br into a temporary file under staging
+// until it holds at least limit bytes or br is drained, skipping blank
+// lines. No file is left behind when it reports zero bytes.
+func stageChunk(staging string, br *bufio.Reader, limit int64) (name string, size int64, err error) {
+	f, err := os.CreateTemp(staging, insertPrefix)
+	if err != nil {
+		return "", 0, err
+	}
+	defer func() {
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil || size == 0 {
+			os.Remove(f.Name())
+		}
+	}()
+	for size < limit {
+		line, rerr := br.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			if line[len(line)-1] != '\n' {
+				line = append(line, '\n')
+			}
+			n, err := f.Write(line)
+			size += int64(n)
+			if err != nil {
+				return "", 0, err
+			}
+		}
+		if rerr == io.EOF {
+			break
+		} else if rerr != nil {
+			return "", 0, rerr
+		}
+	}
+	return f.Name(), size, nil
+}
@@ -0,0 +1,81 @@
+package quack
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/require"
+)
+
+// stagingWatcher records the most bytes staged while r is read.
+type stagingWatcher struct {
+	t    *testing.T
+	r    io.Reader
+	dir  string
+	peak int64
+}
+
+func (w *stagingWatcher) Read(p []byte) (int, error) {
+	size, err := dirSize(w.dir)
+	require.NoError(w.t, err)
+	w.peak = max(w.peak, size)
+	return w.r.Read(p)
+}
+
+func Test_InsertStream(t *testing.T) {
+	staging := t.TempDir()
+	client, err := New(t.TempDir(), 1, WithStagingDir(staging), WithJournal())
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+	var lines strings.Builder
+	for i := range 1000 {
+		fmt.Fprintf(&lines, `{"id":%d,"name":"n%d"}`+"\n", i, i)
+		if i%100 == 0 {
+			lines.WriteString("\n")
+		}
+	}
+	count := func() int {
+		var n int
+		require.NoError(t, client.db.QueryRow("SELECT count(*) FROM events;").Scan(&n))
+		return n
+	}
+	w := &stagingWatcher{t: t, r: iotest.OneByteReader(strings.NewReader(lines.String())), dir: staging}
+	res, err := client.InsertStream(t.Context(), "events", w, WithChunkSize(1000))
+	require.NoError(t, err)
+	require.Equal(t, int64(1000), res.Rows)
+	require.True(t, res.Created)
+	require.Equal(t, []Column{{Name: "id", Type: "BIGINT", Nullable: true}, {Name: "name", Type: "VARCHAR", Nullable: true}}, res.Columns)
+	require.Less(t, w.peak, int64(1100))
+	require.Equal(t, 1000, count())
+	ids, err := client.journalEntries()
+	require.NoError(t, err)
+	require.Greater(t, len(ids), 1)
+
+	errRead := errors.New("read failed")
+	_, err = client.InsertStream(t.Context(), "events", io.MultiReader(strings.NewReader(lines.String()), iotest.ErrReader(errRead)), WithChunkSize(1000))
+	require.ErrorIs(t, err, errRead)
+	require.Equal(t, 1000, count())
+	after, err := client.journalEntries()
+	require.NoError(t, err)
+	require.Equal(t, ids, after)
+	_, err = client.InsertStream(t.Context(), "fresh", io.MultiReader(strings.NewReader(lines.String()), iotest.ErrReader(errRead)), WithChunkSize(1000))
+	require.ErrorIs(t, err, errRead)
+	require.Error(t, tableExists(t.Context(), client.db, "fresh"))
+
+	res, err = client.InsertStream(t.Context(), "events", strings.NewReader(`{"id":1000,"name":"last"}`))
+	require.NoError(t, err)
+	require.Equal(t, InsertResult{Table: "events", Rows: 1, Columns: res.Columns, Bytes: 26}, res)
+	res, err = client.InsertStream(t.Context(), "events", strings.NewReader(""))
+	require.NoError(t, err)
+	require.Zero(t, res.Rows)
+	_, err = client.InsertStream(t.Context(), "events", strings.NewReader(""), WithRowLimit(1))
+	require.Error(t, err)
+	entries, err := os.ReadDir(staging)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
@@ -0,0 +1,65 @@
+package quack
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// StrictSchema refuses appends whose input columns differ from the table's:
+// the insert fails with a *SchemaError listing the input columns the table
+// lacks as Extra, the table columns the input lacks as Missing, and the
+// columns whose input type does not widen to the table's as Types. The check
+// runs before anything is written. Inserts creating the table are not
+// checked.
+func StrictSchema() InsertOption {
+	return func(c *insertConfig) { c.strictSchema = true }
+}
+
+// checkSchema compares the columns of source with those of table. Columns
+// holding only NULL take any type.
+func checkSchema(ctx context.Context, db querier, table, source string) error {
+	columns, err := describe(ctx, db, table)
+	if err != nil {
+		return err
+	}
+	staged, err := describe(ctx, db, "SELECT * FROM "+source)
+	if err != nil {
+		return csvError(err)
+	}
+	input := make(map[string]Column, len(staged))
+	for _, col := range staged {
+		input[strings.ToLower(col.Name)] = col
+	}
+	schemaErr := &SchemaError{Table: table}
+	for _, col := range columns {
+		got, ok := input[strings.ToLower(col.Name)]
+		if !ok {
+			schemaErr.Missing = append(schemaErr.Missing, col.Name)
+			continue
+		}
+		delete(input, strings.ToLower(col.Name))
+		if widens(got.Type, col.Type) {
+			continue
+		}
+		name := quoteIdent(got.Name)
+		var sample sql.NullString
+		err := db.QueryRowContext(ctx, fmt.Sprintf("SELECT %s::VARCHAR FROM %s WHERE %s IS NOT NULL LIMIT 1;", name, source, name)).Scan(&sample)
+		if err == sql.ErrNoRows {
+			continue
+		} else if err != nil {
+			return csvError(err)
+		}
+		schemaErr.Types = append(schemaErr.Types, TypeMismatch{Column: col.Name, Want: col.Type, Got: got.Type, Sample: sample.String})
+	}
+	for _, col := range staged {
+		if _, ok := input[strings.ToLower(col.Name)]; ok {
+			schemaErr.Extra = append(schemaErr.Extra, col.Name)
+		}
+	}
+	if schemaErr.empty() {
+		return nil
+	}
+	return schemaErr
+}
@@ -0,0 +1,31 @@
+package quack
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_StrictSchema(t *testing.T) {
+	client, err := New(t.TempDir(), 1)
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+	require.NoError(t, client.Insert(t.Context(), "events", strings.NewReader(`{"id":1,"name":"a","score":1.5}`), StrictSchema()))
+	require.NoError(t, client.Insert(t.Context(), "events", strings.NewReader(`{"score":2,"name":null,"id":2}`), StrictSchema()))
+
+	var schemaErr *SchemaError
+	err = client.Insert(t.Context(), "events", strings.NewReader(`{"id":"x","score":3.5,"extra":true}`), StrictSchema())
+	require.ErrorAs(t, err, &schemaErr)
+	require.Equal(t, &SchemaError{
+		Table:   "events",
+		Missing: []string{"name"},
+		Extra:   []string{"extra"},
+		Types:   []TypeMismatch{{Column: "id", Want: "BIGINT", Got: "VARCHAR", Sample: "x"}},
+	}, schemaErr)
+	var n int64
+	require.NoError(t, client.db.QueryRow("SELECT count(*) FROM events;").Scan(&n))
+	require.Equal(t, int64(2), n)
+
+	require.ErrorContains(t, client.Insert(t.Context(), "events", strings.NewReader(`{"id":3}`), StrictSchema(), AllowSchemaEvolution()), "cannot also evolve")
+}
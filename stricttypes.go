@@ -0,0 +1,91 @@
+package quack
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// TypeError reports input columns whose type differs from the table's.
+type TypeError struct {
+	Table   string
+	Columns []TypeMismatch
+}
+
+func (e *TypeError) Error() string {
+	msgs := make([]string, len(e.Columns))
+	for i, m := range e.Columns {
+		msgs[i] = fmt.Sprintf("column %s: want %s, got %s", m.Column, m.Want, m.Got)
+		if m.Sample != "" {
+			msgs[i] += fmt.Sprintf(" (e.g. %s)", quoteLiteral(m.Sample))
+		}
+	}
+	return fmt.Sprintf("%s: %s", e.Table, strings.Join(msgs, "; "))
+}
+
+var (
+	integerTypes = []string{"TINYINT", "SMALLINT", "INTEGER", "BIGINT", "HUGEINT"}
+	floatTypes   = []string{"FLOAT", "DOUBLE"}
+)
+
+// widens reports whether values of type got fit a column of type want
+// without loss or reinterpretation.
+func widens(got, want string) bool {
+	switch {
+	case got == want, got == "NULL", want == "JSON":
+		return true
+	case slices.Contains(integerTypes, got):
+		if i := slices.Index(integerTypes, want); i >= 0 {
+			return i >= slices.Index(integerTypes, got)
+		}
+		return slices.Contains(floatTypes, want)
+	case got == "FLOAT":
+		return want == "DOUBLE"
+	case got == "DATE":
+		return strings.HasPrefix(want, "TIMESTAMP")
+	case got == "VARCHAR":
+		// checkEnums validates the values themselves.
+		return strings.HasPrefix(want, "ENUM(")
+	}
+	return false
+}
+
+// checkStrictTypes compares the inferred types of source with the columns
+// of table.
+func checkStrictTypes(ctx context.Context, db querier, table, source string) error {
+	columns, err := describe(ctx, db, table)
+	if err != nil {
+		return err
+	}
+	want := make(map[string]string, len(columns))
+	for _, col := range columns {
+		want[col.Name] = col.Type
+	}
+	staged, err := describe(ctx, db, "SELECT * FROM "+source)
+	if err != nil {
+		return err
+	}
+	var mismatches []TypeMismatch
+	for _, col := range staged {
+		typ, ok := want[col.Name]
+		if !ok || widens(col.Type, typ) {
+			continue
+		}
+		name := quoteIdent(col.Name)
+		var sample sql.NullString
+		err := db.QueryRowContext(ctx, fmt.Sprintf("SELECT %s::VARCHAR FROM %s WHERE %s IS NOT NULL LIMIT 1;", name, source, name)).Scan(&sample)
+		if err == sql.ErrNoRows {
+			// All NULL, which readers infer as JSON or VARCHAR.
+			continue
+		} else if err != nil {
+			return err
+		}
+		mismatches = append(mismatches, TypeMismatch{Column: col.Name, Want: typ, Got: col.Type, Sample: sample.String})
+	}
+	if len(mismatches) > 0 {
+		return &TypeError{Table: table, Columns: mismatches}
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package quack
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_StrictTypes(t *testing.T) {
+	client, err := New(t.TempDir(), 3)
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+	require.NoError(t, client.CreateTable(t.Context(), "feed", []Column{
+		{Name: "id", Type: "BIGINT", Nullable: true},
+		{Name: "score", Type: "DOUBLE", Nullable: true},
+		{Name: "at", Type: "TIMESTAMP", Nullable: true},
+		{Name: "note", Type: "VARCHAR", Nullable: true},
+	}))
+
+	require.NoError(t, client.Insert(t.Context(), "feed", strings.NewReader(`{"id":1,"score":2,"at":"2024-01-01","note":null}`), WithStrictTypes()))
+	err = client.Insert(t.Context(), "feed", strings.NewReader(`{"id":"123","score":1.5,"note":7}`), WithStrictTypes())
+	var terr *TypeError
+	require.ErrorAs(t, err, &terr)
+	require.Equal(t, []TypeMismatch{
+		{Column: "id", Want: "BIGINT", Got: "VARCHAR", Sample: "123"},
+		{Column: "note", Want: "VARCHAR", Got: "BIGINT", Sample: "7"},
+	}, terr.Columns)
+	require.EqualError(t, err, `feed: column id: want BIGINT, got VARCHAR (e.g. '123'); column note: want VARCHAR, got BIGINT (e.g. '7')`)
+
+	require.NoError(t, client.Insert(t.Context(), "feed", strings.NewReader(`{"id":"123","score":1.5,"note":7}`)))
+	var n int
+	require.NoError(t, client.db.QueryRow("SELECT count(*) FROM feed;").Scan(&n))
+	require.Equal(t, 2, n)
+}
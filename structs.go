@@ -0,0 +1,305 @@
+package quack
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+var (
+	timeType  = reflect.TypeOf(time.Time{})
+	bytesType = reflect.TypeOf([]byte(nil))
+)
+
+type structField struct {
+	index    int
+	name     string
+	typ      reflect.Type
+	nullable bool
+}
+
+func fieldName(f reflect.StructField) string {
+	for _, key := range []string{"db", "json"} {
+		if tag, ok := f.Tag.Lookup(key); ok {
+			if name, _, _ := strings.Cut(tag, ","); name != "" {
+				return name
+			}
+		}
+	}
+	return f.Name
+}
+
+// structFields lists the exported fields of t that map to columns. Pointer
+// fields map to nullable columns.
+func structFields(t reflect.Type) ([]structField, error) {
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%s is not a struct", t)
+	}
+	var fields []structField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() || f.Tag.Get("db") == "-" {
+			continue
+		}
+		field := structField{index: i, name: fieldName(f), typ: f.Type}
+		if field.typ.Kind() == reflect.Pointer {
+			field.typ, field.nullable = field.typ.Elem(), true
+		}
+		if _, err := duckdbType(field.typ); err != nil {
+			return nil, fmt.Errorf("field %s: %w", f.Name, err)
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// duckdbType maps a Go type to the DuckDB column type used for it.
+func duckdbType(t reflect.Type) (string, error) {
+	switch t {
+	case timeType:
+		return "TIMESTAMP", nil
+	case bytesType:
+		return "BLOB", nil
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return "BOOLEAN", nil
+	case reflect.Int8:
+		return "TINYINT", nil
+	case reflect.Int16:
+		return "SMALLINT", nil
+	case reflect.Int32:
+		return "INTEGER", nil
+	case reflect.Int, reflect.Int64:
+		return "BIGINT", nil
+	case reflect.Uint8:
+		return "UTINYINT", nil
+	case reflect.Uint16:
+		return "USMALLINT", nil
+	case reflect.Uint32:
+		return "UINTEGER", nil
+	case reflect.Uint, reflect.Uint64:
+		return "UBIGINT", nil
+	case reflect.Float32:
+		return "FLOAT", nil
+	case reflect.Float64:
+		return "DOUBLE", nil
+	case reflect.String:
+		return "VARCHAR", nil
+	}
+	return "", fmt.Errorf("unsupported type %s", t)
+}
+
+// compatibleType reports whether values of a column of type dbType can be
+// stored in t without loss.
+func compatibleType(t reflect.Type, dbType string) bool {
+	want, err := duckdbType(t)
+	if err != nil {
+		return false
+	}
+	if want == dbType {
+		return true
+	}
+	switch want {
+	case "TIMESTAMP":
+		return dbType == "DATE" || strings.HasPrefix(dbType, "TIMESTAMP")
+	case "VARCHAR":
+		return dbType == "UUID" || dbType == "JSON" || strings.HasPrefix(dbType, "ENUM(")
+	}
+	return false
+}
+
+type TypeMismatch struct {
+	Column string
+	Want   string
+	Got    string
+	// Sample is a value of the offending type, when one is at hand.
+	Sample string `json:",omitempty"`
+}
+
+// SchemaError describes how a table differs from an expected shape.
+type SchemaError struct {
+	Table string
+	// Missing columns are expected but absent, Extra columns are present
+	// but not expected. ValidateSchema expects the fields of a struct in
+	// the table, StrictSchema the columns of the table in the input.
+	Missing []string
+	Extra   []string
+	Types   []TypeMismatch
+	// Nullable lists columns that allow NULL where the expected shape
+	// does not.
+	Nullable []string
+}
+
+func (e *SchemaError) empty() bool {
+	return len(e.Missing)+len(e.Extra)+len(e.Types)+len(e.Nullable) == 0
+}
+
+func (e *SchemaError) Error() string {
+	var parts []string
+	if len(e.Missing) > 0 {
+		parts = append(parts, "missing columns "+strings.Join(e.Missing, ", "))
+	}
+	if len(e.Extra) > 0 {
+		parts = append(parts, "extra columns "+strings.Join(e.Extra, ", "))
+	}
+	for _, m := range e.Types {
+		parts = append(parts, fmt.Sprintf("column %s is %s, want %s", m.Column, m.Got, m.Want))
+	}
+	if len(e.Nullable) > 0 {
+		parts = append(parts, "nullable columns "+strings.Join(e.Nullable, ", ")+" map to non-pointer fields")
+	}
+	return fmt.Sprintf("schema mismatch for %s: %s", e.Table, strings.Join(parts, "; "))
+}
+
+type validateConfig struct {
+	migrate bool
+}
+
+type ValidateOption func(*validateConfig)
+
+// AutoMigrate makes ValidateSchema add columns missing from the table as
+// nullable columns instead of reporting them.
+func AutoMigrate() ValidateOption {
+	return func(c *validateConfig) { c.migrate = true }
+}
+
+// ValidateSchema checks that table can hold values of T: every field needs a
+// column of a compatible type, and non-pointer fields need NOT NULL columns.
+// Columns without a matching field are ignored.
+func ValidateSchema[T any](ctx context.Context, c *Client, table string, opts ...ValidateOption) (err error) {
+	var cfg validateConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	fields, err := structFields(reflect.TypeFor[T]())
+	if err != nil {
+		return err
+	}
+	// Only Migrate writes, so plain validation shares the lock with reads.
+	lock, unlock := c.rlock, c.runlock
+	if cfg.migrate {
+		lock, unlock = c.lock, c.unlock
+	}
+	if err := lock("ValidateSchema"); err != nil {
+		return err
+	}
+	defer unlock("ValidateSchema", &err)
+	columns, err := describe(ctx, c.db, quoteTable(table))
+	if err != nil {
+		return err
+	}
+	byName := make(map[string]Column, len(columns))
+	for _, col := range columns {
+		byName[strings.ToLower(col.Name)] = col
+	}
+	schemaErr := &SchemaError{Table: table}
+	for _, f := range fields {
+		col, ok := byName[strings.ToLower(f.name)]
+		if !ok {
+			if !cfg.migrate {
+				schemaErr.Missing = append(schemaErr.Missing, f.name)
+				continue
+			}
+			typ, _ := duckdbType(f.typ)
+			added := Column{Name: f.name, Type: typ, Nullable: true}
+			if _, err := c.db.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s;", quoteTable(table), added.definition())); err != nil {
+				return err
+			}
+			if err := recordSchema(ctx, c.db, table, "add_column"); err != nil {
+				return err
+			}
+			continue
+		}
+		if !compatibleType(f.typ, col.Type) {
+			want, _ := duckdbType(f.typ)
+			schemaErr.Types = append(schemaErr.Types, TypeMismatch{Column: col.Name, Want: want, Got: col.Type})
+		}
+		if col.Nullable && !f.nullable {
+			schemaErr.Nullable = append(schemaErr.Nullable, col.Name)
+		}
+	}
+	if schemaErr.empty() {
+		return nil
+	}
+	return schemaErr
+}
+
+// QueryStructs runs stmt with args and scans each row into a T, matching
+// columns to fields by db or json tag, or by name, ignoring case. NULL
+// values need pointer fields. Columns without a field are skipped unless
+// StrictColumns is given among args.
+func QueryStructs[T any](ctx context.Context, c *Client, stmt string, args ...any) ([]T, error) {
+	t := reflect.TypeFor[T]()
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%s is not a struct", t)
+	}
+	byName := make(map[string]int)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.IsExported() && f.Tag.Get("db") != "-" {
+			byName[strings.ToLower(fieldName(f))] = i
+		}
+	}
+	rows, cfg, err := c.streamQuery(ctx, "QueryStructs", stmt, args)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	// Each matched column is scanned into a *F, nil for NULL, so NULLs
+	// in fields that are not pointers can be reported by column.
+	fields := make([]int, len(columns))
+	ptrs := make([]any, len(columns))
+	var extra []string
+	for i, col := range columns {
+		index, ok := byName[strings.ToLower(col)]
+		if !ok {
+			extra = append(extra, col)
+			fields[i], ptrs[i] = -1, new(any)
+			continue
+		}
+		fields[i] = index
+		typ := t.Field(index).Type
+		if typ.Kind() != reflect.Pointer {
+			typ = reflect.PointerTo(typ)
+		}
+		ptrs[i] = reflect.New(typ).Interface()
+	}
+	if cfg.strict && extra != nil {
+		return nil, fmt.Errorf("no field of %s for column %s", t, strings.Join(extra, ", "))
+	}
+	var out []T
+	for rows.Next() {
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, err
+		}
+		var v T
+		rv := reflect.ValueOf(&v).Elem()
+		for i, index := range fields {
+			if index < 0 {
+				continue
+			}
+			field := rv.Field(index)
+			p := reflect.ValueOf(ptrs[i]).Elem()
+			switch {
+			case field.Kind() == reflect.Pointer:
+				field.Set(p)
+			case p.IsNil():
+				return nil, fmt.Errorf("column %s is NULL but field %s is not a pointer", columns[i], t.Field(index).Name)
+			default:
+				field.Set(p.Elem())
+			}
+		}
+		out = append(out, v)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return out, rows.Close()
+}
@@ -0,0 +1,91 @@
+package quack
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ValidateSchema(t *testing.T) {
+	client, err := New(t.TempDir(), 1)
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+	require.NoError(t, client.CreateTable(t.Context(), "events", []Column{
+		{Name: "id", Type: "BIGINT"},
+		{Name: "name", Type: "VARCHAR", Nullable: true},
+		{Name: "ts", Type: "TIMESTAMP", Nullable: true},
+	}))
+	t.Run("match", func(t *testing.T) {
+		type event struct {
+			ID   int64 `db:"id"`
+			Name *string
+			TS   *time.Time `json:"ts"`
+		}
+		require.NoError(t, ValidateSchema[event](t.Context(), client, "events"))
+	})
+	t.Run("mismatch", func(t *testing.T) {
+		type event struct {
+			ID    string `db:"id"`
+			Name  string
+			Extra bool
+		}
+		err := ValidateSchema[event](t.Context(), client, "events")
+		var schemaErr *SchemaError
+		require.True(t, errors.As(err, &schemaErr))
+		require.Equal(t, []string{"Extra"}, schemaErr.Missing)
+		require.Equal(t, []TypeMismatch{{Column: "id", Want: "VARCHAR", Got: "BIGINT"}}, schemaErr.Types)
+		require.Equal(t, []string{"name"}, schemaErr.Nullable)
+	})
+	t.Run("unsupported", func(t *testing.T) {
+		type event struct {
+			C chan int
+		}
+		require.ErrorContains(t, ValidateSchema[event](t.Context(), client, "events"), "unsupported type chan int")
+	})
+	t.Run("auto migrate", func(t *testing.T) {
+		type event struct {
+			ID    int64 `db:"id"`
+			Score *float64
+		}
+		require.NoError(t, ValidateSchema[event](t.Context(), client, "events", AutoMigrate()))
+		require.NoError(t, ValidateSchema[event](t.Context(), client, "events"))
+	})
+}
+
+func Test_QueryStructs(t *testing.T) {
+	client, err := New(t.TempDir(), 1)
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+	type event struct {
+		ID    int64   `db:"id"`
+		Name  *string `json:"label"`
+		At    time.Time
+		Score *float64
+		Tags  []any
+		skip  int
+	}
+	stmt := `SELECT * FROM (VALUES (1, 'a', TIMESTAMP '2024-01-02 03:04:05', 1.5, ['x'], 'unused'),
+		(2, NULL, TIMESTAMP '2024-01-03 00:00:00', NULL, [], 'unused')) t(id, label, "AT", score, tags, other) WHERE id <= ? ORDER BY id;`
+	events, err := QueryStructs[event](t.Context(), client, stmt, 2)
+	require.NoError(t, err)
+	name, score := "a", 1.5
+	require.Equal(t, []event{
+		{ID: 1, Name: &name, At: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), Score: &score, Tags: []any{"x"}},
+		{ID: 2, At: time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC), Tags: []any{}},
+	}, events)
+
+	_, err = QueryStructs[event](t.Context(), client, stmt, 2, StrictColumns())
+	require.ErrorContains(t, err, "column other")
+
+	type strictName struct{ Label string }
+	_, err = QueryStructs[strictName](t.Context(), client, "SELECT NULL::VARCHAR AS label;")
+	require.ErrorContains(t, err, "column label is NULL but field Label is not a pointer")
+
+	none, err := QueryStructs[event](t.Context(), client, "SELECT 1 AS id WHERE false;")
+	require.NoError(t, err)
+	require.Empty(t, none)
+	_, err = QueryStructs[int](t.Context(), client, "SELECT 1;")
+	require.Error(t, err)
+}
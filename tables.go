@@ -0,0 +1,114 @@
+package quack
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// TableInfo describes a table or view in the main database.
+type TableInfo struct {
+	Schema, Name string
+	// Rows is DuckDB's estimate of the row count, 0 for views.
+	Rows    int64
+	Columns int
+	View    bool
+	// LastWrite is when quack last wrote to the table since New, zero if
+	// it has not.
+	LastWrite time.Time
+}
+
+type TablesOption func(*tablesConfig)
+
+type tablesConfig struct {
+	excludeInternal bool
+}
+
+// ExcludeInternal leaves out the quack_ tables quack keeps its own
+// bookkeeping in.
+func ExcludeInternal() TablesOption {
+	return func(c *tablesConfig) { c.excludeInternal = true }
+}
+
+func newTablesConfig(opts []TablesOption) tablesConfig {
+	var cfg tablesConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg
+}
+
+// Tables lists the tables and views of the main database, ordered by
+// schema and name. It reads catalog metadata only, so it is cheap to call.
+func (c *Client) Tables(ctx context.Context, opts ...TablesOption) (_ []TableInfo, err error) {
+	cfg := newTablesConfig(opts)
+	if err := c.rlock("Tables"); err != nil {
+		return nil, err
+	}
+	defer c.runlock("Tables", &err)
+	return listTables(ctx, c.db, cfg, c.counters.lastModified())
+}
+
+func listTables(ctx context.Context, db querier, cfg tablesConfig, modified map[string]time.Time) ([]TableInfo, error) {
+	rows, err := db.QueryContext(ctx, `SELECT schema_name, table_name, estimated_size, column_count, false FROM duckdb_tables() WHERE database_name = current_database()
+UNION ALL
+SELECT schema_name, view_name, 0, column_count, true FROM duckdb_views() WHERE database_name = current_database() AND NOT internal
+ORDER BY 1, 2;`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var tables []TableInfo
+	for rows.Next() {
+		var t TableInfo
+		if err := rows.Scan(&t.Schema, &t.Name, &t.Rows, &t.Columns, &t.View); err != nil {
+			return nil, err
+		}
+		if cfg.excludeInternal && strings.HasPrefix(t.Name, "quack_") {
+			continue
+		}
+		// Writes are tracked by the name they were made with, qualified
+		// outside the main schema.
+		if t.Schema == "main" {
+			t.LastWrite = modified[t.Name]
+		} else {
+			t.LastWrite = modified[t.Schema+"."+t.Name]
+		}
+		tables = append(tables, t)
+	}
+	return tables, rows.Err()
+}
+
+// HasTable reports whether table, or a view of that name, exists. The name
+// may be qualified with its schema.
+func (c *Client) HasTable(ctx context.Context, table string) (_ bool, err error) {
+	if err := c.rlock("HasTable"); err != nil {
+		return false, err
+	}
+	defer c.runlock("HasTable", &err)
+	if err := tableExists(ctx, c.db, table); os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Count returns the number of rows of table, failing with os.ErrNotExist
+// when there is no such table.
+func (c *Client) Count(ctx context.Context, table string) (_ int64, err error) {
+	if err := c.rlock("Count"); err != nil {
+		return 0, err
+	}
+	defer c.runlock("Count", &err)
+	if err := tableExists(ctx, c.db, table); os.IsNotExist(err) {
+		return 0, fmt.Errorf("table %s: %w", table, err)
+	} else if err != nil {
+		return 0, err
+	}
+	var n int64
+	err = c.db.QueryRowContext(c.opContext(ctx), fmt.Sprintf("SELECT count(*) FROM %s;", quoteTable(table))).Scan(&n)
+	return n, err
+}
@@ -0,0 +1,72 @@
+package quack
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Tables(t *testing.T) {
+	client, err := New(t.TempDir(), 3)
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+	require.NoError(t, client.Insert(t.Context(), "events", strings.NewReader("{\"id\":1,\"v\":\"a\"}\n{\"id\":2,\"v\":\"b\"}")))
+	_, err = client.db.Exec("CREATE VIEW recent AS SELECT id FROM events; CREATE SCHEMA s; CREATE TABLE s.other (a INT);")
+	require.NoError(t, err)
+
+	tables, err := client.Tables(t.Context(), ExcludeInternal())
+	require.NoError(t, err)
+	require.Len(t, tables, 3)
+	require.Equal(t, "events", tables[0].Name)
+	require.Equal(t, "main", tables[0].Schema)
+	require.Equal(t, int64(2), tables[0].Rows)
+	require.Equal(t, 2, tables[0].Columns)
+	require.False(t, tables[0].View)
+	require.False(t, tables[0].LastWrite.IsZero())
+	require.Equal(t, TableInfo{Schema: "main", Name: "recent", Columns: 1, View: true}, tables[1])
+	require.Equal(t, TableInfo{Schema: "s", Name: "other", Columns: 1}, tables[2])
+
+	all, err := client.Tables(t.Context())
+	require.NoError(t, err)
+	var names []string
+	for _, t := range all {
+		names = append(names, t.Name)
+	}
+	require.Contains(t, names, historyTable)
+
+	client.ResetStats()
+	tables, err = client.Tables(t.Context(), ExcludeInternal())
+	require.NoError(t, err)
+	require.False(t, tables[0].LastWrite.IsZero())
+
+	require.NoError(t, client.Insert(t.Context(), "s.other", strings.NewReader(`{"a":1}`)))
+	tables, err = client.Tables(t.Context(), ExcludeInternal())
+	require.NoError(t, err)
+	require.Equal(t, "other", tables[2].Name)
+	require.Equal(t, int64(1), tables[2].Rows)
+	require.False(t, tables[2].LastWrite.IsZero())
+}
+
+func Test_CountHasTable(t *testing.T) {
+	client, err := New(t.TempDir(), 1)
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+	require.NoError(t, client.Insert(t.Context(), "raw.events", strings.NewReader("{\"id\":1}\n{\"id\":2}\n")))
+	_, err = client.Exec(t.Context(), "CREATE VIEW recent AS SELECT * FROM raw.events WHERE id > 1;")
+	require.NoError(t, err)
+	for table, want := range map[string]bool{"raw.events": true, "recent": true, "events": false, "raw.missing": false, "mart.events": false} {
+		ok, err := client.HasTable(t.Context(), table)
+		require.NoError(t, err)
+		require.Equal(t, want, ok, table)
+	}
+	n, err := client.Count(t.Context(), "raw.events")
+	require.NoError(t, err)
+	require.Equal(t, int64(2), n)
+	n, err = client.Count(t.Context(), "recent")
+	require.NoError(t, err)
+	require.Equal(t, int64(1), n)
+	_, err = client.Count(t.Context(), "events")
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
@@ -0,0 +1,106 @@
+package quack
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// TimestampFormat parses column as a TIMESTAMP with the first of formats
+// that matches, in strptime notation such as "%Y-%m-%d %H:%M:%S" or
+// "%Y-%m-%dT%H:%M:%S%z" for RFC 3339. Formats with a zone offset give UTC
+// timestamps. A value matching none fails the insert unless LenientCasts is
+// set. Values the reader already detected as timestamps are kept.
+func TimestampFormat(column string, formats ...string) InsertOption {
+	return func(c *insertConfig) {
+		if c.timestamps == nil {
+			c.timestamps = make(map[string][]string)
+		}
+		c.timestamps[column] = formats
+	}
+}
+
+// EpochMillisColumns reads the named columns, holding milliseconds since the
+// Unix epoch as numbers or strings, as TIMESTAMPs. A value that is not an
+// integer fails the insert unless LenientCasts is set.
+func EpochMillisColumns(columns ...string) InsertOption {
+	return func(c *insertConfig) { c.epochMillis = append(c.epochMillis, columns...) }
+}
+
+// parsesTimestamps reports whether TimestampFormat or EpochMillisColumns
+// are set.
+func (c insertConfig) parsesTimestamps() bool {
+	return c.timestamps != nil || c.epochMillis != nil
+}
+
+// parseTimestamps wraps source in the casts of TimestampFormat and
+// EpochMillisColumns.
+func parseTimestamps(ctx context.Context, db querier, source string, cfg insertConfig) (string, error) {
+	columns, err := describe(ctx, db, "SELECT * FROM "+source)
+	if err != nil {
+		return "", csvError(err)
+	}
+	types := make(map[string]Column, len(columns))
+	for _, col := range columns {
+		types[strings.ToLower(col.Name)] = col
+	}
+	var missing, replaces []string
+	// text reads a column as a string, unquoting JSON values.
+	text := func(col Column) string {
+		if col.Type == "JSON" {
+			return fmt.Sprintf("json_extract_string(%s, '$')", quoteIdent(col.Name))
+		}
+		return fmt.Sprintf("CAST(%s AS VARCHAR)", quoteIdent(col.Name))
+	}
+	cast := "CAST"
+	if cfg.lenient {
+		cast = "TRY_CAST"
+	}
+	for _, name := range slices.Sorted(maps.Keys(cfg.timestamps)) {
+		col, ok := types[strings.ToLower(name)]
+		if !ok {
+			missing = append(missing, name)
+			continue
+		}
+		if strings.HasPrefix(col.Type, "TIMESTAMP") {
+			continue
+		}
+		// Each format is tried on its own: a list of formats given to
+		// strptime carries zone offsets over from one row to the next.
+		value := text(col)
+		parses := make([]string, 0, len(cfg.timestamps[name])+1)
+		for _, f := range cfg.timestamps[name] {
+			p := fmt.Sprintf("try_strptime(%s, %s)", value, quoteLiteral(f))
+			if strings.Contains(f, "%z") || strings.Contains(f, "%Z") {
+				p = fmt.Sprintf("timezone('UTC', %s)", p)
+			}
+			parses = append(parses, p)
+		}
+		if !cfg.lenient {
+			msg := fmt.Sprintf("column %s: cannot parse timestamp ", col.Name)
+			parses = append(parses, fmt.Sprintf("CAST(error(%s || %s) AS TIMESTAMP)", quoteLiteral(msg), value))
+		}
+		replaces = append(replaces, fmt.Sprintf("CASE WHEN %s IS NULL THEN NULL ELSE coalesce(%s) END AS %s", value, strings.Join(parses, ", "), quoteIdent(col.Name)))
+	}
+	for _, name := range cfg.epochMillis {
+		col, ok := types[strings.ToLower(name)]
+		if !ok {
+			missing = append(missing, name)
+			continue
+		}
+		millis := quoteIdent(col.Name)
+		if !slices.Contains(integerTypes, col.Type) {
+			millis = fmt.Sprintf("%s(%s AS BIGINT)", cast, text(col))
+		}
+		replaces = append(replaces, fmt.Sprintf("epoch_ms(%s) AS %s", millis, quoteIdent(col.Name)))
+	}
+	if len(missing) > 0 {
+		return "", fmt.Errorf("input has no column %s", strings.Join(missing, ", "))
+	}
+	if len(replaces) == 0 {
+		return source, nil
+	}
+	return fmt.Sprintf("(SELECT * REPLACE (%s) FROM %s)", strings.Join(replaces, ", "), source), nil
+}
@@ -0,0 +1,43 @@
+package quack
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_TimestampFormat(t *testing.T) {
+	client, err := New(t.TempDir(), 1)
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+	opts := []InsertOption{
+		TimestampFormat("seen", "%Y-%m-%dT%H:%M:%S%z", "%Y-%m-%d %H:%M:%S"),
+		EpochMillisColumns("created_at"),
+	}
+	require.NoError(t, client.InsertFile(t.Context(), "events", "testdata/timestamps.json", FormatJSON, opts...))
+	columns, err := describe(t.Context(), client.db, "events")
+	require.NoError(t, err)
+	require.Equal(t, []Column{
+		{Name: "id", Type: "BIGINT", Nullable: true},
+		{Name: "seen", Type: "TIMESTAMP", Nullable: true},
+		{Name: "created_at", Type: "TIMESTAMP", Nullable: true},
+	}, columns)
+	rows := func() string {
+		var s string
+		require.NoError(t, client.db.QueryRow("SELECT string_agg(concat_ws('|', id, coalesce(seen::VARCHAR, 'null'), coalesce(created_at::VARCHAR, 'null')), ',' ORDER BY id) FROM events;").Scan(&s))
+		return s
+	}
+	require.Equal(t, "1|2024-01-02 15:04:05|2024-01-02 15:04:05,2|2024-01-02 15:04:05|2024-01-02 15:04:05,3|null|null", rows())
+
+	t.Run("strict", func(t *testing.T) {
+		err := client.InsertFile(t.Context(), "events", "testdata/timestamps_bad.json", FormatJSON, opts...)
+		require.ErrorContains(t, err, "02/01/2024")
+		require.ErrorContains(t, client.InsertFile(t.Context(), "events", "testdata/timestamps_bad.json", FormatJSON, EpochMillisColumns("created_at")), "yesterday")
+		require.Equal(t, "1|2024-01-02 15:04:05|2024-01-02 15:04:05,2|2024-01-02 15:04:05|2024-01-02 15:04:05,3|null|null", rows())
+	})
+	t.Run("lenient", func(t *testing.T) {
+		require.NoError(t, client.InsertFile(t.Context(), "events", "testdata/timestamps_bad.json", FormatJSON, append(opts, LenientCasts())...))
+		require.Equal(t, "1|2024-01-02 15:04:05|2024-01-02 15:04:05,2|2024-01-02 15:04:05|2024-01-02 15:04:05,3|null|null,4|null|2024-01-02 15:04:05,5|2024-01-02 15:04:05|null", rows())
+	})
+	require.ErrorContains(t, client.InsertFile(t.Context(), "events", "testdata/timestamps.json", FormatJSON, EpochMillisColumns("missing")), "input has no column missing")
+}
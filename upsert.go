@@ -0,0 +1,145 @@
+package quack
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// stagedTable is the temporary table Upsert and DedupOnInsert stage the
+// incoming rows in.
+const stagedTable = "quack_staged"
+
+// Upsert is Insert replacing the rows of table whose keyCols match an
+// incoming row, NULL keys included, instead of appending duplicates. The
+// replacement runs in one transaction. A table that does not exist yet is
+// created from the input. Of incoming rows sharing a key, the last one in
+// the input wins. Every key column must be present in the input; the target
+// table is not touched otherwise.
+func (c *Client) Upsert(ctx context.Context, table string, keyCols []string, r io.Reader, opts ...InsertOption) error {
+	if len(keyCols) == 0 {
+		return errors.New("upsert needs at least one key column")
+	}
+	return c.Insert(ctx, table, r, append(opts, UpsertOn(keyCols...))...)
+}
+
+// DedupOnInsert makes an insert skip incoming rows equal to a row of the
+// table or to an earlier incoming row, comparing every column as
+// Deduplicate does, so the table never needs a full rewrite. Table columns
+// missing from the input compare as NULL.
+func DedupOnInsert() InsertOption {
+	return func(c *insertConfig) { c.dedup = true }
+}
+
+// UpsertOn makes an insert upsert on keyCols as Upsert does, for Ingest to
+// report the rows replaced.
+func UpsertOn(keyCols ...string) InsertOption {
+	return func(c *insertConfig) { c.keys = keyCols }
+}
+
+// checkKeys checks that source has every key column.
+func checkKeys(ctx context.Context, db querier, source string, keys []string) error {
+	columns, err := describe(ctx, db, "SELECT * FROM "+source)
+	if err != nil {
+		return err
+	}
+	present := make(map[string]bool, len(columns))
+	for _, col := range columns {
+		present[strings.ToLower(col.Name)] = true
+	}
+	var missing []string
+	for _, key := range keys {
+		if !present[strings.ToLower(key)] {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("input has no key column %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// rowColumn numbers the incoming rows in input order.
+const rowColumn = "quack_row"
+
+// lastByKeys keeps the last row of source for each key, in input order, so
+// an upsert never leaves two rows with one key.
+func lastByKeys(source string, keys []string) string {
+	cols := make([]string, len(keys))
+	for i, key := range keys {
+		cols[i] = quoteIdent(key)
+	}
+	return fmt.Sprintf("(SELECT * EXCLUDE (%s) FROM (SELECT *, row_number() OVER () AS %s FROM %s) QUALIFY row_number() OVER (PARTITION BY %s ORDER BY %s DESC) = 1 ORDER BY %s)", rowColumn, rowColumn, source, strings.Join(cols, ", "), rowColumn, rowColumn)
+}
+
+// upsert replaces the rows of table matching source on keys and appends
+// the rest, returning how many rows it inserted and replaced.
+func upsert(ctx context.Context, db querier, table, source string, keys []string) (inserted, replaced int64, err error) {
+	if _, err := db.ExecContext(ctx, fmt.Sprintf("CREATE OR REPLACE TEMP TABLE %s AS SELECT * FROM %s;", stagedTable, source)); err != nil {
+		return 0, 0, csvError(err)
+	}
+	conds := make([]string, len(keys))
+	for i, key := range keys {
+		conds[i] = fmt.Sprintf("t.%s IS NOT DISTINCT FROM s.%s", quoteIdent(key), quoteIdent(key))
+	}
+	res, err := db.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s AS t USING %s AS s WHERE %s;", table, stagedTable, strings.Join(conds, " AND ")))
+	if err != nil {
+		return 0, 0, err
+	}
+	if replaced, err = res.RowsAffected(); err != nil {
+		return 0, 0, err
+	}
+	if res, err = db.ExecContext(ctx, fmt.Sprintf("INSERT INTO %s BY NAME SELECT * FROM %s;", table, stagedTable)); err != nil {
+		return 0, 0, err
+	}
+	if inserted, err = res.RowsAffected(); err != nil {
+		return 0, 0, err
+	}
+	_, err = db.ExecContext(ctx, "DROP TABLE "+stagedTable+";")
+	return inserted, replaced, err
+}
+
+// insertNew appends the distinct rows of source not already in table and
+// returns how many it inserted and skipped.
+func insertNew(ctx context.Context, db querier, table, source string) (inserted, skipped int64, err error) {
+	if _, err := db.ExecContext(ctx, fmt.Sprintf("CREATE OR REPLACE TEMP TABLE %s AS SELECT * FROM %s;", stagedTable, source)); err != nil {
+		return 0, 0, csvError(err)
+	}
+	staged, err := describe(ctx, db, stagedTable)
+	if err != nil {
+		return 0, 0, err
+	}
+	columns, err := describe(ctx, db, table)
+	if err != nil {
+		return 0, 0, err
+	}
+	incoming := make(map[string]bool, len(staged))
+	for _, col := range staged {
+		incoming[strings.ToLower(col.Name)] = true
+	}
+	conds := make([]string, len(columns))
+	for i, col := range columns {
+		name := quoteIdent(col.Name)
+		if incoming[strings.ToLower(col.Name)] {
+			conds[i] = fmt.Sprintf("t.%s IS NOT DISTINCT FROM s.%s", name, name)
+		} else {
+			conds[i] = fmt.Sprintf("t.%s IS NULL", name)
+		}
+	}
+	var total int64
+	if err := db.QueryRowContext(ctx, "SELECT count(*) FROM "+stagedTable+";").Scan(&total); err != nil {
+		return 0, 0, err
+	}
+	res, err := db.ExecContext(ctx, fmt.Sprintf("INSERT INTO %s BY NAME SELECT DISTINCT * FROM %s AS s WHERE NOT EXISTS (SELECT 1 FROM %s AS t WHERE %s);",
+		table, stagedTable, table, strings.Join(conds, " AND ")))
+	if err != nil {
+		return 0, 0, err
+	}
+	if inserted, err = res.RowsAffected(); err != nil {
+		return 0, 0, err
+	}
+	_, err = db.ExecContext(ctx, "DROP TABLE "+stagedTable+";")
+	return inserted, total - inserted, err
+}
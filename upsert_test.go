@@ -0,0 +1,93 @@
+package quack
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Upsert(t *testing.T) {
+	client, err := New(t.TempDir(), 3, WithJournal())
+	require.NoError(t, err)
+	require.NoError(t, client.Close(t.Context()))
+	client, err = New(client.dir, 3, WithJournal())
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+	rows := func() string {
+		var s string
+		require.NoError(t, client.db.QueryRow("SELECT string_agg(concat_ws(':', coalesce(region, 'NULL'), id, name), ',' ORDER BY region NULLS LAST, id) FROM people;").Scan(&s))
+		return s
+	}
+	keys := []string{"region", "id"}
+	require.NoError(t, client.Upsert(t.Context(), "people", keys, strings.NewReader(`{"region":"eu","id":1,"name":"a"}
+{"region":"us","id":1,"name":"b"}
+{"region":null,"id":2,"name":"c"}`)))
+	require.Equal(t, "eu:1:a,us:1:b,NULL:2:c", rows())
+	res, err := client.Ingest(t.Context(), "people", strings.NewReader(`{"region":"us","id":1,"name":"B"}
+{"region":null,"id":2,"name":"C"}
+{"region":"eu","id":3,"name":"d"}`), UpsertOn(keys...))
+	require.NoError(t, err)
+	require.Equal(t, int64(3), res.Rows)
+	require.Equal(t, int64(2), res.Replaced)
+	require.Equal(t, "eu:1:a,eu:3:d,us:1:B,NULL:2:C", rows())
+
+	// The last incoming row of a key wins, on creation too.
+	require.NoError(t, client.Upsert(t.Context(), "people", keys, strings.NewReader(`{"region":"eu","id":3,"name":"x"}
+{"region":null,"id":2,"name":"y"}
+{"region":"eu","id":3,"name":"e"}
+{"region":null,"id":2,"name":"z"}`)))
+	require.Equal(t, "eu:1:a,eu:3:e,us:1:B,NULL:2:z", rows())
+	require.NoError(t, client.Upsert(t.Context(), "pairs", []string{"k"}, strings.NewReader(`{"k":1,"v":"a"}
+{"k":1,"v":"b"}`)))
+	var v string
+	require.NoError(t, client.db.QueryRow("SELECT string_agg(v, ',') FROM pairs;").Scan(&v))
+	require.Equal(t, "b", v)
+
+	err = client.Upsert(t.Context(), "people", []string{"id", "email"}, strings.NewReader(`{"id":1,"name":"x"}`))
+	require.ErrorContains(t, err, "input has no key column email")
+	require.Error(t, client.Upsert(t.Context(), "people", nil, strings.NewReader(`{"id":1}`)))
+	require.ErrorContains(t, client.Upsert(t.Context(), "fresh", []string{"key"}, strings.NewReader(`{"id":1}`)), "no key column key")
+	require.Error(t, tableExists(t.Context(), client.db, "fresh"))
+	require.Equal(t, "eu:1:a,eu:3:e,us:1:B,NULL:2:z", rows())
+
+	// Journal replay upserts again rather than appending.
+	require.NoError(t, client.RollbackSnapshot(t.Context(), 1))
+	_, err = client.RecoverJournal(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, "eu:1:a,eu:3:e,us:1:B,NULL:2:z", rows())
+}
+
+func Test_DedupOnInsert(t *testing.T) {
+	client, err := New(t.TempDir(), 1)
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+	res, err := client.Ingest(t.Context(), "events", strings.NewReader(`{"id":1,"tags":["a"]}
+{"id":1,"tags":["a"]}
+{"id":2,"tags":null}`), DedupOnInsert())
+	require.NoError(t, err)
+	require.True(t, res.Created)
+	require.Equal(t, int64(2), res.Rows)
+	require.Equal(t, int64(1), res.Skipped)
+	res, err = client.Ingest(t.Context(), "events", strings.NewReader(`{"id":2,"tags":null}
+{"id":1,"tags":["b"]}
+{"id":3,"tags":["c"]}
+{"id":3,"tags":["c"]}
+{"id":1,"tags":["a"]}`), DedupOnInsert())
+	require.NoError(t, err)
+	require.Equal(t, int64(2), res.Rows)
+	require.Equal(t, int64(3), res.Skipped)
+
+	// A table column missing from the input compares as NULL.
+	_, err = client.db.Exec("ALTER TABLE events ADD COLUMN note VARCHAR; UPDATE events SET note = 'x' WHERE id = 3;")
+	require.NoError(t, err)
+	res, err = client.Ingest(t.Context(), "events", strings.NewReader(`{"id":2,"tags":null}
+{"id":3,"tags":["c"]}`), DedupOnInsert())
+	require.NoError(t, err)
+	require.Equal(t, int64(1), res.Rows)
+	var s string
+	require.NoError(t, client.db.QueryRow("SELECT string_agg(concat_ws(':', id, tags::VARCHAR, note), ',' ORDER BY id, tags, note NULLS FIRST) FROM events;").Scan(&s))
+	require.Equal(t, "1:[a],1:[b],2,3:[c],3:[c]:x", s)
+	_, err = client.Ingest(t.Context(), "events", strings.NewReader(`{"id":4}`), DedupOnInsert(), UpsertOn("id"))
+	require.ErrorContains(t, err, "cannot also skip duplicates")
+}
@@ -0,0 +1,158 @@
+package quack
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+const defaultFlushSize = 4 << 20
+
+// ErrWriterClosed is returned by a Writer used after Close.
+var ErrWriterClosed = errors.New("writer is closed")
+
+type writerConfig struct {
+	size     int
+	interval time.Duration
+	insert   []InsertOption
+}
+
+type WriterOption func(*writerConfig)
+
+// WithFlushSize flushes a Writer once it buffers at least n bytes. The
+// default is 4 MiB.
+func WithFlushSize(n int) WriterOption {
+	return func(c *writerConfig) { c.size = n }
+}
+
+// WithFlushInterval flushes a Writer at most d after the first line it
+// buffers, so rows land even when writes are sparse.
+func WithFlushInterval(d time.Duration) WriterOption {
+	return func(c *writerConfig) { c.interval = d }
+}
+
+// WithWriterInsertOptions sets the options each flush inserts with.
+func WithWriterInsertOptions(opts ...InsertOption) WriterOption {
+	return func(c *writerConfig) { c.insert = opts }
+}
+
+// Writer buffers NDJSON lines written to it and inserts them into a table
+// in batches. Writers of different tables buffer independently and only
+// share the client lock while flushing. It is safe for concurrent use.
+type Writer struct {
+	c     *Client
+	ctx   context.Context
+	table string
+	cfg   writerConfig
+
+	mux   sync.Mutex
+	buf   bytes.Buffer
+	timer *time.Timer
+	// err is the error of a flush that has not been reported yet.
+	err    error
+	closed bool
+}
+
+// OpenWriter opens a Writer inserting into table, which is created by the
+// first flush when it does not exist. ctx governs the flushes. Close the
+// writer before the client, or rows still buffered are lost.
+func (c *Client) OpenWriter(ctx context.Context, table string, opts ...WriterOption) (*Writer, error) {
+	cfg := writerConfig{size: defaultFlushSize}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if c.closing.Load() {
+		return nil, ErrClosed
+	}
+	return &Writer{c: c, ctx: ctx, table: table, cfg: cfg}, nil
+}
+
+// Write buffers p, flushing the complete lines buffered once they reach the
+// flush size. A failed flush drops its lines, and its error is returned by
+// the next Write or Close.
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	if w.closed {
+		return 0, ErrWriterClosed
+	}
+	if err := w.takeErr(); err != nil {
+		return 0, err
+	}
+	if w.timer == nil && w.cfg.interval > 0 && len(p) > 0 {
+		w.timer = time.AfterFunc(w.cfg.interval, w.tick)
+	}
+	w.buf.Write(p)
+	if w.buf.Len() >= w.cfg.size {
+		return len(p), w.flush(false)
+	}
+	return len(p), nil
+}
+
+// Flush inserts the complete lines buffered.
+func (w *Writer) Flush() error {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	if w.closed {
+		return ErrWriterClosed
+	}
+	if err := w.flush(false); err != nil {
+		return err
+	}
+	return w.takeErr()
+}
+
+// Close inserts whatever is buffered, including a last line without a
+// newline, and reports any flush error not yet returned. Later calls
+// return nil.
+func (w *Writer) Close() error {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	err := w.flush(true)
+	return errors.Join(w.takeErr(), err)
+}
+
+func (w *Writer) tick() {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	w.timer = nil
+	if w.closed {
+		return
+	}
+	if err := w.flush(false); err != nil && w.err == nil {
+		w.err = err
+	}
+}
+
+func (w *Writer) takeErr() error {
+	err := w.err
+	w.err = nil
+	return err
+}
+
+// flush inserts the buffered lines, up to the last newline unless all is
+// set. Call it with w.mux held.
+func (w *Writer) flush(all bool) error {
+	n := w.buf.Len()
+	if !all {
+		n = bytes.LastIndexByte(w.buf.Bytes(), '\n') + 1
+	}
+	if n == 0 {
+		return nil
+	}
+	if w.timer != nil {
+		w.timer.Stop()
+		w.timer = nil
+	}
+	batch := bytes.Clone(w.buf.Next(n))
+	if w.buf.Len() > 0 && w.cfg.interval > 0 {
+		w.timer = time.AfterFunc(w.cfg.interval, w.tick)
+	}
+	return w.c.Insert(w.ctx, w.table, bytes.NewReader(batch), w.cfg.insert...)
+}
@@ -0,0 +1,82 @@
+package quack
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Writer(t *testing.T) {
+	client, err := New(t.TempDir(), 1)
+	require.NoError(t, err)
+	defer client.Close(t.Context())
+	count := func(table string) int64 {
+		var n int64
+		if err := client.db.QueryRow("SELECT count(*) FROM " + table + ";").Scan(&n); err != nil {
+			return 0
+		}
+		return n
+	}
+	t.Run("size", func(t *testing.T) {
+		w, err := client.OpenWriter(t.Context(), "sized", WithFlushSize(32))
+		require.NoError(t, err)
+		var _ io.WriteCloser = w
+		_, err = io.WriteString(w, `{"id":1}`+"\n"+`{"id":2}`+"\n")
+		require.NoError(t, err)
+		require.Zero(t, count("sized"))
+		_, err = io.WriteString(w, `{"id":3}`+"\n"+`{"id":4}`+"\n"+`{"id"`)
+		require.NoError(t, err)
+		require.Equal(t, int64(4), count("sized"))
+		_, err = io.WriteString(w, `:5}`)
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+		require.Equal(t, int64(5), count("sized"))
+		require.NoError(t, w.Close())
+		_, err = w.Write([]byte("{}\n"))
+		require.ErrorIs(t, err, ErrWriterClosed)
+	})
+	t.Run("interval", func(t *testing.T) {
+		w, err := client.OpenWriter(t.Context(), "timed", WithFlushInterval(20*time.Millisecond))
+		require.NoError(t, err)
+		defer w.Close()
+		_, err = io.WriteString(w, `{"id":1}`+"\n")
+		require.NoError(t, err)
+		require.Eventually(t, func() bool { return count("timed") == 1 }, 5*time.Second, 10*time.Millisecond)
+	})
+	t.Run("deferred error", func(t *testing.T) {
+		w, err := client.OpenWriter(t.Context(), "broken", WithFlushSize(1))
+		require.NoError(t, err)
+		_, err = io.WriteString(w, "not json\n")
+		require.Error(t, err)
+		w, err = client.OpenWriter(t.Context(), "broken", WithFlushInterval(time.Millisecond))
+		require.NoError(t, err)
+		_, err = io.WriteString(w, "not json\n")
+		require.NoError(t, err)
+		time.Sleep(100 * time.Millisecond)
+		require.Error(t, w.Close())
+	})
+	t.Run("concurrent", func(t *testing.T) {
+		var wg sync.WaitGroup
+		for i := range 4 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				w, err := client.OpenWriter(t.Context(), fmt.Sprintf("parallel_%d", i), WithFlushSize(64))
+				require.NoError(t, err)
+				for j := range 100 {
+					_, err := fmt.Fprintf(w, `{"id":%d}`+"\n", j)
+					require.NoError(t, err)
+				}
+				require.NoError(t, w.Close())
+			}()
+		}
+		wg.Wait()
+		for i := range 4 {
+			require.Equal(t, int64(100), count(fmt.Sprintf("parallel_%d", i)))
+		}
+	})
+}